@@ -1,27 +1,391 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"nikwallet/logger"
+	"nikwallet/repository/money"
+	"nikwallet/services/password"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+)
+
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
+// ConfigFileEnv names the environment variable LoadConfig reads the config
+// file path from.
+const ConfigFileEnv = "NIKWALLET_CONFIG"
 
 type Config struct {
-	DbUser     string `mapstructure:"DB_USER"`
-	DbPassword string `mapstructure:"DB_PASSWORD"`
-	DbName     string `mapstructure:"DB_NAME"`
+	Profile string `mapstructure:"APP_PROFILE"`
+
+	DbHost            string        `mapstructure:"DB_HOST"`
+	DbPort            int           `mapstructure:"DB_PORT"`
+	DbUser            string        `mapstructure:"DB_USER"`
+	DbPassword        string        `mapstructure:"DB_PASSWORD"`
+	DbName            string        `mapstructure:"DB_NAME"`
+	DbSSLMode         string        `mapstructure:"DB_SSLMODE"`
+	DbMaxOpenConns    int           `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DbMaxIdleConns    int           `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DbConnMaxLifetime time.Duration `mapstructure:"DB_CONN_MAX_LIFETIME"`
+	DbSkipMigrations  bool          `mapstructure:"DB_SKIP_MIGRATIONS"`
+
+	HTTPAddr            string        `mapstructure:"HTTP_ADDR"`
+	HTTPReadTimeout     time.Duration `mapstructure:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout    time.Duration `mapstructure:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout     time.Duration `mapstructure:"HTTP_IDLE_TIMEOUT"`
+	HTTPShutdownTimeout time.Duration `mapstructure:"HTTP_SHUTDOWN_TIMEOUT"`
+
+	LogLevel string `mapstructure:"LOG_LEVEL"`
+
+	IdempotencyKeyRetention time.Duration `mapstructure:"IDEMPOTENCY_KEY_RETENTION"`
+
+	PasswordHashAlgorithm string `mapstructure:"PASSWORD_HASH_ALGORITHM"`
+
+	JWTIssuer       string        `mapstructure:"JWT_ISSUER"`
+	JWTAudience     string        `mapstructure:"JWT_AUDIENCE"`
+	JWTTTL          time.Duration `mapstructure:"JWT_TTL"`
+	JWTRefreshTTL   time.Duration `mapstructure:"JWT_REFRESH_TTL"`
+	JWTSigningKeyID string        `mapstructure:"JWT_SIGNING_KEY_ID"`
+	JWTSigningKeys  string        `mapstructure:"JWT_SIGNING_KEYS"`
+
+	FXRatesSource string        `mapstructure:"FX_RATES_SOURCE"`
+	FXRatesFile   string        `mapstructure:"FX_RATES_FILE"`
+	FXRateMaxAge  time.Duration `mapstructure:"FX_RATE_MAX_AGE"`
+	FXQuoteTTL    time.Duration `mapstructure:"FX_QUOTE_TTL"`
+
+	MoneyRoundingMode string `mapstructure:"MONEY_ROUNDING_MODE"`
+
+	MaxRequestBodyBytes int64 `mapstructure:"MAX_REQUEST_BODY_BYTES"`
+	HistoryMaxLimit     int   `mapstructure:"HISTORY_MAX_LIMIT"`
+
+	// The limit amounts are decimals in LIMIT_CURRENCY; "0" lifts a limit.
+	LimitCurrency        string `mapstructure:"LIMIT_CURRENCY"`
+	WithdrawMinAmount    string `mapstructure:"WITHDRAW_MIN_AMOUNT"`
+	WithdrawMaxAmount    string `mapstructure:"WITHDRAW_MAX_AMOUNT"`
+	WithdrawDailyLimit   string `mapstructure:"WITHDRAW_DAILY_LIMIT"`
+	WithdrawMonthlyLimit string `mapstructure:"WITHDRAW_MONTHLY_LIMIT"`
+	WithdrawMaxPerHour   int    `mapstructure:"WITHDRAW_MAX_PER_HOUR"`
+	TransferMinAmount    string `mapstructure:"TRANSFER_MIN_AMOUNT"`
+	TransferMaxAmount    string `mapstructure:"TRANSFER_MAX_AMOUNT"`
+	TransferDailyLimit   string `mapstructure:"TRANSFER_DAILY_LIMIT"`
+	TransferMonthlyLimit string `mapstructure:"TRANSFER_MONTHLY_LIMIT"`
+	TransferMaxPerHour   int    `mapstructure:"TRANSFER_MAX_PER_HOUR"`
+
+	// Each KYC level caps the total balance of a user and the amount and
+	// monthly total of their withdrawals and transfers, in LIMIT_CURRENCY.
+	KYCUnverifiedMaxBalance   string `mapstructure:"KYC_UNVERIFIED_MAX_BALANCE"`
+	KYCUnverifiedMaxAmount    string `mapstructure:"KYC_UNVERIFIED_MAX_AMOUNT"`
+	KYCUnverifiedMonthlyLimit string `mapstructure:"KYC_UNVERIFIED_MONTHLY_LIMIT"`
+	KYCMinimalMaxBalance      string `mapstructure:"KYC_MINIMAL_MAX_BALANCE"`
+	KYCMinimalMaxAmount       string `mapstructure:"KYC_MINIMAL_MAX_AMOUNT"`
+	KYCMinimalMonthlyLimit    string `mapstructure:"KYC_MINIMAL_MONTHLY_LIMIT"`
+	KYCFullMaxBalance         string `mapstructure:"KYC_FULL_MAX_BALANCE"`
+	KYCFullMaxAmount          string `mapstructure:"KYC_FULL_MAX_AMOUNT"`
+	KYCFullMonthlyLimit       string `mapstructure:"KYC_FULL_MONTHLY_LIMIT"`
+}
+
+// defaults lists every key, so that viper also picks up keys that are only
+// set in the environment.
+var defaults = map[string]interface{}{
+	"APP_PROFILE": ProfileDev,
+
+	"DB_HOST":              "localhost",
+	"DB_PORT":              5432,
+	"DB_USER":              "",
+	"DB_PASSWORD":          "",
+	"DB_NAME":              "",
+	"DB_SSLMODE":           "disable",
+	"DB_MAX_OPEN_CONNS":    10,
+	"DB_MAX_IDLE_CONNS":    5,
+	"DB_CONN_MAX_LIFETIME": 30 * time.Minute,
+	"DB_SKIP_MIGRATIONS":   false,
+
+	"HTTP_ADDR":             ":8080",
+	"HTTP_READ_TIMEOUT":     15 * time.Second,
+	"HTTP_WRITE_TIMEOUT":    15 * time.Second,
+	"HTTP_IDLE_TIMEOUT":     60 * time.Second,
+	"HTTP_SHUTDOWN_TIMEOUT": 30 * time.Second,
+
+	"LOG_LEVEL": logger.LevelInfo.String(),
+
+	"IDEMPOTENCY_KEY_RETENTION": 24 * time.Hour,
+
+	"PASSWORD_HASH_ALGORITHM": password.AlgorithmArgon2id,
+
+	"JWT_ISSUER":         "nikwallet",
+	"JWT_AUDIENCE":       "nikwallet",
+	"JWT_TTL":            15 * time.Minute,
+	"JWT_REFRESH_TTL":    30 * 24 * time.Hour,
+	"JWT_SIGNING_KEY_ID": "",
+	"JWT_SIGNING_KEYS":   "",
+
+	"FX_RATES_SOURCE": "static",
+	"FX_RATES_FILE":   "",
+	"FX_RATE_MAX_AGE": time.Duration(0),
+	"FX_QUOTE_TTL":    30 * time.Second,
+
+	"MONEY_ROUNDING_MODE": money.RoundHalfEven.String(),
+
+	"MAX_REQUEST_BODY_BYTES": int64(1 << 20),
+	"HISTORY_MAX_LIMIT":      100,
+
+	"LIMIT_CURRENCY":         string(money.INR),
+	"WITHDRAW_MIN_AMOUNT":    "0",
+	"WITHDRAW_MAX_AMOUNT":    "200000",
+	"WITHDRAW_DAILY_LIMIT":   "200000",
+	"WITHDRAW_MONTHLY_LIMIT": "1000000",
+	"WITHDRAW_MAX_PER_HOUR":  0,
+	"TRANSFER_MIN_AMOUNT":    "0",
+	"TRANSFER_MAX_AMOUNT":    "100000",
+	"TRANSFER_DAILY_LIMIT":   "200000",
+	"TRANSFER_MONTHLY_LIMIT": "1000000",
+	"TRANSFER_MAX_PER_HOUR":  20,
+
+	"KYC_UNVERIFIED_MAX_BALANCE":   "10000",
+	"KYC_UNVERIFIED_MAX_AMOUNT":    "5000",
+	"KYC_UNVERIFIED_MONTHLY_LIMIT": "20000",
+	"KYC_MINIMAL_MAX_BALANCE":      "100000",
+	"KYC_MINIMAL_MAX_AMOUNT":       "50000",
+	"KYC_MINIMAL_MONTHLY_LIMIT":    "200000",
+	"KYC_FULL_MAX_BALANCE":         "0",
+	"KYC_FULL_MAX_AMOUNT":          "0",
+	"KYC_FULL_MONTHLY_LIMIT":       "0",
 }
 
-func LoadConfig() (c Config, err error) {
-	viper.AddConfigPath("/home/anurag/docs/swiggy/nikwallet/config/envs")
-	viper.SetConfigName("dev")
-	viper.SetConfigType("env")
+// profileDefaults override defaults for the selected profile.
+var profileDefaults = map[string]map[string]interface{}{
+	ProfileDev: {
+		"LOG_LEVEL": "debug",
+	},
+	ProfileTest: {
+		"LOG_LEVEL": "warn",
+	},
+	ProfileProd: {
+		"DB_SSLMODE":        "require",
+		"DB_MAX_OPEN_CONNS": 25,
+		"DB_MAX_IDLE_CONNS": 10,
+	},
+}
+
+// LoadConfig loads the config file named by NIKWALLET_CONFIG, if it is set.
+func LoadConfig() (Config, error) {
+	return Load(os.Getenv(ConfigFileEnv))
+}
+
+// Load builds the config from, in increasing precedence, the defaults, the
+// defaults of the profile, the file at path, its profile overlay and the
+// environment. The profile is read from APP_PROFILE in the environment or the
+// file. The overlay sits next to the file with the profile before its
+// extension, so config.env is overlaid by config.prod.env for the prod
+// profile. path may be empty, in which case only the environment is read.
+func Load(path string) (c Config, err error) {
+	v := viper.New()
+	for key, value := range defaults {
+		v.SetDefault(key, value)
+	}
+	v.AutomaticEnv()
+
+	if path != "" {
+		v.SetConfigFile(path)
+		if err = v.ReadInConfig(); err != nil {
+			return c, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
 
-	viper.AutomaticEnv()
+	profile := v.GetString("APP_PROFILE")
+	for key, value := range profileDefaults[profile] {
+		v.SetDefault(key, value)
+	}
 
-	err = viper.ReadInConfig()
+	if path != "" {
+		ext := filepath.Ext(path)
+		overlay := strings.TrimSuffix(path, ext) + "." + profile + ext
+		if _, statErr := os.Stat(overlay); statErr == nil {
+			v.SetConfigFile(overlay)
+			if err = v.MergeInConfig(); err != nil {
+				return c, fmt.Errorf("failed to read config file %s: %w", overlay, err)
+			}
+		}
+	}
 
-	if err != nil {
-		return
+	if err = v.Unmarshal(&c); err != nil {
+		return c, fmt.Errorf("failed to decode config: %w", err)
 	}
 
-	err = viper.Unmarshal(&c)
+	return c, c.Validate()
+}
 
-	return
+// Validate reports every invalid field at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	switch c.Profile {
+	case ProfileDev, ProfileTest, ProfileProd:
+	default:
+		invalid("APP_PROFILE must be one of %s, %s or %s, got %q", ProfileDev, ProfileTest, ProfileProd, c.Profile)
+	}
+
+	if c.DbHost == "" {
+		invalid("DB_HOST is required")
+	}
+	if c.DbPort < 1 || c.DbPort > 65535 {
+		invalid("DB_PORT must be between 1 and 65535, got %d", c.DbPort)
+	}
+	if c.DbUser == "" {
+		invalid("DB_USER is required")
+	}
+	if c.DbName == "" {
+		invalid("DB_NAME is required")
+	}
+	switch c.DbSSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		if c.Profile == ProfileProd && c.DbSSLMode == "disable" {
+			invalid("DB_SSLMODE cannot be disable in the %s profile", ProfileProd)
+		}
+	default:
+		invalid("DB_SSLMODE %q is not a libpq sslmode", c.DbSSLMode)
+	}
+	if c.DbMaxOpenConns < 0 {
+		invalid("DB_MAX_OPEN_CONNS cannot be negative, got %d", c.DbMaxOpenConns)
+	}
+	if c.DbMaxIdleConns < 0 {
+		invalid("DB_MAX_IDLE_CONNS cannot be negative, got %d", c.DbMaxIdleConns)
+	}
+	if c.DbMaxOpenConns > 0 && c.DbMaxIdleConns > c.DbMaxOpenConns {
+		invalid("DB_MAX_IDLE_CONNS (%d) cannot exceed DB_MAX_OPEN_CONNS (%d)", c.DbMaxIdleConns, c.DbMaxOpenConns)
+	}
+	if c.DbConnMaxLifetime < 0 {
+		invalid("DB_CONN_MAX_LIFETIME cannot be negative, got %s", c.DbConnMaxLifetime)
+	}
+
+	if _, _, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+		invalid("HTTP_ADDR %q is not a host:port address", c.HTTPAddr)
+	}
+	if c.HTTPReadTimeout <= 0 {
+		invalid("HTTP_READ_TIMEOUT must be positive, got %s", c.HTTPReadTimeout)
+	}
+	if c.HTTPWriteTimeout <= 0 {
+		invalid("HTTP_WRITE_TIMEOUT must be positive, got %s", c.HTTPWriteTimeout)
+	}
+	if c.HTTPIdleTimeout <= 0 {
+		invalid("HTTP_IDLE_TIMEOUT must be positive, got %s", c.HTTPIdleTimeout)
+	}
+	if c.HTTPShutdownTimeout <= 0 {
+		invalid("HTTP_SHUTDOWN_TIMEOUT must be positive, got %s", c.HTTPShutdownTimeout)
+	}
+
+	if _, err := logger.ParseLevel(c.LogLevel); err != nil {
+		invalid("LOG_LEVEL: %s", err)
+	}
+
+	if c.IdempotencyKeyRetention <= 0 {
+		invalid("IDEMPOTENCY_KEY_RETENTION must be positive, got %s", c.IdempotencyKeyRetention)
+	}
+
+	if _, err := password.NewManagerFor(c.PasswordHashAlgorithm); err != nil {
+		invalid("PASSWORD_HASH_ALGORITHM: %s", err)
+	}
+
+	if c.JWTIssuer == "" {
+		invalid("JWT_ISSUER is required")
+	}
+	if c.JWTAudience == "" {
+		invalid("JWT_AUDIENCE is required")
+	}
+	if c.JWTTTL <= 0 {
+		invalid("JWT_TTL must be positive, got %s", c.JWTTTL)
+	}
+	if c.JWTRefreshTTL <= c.JWTTTL {
+		invalid("JWT_REFRESH_TTL (%s) must be longer than JWT_TTL (%s)", c.JWTRefreshTTL, c.JWTTTL)
+	}
+	if c.JWTSigningKeys == "" && c.Profile == ProfileProd {
+		invalid("JWT_SIGNING_KEYS is required in the %s profile", ProfileProd)
+	}
+
+	switch c.FXRatesSource {
+	case "", "static", "database":
+	default:
+		invalid("FX_RATES_SOURCE must be static or database, got %q", c.FXRatesSource)
+	}
+	if c.FXRateMaxAge < 0 {
+		invalid("FX_RATE_MAX_AGE cannot be negative, got %s", c.FXRateMaxAge)
+	}
+	if c.FXQuoteTTL <= 0 {
+		invalid("FX_QUOTE_TTL must be positive, got %s", c.FXQuoteTTL)
+	}
+
+	if _, err := money.ParseRoundingMode(c.MoneyRoundingMode); err != nil {
+		invalid("MONEY_ROUNDING_MODE: %s", err)
+	}
+
+	if c.MaxRequestBodyBytes <= 0 {
+		invalid("MAX_REQUEST_BODY_BYTES must be positive, got %d", c.MaxRequestBodyBytes)
+	}
+	if c.HistoryMaxLimit <= 0 {
+		invalid("HISTORY_MAX_LIMIT must be positive, got %d", c.HistoryMaxLimit)
+	}
+
+	if !money.Currency(c.LimitCurrency).IsValid() {
+		invalid("LIMIT_CURRENCY %q is not a supported currency", c.LimitCurrency)
+	}
+	for _, limit := range []struct{ key, value string }{
+		{"WITHDRAW_MIN_AMOUNT", c.WithdrawMinAmount},
+		{"WITHDRAW_MAX_AMOUNT", c.WithdrawMaxAmount},
+		{"WITHDRAW_DAILY_LIMIT", c.WithdrawDailyLimit},
+		{"WITHDRAW_MONTHLY_LIMIT", c.WithdrawMonthlyLimit},
+		{"TRANSFER_MIN_AMOUNT", c.TransferMinAmount},
+		{"TRANSFER_MAX_AMOUNT", c.TransferMaxAmount},
+		{"TRANSFER_DAILY_LIMIT", c.TransferDailyLimit},
+		{"TRANSFER_MONTHLY_LIMIT", c.TransferMonthlyLimit},
+		{"KYC_UNVERIFIED_MAX_BALANCE", c.KYCUnverifiedMaxBalance},
+		{"KYC_UNVERIFIED_MAX_AMOUNT", c.KYCUnverifiedMaxAmount},
+		{"KYC_UNVERIFIED_MONTHLY_LIMIT", c.KYCUnverifiedMonthlyLimit},
+		{"KYC_MINIMAL_MAX_BALANCE", c.KYCMinimalMaxBalance},
+		{"KYC_MINIMAL_MAX_AMOUNT", c.KYCMinimalMaxAmount},
+		{"KYC_MINIMAL_MONTHLY_LIMIT", c.KYCMinimalMonthlyLimit},
+		{"KYC_FULL_MAX_BALANCE", c.KYCFullMaxBalance},
+		{"KYC_FULL_MAX_AMOUNT", c.KYCFullMaxAmount},
+		{"KYC_FULL_MONTHLY_LIMIT", c.KYCFullMonthlyLimit},
+	} {
+		if amount, err := decimal.NewFromString(limit.value); err != nil || amount.IsNegative() {
+			invalid("%s must be a non-negative decimal, got %q", limit.key, limit.value)
+		}
+	}
+	if c.WithdrawMaxPerHour < 0 {
+		invalid("WITHDRAW_MAX_PER_HOUR cannot be negative, got %d", c.WithdrawMaxPerHour)
+	}
+	if c.TransferMaxPerHour < 0 {
+		invalid("TRANSFER_MAX_PER_HOUR cannot be negative, got %d", c.TransferMaxPerHour)
+	}
+
+	return errors.Join(errs...)
+}
+
+// DSN is the connection URL for the database settings.
+func (c *Config) DSN() string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.DbUser, c.DbPassword),
+		Host:     net.JoinHostPort(c.DbHost, strconv.Itoa(c.DbPort)),
+		Path:     "/" + c.DbName,
+		RawQuery: url.Values{"sslmode": {c.DbSSLMode}}.Encode(),
+	}
+	return dsn.String()
 }
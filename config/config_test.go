@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Load method to apply defaults and read the environment", func(t *testing.T) {
+		t.Setenv("DB_USER", "wallet")
+		t.Setenv("DB_NAME", "nikwallet")
+		t.Setenv("HTTP_READ_TIMEOUT", "5s")
+
+		c, err := Load("")
+		assert.NoError(t, err)
+		assert.Equal(t, ProfileDev, c.Profile)
+		assert.Equal(t, "localhost", c.DbHost)
+		assert.Equal(t, 5432, c.DbPort)
+		assert.Equal(t, ":8080", c.HTTPAddr)
+		assert.Equal(t, 5*time.Second, c.HTTPReadTimeout)
+		assert.Equal(t, "debug", c.LogLevel)
+		assert.Equal(t, "postgres://wallet:@localhost:5432/nikwallet?sslmode=disable", c.DSN())
+	})
+
+	t.Run("Load method to overlay the profile file on the config file and the environment on both", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "nikwallet.env")
+		err := os.WriteFile(path, []byte("APP_PROFILE=prod\nDB_USER=wallet\nDB_NAME=nikwallet\nDB_HOST=db.internal\nJWT_SIGNING_KEYS=/etc/nikwallet/keys\n"), 0o600)
+		assert.NoError(t, err)
+		err = os.WriteFile(filepath.Join(dir, "nikwallet.prod.env"), []byte("DB_HOST=primary.db.internal\nHTTP_ADDR=:9090\n"), 0o600)
+		assert.NoError(t, err)
+		t.Setenv("HTTP_ADDR", ":9443")
+
+		c, err := Load(path)
+		assert.NoError(t, err)
+		assert.Equal(t, ProfileProd, c.Profile)
+		assert.Equal(t, "primary.db.internal", c.DbHost)
+		assert.Equal(t, "require", c.DbSSLMode)
+		assert.Equal(t, ":9443", c.HTTPAddr)
+	})
+
+	t.Run("Load method to report every invalid field at once", func(t *testing.T) {
+		t.Setenv("APP_PROFILE", ProfileProd)
+		t.Setenv("DB_PORT", "70000")
+		t.Setenv("DB_SSLMODE", "disable")
+		t.Setenv("LOG_LEVEL", "verbose")
+		t.Setenv("TRANSFER_DAILY_LIMIT", "-5")
+		t.Setenv("KYC_MINIMAL_MAX_BALANCE", "lots")
+
+		_, err := Load("")
+		assert.Error(t, err)
+		for _, field := range []string{"DB_USER", "DB_NAME", "DB_PORT", "DB_SSLMODE", "LOG_LEVEL", "JWT_SIGNING_KEYS", "TRANSFER_DAILY_LIMIT", "KYC_MINIMAL_MAX_BALANCE"} {
+			assert.True(t, strings.Contains(err.Error(), field), "expected %s in %q", field, err)
+		}
+	})
+}
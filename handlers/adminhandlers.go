@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/services"
+
+	"github.com/gorilla/mux"
+)
+
+// AdminHandlers serve the staff endpoints under /admin. The router decides
+// which roles reach each of them through the scopes of the token.
+type AdminHandlers struct {
+	userService   *services.UserService
+	walletService *services.WalletService
+}
+
+func NewAdminHandlers(userService *services.UserService, walletService *services.WalletService) *AdminHandlers {
+	return &AdminHandlers{userService: userService, walletService: walletService}
+}
+
+// SearchUsersHandler pages through the users whose email contains the email
+// parameter, optionally of a single role, with the after and limit
+// parameters.
+func (ah *AdminHandlers) SearchUsersHandler(respWriter http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+	var failures []dto.FieldError
+
+	afterID := 0
+	if after := params.Get("after"); after != "" {
+		var err error
+		if afterID, err = strconv.Atoi(after); err != nil || afterID < 0 {
+			failures = append(failures, dto.FieldError{Field: "after", Code: "invalid_cursor", Message: "must be a user id"})
+		}
+	}
+	limit := services.DefaultUserSearchLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			failures = append(failures, dto.FieldError{Field: "limit", Code: "invalid_limit", Message: "must be a positive integer"})
+		}
+	}
+	if len(failures) > 0 {
+		WriteError(respWriter, &dto.ValidationError{Fields: failures})
+		return
+	}
+
+	users, err := ah.userService.SearchUsers(params.Get("email"), models.Role(params.Get("role")), afterID, limit)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.AdminUsersDTO{Users: make([]dto.AdminUserDTO, 0, len(users))}
+	for _, user := range users {
+		response.Users = append(response.Users, adminUserResponse(user))
+	}
+	if len(users) == limit {
+		response.NextAfter = int(users[len(users)-1].ID)
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
+
+func (ah *AdminHandlers) GetUserHandler(respWriter http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	user, err := ah.userService.GetUserByID(userID)
+	if err != nil {
+		WriteError(respWriter, fmt.Errorf("%w: %d", services.ErrUserNotFound, userID))
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(adminUserResponse(user))
+}
+
+func (ah *AdminHandlers) ListUserWalletsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	if _, err := ah.userService.GetUserByID(userID); err != nil {
+		WriteError(respWriter, fmt.Errorf("%w: %d", services.ErrUserNotFound, userID))
+		return
+	}
+	wallets, err := ah.walletService.ListWallets(userID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(wallets)
+}
+
+// GetUserHistoryHandler takes the same parameters as the wallet history of
+// the user themselves.
+func (ah *AdminHandlers) GetUserHistoryHandler(respWriter http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	query, err := historyQuery(req.URL.Query())
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+	query.WalletID = walletID
+
+	page, err := ah.walletService.ListHistory(userID, query)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.HistoryPageDTO{Items: page.Postings, NextCursor: page.NextCursor})
+}
+
+func (ah *AdminHandlers) GetEntryHandler(respWriter http.ResponseWriter, req *http.Request) {
+	entryID, ok := entryIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	entry, err := ah.walletService.GetJournalEntry(entryID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(entry)
+}
+
+func (ah *AdminHandlers) SetUserRoleHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.SetRoleDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	user, err := ah.userService.SetRole(principal.UserID, userID, models.Role(payload.Role))
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(adminUserResponse(user))
+}
+
+func (ah *AdminHandlers) FreezeUserHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.FreezeDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	user, err := ah.userService.FreezeUser(principal.UserID, userID, payload.Reason)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(adminUserResponse(user))
+}
+
+func (ah *AdminHandlers) UnfreezeUserHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	user, err := ah.userService.UnfreezeUser(principal.UserID, userID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(adminUserResponse(user))
+}
+
+func (ah *AdminHandlers) AdjustWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	walletID, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil || walletID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid wallet id")
+		return
+	}
+
+	var payload dto.AdjustmentDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	direction := models.PostingDirection(payload.Direction)
+	entry, err := ah.walletService.In(req.Context()).AdjustWallet(principal.UserID, walletID, direction, *payload.Amount, payload.Reason)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(respWriter).Encode(entry)
+}
+
+func userIDParam(respWriter http.ResponseWriter, req *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil || userID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid user id")
+		return 0, false
+	}
+	return userID, true
+}
+
+func adminUserResponse(user *models.User) dto.AdminUserDTO {
+	return dto.AdminUserDTO{
+		ID:           int(user.ID),
+		Email:        user.EmailID,
+		Role:         string(user.Role),
+		KYCLevel:     string(user.KYCLevel),
+		Frozen:       user.IsFrozen(),
+		FrozenAt:     user.FrozenAt,
+		FrozenBy:     user.FrozenBy,
+		FrozenReason: user.FrozenReason,
+		CreatedAt:    user.CreatedAt,
+	}
+}
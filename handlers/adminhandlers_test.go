@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+func TestAdminHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	walletService := services.NewWalletService(db, services.DefaultStaticRateProvider(), 0, services.LimitPolicy{})
+	adminHandlers := NewAdminHandlers(userService, walletService)
+
+	newUser := func(t *testing.T, email string, role models.Role) (int, string) {
+		userID, err := userService.CreateUser(&models.User{EmailID: email, Password: "password", Role: role})
+		assert.NoError(t, err)
+		IDToken, err := authService.AuthenticateUser(email, "password")
+		assert.NoError(t, err)
+		return userID, IDToken
+	}
+
+	serve := func(handler http.HandlerFunc, method, url, IDToken string, vars map[string]string, payload interface{}) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+		req = mux.SetURLVars(authenticated(authService, req, IDToken), vars)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	_, adminToken := newUser(t, "testadmin@example.com", models.RoleAdmin)
+
+	t.Run("SearchUsersHandler to page through users by email and role", func(t *testing.T) {
+		firstID, _ := newUser(t, "testadminsearch1@example.com", models.RoleCustomer)
+		secondID, _ := newUser(t, "testadminsearch2@example.com", models.RoleCustomer)
+
+		recorder := serve(adminHandlers.SearchUsersHandler, "GET", "/admin/users?email=testadminsearch&limit=1", adminToken, nil, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var page dto.AdminUsersDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&page))
+		assert.Len(t, page.Users, 1)
+		assert.Equal(t, firstID, page.Users[0].ID)
+		assert.Equal(t, "customer", page.Users[0].Role)
+		assert.Equal(t, firstID, page.NextAfter)
+
+		recorder = serve(adminHandlers.SearchUsersHandler, "GET", fmt.Sprintf("/admin/users?email=testadminsearch&after=%d", page.NextAfter), adminToken, nil, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		page = dto.AdminUsersDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&page))
+		assert.Len(t, page.Users, 1)
+		assert.Equal(t, secondID, page.Users[0].ID)
+		assert.Zero(t, page.NextAfter)
+
+		recorder = serve(adminHandlers.SearchUsersHandler, "GET", "/admin/users?role=owner", adminToken, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(adminHandlers.SearchUsersHandler, "GET", "/admin/users?limit=0", adminToken, nil, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("FreezeUserHandler and UnfreezeUserHandler to require a reason and record who froze the account", func(t *testing.T) {
+		userID, _ := newUser(t, "testadminfreeze@example.com", models.RoleCustomer)
+		vars := map[string]string{"id": fmt.Sprint(userID)}
+
+		recorder := serve(adminHandlers.FreezeUserHandler, "POST", "/admin/users/freeze", adminToken, vars, dto.FreezeDTO{})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(adminHandlers.FreezeUserHandler, "POST", "/admin/users/freeze", adminToken, vars, dto.FreezeDTO{Reason: "fraud report"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var user dto.AdminUserDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&user))
+		assert.True(t, user.Frozen)
+		assert.Equal(t, "fraud report", user.FrozenReason)
+
+		_, err := authService.AuthenticateUser("testadminfreeze@example.com", "password")
+		assert.ErrorIs(t, err, services.ErrAccountFrozen)
+
+		recorder = serve(adminHandlers.FreezeUserHandler, "POST", "/admin/users/freeze", adminToken, vars, dto.FreezeDTO{Reason: "again"})
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		recorder = serve(adminHandlers.UnfreezeUserHandler, "POST", "/admin/users/unfreeze", adminToken, vars, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		recorder = serve(adminHandlers.GetUserHandler, "GET", "/admin/users", adminToken, vars, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		user = dto.AdminUserDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&user))
+		assert.False(t, user.Frozen)
+
+		recorder = serve(adminHandlers.GetUserHandler, "GET", "/admin/users", adminToken, map[string]string{"id": "0"}, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		_, supportToken := newUser(t, "testadminfreezesupport@example.com", models.RoleSupport)
+		adminPrincipal, err := authService.Authenticate(adminToken)
+		assert.NoError(t, err)
+		recorder = serve(adminHandlers.FreezeUserHandler, "POST", "/admin/users/freeze", supportToken, map[string]string{"id": fmt.Sprint(adminPrincipal.UserID)}, dto.FreezeDTO{Reason: "testing"})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "outranked")
+	})
+
+	t.Run("AdjustWalletHandler to post an adjustment the ledger endpoints show", func(t *testing.T) {
+		userID, _ := newUser(t, "testadminadjust@example.com", models.RoleCustomer)
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		vars := map[string]string{"id": fmt.Sprint(wallet.ID)}
+		amount := &money.Money{Amount: decimal.NewFromInt(40), Currency: money.INR}
+
+		recorder := serve(adminHandlers.AdjustWalletHandler, "POST", "/admin/wallets/adjustments", adminToken, vars, dto.AdjustmentDTO{Direction: "credit", Amount: amount})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(adminHandlers.AdjustWalletHandler, "POST", "/admin/wallets/adjustments", adminToken, vars, dto.AdjustmentDTO{Direction: "credit", Amount: amount, Reason: "missed deposit"})
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		var entry models.JournalEntry
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&entry))
+		assert.Equal(t, "missed deposit", entry.Reason)
+
+		recorder = serve(adminHandlers.GetEntryHandler, "GET", "/admin/entries", adminToken, map[string]string{"id": fmt.Sprint(entry.ID)}, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var stored models.JournalEntry
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&stored))
+		assert.Equal(t, entry.ActorID, stored.ActorID)
+		assert.Equal(t, string(models.TransactionTypeAdjustment), stored.TransactionType)
+
+		userVars := map[string]string{"id": fmt.Sprint(userID)}
+		recorder = serve(adminHandlers.ListUserWalletsHandler, "GET", "/admin/users/wallets", adminToken, userVars, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var wallets []*models.Wallet
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&wallets))
+		assert.Len(t, wallets, 1)
+		assert.Equal(t, "40", wallets[0].Money.Amount.String())
+
+		recorder = serve(adminHandlers.GetUserHistoryHandler, "GET", "/admin/users/history?type=adjustment", adminToken, userVars, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var history dto.HistoryPageDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&history))
+		assert.Len(t, history.Items, 1)
+
+		recorder = serve(adminHandlers.AdjustWalletHandler, "POST", "/admin/wallets/adjustments", adminToken, vars, dto.AdjustmentDTO{Direction: "debit", Amount: &money.Money{Amount: decimal.NewFromInt(41), Currency: money.INR}, Reason: "reverse deposit"})
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+
+	t.Run("SetUserRoleHandler to reject an unknown role or a change to the own role", func(t *testing.T) {
+		userID, _ := newUser(t, "testadminrole@example.com", models.RoleCustomer)
+		adminPrincipal, err := authService.Authenticate(adminToken)
+		assert.NoError(t, err)
+
+		recorder := serve(adminHandlers.SetUserRoleHandler, "PUT", "/admin/users/role", adminToken, map[string]string{"id": fmt.Sprint(userID)}, dto.SetRoleDTO{Role: "owner"})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(adminHandlers.SetUserRoleHandler, "PUT", "/admin/users/role", adminToken, map[string]string{"id": fmt.Sprint(adminPrincipal.UserID)}, dto.SetRoleDTO{Role: "customer"})
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		recorder = serve(adminHandlers.SetUserRoleHandler, "PUT", "/admin/users/role", adminToken, map[string]string{"id": fmt.Sprint(userID)}, dto.SetRoleDTO{Role: "support"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var user dto.AdminUserDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&user))
+		assert.Equal(t, "support", user.Role)
+	})
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"nikwallet/services"
+)
+
+// requirePrincipal returns the caller put into the context by the router's
+// authentication middleware, answering 401 when a route was mounted without it.
+func requirePrincipal(respWriter http.ResponseWriter, req *http.Request) (*services.Principal, bool) {
+	principal, ok := services.PrincipalFromContext(req.Context())
+	if !ok {
+		respWriter.Header().Set("WWW-Authenticate", `Bearer realm="nikwallet"`)
+		WriteProblem(respWriter, http.StatusUnauthorized, CodeUnauthorized, "missing access token")
+		return nil, false
+	}
+	return principal, true
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"nikwallet/handlers/dto"
+)
+
+// maxRequestBodyBytes caps a JSON request body. The server puts its own,
+// configurable limit in front of every handler as well.
+const maxRequestBodyBytes = 1 << 20
+
+// decodeRequest decodes the JSON body of req into dst, rejecting unknown
+// fields, trailing data and oversized bodies, and validates the result. When
+// either fails it writes the problem and returns false.
+func decodeRequest(respWriter http.ResponseWriter, req *http.Request, dst interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(respWriter, req.Body, maxRequestBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		if _, trailingErr := decoder.Token(); trailingErr != io.EOF {
+			err = errors.New("request body must hold a single JSON value")
+		}
+	}
+	if err != nil {
+		writeDecodeError(respWriter, err)
+		return false
+	}
+
+	if err := dto.Validate(dst); err != nil {
+		WriteError(respWriter, err)
+		return false
+	}
+	return true
+}
+
+func writeDecodeError(respWriter http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		WriteProblem(respWriter, http.StatusRequestEntityTooLarge, CodeRequestTooLarge, fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "request body is empty")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		WriteError(respWriter, &dto.ValidationError{Fields: []dto.FieldError{
+			{Field: typeErr.Field, Code: "invalid_type", Message: "cannot be a JSON " + typeErr.Value},
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		WriteError(respWriter, &dto.ValidationError{Fields: []dto.FieldError{
+			{Field: field, Code: "unknown_field", Message: "is not a known field"},
+		}})
+	default:
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid JSON body: "+err.Error())
+	}
+}
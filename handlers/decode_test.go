@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/handlers/dto"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	decode := func(body string, dst interface{}) (*httptest.ResponseRecorder, bool, dto.ProblemDTO) {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		ok := decodeRequest(recorder, req, dst)
+
+		var problem dto.ProblemDTO
+		if !ok {
+			assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		}
+		return recorder, ok, problem
+	}
+
+	t.Run("decodeRequest method to accept a valid body", func(t *testing.T) {
+		var payload dto.UserSignupRequestDTO
+		_, ok, _ := decode(`{"email": "decode@example.com", "password": "password123"}`, &payload)
+		assert.True(t, ok)
+		assert.Equal(t, "decode@example.com", payload.Email)
+	})
+
+	t.Run("decodeRequest method to list every invalid field", func(t *testing.T) {
+		recorder, ok, problem := decode(`{"email": "not-an-email", "password": "short"}`, &dto.UserSignupRequestDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, CodeValidationFailed, problem.Code)
+		assert.Equal(t, []dto.FieldError{
+			{Field: "email", Code: "invalid_email", Message: "must be an email address"},
+			{Field: "password", Code: "weak_password", Message: "must be between 8 and 72 characters long"},
+		}, problem.Errors)
+	})
+
+	t.Run("decodeRequest method to reject a transfer without an amount or with a non-positive one", func(t *testing.T) {
+		_, ok, problem := decode(`{"recipient_email": "someone@example.com"}`, &dto.MoneyTransferDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, []dto.FieldError{{Field: "amount", Code: "required", Message: "is required"}}, problem.Errors)
+
+		_, ok, problem = decode(`{"amount": {"amount": "0", "currency": "INR"}, "recipient_email": "someone@example.com"}`, &dto.MoneyTransferDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, []dto.FieldError{{Field: "amount", Code: "not_positive", Message: "must be greater than zero"}}, problem.Errors)
+	})
+
+	t.Run("decodeRequest method to reject unsupported currencies and overlong names", func(t *testing.T) {
+		_, ok, problem := decode(`{"currency": "XYZ", "name": "`+strings.Repeat("n", 65)+`"}`, &dto.CreateWalletDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, []dto.FieldError{
+			{Field: "currency", Code: "unsupported_currency", Message: "XYZ is not a supported currency"},
+			{Field: "name", Code: "too_large", Message: "must be at most 64 characters"},
+		}, problem.Errors)
+	})
+
+	t.Run("decodeRequest method to reject unknown fields and trailing data", func(t *testing.T) {
+		_, ok, problem := decode(`{"email_id": "decode@example.com", "password": "password123"}`, &dto.UserSigninRequestDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, []dto.FieldError{{Field: "email_id", Code: "unknown_field", Message: "is not a known field"}}, problem.Errors)
+
+		recorder, ok, problem := decode(`{"refresh_token": "a"} {"refresh_token": "b"}`, &dto.TokenRefreshRequestDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, CodeInvalidRequest, problem.Code)
+	})
+
+	t.Run("decodeRequest method to return 413 for an oversized body", func(t *testing.T) {
+		body := `{"refresh_token": "` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+		recorder, ok, problem := decode(body, &dto.TokenRefreshRequestDTO{})
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.Equal(t, CodeRequestTooLarge, problem.Code)
+	})
+}
@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"nikwallet/repository/money"
+	"time"
+)
+
+type AdminUserDTO struct {
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	Role         string     `json:"role"`
+	KYCLevel     string     `json:"kyc_level"`
+	Frozen       bool       `json:"frozen"`
+	FrozenAt     *time.Time `json:"frozen_at,omitempty"`
+	FrozenBy     int        `json:"frozen_by,omitempty"`
+	FrozenReason string     `json:"frozen_reason,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// AdminUsersDTO is a page of a user search. NextAfter is passed as after to
+// fetch the next page and is left out on the last one.
+type AdminUsersDTO struct {
+	Users     []AdminUserDTO `json:"users"`
+	NextAfter int            `json:"next_after,omitempty"`
+}
+
+type SetRoleDTO struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type FreezeDTO struct {
+	Reason string `json:"reason" validate:"required,max=512"`
+}
+
+// AdjustmentDTO is the body of a manual adjustment. Direction is credit or
+// debit, and Amount must be in the currency of the wallet.
+type AdjustmentDTO struct {
+	Direction string       `json:"direction" validate:"required"`
+	Amount    *money.Money `json:"amount" validate:"required,positive"`
+	Reason    string       `json:"reason" validate:"required,max=512"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import (
+	"nikwallet/repository/money"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type FXQuoteRequestDTO struct {
+	From   money.Currency   `json:"from" validate:"required,currency"`
+	To     money.Currency   `json:"to" validate:"required,currency"`
+	Amount *decimal.Decimal `json:"amount,omitempty" validate:"positive"`
+}
+
+type FXQuoteDTO struct {
+	QuoteID         string          `json:"quote_id"`
+	From            money.Currency  `json:"from"`
+	To              money.Currency  `json:"to"`
+	Rate            decimal.Decimal `json:"rate"`
+	Source          string          `json:"source"`
+	ExpiresAt       time.Time       `json:"expires_at"`
+	Amount          *money.Money    `json:"amount,omitempty"`
+	ConvertedAmount *money.Money    `json:"converted_amount,omitempty"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"nikwallet/repository/money"
+	"time"
+)
+
+// AuthorizeHoldDTO is the body of a hold authorization. ExpiresIn is the
+// lifetime of the hold in seconds and defaults to a week.
+type AuthorizeHoldDTO struct {
+	Amount    *money.Money `json:"amount" validate:"required,positive"`
+	WalletID  int          `json:"wallet_id,omitempty" validate:"min=1"`
+	Reference string       `json:"reference,omitempty" validate:"max=128"`
+	ExpiresIn int          `json:"expires_in,omitempty" validate:"min=1,max=2592000"`
+}
+
+// CaptureHoldDTO is the optional body of a capture. Without an amount the
+// whole hold is captured.
+type CaptureHoldDTO struct {
+	Amount *money.Money `json:"amount,omitempty" validate:"positive"`
+}
+
+type HoldDTO struct {
+	ID             int          `json:"id"`
+	WalletID       int          `json:"wallet_id"`
+	Amount         *money.Money `json:"amount"`
+	CapturedAmount *money.Money `json:"captured_amount,omitempty"`
+	Status         string       `json:"status"`
+	Reference      string       `json:"reference,omitempty"`
+	JournalEntryID int          `json:"journal_entry_id,omitempty"`
+	ExpiresAt      time.Time    `json:"expires_at"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,63 @@
+package dto
+
+import "time"
+
+// SubmitKYCDTO asks for the user to be verified at Level, minimal or full.
+// ExpiresOn is the expiry date of the document as YYYY-MM-DD.
+type SubmitKYCDTO struct {
+	Level          string `json:"level" validate:"required"`
+	DocumentType   string `json:"document_type" validate:"required,max=32"`
+	DocumentNumber string `json:"document_number" validate:"required,min=4,max=64"`
+	IssuingCountry string `json:"issuing_country" validate:"required,min=2,max=2"`
+	ExpiresOn      string `json:"expires_on,omitempty"`
+}
+
+type ApproveKYCDTO struct {
+	Note string `json:"note,omitempty" validate:"max=512"`
+}
+
+type RejectKYCDTO struct {
+	Reason string `json:"reason" validate:"required,max=512"`
+}
+
+type KYCSubmissionDTO struct {
+	ID                  int        `json:"id"`
+	UserID              int        `json:"user_id"`
+	Level               string     `json:"level"`
+	DocumentType        string     `json:"document_type"`
+	DocumentNumberLast4 string     `json:"document_number_last4"`
+	IssuingCountry      string     `json:"issuing_country"`
+	ExpiresOn           string     `json:"expires_on,omitempty"`
+	Status              string     `json:"status"`
+	ReviewerID          int        `json:"reviewer_id,omitempty"`
+	ReviewNote          string     `json:"review_note,omitempty"`
+	ReviewedAt          *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+type KYCStatusDTO struct {
+	Level       string             `json:"level"`
+	Submissions []KYCSubmissionDTO `json:"submissions"`
+}
+
+// KYCSubmissionsDTO is a page of the review queue. NextAfter is passed as
+// after to fetch the next page and is left out on the last one.
+type KYCSubmissionsDTO struct {
+	Submissions []KYCSubmissionDTO `json:"submissions"`
+	NextAfter   int                `json:"next_after,omitempty"`
+}
+
+type KYCEventDTO struct {
+	ID           int       `json:"id"`
+	SubmissionID int       `json:"submission_id"`
+	ActorID      int       `json:"actor_id"`
+	Action       string    `json:"action"`
+	FromLevel    string    `json:"from_level"`
+	ToLevel      string    `json:"to_level"`
+	Note         string    `json:"note,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type KYCEventsDTO struct {
+	Events []KYCEventDTO `json:"events"`
+}
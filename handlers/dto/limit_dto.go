@@ -0,0 +1,42 @@
+package dto
+
+import (
+	"nikwallet/repository/money"
+	"time"
+)
+
+// LimitsDTO lists the balance cap of the KYC level of the user and the limits
+// of every limited transaction type. A limit that is not enforced is left
+// out.
+type LimitsDTO struct {
+	KYCLevel   string                 `json:"kyc_level"`
+	Balance    *money.Money           `json:"balance"`
+	MaxBalance *money.Money           `json:"max_balance,omitempty"`
+	Limits     []TransactionLimitsDTO `json:"limits"`
+}
+
+type TransactionLimitsDTO struct {
+	TransactionType string             `json:"transaction_type"`
+	MinAmount       *money.Money       `json:"min_amount,omitempty"`
+	MaxAmount       *money.Money       `json:"max_amount,omitempty"`
+	Daily           *AllowanceDTO      `json:"daily,omitempty"`
+	Monthly         *AllowanceDTO      `json:"monthly,omitempty"`
+	Hourly          *CountAllowanceDTO `json:"hourly,omitempty"`
+}
+
+// AllowanceDTO is how much of a cumulative limit is used and left until it
+// resets.
+type AllowanceDTO struct {
+	Limit     *money.Money `json:"limit"`
+	Used      *money.Money `json:"used"`
+	Remaining *money.Money `json:"remaining"`
+	ResetsAt  time.Time    `json:"resets_at"`
+}
+
+// CountAllowanceDTO is how many transactions were made in the last hour and
+// how many more are allowed.
+type CountAllowanceDTO struct {
+	Limit     int `json:"limit"`
+	Used      int `json:"used"`
+	Remaining int `json:"remaining"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+// ProblemDTO is an RFC 7807 problem details object. Code is the stable,
+// machine-readable identifier of the problem that clients can branch on;
+// Errors lists the rejected fields of an invalid request.
+type ProblemDTO struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
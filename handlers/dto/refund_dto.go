@@ -0,0 +1,9 @@
+package dto
+
+import "nikwallet/repository/money"
+
+// RefundDTO is the body of a partial refund, in the currency the refunding
+// user was credited in.
+type RefundDTO struct {
+	Amount *money.Money `json:"amount" validate:"required,positive"`
+}
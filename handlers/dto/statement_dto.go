@@ -0,0 +1,28 @@
+package dto
+
+import "time"
+
+// Kinds of the records of a JSON Lines statement. A statement is an opening
+// balance record, one entry record per posting and a closing balance record.
+const (
+	StatementRecordOpeningBalance = "opening_balance"
+	StatementRecordEntry          = "entry"
+	StatementRecordClosingBalance = "closing_balance"
+)
+
+// StatementRecordDTO is one line of a JSON Lines statement. Amounts are
+// decimal strings in the currency of the wallet; the amount of a debit is
+// negative.
+type StatementRecordDTO struct {
+	Record            string    `json:"record"`
+	At                time.Time `json:"at"`
+	WalletID          int       `json:"wallet_id"`
+	Currency          string    `json:"currency"`
+	EntryID           int       `json:"entry_id,omitempty"`
+	PostingID         int       `json:"posting_id,omitempty"`
+	TransactionType   string    `json:"transaction_type,omitempty"`
+	Direction         string    `json:"direction,omitempty"`
+	Amount            string    `json:"amount,omitempty"`
+	CounterpartyEmail string    `json:"counterparty_email,omitempty"`
+	Balance           string    `json:"balance"`
+}
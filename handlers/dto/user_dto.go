@@ -1,8 +1,10 @@
 package dto
 
+import "time"
+
 type UserSignupRequestDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,password"`
 }
 
 type UserSignupResponseDTO struct {
@@ -11,10 +13,25 @@ type UserSignupResponseDTO struct {
 }
 
 type UserSigninRequestDTO struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,max=254"`
+	Password string `json:"password" validate:"required,max=72"`
 }
 
 type UserSigninResponseDTO struct {
-	IDToken string `json:"id_token"`
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type TokenRefreshRequestDTO struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=512"`
+}
+
+type SessionDTO struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
 }
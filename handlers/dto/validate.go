@@ -0,0 +1,155 @@
+package dto
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"nikwallet/repository/money"
+	"nikwallet/services/password"
+
+	"github.com/shopspring/decimal"
+)
+
+// FieldError describes why a single field of a request was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a request that broke its rules.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (ve *ValidationError) Error() string {
+	messages := make([]string, 0, len(ve.Fields))
+	for _, field := range ve.Fields {
+		messages = append(messages, field.Field+": "+field.Message)
+	}
+	return "invalid request: " + strings.Join(messages, "; ")
+}
+
+// Validate checks every field of the struct v points to against the rules in
+// its validate tag and returns a *ValidationError listing all failures.
+// Fields are named after their JSON keys. Rules are separated by commas:
+//
+//	required   the field must not be empty
+//	email      a bare email address, such as name@example.com
+//	password   the password policy of the password package
+//	positive   an amount greater than zero
+//	currency   an ISO 4217 currency code
+//	min=N      at least N characters for strings, at least N for numbers
+//	max=N      at most N characters for strings, at most N for numbers
+//
+// Rules other than required are skipped for empty optional fields.
+func Validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	fields := value.Type()
+
+	var failures []FieldError
+	for i := 0; i < fields.NumField(); i++ {
+		tag := fields.Field(i).Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name := jsonName(fields.Field(i))
+		fieldValue := value.Field(i)
+
+		for _, rule := range strings.Split(tag, ",") {
+			if fieldValue.IsZero() && rule != "required" {
+				break
+			}
+			if code, message := checkRule(rule, fieldValue); code != "" {
+				failures = append(failures, FieldError{Field: name, Code: code, Message: message})
+				break
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		return &ValidationError{Fields: failures}
+	}
+	return nil
+}
+
+func checkRule(rule string, value reflect.Value) (code string, message string) {
+	name, arg, _ := strings.Cut(rule, "=")
+	value = reflect.Indirect(value)
+
+	switch name {
+	case "required":
+		if !value.IsValid() || value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "") {
+			return "required", "is required"
+		}
+	case "email":
+		address, err := mail.ParseAddress(value.String())
+		if err != nil || address.Address != value.String() {
+			return "invalid_email", "must be an email address"
+		}
+	case "password":
+		if err := password.CheckPolicy(value.String()); err != nil {
+			return "weak_password", strings.TrimPrefix(err.Error(), password.ErrWeakPassword.Error()+": ")
+		}
+	case "positive":
+		if amount, ok := decimalOf(value); !ok || !amount.IsPositive() {
+			return "not_positive", "must be greater than zero"
+		}
+	case "currency":
+		if !money.Currency(value.String()).IsValid() {
+			return "unsupported_currency", fmt.Sprintf("%s is not a supported currency", value.String())
+		}
+	case "min", "max":
+		bound, err := strconv.Atoi(arg)
+		if err != nil {
+			panic(fmt.Sprintf("dto: invalid validate rule %q", rule))
+		}
+		return checkBound(name, bound, value)
+	default:
+		panic(fmt.Sprintf("dto: unknown validate rule %q", rule))
+	}
+	return "", ""
+}
+
+func checkBound(name string, bound int, value reflect.Value) (code string, message string) {
+	var size int64
+	var unit string
+	switch value.Kind() {
+	case reflect.String:
+		size, unit = int64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = value.Int()
+	default:
+		panic(fmt.Sprintf("dto: %s cannot be applied to %s", name, value.Type()))
+	}
+
+	if name == "min" && size < int64(bound) {
+		return "too_small", fmt.Sprintf("must be at least %d%s", bound, unit)
+	}
+	if name == "max" && size > int64(bound) {
+		return "too_large", fmt.Sprintf("must be at most %d%s", bound, unit)
+	}
+	return "", ""
+}
+
+func decimalOf(value reflect.Value) (decimal.Decimal, bool) {
+	switch amount := value.Interface().(type) {
+	case decimal.Decimal:
+		return amount, true
+	case money.Money:
+		return amount.Amount, true
+	}
+	return decimal.Decimal{}, false
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
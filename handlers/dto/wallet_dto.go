@@ -1,10 +1,52 @@
 package dto
 
-import "nikwallet/repository/money"
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MoneyDTO is the body of the add and withdraw money requests.
+type MoneyDTO struct {
+	Amount   decimal.Decimal `json:"amount" validate:"required,positive"`
+	Currency money.Currency  `json:"currency" validate:"required,currency"`
+}
+
+func (md MoneyDTO) Money() money.Money {
+	return money.Money{Amount: md.Amount, Currency: md.Currency}
+}
 
 type MoneyTransferDTO struct {
-	Amount         *money.Money `json:"amount"`
-	RecipientEmail string       `json:"recipient_email"`
+	Amount            *money.Money `json:"amount" validate:"required,positive"`
+	RecipientEmail    string       `json:"recipient_email" validate:"required,email,max=254"`
+	RecipientWalletID int          `json:"recipient_wallet_id,omitempty" validate:"min=1"`
+	QuoteID           string       `json:"quote_id,omitempty" validate:"max=64"`
+}
+
+type CreateWalletDTO struct {
+	Currency money.Currency `json:"currency" validate:"required,currency"`
+	Name     string         `json:"name,omitempty" validate:"max=64"`
+}
+
+type DefaultWalletDTO struct {
+	WalletID int `json:"wallet_id" validate:"required,min=1"`
+}
+
+// HistoryPageDTO is one page of the wallet history. NextCursor is passed as
+// the cursor parameter to fetch the following page and is omitted on the
+// last one.
+type HistoryPageDTO struct {
+	Items      []*models.Posting `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// BalanceDTO is the balance of a wallet at a point in time.
+type BalanceDTO struct {
+	WalletID int          `json:"wallet_id"`
+	Balance  *money.Money `json:"balance"`
+	At       time.Time    `json:"at"`
 }
 
 type Response struct {
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+type FXHandlers struct {
+	fxService *services.FXService
+}
+
+func NewFXHandlers(fxService *services.FXService) *FXHandlers {
+	return &FXHandlers{fxService: fxService}
+}
+
+func (fh *FXHandlers) QuoteHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.FXQuoteRequestDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+	if payload.Amount != nil {
+		if err := (money.Money{Amount: *payload.Amount, Currency: payload.From}).Validate(); err != nil {
+			WriteError(respWriter, err)
+			return
+		}
+	}
+
+	quote, err := fh.fxService.Quote(principal.UserID, payload.From, payload.To)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.FXQuoteDTO{
+		QuoteID:   quote.ID,
+		From:      quote.FromCurrency,
+		To:        quote.ToCurrency,
+		Rate:      quote.Rate,
+		Source:    quote.Source,
+		ExpiresAt: quote.ExpiresAt,
+	}
+	if payload.Amount != nil {
+		response.Amount = &money.Money{Amount: *payload.Amount, Currency: quote.FromCurrency}
+		response.ConvertedAmount, err = response.Amount.Convert(quote.ToCurrency, quote.Rate)
+		if err != nil {
+			WriteError(respWriter, err)
+			return
+		}
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+func TestFXHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	fxHandlers := NewFXHandlers(services.NewFXService(db, services.DefaultStaticRateProvider(), time.Minute))
+
+	_, err := userService.CreateUser(&models.User{EmailID: "testfxquote@example.com", Password: "password"})
+	assert.NoError(t, err)
+	IDToken, err := authService.AuthenticateUser("testfxquote@example.com", "password")
+	assert.NoError(t, err)
+
+	quote := func(payload interface{}) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(payload)
+		req, _ := http.NewRequest("POST", "/fx/quote", bytes.NewReader(reqBody))
+		req = authenticated(authService, req, IDToken)
+
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(fxHandlers.QuoteHandler).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("QuoteHandler to return 200 StatusOk with the locked rate and converted amount", func(t *testing.T) {
+		amount := decimal.NewFromInt(100)
+		recorder := quote(dto.FXQuoteRequestDTO{From: money.USD, To: money.EUR, Amount: &amount})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var response dto.FXQuoteDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+		assert.NotEmpty(t, response.QuoteID)
+		assert.Equal(t, "0.91666667", response.Rate.String())
+		assert.Equal(t, "static:default", response.Source)
+		assert.True(t, response.ExpiresAt.After(time.Now()))
+		assert.True(t, response.ConvertedAmount.Equals(money.Money{Amount: decimal.RequireFromString("91.67"), Currency: money.EUR}))
+	})
+
+	t.Run("QuoteHandler to return 400 BadRequest for an unsupported currency", func(t *testing.T) {
+		recorder := quote(dto.FXQuoteRequestDTO{From: money.USD, To: money.Currency("XYZ")})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("QuoteHandler to return 503 ServiceUnavailable for a currency without a rate", func(t *testing.T) {
+		recorder := quote(dto.FXQuoteRequestDTO{From: money.USD, To: money.Currency("GBP")})
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	})
+}
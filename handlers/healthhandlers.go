@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"nikwallet/handlers/dto"
+	"nikwallet/services"
+	"time"
+)
+
+// readinessTimeout bounds how long the readiness checks may take, so that a
+// hanging database fails the probe instead of the prober timing out.
+const readinessTimeout = 2 * time.Second
+
+type HealthHandlers struct {
+	healthService *services.HealthService
+}
+
+func NewHealthHandlers(healthService *services.HealthService) *HealthHandlers {
+	return &HealthHandlers{healthService: healthService}
+}
+
+// LivenessHandler reports that the process is up and serving HTTP.
+func (hh *HealthHandlers) LivenessHandler(respWriter http.ResponseWriter, req *http.Request) {
+	respWriter.Header().Set("Content-Type", "application/json")
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.HealthDTO{Status: "ok"})
+}
+
+// ReadinessHandler reports whether the server can take traffic, listing the
+// checks that failed when it cannot.
+func (hh *HealthHandlers) ReadinessHandler(respWriter http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), readinessTimeout)
+	defer cancel()
+
+	failures := hh.healthService.Ready(ctx)
+
+	respWriter.Header().Set("Content-Type", "application/json")
+	if len(failures) == 0 {
+		respWriter.WriteHeader(http.StatusOK)
+		json.NewEncoder(respWriter).Encode(dto.HealthDTO{Status: "ready"})
+		return
+	}
+
+	checks := map[string]string{}
+	for name, err := range failures {
+		checks[name] = err.Error()
+	}
+	respWriter.WriteHeader(http.StatusServiceUnavailable)
+	json.NewEncoder(respWriter).Encode(dto.HealthDTO{Status: "unavailable", Checks: checks})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"nikwallet/handlers/dto"
+	"nikwallet/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	healthService := services.NewHealthService()
+	healthHandlers := NewHealthHandlers(healthService)
+
+	databaseErr := errors.New("connection refused")
+	var databaseDown bool
+	healthService.AddCheck("database", func(ctx context.Context) error {
+		if databaseDown {
+			return databaseErr
+		}
+		return nil
+	})
+
+	readiness := func() (int, dto.HealthDTO) {
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		recorder := httptest.NewRecorder()
+		healthHandlers.ReadinessHandler(recorder, req)
+
+		var response dto.HealthDTO
+		json.NewDecoder(recorder.Body).Decode(&response)
+		return recorder.Code, response
+	}
+
+	t.Run("LivenessHandler to report ok", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/healthz", nil)
+		recorder := httptest.NewRecorder()
+		healthHandlers.LivenessHandler(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("ReadinessHandler to report ready while every check passes", func(t *testing.T) {
+		status, response := readiness()
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, "ready", response.Status)
+	})
+
+	t.Run("ReadinessHandler to list failing checks", func(t *testing.T) {
+		databaseDown = true
+		defer func() { databaseDown = false }()
+
+		status, response := readiness()
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Equal(t, databaseErr.Error(), response.Checks["database"])
+	})
+
+	t.Run("ReadinessHandler to report not ready once shutdown started", func(t *testing.T) {
+		healthService.SetShuttingDown()
+
+		status, response := readiness()
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		assert.Contains(t, response.Checks, "shutdown")
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/gorilla/mux"
+)
+
+func (wh *WalletHandlers) AuthorizeHoldHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.AuthorizeHoldDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	ttl := time.Duration(payload.ExpiresIn) * time.Second
+	hold, err := wh.walletService.In(req.Context()).AuthorizeHold(principal.UserID, payload.WalletID, *payload.Amount, payload.Reference, ttl)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(respWriter).Encode(holdResponse(hold))
+}
+
+func (wh *WalletHandlers) GetHoldHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	holdID, ok := holdIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	hold, err := wh.walletService.GetHold(principal.UserID, holdID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(holdResponse(hold))
+}
+
+// CaptureHoldHandler captures the amount in the body, or the whole hold when
+// the request has no body.
+func (wh *WalletHandlers) CaptureHoldHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	holdID, ok := holdIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.CaptureHoldDTO
+	if req.ContentLength != 0 && !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	hold, err := wh.walletService.In(req.Context()).CaptureHold(principal.UserID, holdID, payload.Amount)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(holdResponse(hold))
+}
+
+func (wh *WalletHandlers) VoidHoldHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	holdID, ok := holdIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	hold, err := wh.walletService.In(req.Context()).VoidHold(principal.UserID, holdID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(holdResponse(hold))
+}
+
+func holdIDParam(respWriter http.ResponseWriter, req *http.Request) (int, bool) {
+	holdID, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil || holdID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid hold id")
+		return 0, false
+	}
+	return holdID, true
+}
+
+func holdResponse(hold *models.Hold) dto.HoldDTO {
+	response := dto.HoldDTO{
+		ID:             hold.ID,
+		WalletID:       hold.WalletID,
+		Amount:         hold.Amount,
+		Status:         string(hold.Status),
+		Reference:      hold.Reference,
+		JournalEntryID: hold.JournalEntryID,
+		ExpiresAt:      hold.ExpiresAt,
+		CreatedAt:      hold.CreatedAt,
+		UpdatedAt:      hold.UpdatedAt,
+	}
+	if hold.Status == models.HoldStatusCaptured {
+		response.CapturedAmount = &money.Money{Amount: hold.CapturedAmount, Currency: hold.Amount.Currency}
+	}
+	return response
+}
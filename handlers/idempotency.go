@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"nikwallet/logger"
+	"nikwallet/services"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// idempotencyRecorder holds the response back until the transaction that
+// stores it has committed.
+type idempotencyRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (ir *idempotencyRecorder) Header() http.Header {
+	return ir.header
+}
+
+func (ir *idempotencyRecorder) WriteHeader(status int) {
+	if ir.status == 0 {
+		ir.status = status
+	}
+}
+
+func (ir *idempotencyRecorder) Write(b []byte) (int, error) {
+	if ir.status == 0 {
+		ir.status = http.StatusOK
+	}
+	return ir.body.Write(b)
+}
+
+func (ir *idempotencyRecorder) flush(respWriter http.ResponseWriter) {
+	if ir.status == 0 {
+		return
+	}
+	for name, values := range ir.header {
+		respWriter.Header()[name] = values
+	}
+	respWriter.WriteHeader(ir.status)
+	respWriter.Write(ir.body.Bytes())
+}
+
+// errIdempotentRequestFailed rolls back a request that ended without a
+// response worth replaying.
+var errIdempotentRequestFailed = errors.New("idempotent request failed")
+
+// Idempotent makes next safe to retry. Requests carrying an Idempotency-Key
+// header run at most once per user and key; retries with the same body get
+// the stored response back and retries with a different body are rejected.
+// next runs in the transaction that stores its response, so it must reach
+// the store through services bound with In.
+func (wh *WalletHandlers) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(respWriter http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(IdempotencyKeyHeader)
+		if key == "" || wh.idempotencyService == nil {
+			next(respWriter, req)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "idempotency key is too long")
+			return
+		}
+
+		principal, ok := services.PrincipalFromContext(req.Context())
+		if !ok {
+			next(respWriter, req)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(respWriter, req.Body, maxRequestBodyBytes))
+		if err != nil {
+			writeDecodeError(respWriter, err)
+			return
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+
+		idempotencyKey, replay, err := wh.idempotencyService.Begin(principal.UserID, key, requestFingerprint(req, body))
+		if err != nil {
+			WriteError(respWriter, err)
+			return
+		}
+
+		if replay {
+			if idempotencyKey.ResponseContentType != "" {
+				respWriter.Header().Set("Content-Type", idempotencyKey.ResponseContentType)
+			}
+			respWriter.Header().Set(IdempotentReplayedHeader, "true")
+			respWriter.WriteHeader(idempotencyKey.ResponseStatus)
+			respWriter.Write(idempotencyKey.ResponseBody)
+			return
+		}
+
+		recorder := &idempotencyRecorder{header: http.Header{}}
+		err = wh.idempotencyService.Run(req.Context(), idempotencyKey, func(ctx context.Context) (*services.IdempotentResponse, error) {
+			next(recorder, req.WithContext(ctx))
+			if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+				return nil, errIdempotentRequestFailed
+			}
+			return &services.IdempotentResponse{
+				Status:      recorder.status,
+				ContentType: recorder.header.Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, nil
+		})
+		if err != nil && !errors.Is(err, errIdempotentRequestFailed) {
+			logger.Errorf("failed to store idempotent response: %s", err)
+			WriteError(respWriter, err)
+			return
+		}
+		recorder.flush(respWriter)
+	}
+}
+
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.URL.Path))
+	hash.Write([]byte{0})
+	hash.Write([]byte(req.URL.RawQuery))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
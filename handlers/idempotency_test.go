@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+func TestIdempotentWalletHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	walletService := services.NewWalletService(db, services.DefaultStaticRateProvider(), 0, services.LimitPolicy{})
+	idempotencyService := services.NewIdempotencyService(db, time.Hour)
+
+	walletHandlers := NewWalletHandlers(walletService, userService, idempotencyService)
+	addMoney := walletHandlers.Idempotent(walletHandlers.AddMoneyToWalletHandler)
+
+	newFundedUser := func(t *testing.T, email string) (int, string) {
+		userID, err := userService.CreateUser(&models.User{EmailID: email, Password: "password"})
+		assert.NoError(t, err)
+		_, err = walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		IDToken, err := authService.AuthenticateUser(email, "password")
+		assert.NoError(t, err)
+		return userID, IDToken
+	}
+
+	putMoney := func(IDToken, key string, amount float64) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR})
+		req, _ := http.NewRequest("PUT", "/wallet/", bytes.NewReader(reqBody))
+		req = authenticated(authService, req, IDToken)
+		req.Header.Set(IdempotencyKeyHeader, key)
+
+		recorder := httptest.NewRecorder()
+		addMoney.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("Idempotent AddMoneyToWalletHandler to move money once and replay the original response on retry", func(t *testing.T) {
+		userID, IDToken := newFundedUser(t, "idempotent_retry@example.com")
+
+		first := putMoney(IDToken, "retry-key", 50.0)
+		assert.Equal(t, http.StatusOK, first.Code)
+
+		second := putMoney(IDToken, "retry-key", 50.0)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, "true", second.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+
+		wallet, _ := walletService.GetWalletByUserID(userID)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromFloat(50.0)), "got %s, want 50", wallet.Money.Amount)
+	})
+
+	t.Run("Idempotent AddMoneyToWalletHandler to return 422 for key reuse with a different body", func(t *testing.T) {
+		userID, IDToken := newFundedUser(t, "idempotent_mismatch@example.com")
+
+		first := putMoney(IDToken, "mismatch-key", 50.0)
+		assert.Equal(t, http.StatusOK, first.Code)
+
+		second := putMoney(IDToken, "mismatch-key", 75.0)
+		assert.Equal(t, http.StatusUnprocessableEntity, second.Code)
+
+		wallet, _ := walletService.GetWalletByUserID(userID)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromFloat(50.0)), "got %s, want 50", wallet.Money.Amount)
+	})
+
+	t.Run("Idempotent AddMoneyToWalletHandler to return 409 while the same key is in flight", func(t *testing.T) {
+		userID, IDToken := newFundedUser(t, "idempotent_inflight@example.com")
+
+		reqBody, _ := json.Marshal(money.Money{Amount: decimal.NewFromFloat(50.0), Currency: money.INR})
+		req, _ := http.NewRequest("PUT", "/wallet/", bytes.NewReader(reqBody))
+		_, _, err := idempotencyService.Begin(userID, "inflight-key", requestFingerprint(req, reqBody))
+		assert.NoError(t, err)
+
+		recorder := putMoney(IDToken, "inflight-key", 50.0)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+
+		wallet, _ := walletService.GetWalletByUserID(userID)
+		assert.True(t, wallet.Money.Amount.IsZero(), "got %s, want 0", wallet.Money.Amount)
+	})
+
+	t.Run("Idempotent AddMoneyToWalletHandler to run every request without an Idempotency-Key", func(t *testing.T) {
+		userID, IDToken := newFundedUser(t, "idempotent_nokey@example.com")
+
+		assert.Equal(t, http.StatusOK, putMoney(IDToken, "", 50.0).Code)
+		assert.Equal(t, http.StatusOK, putMoney(IDToken, "", 50.0).Code)
+
+		wallet, _ := walletService.GetWalletByUserID(userID)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromFloat(100.0)), "got %s, want 100", wallet.Money.Amount)
+	})
+
+	t.Run("Idempotent AddMoneyToWalletHandler to release the key when the request fails with a server error", func(t *testing.T) {
+		userID, err := userService.CreateUser(&models.User{EmailID: "idempotent_norate@example.com", Password: "password"})
+		assert.NoError(t, err)
+		_, err = walletService.CreateWallet(userID, money.Currency("GBP"))
+		assert.NoError(t, err)
+		IDToken, _ := authService.AuthenticateUser("idempotent_norate@example.com", "password")
+
+		first := putMoney(IDToken, "release-key", 50.0)
+		assert.Equal(t, http.StatusServiceUnavailable, first.Code)
+
+		_, err = db.GetIdempotencyKey(userID, "release-key")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/services"
+
+	"github.com/gorilla/mux"
+)
+
+type KYCHandlers struct {
+	kycService *services.KYCService
+}
+
+func NewKYCHandlers(kycService *services.KYCService) *KYCHandlers {
+	return &KYCHandlers{kycService: kycService}
+}
+
+func (kh *KYCHandlers) SubmitKYCHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.SubmitKYCDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	submission := &models.KYCSubmission{
+		Level:          models.KYCLevel(payload.Level),
+		DocumentType:   payload.DocumentType,
+		IssuingCountry: payload.IssuingCountry,
+	}
+	if payload.ExpiresOn != "" {
+		expiresOn, err := time.Parse(time.DateOnly, payload.ExpiresOn)
+		if err != nil {
+			WriteError(respWriter, &dto.ValidationError{Fields: []dto.FieldError{
+				{Field: "expires_on", Code: "invalid_date", Message: "must be a date as YYYY-MM-DD"},
+			}})
+			return
+		}
+		submission.DocumentExpiresOn = &expiresOn
+	}
+
+	if err := kh.kycService.Submit(principal.UserID, submission, payload.DocumentNumber); err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(respWriter).Encode(kycSubmissionResponse(submission))
+}
+
+func (kh *KYCHandlers) GetKYCStatusHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	status, err := kh.kycService.GetStatus(principal.UserID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.KYCStatusDTO{
+		Level:       string(status.Level),
+		Submissions: make([]dto.KYCSubmissionDTO, 0, len(status.Submissions)),
+	}
+	for _, submission := range status.Submissions {
+		response.Submissions = append(response.Submissions, kycSubmissionResponse(submission))
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
+
+// ListKYCSubmissionsHandler pages through the submissions waiting for review,
+// oldest first, with the after and limit query parameters.
+func (kh *KYCHandlers) ListKYCSubmissionsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	params := req.URL.Query()
+	var failures []dto.FieldError
+
+	afterID := 0
+	if after := params.Get("after"); after != "" {
+		var err error
+		if afterID, err = strconv.Atoi(after); err != nil || afterID < 0 {
+			failures = append(failures, dto.FieldError{Field: "after", Code: "invalid_cursor", Message: "must be a submission id"})
+		}
+	}
+	limit := services.DefaultKYCQueueLimit
+	if value := params.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			failures = append(failures, dto.FieldError{Field: "limit", Code: "invalid_limit", Message: "must be a positive integer"})
+		}
+	}
+	if len(failures) > 0 {
+		WriteError(respWriter, &dto.ValidationError{Fields: failures})
+		return
+	}
+
+	submissions, err := kh.kycService.ListPending(afterID, limit)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.KYCSubmissionsDTO{Submissions: make([]dto.KYCSubmissionDTO, 0, len(submissions))}
+	for _, submission := range submissions {
+		response.Submissions = append(response.Submissions, kycSubmissionResponse(submission))
+	}
+	if len(submissions) == limit {
+		response.NextAfter = submissions[len(submissions)-1].ID
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
+
+// ApproveKYCSubmissionHandler takes an optional note in the body.
+func (kh *KYCHandlers) ApproveKYCSubmissionHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	submissionID, ok := kycSubmissionIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.ApproveKYCDTO
+	if req.ContentLength != 0 && !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	submission, err := kh.kycService.Approve(principal.UserID, submissionID, payload.Note)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(kycSubmissionResponse(submission))
+}
+
+func (kh *KYCHandlers) RejectKYCSubmissionHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	submissionID, ok := kycSubmissionIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.RejectKYCDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	submission, err := kh.kycService.Reject(principal.UserID, submissionID, payload.Reason)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(kycSubmissionResponse(submission))
+}
+
+func (kh *KYCHandlers) ListKYCEventsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	userID, ok := userIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	events, err := kh.kycService.ListEvents(userID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.KYCEventsDTO{Events: make([]dto.KYCEventDTO, 0, len(events))}
+	for _, event := range events {
+		response.Events = append(response.Events, dto.KYCEventDTO{
+			ID:           event.ID,
+			SubmissionID: event.SubmissionID,
+			ActorID:      event.ActorID,
+			Action:       string(event.Action),
+			FromLevel:    string(event.FromLevel),
+			ToLevel:      string(event.ToLevel),
+			Note:         event.Note,
+			CreatedAt:    event.CreatedAt,
+		})
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
+
+func kycSubmissionIDParam(respWriter http.ResponseWriter, req *http.Request) (int, bool) {
+	submissionID, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil || submissionID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid kyc submission id")
+		return 0, false
+	}
+	return submissionID, true
+}
+
+func kycSubmissionResponse(submission *models.KYCSubmission) dto.KYCSubmissionDTO {
+	response := dto.KYCSubmissionDTO{
+		ID:                  submission.ID,
+		UserID:              submission.UserID,
+		Level:               string(submission.Level),
+		DocumentType:        submission.DocumentType,
+		DocumentNumberLast4: submission.DocumentNumberLast4,
+		IssuingCountry:      submission.IssuingCountry,
+		Status:              string(submission.Status),
+		ReviewerID:          submission.ReviewerID,
+		ReviewNote:          submission.ReviewNote,
+		ReviewedAt:          submission.ReviewedAt,
+		CreatedAt:           submission.CreatedAt,
+	}
+	if submission.DocumentExpiresOn != nil {
+		response.ExpiresOn = submission.DocumentExpiresOn.Format(time.DateOnly)
+	}
+	return response
+}
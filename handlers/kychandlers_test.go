@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/services"
+)
+
+func TestKYCHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	kycHandlers := NewKYCHandlers(services.NewKYCService(db))
+
+	newUser := func(t *testing.T, email string) (int, string) {
+		userID, err := userService.CreateUser(&models.User{EmailID: email, Password: "password"})
+		assert.NoError(t, err)
+		IDToken, err := authService.AuthenticateUser(email, "password")
+		assert.NoError(t, err)
+		return userID, IDToken
+	}
+
+	serve := func(handler http.HandlerFunc, method, url, IDToken string, vars map[string]string, payload interface{}) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+		req = mux.SetURLVars(authenticated(authService, req, IDToken), vars)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	_, err := userService.CreateUser(&models.User{EmailID: "testkycreviewer@example.com", Password: "password", Role: models.RoleSupport})
+	assert.NoError(t, err)
+	reviewerToken, err := authService.AuthenticateUser("testkycreviewer@example.com", "password")
+	assert.NoError(t, err)
+	principal, err := authService.Authenticate(reviewerToken)
+	assert.NoError(t, err)
+	assert.True(t, principal.HasScope(services.ScopeKYCReview))
+
+	t.Run("SubmitKYCHandler and ApproveKYCSubmissionHandler to verify a user at the level asked for", func(t *testing.T) {
+		userID, IDToken := newUser(t, "testkycsubmit@example.com")
+		principal, err := authService.Authenticate(IDToken)
+		assert.NoError(t, err)
+		assert.False(t, principal.HasScope(services.ScopeKYCReview))
+
+		payload := dto.SubmitKYCDTO{Level: "minimal", DocumentType: "passport", DocumentNumber: "K1234567", IssuingCountry: "IN", ExpiresOn: "2031-05-31"}
+		recorder := serve(kycHandlers.SubmitKYCHandler, "POST", "/user/kyc", IDToken, nil, payload)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		var submission dto.KYCSubmissionDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&submission))
+		assert.Equal(t, "4567", submission.DocumentNumberLast4)
+		assert.Equal(t, "2031-05-31", submission.ExpiresOn)
+		assert.NotContains(t, recorder.Body.String(), "K1234567")
+
+		recorder = serve(kycHandlers.SubmitKYCHandler, "POST", "/user/kyc", IDToken, nil, payload)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+
+		recorder = serve(kycHandlers.ListKYCSubmissionsHandler, "GET", fmt.Sprintf("/admin/kyc/submissions?after=%d&limit=1", submission.ID-1), reviewerToken, nil, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var queue dto.KYCSubmissionsDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&queue))
+		assert.Len(t, queue.Submissions, 1)
+		assert.Equal(t, submission.ID, queue.NextAfter)
+
+		vars := map[string]string{"id": fmt.Sprint(submission.ID)}
+		recorder = serve(kycHandlers.ApproveKYCSubmissionHandler, "POST", "/admin/kyc/submissions/approve", IDToken, vars, nil)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		recorder = serve(kycHandlers.ApproveKYCSubmissionHandler, "POST", "/admin/kyc/submissions/approve", reviewerToken, vars, dto.ApproveKYCDTO{Note: "ok"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = serve(kycHandlers.GetKYCStatusHandler, "GET", "/user/kyc", IDToken, nil, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var status dto.KYCStatusDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+		assert.Equal(t, "minimal", status.Level)
+		assert.Equal(t, "approved", status.Submissions[0].Status)
+
+		recorder = serve(kycHandlers.ListKYCEventsHandler, "GET", "/admin/kyc/users/events", reviewerToken, map[string]string{"id": fmt.Sprint(userID)}, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var events dto.KYCEventsDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&events))
+		assert.Len(t, events.Events, 2)
+		assert.Equal(t, "approved", events.Events[1].Action)
+	})
+
+	t.Run("RejectKYCSubmissionHandler to require a reason", func(t *testing.T) {
+		_, IDToken := newUser(t, "testkycreject@example.com")
+		recorder := serve(kycHandlers.SubmitKYCHandler, "POST", "/user/kyc", IDToken, nil, dto.SubmitKYCDTO{Level: "full", DocumentType: "passport", DocumentNumber: "K7654321", IssuingCountry: "IN"})
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		var submission dto.KYCSubmissionDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&submission))
+
+		vars := map[string]string{"id": fmt.Sprint(submission.ID)}
+		recorder = serve(kycHandlers.RejectKYCSubmissionHandler, "POST", "/admin/kyc/submissions/reject", reviewerToken, vars, dto.RejectKYCDTO{})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(kycHandlers.RejectKYCSubmissionHandler, "POST", "/admin/kyc/submissions/reject", reviewerToken, vars, dto.RejectKYCDTO{Reason: "blurry scan"})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&submission))
+		assert.Equal(t, "rejected", submission.Status)
+		assert.Equal(t, "blurry scan", submission.ReviewNote)
+	})
+
+	t.Run("SubmitKYCHandler to return 400 BadRequest for an unknown level or a malformed expiry", func(t *testing.T) {
+		_, IDToken := newUser(t, "testkycinvalid@example.com")
+		recorder := serve(kycHandlers.SubmitKYCHandler, "POST", "/user/kyc", IDToken, nil, dto.SubmitKYCDTO{Level: "gold", DocumentType: "passport", DocumentNumber: "K7654321", IssuingCountry: "IN"})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		recorder = serve(kycHandlers.SubmitKYCHandler, "POST", "/user/kyc", IDToken, nil, dto.SubmitKYCDTO{Level: "full", DocumentType: "passport", DocumentNumber: "K7654321", IssuingCountry: "IN", ExpiresOn: "31/05/2031"})
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
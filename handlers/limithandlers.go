@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+
+	"github.com/shopspring/decimal"
+)
+
+func (wh *WalletHandlers) GetLimitsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	summary, err := wh.walletService.GetLimits(principal.UserID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	response := dto.LimitsDTO{
+		KYCLevel: string(summary.KYCLevel),
+		Balance:  &money.Money{Amount: summary.Balance, Currency: summary.Currency},
+		Limits:   make([]dto.TransactionLimitsDTO, 0, len(summary.Statuses)),
+	}
+	if summary.MaxBalance.IsPositive() {
+		response.MaxBalance = &money.Money{Amount: summary.MaxBalance, Currency: summary.Currency}
+	}
+	for _, status := range summary.Statuses {
+		response.Limits = append(response.Limits, limitsResponse(status))
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(response)
+}
+
+func limitsResponse(status *services.LimitStatus) dto.TransactionLimitsDTO {
+	amount := func(value decimal.Decimal) *money.Money {
+		return &money.Money{Amount: value, Currency: status.Currency}
+	}
+
+	response := dto.TransactionLimitsDTO{TransactionType: string(status.TransactionType)}
+	limits := status.Limits
+	if limits.MinAmount.IsPositive() {
+		response.MinAmount = amount(limits.MinAmount)
+	}
+	if limits.MaxAmount.IsPositive() {
+		response.MaxAmount = amount(limits.MaxAmount)
+	}
+	if remaining := status.RemainingToday(); remaining != nil {
+		response.Daily = &dto.AllowanceDTO{
+			Limit:     amount(limits.DailyAmount),
+			Used:      amount(status.UsedToday),
+			Remaining: amount(*remaining),
+			ResetsAt:  status.DayResetsAt,
+		}
+	}
+	if remaining := status.RemainingThisMonth(); remaining != nil {
+		response.Monthly = &dto.AllowanceDTO{
+			Limit:     amount(limits.MonthlyAmount),
+			Used:      amount(status.UsedThisMonth),
+			Remaining: amount(*remaining),
+			ResetsAt:  status.MonthResetsAt,
+		}
+	}
+	if remaining := status.RemainingThisHour(); remaining >= 0 {
+		response.Hourly = &dto.CountAllowanceDTO{
+			Limit:     limits.MaxPerHour,
+			Used:      status.CountLastHour,
+			Remaining: remaining,
+		}
+	}
+	return response
+}
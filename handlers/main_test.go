@@ -2,30 +2,50 @@ package handlers
 
 import (
 	"log"
-	"nikwallet/config"
+	"net/http"
 	"nikwallet/repository"
+	"nikwallet/services"
+	"nikwallet/services/password"
 	"os"
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-var db *repository.PostgreSQL
+var db repository.Store
 
-func TestMain(m *testing.M) {
-	c, err := config.LoadConfig()
+var tokenOptions services.TokenOptions
 
+// passwords uses the cheapest bcrypt cost to keep the tests fast.
+var passwords = password.NewManager(password.NewBcryptHasher(bcrypt.MinCost))
+
+func TestMain(m *testing.M) {
+	store, closeStore, err := repository.OpenTestStore()
 	if err != nil {
-		log.Fatalln("Failed at config", err)
+		log.Fatalln("failed to open test store:", err)
 	}
+	db = store
 
-	db = &repository.PostgreSQL{}
-	err = db.Connect(&c)
+	keys, err := services.NewEphemeralKeySet()
 	if err != nil {
-		log.Panic("failed to connect to database:", err)
+		log.Fatalln("failed to generate signing key:", err)
 	}
+	tokenOptions = services.TokenOptions{Keys: keys}
 
 	exitCode := m.Run()
 
-	db.Close()
+	closeStore()
 
 	os.Exit(exitCode)
 }
+
+// authenticated stands in for the router's authentication middleware when a
+// test calls a handler directly. An invalid token leaves the request
+// unauthenticated.
+func authenticated(authService *services.AuthService, req *http.Request, IDToken string) *http.Request {
+	principal, err := authService.Authenticate(IDToken)
+	if err != nil {
+		return req
+	}
+	return req.WithContext(services.ContextWithPrincipal(req.Context(), principal))
+}
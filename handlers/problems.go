@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/logger"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:nikwallet:problem:"
+)
+
+// Codes of problems raised by the HTTP layer itself rather than by a
+// service.
+const (
+	CodeInvalidRequest    = "invalid_request"
+	CodeValidationFailed  = "validation_failed"
+	CodeRequestTooLarge   = "request_too_large"
+	CodeUnauthorized      = "unauthorized"
+	CodeInsufficientScope = "insufficient_scope"
+	CodeInternalError     = "internal_error"
+)
+
+type problemMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// problemMappings is checked in order with errors.Is, so an error wrapping
+// several sentinels is reported as the first one listed.
+var problemMappings = []problemMapping{
+	{services.ErrUserExists, http.StatusConflict, "user_exists"},
+	{services.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{services.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{services.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token"},
+	{services.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{services.ErrTokenRevoked, http.StatusUnauthorized, "token_revoked"},
+	{services.ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+	{services.ErrAccountFrozen, http.StatusForbidden, "account_frozen"},
+
+	{services.ErrInvalidRole, http.StatusBadRequest, "invalid_role"},
+	{services.ErrReasonRequired, http.StatusBadRequest, "reason_required"},
+	{services.ErrSelfAdmin, http.StatusForbidden, "self_admin"},
+	{services.ErrOutranked, http.StatusForbidden, "outranked"},
+	{services.ErrAlreadyFrozen, http.StatusConflict, "account_already_frozen"},
+	{services.ErrNotFrozen, http.StatusConflict, "account_not_frozen"},
+	{services.ErrInvalidAdjustment, http.StatusBadRequest, "invalid_adjustment"},
+
+	{services.ErrWalletNotFound, http.StatusNotFound, "wallet_not_found"},
+	{services.ErrWalletExists, http.StatusConflict, "wallet_exists"},
+	{services.ErrInvalidWalletName, http.StatusBadRequest, "invalid_wallet_name"},
+	{services.ErrSameWallet, http.StatusBadRequest, "same_wallet"},
+	{services.ErrInvalidLimit, http.StatusBadRequest, "invalid_limit"},
+	{services.ErrInvalidCursor, http.StatusBadRequest, "invalid_cursor"},
+	{services.ErrInvalidStatementPeriod, http.StatusBadRequest, "invalid_statement_period"},
+
+	{services.ErrHoldNotFound, http.StatusNotFound, "hold_not_found"},
+	{services.ErrHoldNotActive, http.StatusConflict, "hold_not_active"},
+	{services.ErrCaptureExceedsHold, http.StatusUnprocessableEntity, "capture_exceeds_hold"},
+	{services.ErrInvalidHoldTTL, http.StatusBadRequest, "invalid_hold_ttl"},
+
+	{services.ErrEntryNotFound, http.StatusNotFound, "entry_not_found"},
+	{services.ErrNotRefundable, http.StatusUnprocessableEntity, "not_refundable"},
+	{services.ErrRefundExceedsOriginal, http.StatusUnprocessableEntity, "refund_exceeds_original"},
+
+	{services.ErrLimitExceeded, http.StatusUnprocessableEntity, "limit_exceeded"},
+	{services.ErrInvalidTransactionLimit, http.StatusBadRequest, "invalid_transaction_limit"},
+	{services.ErrBalanceCapExceeded, http.StatusUnprocessableEntity, "balance_cap_exceeded"},
+
+	{services.ErrKYCSubmissionNotFound, http.StatusNotFound, "kyc_submission_not_found"},
+	{services.ErrKYCSubmissionPending, http.StatusConflict, "kyc_submission_pending"},
+	{services.ErrKYCSubmissionReviewed, http.StatusConflict, "kyc_submission_reviewed"},
+	{services.ErrKYCSelfReview, http.StatusForbidden, "kyc_self_review"},
+	{services.ErrInvalidKYCLevel, http.StatusBadRequest, "invalid_kyc_level"},
+
+	{money.ErrInsufficientFunds, http.StatusUnprocessableEntity, "insufficient_funds"},
+	{money.ErrCurrencyMismatch, http.StatusUnprocessableEntity, "currency_mismatch"},
+	{money.ErrNegativeAmount, http.StatusBadRequest, "negative_amount"},
+	{money.ErrTooPrecise, http.StatusBadRequest, "amount_too_precise"},
+	{money.ErrUnsupportedCurrency, http.StatusBadRequest, "unsupported_currency"},
+
+	{services.ErrSameCurrency, http.StatusBadRequest, "same_currency"},
+	{services.ErrExchangeRateUnavailable, http.StatusServiceUnavailable, "exchange_rate_unavailable"},
+	{services.ErrFXQuoteNotFound, http.StatusNotFound, "fx_quote_not_found"},
+	{services.ErrFXQuoteExpired, http.StatusConflict, "fx_quote_expired"},
+	{services.ErrFXQuoteMismatch, http.StatusBadRequest, "fx_quote_mismatch"},
+
+	{services.ErrIdempotencyKeyMismatch, http.StatusUnprocessableEntity, "idempotency_key_mismatch"},
+	{services.ErrIdempotencyKeyInProgress, http.StatusConflict, "idempotency_key_in_progress"},
+}
+
+// WriteProblem writes an application/problem+json response.
+func WriteProblem(respWriter http.ResponseWriter, status int, code string, detail string) {
+	writeProblem(respWriter, newProblem(status, code, detail))
+}
+
+// WriteError reports err as the problem of the first sentinel it wraps, and
+// a *dto.ValidationError as a 400 listing the rejected fields. Errors without
+// a mapping are logged and answered with a bare 500, so that internals never
+// leak to the client.
+func WriteError(respWriter http.ResponseWriter, err error) {
+	var validationErr *dto.ValidationError
+	if errors.As(err, &validationErr) {
+		problem := newProblem(http.StatusBadRequest, CodeValidationFailed, "request has invalid fields")
+		problem.Errors = validationErr.Fields
+		writeProblem(respWriter, problem)
+		return
+	}
+
+	for _, mapping := range problemMappings {
+		if errors.Is(err, mapping.err) {
+			WriteProblem(respWriter, mapping.status, mapping.code, err.Error())
+			return
+		}
+	}
+
+	logger.Errorf("unhandled error: %s", err)
+	WriteProblem(respWriter, http.StatusInternalServerError, CodeInternalError, "")
+}
+
+func newProblem(status int, code string, detail string) dto.ProblemDTO {
+	return dto.ProblemDTO{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func writeProblem(respWriter http.ResponseWriter, problem dto.ProblemDTO) {
+	respWriter.Header().Set("Content-Type", ProblemContentType)
+	respWriter.WriteHeader(problem.Status)
+	json.NewEncoder(respWriter).Encode(problem)
+}
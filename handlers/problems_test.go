@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+func TestWriteError(t *testing.T) {
+	writeError := func(err error) (*httptest.ResponseRecorder, dto.ProblemDTO) {
+		recorder := httptest.NewRecorder()
+		WriteError(recorder, err)
+
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		return recorder, problem
+	}
+
+	t.Run("WriteError method to map a wrapped sentinel to its status and code", func(t *testing.T) {
+		cases := []struct {
+			err    error
+			status int
+			code   string
+		}{
+			{fmt.Errorf("failed to withdraw: %w", money.ErrInsufficientFunds), http.StatusUnprocessableEntity, "insufficient_funds"},
+			{fmt.Errorf("%w: 42", services.ErrWalletNotFound), http.StatusNotFound, "wallet_not_found"},
+			{services.ErrUserExists, http.StatusConflict, "user_exists"},
+			{fmt.Errorf("cannot add EUR to USD: %w", money.ErrCurrencyMismatch), http.StatusUnprocessableEntity, "currency_mismatch"},
+		}
+		for _, tc := range cases {
+			recorder, problem := writeError(tc.err)
+			assert.Equal(t, tc.status, recorder.Code)
+			assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
+			assert.Equal(t, dto.ProblemDTO{
+				Type:   problemTypePrefix + tc.code,
+				Title:  http.StatusText(tc.status),
+				Status: tc.status,
+				Detail: tc.err.Error(),
+				Code:   tc.code,
+			}, problem)
+		}
+	})
+
+	t.Run("WriteError method to hide unmapped errors behind a 500 internal_error", func(t *testing.T) {
+		recorder, problem := writeError(errors.New("pq: connection refused"))
+		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, CodeInternalError, problem.Code)
+		assert.Empty(t, problem.Detail)
+	})
+}
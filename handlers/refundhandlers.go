@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"nikwallet/handlers/dto"
+
+	"github.com/gorilla/mux"
+)
+
+func (wh *WalletHandlers) RefundTransferHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	entryID, ok := entryIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.RefundDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	entry, err := wh.walletService.In(req.Context()).RefundTransfer(principal.UserID, entryID, *payload.Amount)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(respWriter).Encode(entry)
+}
+
+func (wh *WalletHandlers) ReverseTransferHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	entryID, ok := entryIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	entry, err := wh.walletService.In(req.Context()).ReverseTransfer(principal.UserID, entryID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusCreated)
+	json.NewEncoder(respWriter).Encode(entry)
+}
+
+func entryIDParam(respWriter http.ResponseWriter, req *http.Request) (int, bool) {
+	entryID, err := strconv.Atoi(mux.Vars(req)["id"])
+	if err != nil || entryID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid entry id")
+		return 0, false
+	}
+	return entryID, true
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nikwallet/handlers/dto"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"nikwallet/services"
+)
+
+// Formats of GET /wallet/statement.
+const (
+	StatementFormatCSV   = "csv"
+	StatementFormatJSONL = "jsonl"
+	StatementFormatOFX   = "ofx"
+)
+
+// statementResponse holds back the status line and headers of a statement
+// until its first byte is ready, so that an error found before then can
+// still be answered with a problem.
+type statementResponse struct {
+	respWriter  http.ResponseWriter
+	out         *bufio.Writer
+	contentType string
+	extension   string
+	started     bool
+}
+
+func (r *statementResponse) start(statement *services.Statement) {
+	filename := fmt.Sprintf("statement-%d-%s-%s.%s", statement.Wallet.ID,
+		statement.From.UTC().Format("20060102"), statement.To.UTC().Format("20060102"), r.extension)
+	r.respWriter.Header().Set("Content-Type", r.contentType)
+	r.respWriter.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	r.respWriter.WriteHeader(http.StatusOK)
+	r.out = bufio.NewWriter(r.respWriter)
+	r.started = true
+}
+
+// newStatementWriter returns the writer of format, or nil for an unknown one.
+func newStatementWriter(format string, respWriter http.ResponseWriter) (services.StatementWriter, *statementResponse) {
+	switch format {
+	case StatementFormatCSV:
+		response := &statementResponse{respWriter: respWriter, contentType: "text/csv; charset=utf-8", extension: "csv"}
+		return &csvStatementWriter{statementResponse: response}, response
+	case StatementFormatJSONL:
+		response := &statementResponse{respWriter: respWriter, contentType: "application/x-ndjson", extension: "jsonl"}
+		return &jsonlStatementWriter{statementResponse: response}, response
+	case StatementFormatOFX:
+		response := &statementResponse{respWriter: respWriter, contentType: "application/x-ofx", extension: "ofx"}
+		return &ofxStatementWriter{statementResponse: response}, response
+	}
+	return nil, nil
+}
+
+// statementAmount formats an amount with the minor units of its currency.
+func statementAmount(amount money.Money) string {
+	return amount.Amount.StringFixed(amount.Currency.MinorUnits())
+}
+
+func signedStatementAmount(posting *models.Posting) string {
+	return statementAmount(money.Money{Amount: posting.SignedAmount(), Currency: posting.Amount.Currency})
+}
+
+// csvStatementWriter writes a single table: an opening balance row, a row per
+// posting and a closing balance row, so that the statement opens as is in a
+// spreadsheet.
+type csvStatementWriter struct {
+	*statementResponse
+	csv *csv.Writer
+}
+
+var csvStatementColumns = []string{
+	"date", "record", "entry_id", "posting_id", "transaction_type", "direction",
+	"amount", "currency", "counterparty_email", "balance",
+}
+
+func (w *csvStatementWriter) WriteHeader(statement *services.Statement) error {
+	w.start(statement)
+	w.csv = csv.NewWriter(w.out)
+	if err := w.csv.Write(csvStatementColumns); err != nil {
+		return err
+	}
+	return w.writeBalance(dto.StatementRecordOpeningBalance, statement.From, statement.OpeningBalance)
+}
+
+func (w *csvStatementWriter) WriteLine(line *services.StatementLine) error {
+	posting := line.Posting
+	return w.csv.Write([]string{
+		posting.CreatedAt.UTC().Format(time.RFC3339),
+		dto.StatementRecordEntry,
+		strconv.Itoa(posting.JournalEntryID),
+		strconv.Itoa(posting.ID),
+		posting.TransactionType,
+		string(posting.Direction),
+		signedStatementAmount(posting),
+		string(posting.Amount.Currency),
+		line.CounterpartyEmail,
+		statementAmount(line.Balance),
+	})
+}
+
+func (w *csvStatementWriter) WriteFooter(statement *services.Statement) error {
+	if err := w.writeBalance(dto.StatementRecordClosingBalance, statement.To, statement.ClosingBalance); err != nil {
+		return err
+	}
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+func (w *csvStatementWriter) writeBalance(record string, at time.Time, balance money.Money) error {
+	return w.csv.Write([]string{
+		at.UTC().Format(time.RFC3339), record, "", "", "", "", "",
+		string(balance.Currency), "", statementAmount(balance),
+	})
+}
+
+// jsonlStatementWriter writes one dto.StatementRecordDTO per line.
+type jsonlStatementWriter struct {
+	*statementResponse
+	walletID int
+	encoder  *json.Encoder
+}
+
+func (w *jsonlStatementWriter) WriteHeader(statement *services.Statement) error {
+	w.start(statement)
+	w.walletID = statement.Wallet.ID
+	w.encoder = json.NewEncoder(w.out)
+	return w.encoder.Encode(dto.StatementRecordDTO{
+		Record:   dto.StatementRecordOpeningBalance,
+		At:       statement.From,
+		WalletID: w.walletID,
+		Currency: string(statement.OpeningBalance.Currency),
+		Balance:  statementAmount(statement.OpeningBalance),
+	})
+}
+
+func (w *jsonlStatementWriter) WriteLine(line *services.StatementLine) error {
+	posting := line.Posting
+	return w.encoder.Encode(dto.StatementRecordDTO{
+		Record:            dto.StatementRecordEntry,
+		At:                posting.CreatedAt,
+		WalletID:          w.walletID,
+		Currency:          string(posting.Amount.Currency),
+		EntryID:           posting.JournalEntryID,
+		PostingID:         posting.ID,
+		TransactionType:   posting.TransactionType,
+		Direction:         string(posting.Direction),
+		Amount:            signedStatementAmount(posting),
+		CounterpartyEmail: line.CounterpartyEmail,
+		Balance:           statementAmount(line.Balance),
+	})
+}
+
+func (w *jsonlStatementWriter) WriteFooter(statement *services.Statement) error {
+	err := w.encoder.Encode(dto.StatementRecordDTO{
+		Record:   dto.StatementRecordClosingBalance,
+		At:       statement.To,
+		WalletID: w.walletID,
+		Currency: string(statement.ClosingBalance.Currency),
+		Balance:  statementAmount(statement.ClosingBalance),
+	})
+	if err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
+
+// ofxStatementWriter writes an OFX 1.0.2 bank statement, the SGML dialect
+// that personal-finance tools import most widely. OFX has no opening balance
+// element: importers derive it from the ledger balance and the transactions.
+type ofxStatementWriter struct {
+	*statementResponse
+}
+
+const ofxHeader = "OFXHEADER:100\r\nDATA:OFXSGML\r\nVERSION:102\r\nSECURITY:NONE\r\nENCODING:USASCII\r\n" +
+	"CHARSET:1252\r\nCOMPRESSION:NONE\r\nOLDFILEUID:NONE\r\nNEWFILEUID:NONE\r\n\r\n"
+
+// ofxBankID identifies the platform as the bank of every wallet account.
+const ofxBankID = "NIKWALLET"
+
+// ofxNameLength is the longest NAME the OFX 1.0.2 specification allows.
+const ofxNameLength = 32
+
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// ofxName escapes name and cuts it to ofxNameLength characters, backing off
+// to before an entity that would otherwise be cut in half.
+func ofxName(name string) string {
+	escaped := []rune(ofxEscaper.Replace(name))
+	if len(escaped) <= ofxNameLength {
+		return string(escaped)
+	}
+
+	cut := escaped[:ofxNameLength]
+	if amp := strings.LastIndex(string(cut), "&"); amp >= 0 && !strings.Contains(string(cut)[amp:], ";") {
+		return string(cut)[:amp]
+	}
+	return string(cut)
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+func (w *ofxStatementWriter) WriteHeader(statement *services.Statement) error {
+	w.start(statement)
+	_, err := fmt.Fprintf(w.out, "%s<OFX>\r\n"+
+		"<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>%s<LANGUAGE>ENG</SONRS></SIGNONMSGSRSV1>\r\n"+
+		"<BANKMSGSRSV1><STMTTRNRS><TRNUID>0<STATUS><CODE>0<SEVERITY>INFO</STATUS>\r\n"+
+		"<STMTRS><CURDEF>%s\r\n"+
+		"<BANKACCTFROM><BANKID>%s<ACCTID>%d<ACCTTYPE>CHECKING</BANKACCTFROM>\r\n"+
+		"<BANKTRANLIST><DTSTART>%s<DTEND>%s\r\n",
+		ofxHeader, ofxTime(time.Now()), statement.Wallet.Money.Currency,
+		ofxBankID, statement.Wallet.ID, ofxTime(statement.From), ofxTime(statement.To))
+	return err
+}
+
+func (w *ofxStatementWriter) WriteLine(line *services.StatementLine) error {
+	posting := line.Posting
+
+	transactionType := "CREDIT"
+	if posting.Direction == models.PostingDirectionDebit {
+		transactionType = "DEBIT"
+	}
+	if posting.TransactionType == string(models.TransactionTypeTransfer) {
+		transactionType = "XFER"
+	}
+
+	name := line.CounterpartyEmail
+	if name == "" {
+		name = posting.TransactionType
+	}
+
+	_, err := fmt.Fprintf(w.out, "<STMTTRN><TRNTYPE>%s<DTPOSTED>%s<TRNAMT>%s<FITID>%d<NAME>%s<MEMO>%s</STMTTRN>\r\n",
+		transactionType, ofxTime(posting.CreatedAt), signedStatementAmount(posting), posting.ID,
+		ofxName(name), ofxEscaper.Replace(posting.TransactionType))
+	return err
+}
+
+func (w *ofxStatementWriter) WriteFooter(statement *services.Statement) error {
+	_, err := fmt.Fprintf(w.out, "</BANKTRANLIST>\r\n"+
+		"<LEDGERBAL><BALAMT>%s<DTASOF>%s</LEDGERBAL>\r\n"+
+		"</STMTRS></STMTTRNRS></BANKMSGSRSV1>\r\n</OFX>\r\n",
+		statementAmount(statement.ClosingBalance), ofxTime(statement.To))
+	if err != nil {
+		return err
+	}
+	return w.out.Flush()
+}
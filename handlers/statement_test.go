@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOFXName(t *testing.T) {
+	t.Run("ofxName to escape a short name and keep it whole", func(t *testing.T) {
+		assert.Equal(t, "tom&amp;jerry@example.com", ofxName("tom&jerry@example.com"))
+	})
+
+	t.Run("ofxName to cut a long name by characters, not bytes", func(t *testing.T) {
+		name := ofxName(strings.Repeat("é", 40))
+		assert.True(t, utf8.ValidString(name))
+		assert.Equal(t, ofxNameLength, utf8.RuneCountInString(name))
+	})
+
+	t.Run("ofxName to drop an entity that would be cut in half", func(t *testing.T) {
+		name := ofxName(strings.Repeat("a", 29) + "&bcdef")
+		assert.Equal(t, strings.Repeat("a", 29), name)
+
+		name = ofxName(strings.Repeat("a", 27) + "&bcdef")
+		assert.Equal(t, strings.Repeat("a", 27)+"&amp;", name)
+	})
+}
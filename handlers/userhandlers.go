@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
+	"time"
 
 	"nikwallet/handlers/dto"
 	"nikwallet/repository/models"
@@ -24,8 +26,7 @@ func NewUserHandlers(userService *services.UserService, authService *services.Au
 func (uh *UserHandlers) SignupHandler(respWriter http.ResponseWriter, req *http.Request) {
 	var userData dto.UserSignupRequestDTO
 
-	if err := json.NewDecoder(req.Body).Decode(&userData); err != nil {
-		http.Error(respWriter, err.Error(), http.StatusBadRequest)
+	if !decodeRequest(respWriter, req, &userData) {
 		return
 	}
 
@@ -36,7 +37,7 @@ func (uh *UserHandlers) SignupHandler(respWriter http.ResponseWriter, req *http.
 
 	createdUserID, err := uh.userService.CreateUser(&user)
 	if err != nil {
-		http.Error(respWriter, err.Error(), http.StatusInternalServerError)
+		WriteError(respWriter, err)
 		return
 	}
 
@@ -54,21 +55,117 @@ func (uh *UserHandlers) SignupHandler(respWriter http.ResponseWriter, req *http.
 func (uh *UserHandlers) SigninHandler(respWriter http.ResponseWriter, req *http.Request) {
 	var userData dto.UserSigninRequestDTO
 
-	if err := json.NewDecoder(req.Body).Decode(&userData); err != nil {
-		http.Error(respWriter, err.Error(), http.StatusBadRequest)
+	if !decodeRequest(respWriter, req, &userData) {
 		return
 	}
 
-	tokenString, err := uh.authService.AuthenticateUser(userData.Email, userData.Password)
+	tokenPair, err := uh.authService.SignIn(userData.Email, userData.Password, clientInfo(req))
 	if err != nil {
-		http.Error(respWriter, err.Error(), http.StatusUnauthorized)
+		WriteError(respWriter, err)
 		return
 	}
 
-	responseDTO := dto.UserSigninResponseDTO{
-		IDToken: tokenString,
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(signinResponse(tokenPair))
+}
+
+func (uh *UserHandlers) RefreshTokenHandler(respWriter http.ResponseWriter, req *http.Request) {
+	var payload dto.TokenRefreshRequestDTO
+
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	tokenPair, err := uh.authService.RefreshTokens(payload.RefreshToken, clientInfo(req))
+	if err != nil {
+		WriteError(respWriter, err)
+		return
 	}
 
 	respWriter.WriteHeader(http.StatusOK)
-	json.NewEncoder(respWriter).Encode(responseDTO)
+	json.NewEncoder(respWriter).Encode(signinResponse(tokenPair))
+}
+
+func (uh *UserHandlers) LogoutHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	if err := uh.authService.Logout(principal.Claims); err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.Response{Message: "logged out"})
+}
+
+func (uh *UserHandlers) LogoutAllHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	if err := uh.authService.LogoutAllSessions(principal.Claims); err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.Response{Message: "logged out of all sessions"})
+}
+
+func (uh *UserHandlers) ListSessionsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	sessions, err := uh.authService.ListSessions(principal.UserID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	sessionDTOs := make([]dto.SessionDTO, 0, len(sessions))
+	for _, session := range sessions {
+		sessionDTOs = append(sessionDTOs, dto.SessionDTO{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.ID == principal.SessionID,
+		})
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(sessionDTOs)
+}
+
+func (uh *UserHandlers) JWKSHandler(respWriter http.ResponseWriter, req *http.Request) {
+	respWriter.Header().Set("Content-Type", "application/json")
+	respWriter.Header().Set("Cache-Control", "public, max-age=300")
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(uh.authService.JWKS())
+}
+
+func signinResponse(tokenPair *services.TokenPair) dto.UserSigninResponseDTO {
+	return dto.UserSigninResponseDTO{
+		IDToken:      tokenPair.AccessToken,
+		RefreshToken: tokenPair.RefreshToken,
+		ExpiresIn:    int(time.Until(tokenPair.AccessTokenExpiresAt).Seconds()),
+	}
+}
+
+func clientInfo(req *http.Request) services.ClientInfo {
+	ipAddress, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ipAddress = req.RemoteAddr
+	}
+	return services.ClientInfo{
+		UserAgent: req.UserAgent(),
+		IPAddress: ipAddress,
+	}
 }
@@ -9,19 +9,20 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"nikwallet/handlers/dto"
 	"nikwallet/repository/models"
 	"nikwallet/services"
 )
 
 func TestUserHandlers(t *testing.T) {
 
-	userService := services.NewUserService(db.DB)
-	authService := services.NewAuthService(db.DB)
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
 	userHandlers := NewUserHandlers(userService, authService)
 
 	t.Run("SignupHandler to return 201 StatusCreated for valid user creation", func(t *testing.T) {
 		signupRequest := map[string]interface{}{
-			"email_id": "testhello123@example.com",
+			"email":    "testhello123@example.com",
 			"password": "password123",
 		}
 
@@ -45,7 +46,7 @@ func TestUserHandlers(t *testing.T) {
 		assert.Contains(t, responseBody, "id_token")
 	})
 
-	t.Run("SignupHandler to return 500 InternalServerError for duplicate user entry", func(t *testing.T) {
+	t.Run("SignupHandler to return 409 Conflict for duplicate user entry", func(t *testing.T) {
 		newUser := &models.User{
 			EmailID:  "nikwallethello@example.com",
 			Password: "password",
@@ -55,7 +56,7 @@ func TestUserHandlers(t *testing.T) {
 		assert.NoError(t, err)
 
 		duplicateUser := map[string]interface{}{
-			"email_id": "nikwallethello@example.com",
+			"email":    "nikwallethello@example.com",
 			"password": "password4561",
 		}
 
@@ -69,12 +70,20 @@ func TestUserHandlers(t *testing.T) {
 
 		http.HandlerFunc(userHandlers.SignupHandler).ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+
+		var problem dto.ProblemDTO
+		err = json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, "user_exists", problem.Code)
 	})
 
 	t.Run("SignInHandler to return status 200 StatusOk for successful user login", func(t *testing.T) {
+		_, err := userService.CreateUser(&models.User{EmailID: "testhello321@example.com", Password: "password123"})
+		assert.NoError(t, err)
+
 		signinRequest := map[string]interface{}{
-			"email_id": "testhello321@example.com",
+			"email":    "testhello321@example.com",
 			"password": "password123",
 		}
 
@@ -99,7 +108,7 @@ func TestUserHandlers(t *testing.T) {
 
 	t.Run("SigninHandler to return status 401 Unauthorized for invalid user credentials", func(t *testing.T) {
 		invalidSigninRequest := map[string]interface{}{
-			"email_id": "emaildoesnotexits@example.com",
+			"email":    "emaildoesnotexits@example.com",
 			"password": "wrongpassword",
 		}
 
@@ -115,4 +124,133 @@ func TestUserHandlers(t *testing.T) {
 
 		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
 	})
+
+	t.Run("JWKSHandler to return 200 StatusOK with the public signing keys", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		assert.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+
+		http.HandlerFunc(userHandlers.JWKSHandler).ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+		var jwks services.JSONWebKeySet
+		err = json.NewDecoder(recorder.Body).Decode(&jwks)
+		assert.NoError(t, err)
+		assert.Len(t, jwks.Keys, 1)
+		assert.Equal(t, tokenOptions.Keys.Active().ID, jwks.Keys[0].KeyID)
+		assert.Equal(t, "ES256", jwks.Keys[0].Algorithm)
+	})
+}
+
+func TestSessionHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	userHandlers := NewUserHandlers(userService, authService)
+
+	_, err := userService.CreateUser(&models.User{EmailID: "session_handlers@example.com", Password: "password123"})
+	assert.NoError(t, err)
+
+	signin := func(t *testing.T, userAgent string) dto.UserSigninResponseDTO {
+		reqBody, _ := json.Marshal(map[string]interface{}{
+			"email":    "session_handlers@example.com",
+			"password": "password123",
+		})
+		req, _ := http.NewRequest("POST", "/user/signin", bytes.NewReader(reqBody))
+		req.Header.Set("User-Agent", userAgent)
+
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.SigninHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var response dto.UserSigninResponseDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+		return response
+	}
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(dto.TokenRefreshRequestDTO{RefreshToken: refreshToken})
+		req, _ := http.NewRequest("POST", "/user/token/refresh", bytes.NewReader(reqBody))
+
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.RefreshTokenHandler).ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("SigninHandler to return a refresh token with the access token", func(t *testing.T) {
+		response := signin(t, "test-agent")
+		assert.NotEmpty(t, response.IDToken)
+		assert.NotEmpty(t, response.RefreshToken)
+		assert.Greater(t, response.ExpiresIn, 0)
+	})
+
+	t.Run("RefreshTokenHandler to return 200 StatusOK once and 401 Unauthorized on reuse", func(t *testing.T) {
+		response := signin(t, "test-agent")
+
+		recorder := refresh(response.RefreshToken)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var refreshed dto.UserSigninResponseDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&refreshed))
+		assert.NotEqual(t, response.RefreshToken, refreshed.RefreshToken)
+
+		recorder = refresh(response.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		recorder = refresh(refreshed.RefreshToken)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("RefreshTokenHandler to return 400 BadRequest for a missing refresh token", func(t *testing.T) {
+		recorder := refresh("")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("ListSessionsHandler to list sessions and LogoutHandler to end the current one", func(t *testing.T) {
+		response := signin(t, "sessions-agent")
+
+		req, _ := http.NewRequest("GET", "/user/sessions", nil)
+		req = authenticated(authService, req, response.IDToken)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.ListSessionsHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var sessions []dto.SessionDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&sessions))
+		assert.NotEmpty(t, sessions)
+		assert.True(t, sessions[0].Current)
+		assert.Equal(t, "sessions-agent", sessions[0].UserAgent)
+
+		req, _ = http.NewRequest("POST", "/user/logout", nil)
+		req = authenticated(authService, req, response.IDToken)
+		recorder = httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.LogoutHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		req, _ = http.NewRequest("GET", "/user/sessions", nil)
+		req = authenticated(authService, req, response.IDToken)
+		recorder = httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.ListSessionsHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, refresh(response.RefreshToken).Code)
+	})
+
+	t.Run("LogoutAllHandler to end every session of the user", func(t *testing.T) {
+		first := signin(t, "first-agent")
+		second := signin(t, "second-agent")
+
+		req, _ := http.NewRequest("POST", "/user/logout/all", nil)
+		req = authenticated(authService, req, first.IDToken)
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(userHandlers.LogoutAllHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		assert.Equal(t, http.StatusUnauthorized, refresh(second.RefreshToken).Code)
+
+		_, _, err := authService.VerifyToken(second.IDToken)
+		assert.ErrorIs(t, err, services.ErrTokenRevoked)
+	})
 }
@@ -2,49 +2,58 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/mail"
+	"net/url"
 	"nikwallet/handlers/dto"
+	"nikwallet/logger"
+	"nikwallet/repository/models"
 	"nikwallet/repository/money"
 	"nikwallet/services"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type WalletHandlers struct {
-	walletService *services.WalletService
-	authService   *services.AuthService
-	userService   *services.UserService
+	walletService      *services.WalletService
+	userService        *services.UserService
+	idempotencyService *services.IdempotencyService
 }
 
-func NewWalletHandlers(walletService *services.WalletService, authService *services.AuthService, userService *services.UserService) *WalletHandlers {
+func NewWalletHandlers(walletService *services.WalletService, userService *services.UserService, idempotencyService *services.IdempotencyService) *WalletHandlers {
 	return &WalletHandlers{
-		walletService: walletService,
-		authService:   authService,
-		userService:   userService,
+		walletService:      walletService,
+		userService:        userService,
+		idempotencyService: idempotencyService,
 	}
 }
 
 func (wh *WalletHandlers) CreateWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
-	IDToken := req.Header.Get("id_token")
-	_, userID, err := wh.authService.VerifyToken(IDToken)
-	if err != nil {
-		respWriter.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
 		return
 	}
+	userID := principal.UserID
 
-	var payload struct {
-		Currency money.Currency `json:"currency"`
-	}
+	var payload dto.CreateWalletDTO
 
-	if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-		http.Error(respWriter, "invalid payload", http.StatusBadRequest)
+	if !decodeRequest(respWriter, req, &payload) {
 		return
 	}
 
-	wallet, err := wh.walletService.CreateWallet(userID, payload.Currency)
+	var wallet *models.Wallet
+	var err error
+	if payload.Name == "" {
+		wallet, err = wh.walletService.CreateWallet(userID, payload.Currency)
+	} else {
+		wallet, err = wh.walletService.CreatePocket(userID, payload.Currency, payload.Name)
+	}
 	if err != nil {
-		respWriter.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+		WriteError(respWriter, err)
 		return
 	}
 
@@ -52,24 +61,63 @@ func (wh *WalletHandlers) CreateWalletHandler(respWriter http.ResponseWriter, re
 	json.NewEncoder(respWriter).Encode(wallet)
 }
 
-func (wh *WalletHandlers) AddMoneyToWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
-	IDToken := req.Header.Get("id_token")
-	_, userID, err := wh.authService.VerifyToken(IDToken)
+func (wh *WalletHandlers) ListWalletsHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	wallets, err := wh.walletService.ListWallets(principal.UserID)
 	if err != nil {
-		respWriter.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(wallets)
+}
+
+func (wh *WalletHandlers) SetDefaultWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	var payload dto.DefaultWalletDTO
+	if !decodeRequest(respWriter, req, &payload) {
+		return
+	}
+
+	err := wh.walletService.SetDefaultWallet(principal.UserID, payload.WalletID)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.Response{Message: "default wallet updated"})
+}
+
+func (wh *WalletHandlers) AddMoneyToWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
 		return
 	}
 
-	var moneyToAdd money.Money
-	if err := json.NewDecoder(req.Body).Decode(&moneyToAdd); err != nil {
-		http.Error(respWriter, "invalid amount", http.StatusBadRequest)
+	var payload dto.MoneyDTO
+	if !decodeRequest(respWriter, req, &payload) {
 		return
 	}
-	updatedWallet, err := wh.walletService.AddMoneyToWallet(userID, moneyToAdd)
+
+	updatedWallet, err := wh.walletService.In(req.Context()).AddMoneyToWallet(userID, walletID, payload.Money())
 	if err != nil {
-		respWriter.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+		WriteError(respWriter, err)
 		return
 	}
 
@@ -78,23 +126,25 @@ func (wh *WalletHandlers) AddMoneyToWalletHandler(respWriter http.ResponseWriter
 }
 
 func (wh *WalletHandlers) WithdrawMoneyFromWalletHandler(respWriter http.ResponseWriter, req *http.Request) {
-	IDToken := req.Header.Get("id_token")
-	_, userID, err := wh.authService.VerifyToken(IDToken)
-	if err != nil {
-		respWriter.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
 		return
 	}
 
-	var moneyToAdd money.Money
-	if err := json.NewDecoder(req.Body).Decode(&moneyToAdd); err != nil {
-		http.Error(respWriter, "invalid amount", http.StatusBadRequest)
+	var payload dto.MoneyDTO
+	if !decodeRequest(respWriter, req, &payload) {
 		return
 	}
-	var withdrawnMoney money.Money
 
-	if withdrawnMoney, err = wh.walletService.WithdrawMoneyFromWallet(userID, moneyToAdd); err != nil {
-		http.Error(respWriter, "insufficient funds", http.StatusBadRequest)
+	withdrawnMoney, err := wh.walletService.In(req.Context()).WithdrawMoneyFromWallet(userID, walletID, payload.Money())
+	if err != nil {
+		WriteError(respWriter, err)
 		return
 	}
 
@@ -103,23 +153,26 @@ func (wh *WalletHandlers) WithdrawMoneyFromWalletHandler(respWriter http.Respons
 }
 
 func (wh *WalletHandlers) TransferMoneyHandler(respWriter http.ResponseWriter, req *http.Request) {
-	IDToken := req.Header.Get("id_token")
-	_, userID, err := wh.authService.VerifyToken(IDToken)
-	if err != nil {
-		respWriter.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
 		return
 	}
 
 	var transferPayload dto.MoneyTransferDTO
 
-	if err := json.NewDecoder(req.Body).Decode(&transferPayload); err != nil {
-		http.Error(respWriter, "invalid payload", http.StatusBadRequest)
+	if !decodeRequest(respWriter, req, &transferPayload) {
 		return
 	}
 
-	if err := wh.walletService.TransferMoney(userID, transferPayload.RecipientEmail, *transferPayload.Amount); err != nil {
-		http.Error(respWriter, err.Error(), http.StatusInternalServerError)
+	err := wh.walletService.In(req.Context()).TransferMoneyWithQuote(userID, walletID, transferPayload.RecipientEmail, transferPayload.RecipientWalletID, transferPayload.QuoteID, *transferPayload.Amount)
+	if err != nil {
+		WriteError(respWriter, err)
 		return
 	}
 
@@ -128,27 +181,244 @@ func (wh *WalletHandlers) TransferMoneyHandler(respWriter http.ResponseWriter, r
 }
 
 func (wh *WalletHandlers) GetWalletHistoryHandler(respWriter http.ResponseWriter, req *http.Request) {
-	IDToken := req.Header.Get("id_token")
-	_, userID, err := wh.authService.VerifyToken(IDToken)
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+	userID := principal.UserID
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	query, err := historyQuery(req.URL.Query())
 	if err != nil {
-		respWriter.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+		WriteError(respWriter, err)
+		return
+	}
+	query.WalletID = walletID
+
+	page, err := wh.walletService.ListHistory(userID, query)
+	if err != nil {
+		WriteError(respWriter, err)
+		return
+	}
+
+	respWriter.WriteHeader(http.StatusOK)
+	json.NewEncoder(respWriter).Encode(dto.HistoryPageDTO{Items: page.Postings, NextCursor: page.NextCursor})
+}
+
+// GetWalletBalanceHandler returns the balance of a wallet as it stood at the
+// RFC 3339 timestamp in the at parameter, or its current balance without one.
+func (wh *WalletHandlers) GetWalletBalanceHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
 		return
 	}
 
-	limitStr := req.URL.Query().Get("limit")
-	limit, err := strconv.Atoi(limitStr)
+	now := time.Now()
+	at := now
+	if value := req.URL.Query().Get("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			WriteError(respWriter, &dto.ValidationError{Fields: []dto.FieldError{
+				{Field: "at", Code: "invalid_time", Message: "must be an RFC 3339 timestamp"},
+			}})
+			return
+		}
+		if parsed.After(now) {
+			WriteError(respWriter, &dto.ValidationError{Fields: []dto.FieldError{
+				{Field: "at", Code: "invalid_time", Message: "must not be in the future"},
+			}})
+			return
+		}
+		at = parsed
+	}
+
+	wallet, err := wh.walletService.GetWallet(principal.UserID, walletID)
 	if err != nil {
-		http.Error(respWriter, "invalid limit parameter", http.StatusBadRequest)
+		WriteError(respWriter, err)
 		return
 	}
-	ledgerEntries, err := wh.walletService.GetLastNLedgerEntries(userID, limit)
+
+	balance, err := wh.walletService.GetBalanceAt(principal.UserID, wallet.ID, at)
 	if err != nil {
-		respWriter.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(respWriter).Encode(dto.Response{Error: err.Error()})
+		WriteError(respWriter, err)
 		return
 	}
 
 	respWriter.WriteHeader(http.StatusOK)
-	json.NewEncoder(respWriter).Encode(ledgerEntries)
+	json.NewEncoder(respWriter).Encode(dto.BalanceDTO{WalletID: wallet.ID, Balance: balance, At: at})
+}
+
+// GetWalletStatementHandler streams the statement of a wallet for the period
+// from the from timestamp up to the to timestamp, both RFC 3339, as CSV,
+// JSON Lines or OFX. The format parameter defaults to csv.
+func (wh *WalletHandlers) GetWalletStatementHandler(respWriter http.ResponseWriter, req *http.Request) {
+	principal, ok := requirePrincipal(respWriter, req)
+	if !ok {
+		return
+	}
+
+	walletID, ok := walletIDParam(respWriter, req)
+	if !ok {
+		return
+	}
+
+	params := req.URL.Query()
+	var failures []dto.FieldError
+	period := map[string]time.Time{}
+	for _, field := range []string{"from", "to"} {
+		value := params.Get(field)
+		if value == "" {
+			failures = append(failures, dto.FieldError{Field: field, Code: "required", Message: "is required"})
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			failures = append(failures, dto.FieldError{Field: field, Code: "invalid_time", Message: "must be an RFC 3339 timestamp"})
+			continue
+		}
+		period[field] = parsed
+	}
+
+	format := params.Get("format")
+	if format == "" {
+		format = StatementFormatCSV
+	}
+	statementWriter, response := newStatementWriter(format, respWriter)
+	if statementWriter == nil {
+		failures = append(failures, dto.FieldError{Field: "format", Code: "invalid_format", Message: "must be csv, jsonl or ofx"})
+	}
+
+	if len(failures) > 0 {
+		WriteError(respWriter, &dto.ValidationError{Fields: failures})
+		return
+	}
+
+	err := wh.walletService.WriteStatement(principal.UserID, walletID, period["from"], period["to"], statementWriter)
+	if err != nil {
+		if !response.started {
+			WriteError(respWriter, err)
+			return
+		}
+		// The status line is gone; all that is left is to cut the body short.
+		logger.Errorf("failed to stream statement: %s", err)
+	}
+}
+
+// historyQuery reads the filters of the history API from the query string:
+// limit, cursor, order (asc or desc), type (repeated or comma separated),
+// counterparty (an email), currency, min_amount, max_amount, and from and to
+// as RFC 3339 timestamps.
+func historyQuery(params url.Values) (services.HistoryQuery, error) {
+	query := services.HistoryQuery{
+		Cursor:            params.Get("cursor"),
+		CounterpartyEmail: params.Get("counterparty"),
+		Currency:          money.Currency(params.Get("currency")),
+	}
+	var failures []dto.FieldError
+	fail := func(field, code, message string) {
+		failures = append(failures, dto.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			fail("limit", "invalid_limit", "must be a positive integer")
+		}
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		query.Ascending = true
+	default:
+		fail("order", "invalid_order", "must be asc or desc")
+	}
+
+	for _, param := range params["type"] {
+		for _, name := range strings.Split(param, ",") {
+			transactionType := models.TransactionType(strings.TrimSpace(name))
+			if !transactionType.IsValid() {
+				fail("type", "invalid_type", fmt.Sprintf("%q is not a transaction type", name))
+				continue
+			}
+			query.TransactionTypes = append(query.TransactionTypes, transactionType)
+		}
+	}
+
+	if query.CounterpartyEmail != "" {
+		if address, err := mail.ParseAddress(query.CounterpartyEmail); err != nil || address.Address != query.CounterpartyEmail {
+			fail("counterparty", "invalid_email", "must be an email address")
+		}
+	}
+	if query.Currency != "" && !query.Currency.IsValid() {
+		fail("currency", "unsupported_currency", fmt.Sprintf("%s is not a supported currency", query.Currency))
+	}
+
+	amounts := []struct {
+		field string
+		dst   *decimal.NullDecimal
+	}{{"min_amount", &query.MinAmount}, {"max_amount", &query.MaxAmount}}
+	for _, amount := range amounts {
+		field := amount.field
+		if value := params.Get(field); value != "" {
+			parsed, err := decimal.NewFromString(value)
+			if err != nil || parsed.IsNegative() {
+				fail(field, "invalid_amount", "must be a non-negative number")
+				continue
+			}
+			*amount.dst = decimal.NewNullDecimal(parsed)
+		}
+	}
+	if query.MinAmount.Valid && query.MaxAmount.Valid && query.MinAmount.Decimal.GreaterThan(query.MaxAmount.Decimal) {
+		fail("min_amount", "invalid_range", "must not be greater than max_amount")
+	}
+
+	bounds := []struct {
+		field string
+		dst   *time.Time
+	}{{"from", &query.From}, {"to", &query.To}}
+	for _, bound := range bounds {
+		field := bound.field
+		if value := params.Get(field); value != "" {
+			parsed, err := time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				fail(field, "invalid_time", "must be an RFC 3339 timestamp")
+				continue
+			}
+			*bound.dst = parsed
+		}
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && !query.From.Before(query.To) {
+		fail("from", "invalid_range", "must be before to")
+	}
+
+	if len(failures) > 0 {
+		return query, &dto.ValidationError{Fields: failures}
+	}
+	return query, nil
+}
+
+// walletIDParam reads the optional wallet_id query parameter. Zero means the
+// caller's default wallet.
+func walletIDParam(respWriter http.ResponseWriter, req *http.Request) (int, bool) {
+	walletIDStr := req.URL.Query().Get("wallet_id")
+	if walletIDStr == "" {
+		return 0, true
+	}
+
+	walletID, err := strconv.Atoi(walletIDStr)
+	if err != nil || walletID <= 0 {
+		WriteProblem(respWriter, http.StatusBadRequest, CodeInvalidRequest, "invalid wallet_id parameter")
+		return 0, false
+	}
+	return walletID, true
 }
@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 
@@ -18,11 +25,11 @@ import (
 
 func TestWalletHandlers(t *testing.T) {
 
-	userService := services.NewUserService(db.DB)
-	authService := services.NewAuthService(db.DB)
-	walletService := services.NewWalletService(db.DB)
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	walletService := services.NewWalletService(db, services.DefaultStaticRateProvider(), 0, services.LimitPolicy{})
 
-	walletHandlers := NewWalletHandlers(walletService, authService, userService)
+	walletHandlers := NewWalletHandlers(walletService, userService, services.NewIdempotencyService(db, time.Hour))
 
 	t.Run("CreateWalletHandler to return 201 StatusCreated for successfully create wallet", func(t *testing.T) {
 		newUser := &models.User{
@@ -45,7 +52,7 @@ func TestWalletHandlers(t *testing.T) {
 		assert.NoError(t, err)
 
 		req, err := http.NewRequest("POST", "/wallet", bytes.NewReader(reqBody))
-		req.Header.Set("id_token", token)
+		req = authenticated(authService, req, token)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -80,7 +87,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet"
 		req, err := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -99,19 +106,13 @@ func TestWalletHandlers(t *testing.T) {
 			t.Errorf("AddMoneyToWallet() got = %v, want = %v", senderWallet.Money, expectedAddedMoney)
 		}
 
-		ledgerEntry, err := db.GetLatestLedgerEntry(userID)
+		journalEntry, err := db.GetLatestJournalEntry(userID)
 		assert.NoError(t, err)
 
-		expectedLedgerEntry := models.Ledger{
-			SenderUserID:    userID,
-			ReceiverUserID:  userID,
-			Amount:          &addMoneyRequest,
-			TransactionType: string(models.TransactionTypeAdd),
-		}
-		assert.Equal(t, expectedLedgerEntry.SenderUserID, ledgerEntry.SenderUserID)
-		assert.Equal(t, expectedLedgerEntry.ReceiverUserID, ledgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedLedgerEntry.Amount.Currency, ledgerEntry.Amount.Currency)
-		assert.Equal(t, expectedLedgerEntry.TransactionType, ledgerEntry.TransactionType)
+		walletPosting := journalEntry.PostingForUser(userID)
+		assert.Equal(t, string(models.TransactionTypeAdd), journalEntry.TransactionType)
+		assert.Equal(t, models.PostingDirectionCredit, walletPosting.Direction)
+		assert.Equal(t, addMoneyRequest.Currency, walletPosting.Amount.Currency)
 
 	})
 
@@ -140,7 +141,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet"
 		req, err := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -149,9 +150,10 @@ func TestWalletHandlers(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
 
-		var response dto.Response
-		err = json.NewDecoder(recorder.Body).Decode(&response)
-		assert.Error(t, err, "invalid amount")
+		var problem dto.ProblemDTO
+		err = json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, CodeInvalidRequest, problem.Code)
 	})
 
 	t.Run("WithdrawMoneyFromWalletHandler to return 200 StatusOk for successfull withdraw money from user's wallet", func(t *testing.T) {
@@ -171,7 +173,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		addMoneyRequest := money.Money{Amount: decimal.NewFromFloat(50.0), Currency: "INR"}
 
-		_, err = walletService.AddMoneyToWallet(userID, addMoneyRequest)
+		_, err = walletService.AddMoneyToWallet(userID, 0, addMoneyRequest)
 		assert.NoError(t, err)
 
 		withdrawMoneyRequest := money.Money{Amount: decimal.NewFromFloat(50.0), Currency: "INR"}
@@ -180,7 +182,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "wallet/withdraw"
 		req, err := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -199,23 +201,16 @@ func TestWalletHandlers(t *testing.T) {
 			t.Errorf("AddMoneyToWallet() got = %v, want = %v", senderWallet.Money, expectedRemainedMoney)
 		}
 
-		ledgerEntry, err := db.GetLatestLedgerEntry(userID)
+		journalEntry, err := db.GetLatestJournalEntry(userID)
 		assert.NoError(t, err)
 
-		expectedLedgerEntry := models.Ledger{
-			SenderUserID:    userID,
-			ReceiverUserID:  userID,
-			Amount:          &addMoneyRequest,
-			TransactionType: string(models.TransactionTypeWithdraw),
-		}
-
-		assert.Equal(t, expectedLedgerEntry.SenderUserID, ledgerEntry.SenderUserID)
-		assert.Equal(t, expectedLedgerEntry.ReceiverUserID, ledgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedLedgerEntry.Amount.Currency, ledgerEntry.Amount.Currency)
-		assert.Equal(t, expectedLedgerEntry.TransactionType, ledgerEntry.TransactionType)
+		walletPosting := journalEntry.PostingForUser(userID)
+		assert.Equal(t, string(models.TransactionTypeWithdraw), journalEntry.TransactionType)
+		assert.Equal(t, models.PostingDirectionDebit, walletPosting.Direction)
+		assert.Equal(t, withdrawMoneyRequest.Currency, walletPosting.Amount.Currency)
 	})
 
-	t.Run("WithdrawMoneyFromWalletHandler to return status 400 bad request for InsufficientFunds", func(t *testing.T) {
+	t.Run("WithdrawMoneyFromWalletHandler to return status 422 UnprocessableEntity for InsufficientFunds", func(t *testing.T) {
 		newUser := &models.User{
 			EmailID:  "testw5114@example.com",
 			Password: "password",
@@ -231,7 +226,7 @@ func TestWalletHandlers(t *testing.T) {
 		assert.NotNil(t, IDToken)
 
 		addMoneyRequest := money.Money{Amount: decimal.NewFromFloat(40.0), Currency: money.INR}
-		_, err = walletService.AddMoneyToWallet(userID, addMoneyRequest)
+		_, err = walletService.AddMoneyToWallet(userID, 0, addMoneyRequest)
 		assert.NoError(t, err)
 
 		withdrawMoneyRequest := money.Money{Amount: decimal.NewFromFloat(50.0), Currency: money.INR}
@@ -240,18 +235,21 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet/withdraw"
 		req, err := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
 
 		http.HandlerFunc(walletHandlers.WithdrawMoneyFromWalletHandler).ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Equal(t, ProblemContentType, recorder.Header().Get("Content-Type"))
 
-		var response dto.Response
-		err = json.NewDecoder(recorder.Body).Decode(&response)
-		assert.Error(t, err, "insufficient funds")
+		var problem dto.ProblemDTO
+		err = json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, "insufficient_funds", problem.Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, problem.Status)
 	})
 
 	t.Run("WithdrawMoneyFromWalletHandler to return status 400 BadRequest for InvalidAmount", func(t *testing.T) {
@@ -279,7 +277,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet/withdraw"
 		req, err := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -288,9 +286,10 @@ func TestWalletHandlers(t *testing.T) {
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
 
-		var response dto.Response
-		err = json.NewDecoder(recorder.Body).Decode(&response)
-		assert.Error(t, err, "invalid amount")
+		var problem dto.ProblemDTO
+		err = json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, CodeInvalidRequest, problem.Code)
 	})
 
 	t.Run("TransferMoneyHandler to return 200 StatusOk for successful transfer of money from sender to reciever having same currency", func(t *testing.T) {
@@ -309,7 +308,7 @@ func TestWalletHandlers(t *testing.T) {
 		_, _ = walletService.CreateWallet(recipientID, money.INR)
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.INR)
-		walletService.AddMoneyToWallet(senderID, *initialMoney)
+		walletService.AddMoneyToWallet(senderID, 0, *initialMoney)
 
 		IDToken, _ := authService.AuthenticateUser(sender.EmailID, sender.Password)
 
@@ -324,7 +323,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet/transfer"
 		req, _ := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 
 		recorder := httptest.NewRecorder()
 		http.HandlerFunc(walletHandlers.TransferMoneyHandler).ServeHTTP(recorder, req)
@@ -348,35 +347,23 @@ func TestWalletHandlers(t *testing.T) {
 			t.Errorf("AddMoneyToWallet() got = %v, want = %v", recipientWallet.Money, expectedRecipientMoney)
 		}
 
-		senderLedgerEntry, err := db.GetLatestLedgerEntry(senderID)
+		senderEntry, err := db.GetLatestJournalEntry(senderID)
 		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeTransfer), senderEntry.TransactionType)
 
-		expectedSenderLedgerEntry := models.Ledger{
-			SenderUserID:    senderID,
-			ReceiverUserID:  recipientID,
-			Amount:          transferMoney,
-			TransactionType: string(models.TransactionTypeTransfer),
-		}
-
-		assert.Equal(t, expectedSenderLedgerEntry.SenderUserID, senderLedgerEntry.SenderUserID)
-		assert.Equal(t, expectedSenderLedgerEntry.ReceiverUserID, senderLedgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedSenderLedgerEntry.Amount.Currency, senderLedgerEntry.Amount.Currency)
-		assert.Equal(t, expectedSenderLedgerEntry.TransactionType, senderLedgerEntry.TransactionType)
+		senderPosting := senderEntry.PostingForUser(senderID)
+		assert.Equal(t, models.PostingDirectionDebit, senderPosting.Direction)
+		assert.Equal(t, recipientID, senderPosting.CounterpartyUserID)
+		assert.Equal(t, transferMoney.Currency, senderPosting.Amount.Currency)
 
-		recipientLedgerEntry, err := db.GetLatestLedgerEntry(recipientID)
+		recipientEntry, err := db.GetLatestJournalEntry(recipientID)
 		assert.NoError(t, err)
+		assert.Equal(t, senderEntry.ID, recipientEntry.ID)
 
-		expectedRecipientLedgerEntry := models.Ledger{
-			SenderUserID:    senderID,
-			ReceiverUserID:  recipientID,
-			Amount:          transferMoney,
-			TransactionType: string(models.TransactionTypeTransfer),
-		}
-
-		assert.Equal(t, expectedRecipientLedgerEntry.SenderUserID, recipientLedgerEntry.SenderUserID)
-		assert.Equal(t, expectedRecipientLedgerEntry.ReceiverUserID, recipientLedgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedRecipientLedgerEntry.Amount.Currency, recipientLedgerEntry.Amount.Currency)
-		assert.Equal(t, expectedRecipientLedgerEntry.TransactionType, recipientLedgerEntry.TransactionType)
+		recipientPosting := recipientEntry.PostingForUser(recipientID)
+		assert.Equal(t, models.PostingDirectionCredit, recipientPosting.Direction)
+		assert.Equal(t, senderID, recipientPosting.CounterpartyUserID)
+		assert.Equal(t, transferMoney.Currency, recipientPosting.Amount.Currency)
 
 	})
 
@@ -396,7 +383,7 @@ func TestWalletHandlers(t *testing.T) {
 		_, _ = walletService.CreateWallet(recipientID, money.EUR)
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(10.0), money.USD)
-		walletService.AddMoneyToWallet(senderID, *initialMoney)
+		walletService.AddMoneyToWallet(senderID, 0, *initialMoney)
 
 		IDToken, _ := authService.AuthenticateUser(sender.EmailID, sender.Password)
 
@@ -411,7 +398,7 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet/transfer"
 		req, _ := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 
 		recorder := httptest.NewRecorder()
 		http.HandlerFunc(walletHandlers.TransferMoneyHandler).ServeHTTP(recorder, req)
@@ -428,47 +415,34 @@ func TestWalletHandlers(t *testing.T) {
 			t.Errorf("AddMoneyToWallet() got = %v, want = %v", senderWallet.Money, expectedSenderMoney)
 		}
 
-		expectedRecipientMoney, _ := money.NewMoney(decimal.NewFromFloat(2.18), money.EUR)
+		expectedRecipientMoney, _ := money.NewMoney(decimal.NewFromFloat(1.83), money.EUR)
 		recipientWallet, _ := walletService.GetWalletByUserID(recipientID)
 		if !recipientWallet.Money.Equals(*expectedRecipientMoney) {
 			t.Errorf("AddMoneyToWallet() got = %v, want = %v", recipientWallet.Money, expectedRecipientMoney)
 		}
 
-		senderLedgerEntry, err := db.GetLatestLedgerEntry(senderID)
+		senderEntry, err := db.GetLatestJournalEntry(senderID)
 		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeTransfer), senderEntry.TransactionType)
 
-		expectedSenderLedgerEntry := models.Ledger{
-			SenderUserID:    senderID,
-			ReceiverUserID:  recipientID,
-			Amount:          transferMoney,
-			TransactionType: string(models.TransactionTypeTransfer),
-		}
-
-		assert.Equal(t, expectedSenderLedgerEntry.SenderUserID, senderLedgerEntry.SenderUserID)
-		assert.Equal(t, expectedSenderLedgerEntry.ReceiverUserID, senderLedgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedSenderLedgerEntry.Amount.Amount, senderLedgerEntry.Amount.Amount)
-		assert.Equal(t, expectedSenderLedgerEntry.Amount.Currency, senderLedgerEntry.Amount.Currency)
-		assert.Equal(t, expectedSenderLedgerEntry.TransactionType, senderLedgerEntry.TransactionType)
+		senderPosting := senderEntry.PostingForUser(senderID)
+		assert.Equal(t, models.PostingDirectionDebit, senderPosting.Direction)
+		assert.Equal(t, recipientID, senderPosting.CounterpartyUserID)
+		assert.Equal(t, transferMoney.Amount, senderPosting.Amount.Amount)
+		assert.Equal(t, transferMoney.Currency, senderPosting.Amount.Currency)
 
-		recipientLedgerEntry, err := db.GetLatestLedgerEntry(recipientID)
+		recipientEntry, err := db.GetLatestJournalEntry(recipientID)
 		assert.NoError(t, err)
+		assert.Equal(t, senderEntry.ID, recipientEntry.ID)
 
-		expectedRecipientLedgerEntry := models.Ledger{
-			SenderUserID:    senderID,
-			ReceiverUserID:  recipientID,
-			Amount:          transferMoney,
-			TransactionType: string(models.TransactionTypeTransfer),
-		}
-
-		assert.Equal(t, expectedRecipientLedgerEntry.SenderUserID, recipientLedgerEntry.SenderUserID)
-		assert.Equal(t, expectedRecipientLedgerEntry.ReceiverUserID, recipientLedgerEntry.ReceiverUserID)
-		assert.Equal(t, expectedRecipientLedgerEntry.Amount.Amount, recipientLedgerEntry.Amount.Amount)
-		assert.Equal(t, expectedRecipientLedgerEntry.Amount.Currency, recipientLedgerEntry.Amount.Currency)
-		assert.Equal(t, expectedRecipientLedgerEntry.TransactionType, recipientLedgerEntry.TransactionType)
+		recipientPosting := recipientEntry.PostingForUser(recipientID)
+		assert.Equal(t, models.PostingDirectionCredit, recipientPosting.Direction)
+		assert.Equal(t, senderID, recipientPosting.CounterpartyUserID)
+		assert.True(t, expectedRecipientMoney.Equals(*recipientPosting.Amount))
 
 	})
 
-	t.Run("TransferMoneyHandler to return 500 InternalServerError for invalid recipient email", func(t *testing.T) {
+	t.Run("TransferMoneyHandler to return 404 NotFound for invalid recipient email", func(t *testing.T) {
 		sender := &models.User{
 			EmailID:  "sender@example.com",
 			Password: "test123",
@@ -476,7 +450,7 @@ func TestWalletHandlers(t *testing.T) {
 		senderID, _ := userService.CreateUser(sender)
 		_, _ = walletService.CreateWallet(senderID, money.INR)
 
-		invalidRecipientEmail := "invalidemail"
+		invalidRecipientEmail := "nobody@example.com"
 		transferMoney, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.INR)
 
 		transferMoneyPayload := dto.MoneyTransferDTO{
@@ -490,12 +464,17 @@ func TestWalletHandlers(t *testing.T) {
 		req, _ := http.NewRequest("PUT", url, bytes.NewReader(reqBody))
 
 		IDToken, _ := authService.AuthenticateUser(sender.EmailID, sender.Password)
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 
 		recorder := httptest.NewRecorder()
 		http.HandlerFunc(walletHandlers.TransferMoneyHandler).ServeHTTP(recorder, req)
 
-		assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		var problem dto.ProblemDTO
+		err := json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, "user_not_found", problem.Code)
 	})
 
 	t.Run("TransferMoneyHandler to return 400 BadRequest for invalid payload", func(t *testing.T) {
@@ -512,16 +491,18 @@ func TestWalletHandlers(t *testing.T) {
 
 		url := "/wallet/transfer"
 		req, _ := http.NewRequest("PUT", url, bytes.NewReader(invalidPayload))
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 
 		recorder := httptest.NewRecorder()
 		http.HandlerFunc(walletHandlers.TransferMoneyHandler).ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
 
-		var response dto.Response
-		err := json.NewDecoder(recorder.Body).Decode(&response)
-		assert.Error(t, err, "invalid amount")
+		var problem dto.ProblemDTO
+		err := json.NewDecoder(recorder.Body).Decode(&problem)
+		assert.NoError(t, err)
+		assert.Equal(t, CodeValidationFailed, problem.Code)
+		assert.Equal(t, []dto.FieldError{{Field: "amount", Code: "invalid_type", Message: "cannot be a JSON string"}}, problem.Errors)
 	})
 
 	t.Run("GetWalletHistoryHandler to return 200 StatusOk and history of wallet for valid query params", func(t *testing.T) {
@@ -536,32 +517,24 @@ func TestWalletHandlers(t *testing.T) {
 		IDToken, err := authService.AuthenticateUser(newUser.EmailID, newUser.Password)
 		assert.NoError(t, err)
 
-		_, err = walletService.CreateWallet(userID, money.INR)
+		wallet, err := walletService.CreateWallet(userID, money.INR)
 		assert.NoError(t, err)
 
-		mockLedgerEntries := []*models.Ledger{
-			{
-				SenderUserID:    userID,
-				ReceiverUserID:  userID,
-				Amount:          &money.Money{Amount: decimal.NewFromFloat(50.0), Currency: money.INR},
-				TransactionType: string(models.TransactionTypeAdd),
-			},
-			{
-				SenderUserID:    userID,
-				ReceiverUserID:  userID,
-				Amount:          &money.Money{Amount: decimal.NewFromFloat(100.0), Currency: money.INR},
+		for _, amount := range []float64{50.0, 100.0} {
+			deposit := &money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+			err := db.CreateJournalEntry(&models.JournalEntry{
 				TransactionType: string(models.TransactionTypeAdd),
-			},
-		}
-
-		for _, entry := range mockLedgerEntries {
-			err := db.CreateLedgerEntry(entry)
+				Postings: []*models.Posting{
+					models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, deposit),
+					models.NewWalletPosting(wallet, models.PostingDirectionCredit, deposit),
+				},
+			})
 			assert.NoError(t, err)
 		}
 
 		url := "/wallet/history?limit=2"
 		req, err := http.NewRequest("GET", url, nil)
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -570,11 +543,24 @@ func TestWalletHandlers(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, recorder.Code)
 
-		var response []*models.Ledger
+		var response dto.HistoryPageDTO
 		err = json.NewDecoder(recorder.Body).Decode(&response)
 		assert.NoError(t, err)
 
-		assert.Len(t, response, 2)
+		assert.Len(t, response.Items, 2)
+		assert.Empty(t, response.NextCursor)
+
+		req, _ = http.NewRequest("GET", "/wallet/history?limit=1&type=add&min_amount=60", nil)
+		req = authenticated(authService, req, IDToken)
+		recorder = httptest.NewRecorder()
+		http.HandlerFunc(walletHandlers.GetWalletHistoryHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		response = dto.HistoryPageDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+		assert.Len(t, response.Items, 1)
+		assert.True(t, response.Items[0].Amount.Amount.Equal(decimal.NewFromInt(100)))
+		assert.Empty(t, response.NextCursor)
 	})
 
 	t.Run("GetWalletHistoryHandler to return 400 BadRequest invalid query params", func(t *testing.T) {
@@ -592,9 +578,9 @@ func TestWalletHandlers(t *testing.T) {
 		_, err = walletService.CreateWallet(userID, money.INR)
 		assert.NoError(t, err)
 
-		url := "/wallet/history"
+		url := "/wallet/history?limit=abc&order=sideways&type=add,gift&from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z"
 		req, err := http.NewRequest("GET", url, nil)
-		req.Header.Set("id_token", IDToken)
+		req = authenticated(authService, req, IDToken)
 		assert.NoError(t, err)
 
 		recorder := httptest.NewRecorder()
@@ -602,5 +588,355 @@ func TestWalletHandlers(t *testing.T) {
 		http.HandlerFunc(walletHandlers.GetWalletHistoryHandler).ServeHTTP(recorder, req)
 
 		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, CodeValidationFailed, problem.Code)
+		assert.Equal(t, []dto.FieldError{
+			{Field: "limit", Code: "invalid_limit", Message: "must be a positive integer"},
+			{Field: "order", Code: "invalid_order", Message: "must be asc or desc"},
+			{Field: "type", Code: "invalid_type", Message: `"gift" is not a transaction type`},
+			{Field: "from", Code: "invalid_range", Message: "must be before to"},
+		}, problem.Errors)
+
+		req, _ = http.NewRequest("GET", "/wallet/history?cursor=bogus", nil)
+		req = authenticated(authService, req, IDToken)
+		recorder = httptest.NewRecorder()
+		http.HandlerFunc(walletHandlers.GetWalletHistoryHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		problem = dto.ProblemDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "invalid_cursor", problem.Code)
+	})
+
+	t.Run("AddMoneyToWalletHandler to return status 400 bad request for an amount finer than the currency allows", func(t *testing.T) {
+		userID, err := userService.CreateUser(&models.User{EmailID: "testprecision@example.com", Password: "password"})
+		assert.NoError(t, err)
+		_, err = walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		IDToken, _ := authService.AuthenticateUser("testprecision@example.com", "password")
+
+		reqBody, _ := json.Marshal(money.Money{Amount: decimal.RequireFromString("10.005"), Currency: money.INR})
+		req, _ := http.NewRequest("PUT", "/wallet", bytes.NewReader(reqBody))
+		req = authenticated(authService, req, IDToken)
+
+		recorder := httptest.NewRecorder()
+		http.HandlerFunc(walletHandlers.AddMoneyToWalletHandler).ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+		wallet, _ := walletService.GetWalletByUserID(userID)
+		assert.True(t, wallet.Money.Amount.IsZero())
+	})
+}
+
+func TestMultipleWalletHandlers(t *testing.T) {
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	walletService := services.NewWalletService(db, services.DefaultStaticRateProvider(), 0, services.LimitPolicy{})
+
+	walletHandlers := NewWalletHandlers(walletService, userService, services.NewIdempotencyService(db, time.Hour))
+
+	newUser := func(t *testing.T, email string) (int, string) {
+		userID, err := userService.CreateUser(&models.User{EmailID: email, Password: "password"})
+		assert.NoError(t, err)
+		IDToken, err := authService.AuthenticateUser(email, "password")
+		assert.NoError(t, err)
+		return userID, IDToken
+	}
+
+	serve := func(handler http.HandlerFunc, method, url, IDToken string, payload interface{}) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, url, bytes.NewReader(reqBody))
+		req = authenticated(authService, req, IDToken)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("CreateWalletHandler to create a named pocket and return 409 Conflict for a duplicate", func(t *testing.T) {
+		_, IDToken := newUser(t, "testpockets@example.com")
+
+		recorder := serve(walletHandlers.CreateWalletHandler, "POST", "/wallet", IDToken, dto.CreateWalletDTO{Currency: money.INR})
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		recorder = serve(walletHandlers.CreateWalletHandler, "POST", "/wallet", IDToken, dto.CreateWalletDTO{Currency: money.INR, Name: "Savings"})
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		var pocket models.Wallet
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&pocket))
+		assert.Equal(t, "Savings", pocket.Name)
+		assert.False(t, pocket.IsDefault)
+
+		recorder = serve(walletHandlers.CreateWalletHandler, "POST", "/wallet", IDToken, dto.CreateWalletDTO{Currency: money.INR, Name: "Savings"})
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+	})
+
+	t.Run("ListWalletsHandler and SetDefaultWalletHandler to list wallets and switch the default", func(t *testing.T) {
+		userID, IDToken := newUser(t, "testlistwallets@example.com")
+		main, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		usd, err := walletService.CreateWallet(userID, money.USD)
+		assert.NoError(t, err)
+
+		recorder := serve(walletHandlers.ListWalletsHandler, "GET", "/wallet/", IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var wallets []models.Wallet
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&wallets))
+		assert.Len(t, wallets, 2)
+		assert.True(t, wallets[0].IsDefault)
+
+		recorder = serve(walletHandlers.SetDefaultWalletHandler, "PUT", "/wallet/default", IDToken, dto.DefaultWalletDTO{WalletID: usd.ID})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		wallet, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, usd.ID, wallet.ID)
+		assert.NotEqual(t, main.ID, wallet.ID)
+	})
+
+	t.Run("GetWalletBalanceHandler to return the balance at a point in time", func(t *testing.T) {
+		userID, IDToken := newUser(t, "testbalanceat@example.com")
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(80), Currency: money.INR})
+		assert.NoError(t, err)
+		afterDeposit := time.Now()
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(30), Currency: money.INR})
+		assert.NoError(t, err)
+
+		recorder := serve(walletHandlers.GetWalletBalanceHandler, "GET", "/wallet/balance?at="+url.QueryEscape(afterDeposit.Format(time.RFC3339Nano)), IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var balance dto.BalanceDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&balance))
+		assert.Equal(t, wallet.ID, balance.WalletID)
+		assert.True(t, balance.Balance.Amount.Equal(decimal.NewFromInt(80)), "got %v", balance.Balance)
+
+		recorder = serve(walletHandlers.GetWalletBalanceHandler, "GET", "/wallet/balance", IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		balance = dto.BalanceDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&balance))
+		assert.True(t, balance.Balance.Amount.Equal(decimal.NewFromInt(50)), "got %v", balance.Balance)
+
+		recorder = serve(walletHandlers.GetWalletBalanceHandler, "GET", "/wallet/balance?at=yesterday", IDToken, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, []dto.FieldError{{Field: "at", Code: "invalid_time", Message: "must be an RFC 3339 timestamp"}}, problem.Errors)
+	})
+
+	t.Run("GetWalletStatementHandler to stream a statement as CSV, JSON Lines or OFX", func(t *testing.T) {
+		userID, IDToken := newUser(t, "teststatement@example.com")
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(60), Currency: money.INR})
+		assert.NoError(t, err)
+		from := time.Now()
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(25), Currency: money.INR})
+		assert.NoError(t, err)
+		period := "from=" + url.QueryEscape(from.Format(time.RFC3339Nano)) + "&to=" + url.QueryEscape(time.Now().Format(time.RFC3339Nano))
+
+		recorder := serve(walletHandlers.GetWalletStatementHandler, "GET", "/wallet/statement?"+period, IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", recorder.Header().Get("Content-Type"))
+		assert.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+		rows, err := csv.NewReader(recorder.Body).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, rows, 4)
+		assert.Equal(t, csvStatementColumns, rows[0])
+		assert.Equal(t, []string{"opening_balance", "60.00"}, []string{rows[1][1], rows[1][9]})
+		assert.Equal(t, []string{"entry", "withdraw", "debit", "-25.00", "35.00"}, []string{rows[2][1], rows[2][4], rows[2][5], rows[2][6], rows[2][9]})
+		assert.Equal(t, []string{"closing_balance", "35.00"}, []string{rows[3][1], rows[3][9]})
+
+		recorder = serve(walletHandlers.GetWalletStatementHandler, "GET", "/wallet/statement?format=jsonl&"+period, IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var records []dto.StatementRecordDTO
+		decoder := json.NewDecoder(recorder.Body)
+		for decoder.More() {
+			var record dto.StatementRecordDTO
+			assert.NoError(t, decoder.Decode(&record))
+			records = append(records, record)
+		}
+		assert.Len(t, records, 3)
+		assert.Equal(t, dto.StatementRecordEntry, records[1].Record)
+		assert.Equal(t, "-25.00", records[1].Amount)
+		assert.Equal(t, "35.00", records[2].Balance)
+
+		recorder = serve(walletHandlers.GetWalletStatementHandler, "GET", "/wallet/statement?format=ofx&"+period, IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		ofx := recorder.Body.String()
+		assert.True(t, strings.HasPrefix(ofx, "OFXHEADER:100\r\n"))
+		assert.Contains(t, ofx, "<CURDEF>INR")
+		assert.Contains(t, ofx, "<TRNTYPE>DEBIT")
+		assert.Contains(t, ofx, "<TRNAMT>-25.00")
+		assert.Contains(t, ofx, "<LEDGERBAL><BALAMT>35.00")
+	})
+
+	t.Run("GetWalletStatementHandler to return 400 BadRequest for a missing period or an unknown format", func(t *testing.T) {
+		userID, IDToken := newUser(t, "teststatementbadrequest@example.com")
+		_, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+
+		recorder := serve(walletHandlers.GetWalletStatementHandler, "GET", "/wallet/statement?from=2024-01-01T00:00:00Z&format=pdf", IDToken, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, []dto.FieldError{
+			{Field: "to", Code: "required", Message: "is required"},
+			{Field: "format", Code: "invalid_format", Message: "must be csv, jsonl or ofx"},
+		}, problem.Errors)
+
+		recorder = serve(walletHandlers.GetWalletStatementHandler, "GET", "/wallet/statement?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", IDToken, nil)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		problem = dto.ProblemDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "invalid_statement_period", problem.Code)
+	})
+
+	t.Run("Hold handlers to authorize, capture and void holds", func(t *testing.T) {
+		userID, IDToken := newUser(t, "testholds@example.com")
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(100), Currency: money.INR})
+		assert.NoError(t, err)
+
+		authorize := dto.AuthorizeHoldDTO{Amount: &money.Money{Amount: decimal.NewFromInt(40), Currency: money.INR}, Reference: "order-7"}
+		recorder := serve(walletHandlers.AuthorizeHoldHandler, "POST", "/wallet/holds", IDToken, authorize)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		var hold dto.HoldDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&hold))
+		assert.Equal(t, "active", hold.Status)
+		assert.Equal(t, "order-7", hold.Reference)
+
+		recorder = serve(walletHandlers.ListWalletsHandler, "GET", "/wallet/", IDToken, nil)
+		var wallets []map[string]json.RawMessage
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&wallets))
+		var ledger, available money.Money
+		assert.NoError(t, json.Unmarshal(wallets[0]["ledger_balance"], &ledger))
+		assert.NoError(t, json.Unmarshal(wallets[0]["available_balance"], &available))
+		assert.True(t, ledger.Amount.Equal(decimal.NewFromInt(100)), "got %v", ledger)
+		assert.True(t, available.Amount.Equal(decimal.NewFromInt(60)), "got %v", available)
+
+		captureReq, _ := http.NewRequest("POST", fmt.Sprintf("/wallet/holds/%d/capture", hold.ID), strings.NewReader(`{"amount": {"Amount": "15", "Currency": "INR"}}`))
+		captureReq = mux.SetURLVars(authenticated(authService, captureReq, IDToken), map[string]string{"id": strconv.Itoa(hold.ID)})
+		recorder = httptest.NewRecorder()
+		walletHandlers.CaptureHoldHandler(recorder, captureReq)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		hold = dto.HoldDTO{}
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&hold))
+		assert.Equal(t, "captured", hold.Status)
+		assert.True(t, hold.CapturedAmount.Amount.Equal(decimal.NewFromInt(15)))
+
+		voidReq, _ := http.NewRequest("POST", fmt.Sprintf("/wallet/holds/%d/void", hold.ID), nil)
+		voidReq = mux.SetURLVars(authenticated(authService, voidReq, IDToken), map[string]string{"id": strconv.Itoa(hold.ID)})
+		recorder = httptest.NewRecorder()
+		walletHandlers.VoidHoldHandler(recorder, voidReq)
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "hold_not_active", problem.Code)
+
+		stored, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Money.Amount.Equal(decimal.NewFromInt(85)))
+		assert.True(t, stored.HeldAmount.IsZero())
+	})
+
+	t.Run("Refund handlers to refund part of a transfer and reverse the rest", func(t *testing.T) {
+		senderID, _ := newUser(t, "testrefundsender@example.com")
+		recipientID, IDToken := newUser(t, "testrefundrecipient@example.com")
+		senderWallet, err := walletService.CreateWallet(senderID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.CreateWallet(recipientID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(senderID, senderWallet.ID, money.Money{Amount: decimal.NewFromInt(50), Currency: money.INR})
+		assert.NoError(t, err)
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "testrefundrecipient@example.com", 0, money.Money{Amount: decimal.NewFromInt(30), Currency: money.INR}))
+		postings, err := db.GetLastNPostings(recipientID, 1)
+		assert.NoError(t, err)
+		entryID := strconv.Itoa(postings[0].JournalEntryID)
+
+		refundReq, _ := http.NewRequest("POST", "/wallet/entries/"+entryID+"/refund", strings.NewReader(`{"amount": {"Amount": "10", "Currency": "INR"}}`))
+		refundReq = mux.SetURLVars(authenticated(authService, refundReq, IDToken), map[string]string{"id": entryID})
+		recorder := httptest.NewRecorder()
+		walletHandlers.RefundTransferHandler(recorder, refundReq)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		var refund models.JournalEntry
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&refund))
+		assert.Equal(t, "refund", refund.TransactionType)
+		assert.Equal(t, postings[0].JournalEntryID, refund.ReversedEntryID)
+
+		refundReq, _ = http.NewRequest("POST", "/wallet/entries/"+entryID+"/refund", strings.NewReader(`{"amount": {"Amount": "21", "Currency": "INR"}}`))
+		refundReq = mux.SetURLVars(authenticated(authService, refundReq, IDToken), map[string]string{"id": entryID})
+		recorder = httptest.NewRecorder()
+		walletHandlers.RefundTransferHandler(recorder, refundReq)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "refund_exceeds_original", problem.Code)
+
+		reverseReq, _ := http.NewRequest("POST", "/wallet/entries/"+entryID+"/reverse", nil)
+		reverseReq = mux.SetURLVars(authenticated(authService, reverseReq, IDToken), map[string]string{"id": entryID})
+		recorder = httptest.NewRecorder()
+		walletHandlers.ReverseTransferHandler(recorder, reverseReq)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+
+		stored, err := walletService.GetWalletByUserID(senderID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Money.Amount.Equal(decimal.NewFromInt(50)), "got %v", stored.Money)
+	})
+
+	t.Run("GetLimitsHandler to report the allowance left and WithdrawMoneyFromWalletHandler to reject a breach", func(t *testing.T) {
+		userID, IDToken := newUser(t, "testlimits@example.com")
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(500), Currency: money.INR})
+		assert.NoError(t, err)
+		err = walletService.SetUserLimit(&models.UserLimit{
+			UserID:          userID,
+			TransactionType: string(models.TransactionTypeWithdraw),
+			DailyAmount:     decimal.NewNullDecimal(decimal.NewFromInt(150)),
+		})
+		assert.NoError(t, err)
+
+		recorder := serve(walletHandlers.WithdrawMoneyFromWalletHandler, "PUT", "/wallet/withdraw", IDToken, dto.MoneyDTO{Amount: decimal.NewFromInt(100), Currency: money.INR})
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		recorder = serve(walletHandlers.WithdrawMoneyFromWalletHandler, "PUT", "/wallet/withdraw", IDToken, dto.MoneyDTO{Amount: decimal.NewFromInt(60), Currency: money.INR})
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		var problem dto.ProblemDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&problem))
+		assert.Equal(t, "limit_exceeded", problem.Code)
+
+		recorder = serve(walletHandlers.GetLimitsHandler, "GET", "/wallet/limits", IDToken, nil)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var limits dto.LimitsDTO
+		assert.NoError(t, json.NewDecoder(recorder.Body).Decode(&limits))
+		assert.Equal(t, "unverified", limits.KYCLevel)
+		assert.Equal(t, "400", limits.Balance.Amount.String())
+		assert.Nil(t, limits.MaxBalance)
+		assert.Len(t, limits.Limits, 2)
+		withdraw := limits.Limits[0]
+		assert.Equal(t, "withdraw", withdraw.TransactionType)
+		assert.True(t, withdraw.Daily.Remaining.Amount.Equal(decimal.NewFromInt(50)), "got %v", withdraw.Daily.Remaining)
+		assert.Nil(t, withdraw.Monthly)
+		assert.Nil(t, limits.Limits[1].Daily)
+	})
+
+	t.Run("AddMoneyToWalletHandler to return 404 NotFound for another user's wallet_id", func(t *testing.T) {
+		ownerID, _ := newUser(t, "testwalletowner@example.com")
+		wallet, err := walletService.CreateWallet(ownerID, money.INR)
+		assert.NoError(t, err)
+		_, IDToken := newUser(t, "testwalletintruder@example.com")
+
+		url := fmt.Sprintf("/wallet/?wallet_id=%d", wallet.ID)
+		recorder := serve(walletHandlers.AddMoneyToWalletHandler, "PUT", url, IDToken, money.Money{Amount: decimal.NewFromInt(10), Currency: money.INR})
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		recorder = serve(walletHandlers.SetDefaultWalletHandler, "PUT", "/wallet/default", IDToken, dto.DefaultWalletDTO{WalletID: wallet.ID})
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
 	})
 }
@@ -1,9 +1,68 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"nikwallet/config"
+	"nikwallet/logger"
 	"nikwallet/server"
 )
 
+const usage = "usage: nikwallet [-config <file>] [serve|hash-passwords|import-rates|set-role|migrate]"
+
 func main() {
-	server.StartServer()
+	configFile := flag.String("config", os.Getenv(config.ConfigFileEnv), "config file (.env, .yaml or .json) to read before the environment")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	c, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed at config:\n%v", err)
+	}
+	level, _ := logger.ParseLevel(c.LogLevel)
+	logger.SetLevel(level)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		server.StartServer(c)
+		return
+	}
+
+	switch args[0] {
+	case "serve":
+		server.StartServer(c)
+	case "hash-passwords":
+		server.HashPasswords(c)
+	case "import-rates":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: nikwallet import-rates <rates.json>")
+			os.Exit(2)
+		}
+		server.ImportExchangeRates(c, args[1])
+	case "set-role":
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "usage: nikwallet set-role <email> <customer|support|admin|auditor>")
+			os.Exit(2)
+		}
+		server.SetUserRole(c, args[1], args[2])
+	case "migrate":
+		err := server.Migrate(c, args[1:])
+		if errors.Is(err, server.ErrMigrateUsage) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err != nil {
+			log.Fatalln("Failed to migrate database", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n%s\n", args[0], usage)
+		os.Exit(2)
+	}
 }
@@ -1,10 +1,10 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 
 	"nikwallet/config"
-	"nikwallet/repository/models"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -14,10 +14,10 @@ type PostgreSQL struct {
 	DB *gorm.DB
 }
 
+// Connect opens the database without touching the schema; see MigrateUp.
 func (p *PostgreSQL) Connect(c *config.Config) error {
-	dsn := fmt.Sprintf("host=localhost port=5432 user=%s password=%s dbname=%s sslmode=disable", c.DbUser, c.DbPassword, c.DbName)
 	var err error
-	p.DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	p.DB, err = gorm.Open(postgres.Open(c.DSN()), &gorm.Config{})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -27,32 +27,38 @@ func (p *PostgreSQL) Connect(c *config.Config) error {
 		return fmt.Errorf("failed to get underlying SQL DB: %w", err)
 	}
 
+	sqlDB.SetMaxOpenConns(c.DbMaxOpenConns)
+	sqlDB.SetMaxIdleConns(c.DbMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(c.DbConnMaxLifetime)
+
 	if err := sqlDB.Ping(); err != nil {
 		sqlDB.Close()
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	err = p.DB.AutoMigrate(&models.User{}, &models.Wallet{}, &models.Ledger{})
-	if err != nil {
-		return fmt.Errorf("failed to auto migrate tables: %w", err)
-	}
-
 	return nil
 }
 
-func (p *PostgreSQL) Close() error {
+func (p *PostgreSQL) Ping(ctx context.Context) error {
 	sqlDB, err := p.DB.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get underlying SQL DB: %w", err)
 	}
+	return sqlDB.PingContext(ctx)
+}
 
-	err = p.DB.Migrator().DropTable(
-		&models.User{},
-		&models.Wallet{},
-		&models.Ledger{},
-	)
+func (p *PostgreSQL) Transaction(fn func(tx Store) error) error {
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&PostgreSQL{DB: tx})
+	})
+}
+
+// Close only closes the connection. Tests that need to remove the tables use
+// the teardown function returned by OpenTestStore.
+func (p *PostgreSQL) Close() error {
+	sqlDB, err := p.DB.DB()
 	if err != nil {
-		return fmt.Errorf("failed to drop tables: %w", err)
+		return fmt.Errorf("failed to get underlying SQL DB: %w", err)
 	}
 
 	return sqlDB.Close()
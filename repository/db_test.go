@@ -3,10 +3,36 @@ package repository
 import (
 	"log"
 	"nikwallet/config"
+	"nikwallet/repository/models"
+	"os"
+	"sync"
 	"testing"
+
+	"gorm.io/gorm/schema"
 )
 
+func TestMoneyColumns(t *testing.T) {
+	for _, model := range []interface{}{&models.Wallet{}, &models.Posting{}} {
+		parsed, err := schema.Parse(model, &sync.Map{}, schema.NamingStrategy{})
+		if err != nil {
+			t.Fatalf("failed to parse schema of %T: %v", model, err)
+		}
+
+		amount := parsed.LookUpField("amount")
+		if amount == nil || amount.DataType != "numeric(20,4)" {
+			t.Errorf("%s.amount = %+v, want a numeric(20,4) column", parsed.Table, amount)
+		}
+		if parsed.LookUpField("currency") == nil {
+			t.Errorf("%s has no currency column", parsed.Table)
+		}
+	}
+}
+
 func TestPostgreSQL(t *testing.T) {
+	if os.Getenv("NIKWALLET_TEST_STORE") != "postgres" {
+		t.Skip("set NIKWALLET_TEST_STORE=postgres to run against a real database")
+	}
+
 	c, err := config.LoadConfig()
 
 	if err != nil {
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+)
+
+func (db *PostgreSQL) CreateExchangeRate(newRate *models.ExchangeRate) error {
+	err := db.DB.Create(newRate).Error
+	if err != nil {
+		return fmt.Errorf("failed to create exchange rate: %w", err)
+	}
+	return nil
+}
+
+// GetLatestExchangeRate returns the newest rate for the currency pair that was
+// already in effect at the given time.
+func (db *PostgreSQL) GetLatestExchangeRate(base, quote money.Currency, at time.Time) (*models.ExchangeRate, error) {
+	rate := &models.ExchangeRate{}
+	err := db.DB.Where("base_currency = ? AND quote_currency = ? AND effective_at <= ?", base, quote, at).
+		Order("effective_at DESC, id DESC").
+		First(rate).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rate: %w", err)
+	}
+	return rate, nil
+}
+
+func (db *PostgreSQL) CreateFXQuote(newQuote *models.FXQuote) error {
+	err := db.DB.Create(newQuote).Error
+	if err != nil {
+		return fmt.Errorf("failed to create fx quote: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetFXQuote(id string) (*models.FXQuote, error) {
+	quote := &models.FXQuote{}
+	err := db.DB.Where("id = ?", id).First(quote).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fx quote: %w", err)
+	}
+	return quote, nil
+}
+
+// MarkFXQuoteUsed reports false when the quote had already been used or had
+// expired by usedAt.
+func (db *PostgreSQL) MarkFXQuoteUsed(id string, usedAt time.Time) (bool, error) {
+	result := db.DB.Model(&models.FXQuote{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, usedAt).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark fx quote used: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"nikwallet/repository/models"
+)
+
+func (db *PostgreSQL) CreateHold(newHold *models.Hold) error {
+	err := db.DB.Create(newHold).Error
+	if err != nil {
+		return fmt.Errorf("failed to create hold: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetHoldByID(id int) (*models.Hold, error) {
+	hold := &models.Hold{}
+	err := db.DB.First(hold, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hold %d: %w", id, err)
+	}
+	return hold, nil
+}
+
+func (db *PostgreSQL) UpdateHold(changedHold *models.Hold) error {
+	err := db.DB.Save(changedHold).Error
+	if err != nil {
+		return fmt.Errorf("failed to update hold: %w", err)
+	}
+	return nil
+}
+
+// ListExpiredHolds returns up to limit active holds that expired by now,
+// oldest expiry first.
+func (db *PostgreSQL) ListExpiredHolds(now time.Time, limit int) ([]*models.Hold, error) {
+	var holds []*models.Hold
+	err := db.DB.Where("status = ? AND expires_at <= ?", models.HoldStatusActive, now).
+		Order("expires_at ASC, id ASC").
+		Limit(limit).
+		Find(&holds).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired holds: %w", err)
+	}
+	return holds, nil
+}
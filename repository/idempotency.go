@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrIdempotencyKeyExists = errors.New("idempotency key already exists")
+
+func (db *PostgreSQL) CreateIdempotencyKey(newKey *models.IdempotencyKey) error {
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(newKey)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetIdempotencyKey(userID int, key string) (*models.IdempotencyKey, error) {
+	idempotencyKey := &models.IdempotencyKey{}
+	err := db.DB.Where("user_id = ? AND key = ?", userID, key).First(idempotencyKey).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return idempotencyKey, nil
+}
+
+func (db *PostgreSQL) UpdateIdempotencyKey(changedKey *models.IdempotencyKey) error {
+	result := db.DB.Model(&models.IdempotencyKey{}).Where("id = ?", changedKey.ID).Updates(map[string]interface{}{
+		"state":                 changedKey.State,
+		"response_status":       changedKey.ResponseStatus,
+		"response_content_type": changedKey.ResponseContentType,
+		"response_body":         changedKey.ResponseBody,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update idempotency key: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) DeleteIdempotencyKey(id int) error {
+	err := db.DB.Delete(&models.IdempotencyKey{}, id).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	result := db.DB.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
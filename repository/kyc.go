@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"nikwallet/repository/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrKYCSubmissionPending = errors.New("user already has a pending kyc submission")
+
+func (db *PostgreSQL) UpdateUserKYCLevel(userID int, level models.KYCLevel) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Update("kyc_level", level)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update kyc level: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update kyc level: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) CreateKYCSubmission(newSubmission *models.KYCSubmission) error {
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(newSubmission)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create kyc submission: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrKYCSubmissionPending
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetKYCSubmissionByID(id int) (*models.KYCSubmission, error) {
+	submission := &models.KYCSubmission{}
+	err := db.DB.First(submission, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kyc submission %d: %w", id, err)
+	}
+	return submission, nil
+}
+
+func (db *PostgreSQL) ListKYCSubmissions(query KYCSubmissionQuery) ([]*models.KYCSubmission, error) {
+	tx := db.DB.Where("id > ?", query.AfterID)
+	if query.UserID != 0 {
+		tx = tx.Where("user_id = ?", query.UserID)
+	}
+	if query.Status != "" {
+		tx = tx.Where("status = ?", query.Status)
+	}
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	var submissions []*models.KYCSubmission
+	if err := tx.Order("id ASC").Find(&submissions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list kyc submissions: %w", err)
+	}
+	return submissions, nil
+}
+
+// MarkKYCSubmissionReviewed stores the decision on a pending submission, and
+// reports false when it had already been reviewed.
+func (db *PostgreSQL) MarkKYCSubmissionReviewed(reviewed *models.KYCSubmission) (bool, error) {
+	result := db.DB.Model(&models.KYCSubmission{}).
+		Where("id = ? AND status = ?", reviewed.ID, models.KYCSubmissionPending).
+		Updates(map[string]interface{}{
+			"status":      reviewed.Status,
+			"reviewer_id": reviewed.ReviewerID,
+			"review_note": reviewed.ReviewNote,
+			"reviewed_at": reviewed.ReviewedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to review kyc submission: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (db *PostgreSQL) CreateKYCEvent(newEvent *models.KYCEvent) error {
+	err := db.DB.Create(newEvent).Error
+	if err != nil {
+		return fmt.Errorf("failed to create kyc event: %w", err)
+	}
+	return nil
+}
+
+// ListKYCEvents returns the audit trail of the user, oldest first.
+func (db *PostgreSQL) ListKYCEvents(userID int) ([]*models.KYCEvent, error) {
+	var events []*models.KYCEvent
+	err := db.DB.Where("user_id = ?", userID).Order("id ASC").Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list kyc events: %w", err)
+	}
+	return events, nil
+}
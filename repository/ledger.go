@@ -1,37 +1,236 @@
 package repository
 
 import (
+	"database/sql"
 	"fmt"
 	"nikwallet/repository/models"
+	"time"
+
+	"github.com/shopspring/decimal"
 )
 
-func (db *PostgreSQL) CreateLedgerEntry(newEntry *models.Ledger) error {
+func (db *PostgreSQL) CreateJournalEntry(newEntry *models.JournalEntry) error {
+	if err := newEntry.Validate(); err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	stampJournalEntry(newEntry)
+
 	err := db.DB.Create(newEntry).Error
 	if err != nil {
-		return fmt.Errorf("failed to create ledger entry: %w", err)
+		return fmt.Errorf("failed to create journal entry: %w", err)
 	}
 	return nil
 }
 
-func (db *PostgreSQL) GetLatestLedgerEntry(userID int) (*models.Ledger, error) {
-	ledger := &models.Ledger{}
-	err := db.DB.Where("sender_user_id = ? OR receiver_user_id = ?", userID, userID).
-		Order("created_at DESC").
-		First(ledger).Error
+func (db *PostgreSQL) GetJournalEntryByID(id int) (*models.JournalEntry, error) {
+	entry := &models.JournalEntry{}
+	err := db.DB.Preload("Postings").First(entry, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve journal entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (db *PostgreSQL) GetLatestJournalEntry(userID int) (*models.JournalEntry, error) {
+	posting := &models.Posting{}
+	err := db.DB.Where("account_type = ? AND user_id = ?", models.AccountTypeWallet, userID).
+		Order("created_at DESC, id DESC").
+		First(posting).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve latest ledger entry: %w", err)
+		return nil, fmt.Errorf("failed to retrieve latest journal entry: %w", err)
 	}
-	return ledger, nil
+	return db.GetJournalEntryByID(posting.JournalEntryID)
 }
 
-func (db *PostgreSQL) GetLastNLedgerEntries(userID, limit int) ([]*models.Ledger, error) {
-	var entries []*models.Ledger
-	err := db.DB.Where("sender_user_id = ? OR receiver_user_id = ?", userID, userID).
-		Order("created_at DESC").
+func (db *PostgreSQL) GetLastNPostings(userID, limit int) ([]*models.Posting, error) {
+	var postings []*models.Posting
+	err := db.DB.Where("account_type = ? AND user_id = ?", models.AccountTypeWallet, userID).
+		Order("created_at DESC, id DESC").
 		Limit(limit).
+		Find(&postings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last N postings: %w", err)
+	}
+	return postings, nil
+}
+
+func (db *PostgreSQL) GetLastNWalletPostings(walletID, limit int) ([]*models.Posting, error) {
+	var postings []*models.Posting
+	err := db.DB.Where("account_type = ? AND wallet_id = ?", models.AccountTypeWallet, walletID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(&postings).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve last N postings: %w", err)
+	}
+	return postings, nil
+}
+
+// ListReversingEntries returns the refunds and reversals of the entry, oldest
+// first.
+func (db *PostgreSQL) ListReversingEntries(entryID int) ([]*models.JournalEntry, error) {
+	var entries []*models.JournalEntry
+	err := db.DB.Preload("Postings").
+		Where("reversed_entry_id = ?", entryID).
+		Order("id ASC").
 		Find(&entries).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve last N ledger entries: %w", err)
+		return nil, fmt.Errorf("failed to list reversing entries: %w", err)
 	}
 	return entries, nil
-}
\ No newline at end of file
+}
+
+// ListPostings returns the postings matching query. From is inclusive and
+// To exclusive.
+func (db *PostgreSQL) ListPostings(query PostingQuery) ([]*models.Posting, error) {
+	tx := db.DB.Where("account_type = ? AND user_id = ?", models.AccountTypeWallet, query.UserID)
+	if query.WalletID != 0 {
+		tx = tx.Where("wallet_id = ?", query.WalletID)
+	}
+	if len(query.TransactionTypes) > 0 {
+		tx = tx.Where("transaction_type IN ?", query.TransactionTypes)
+	}
+	if query.CounterpartyUserID != 0 {
+		tx = tx.Where("counterparty_user_id = ?", query.CounterpartyUserID)
+	}
+	if query.Currency != "" {
+		tx = tx.Where("currency = ?", query.Currency)
+	}
+	if query.MinAmount.Valid {
+		tx = tx.Where("amount >= ?", query.MinAmount.Decimal)
+	}
+	if query.MaxAmount.Valid {
+		tx = tx.Where("amount <= ?", query.MaxAmount.Decimal)
+	}
+	if !query.From.IsZero() {
+		tx = tx.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		tx = tx.Where("created_at < ?", query.To)
+	}
+
+	order := "created_at DESC, id DESC"
+	if query.Ascending {
+		order = "created_at ASC, id ASC"
+	}
+	if query.After != nil {
+		comparison := "(created_at, id) < (?, ?)"
+		if query.Ascending {
+			comparison = "(created_at, id) > (?, ?)"
+		}
+		tx = tx.Where(comparison, query.After.CreatedAt, query.After.ID)
+	}
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+
+	var postings []*models.Posting
+	if err := tx.Order(order).Find(&postings).Error; err != nil {
+		return nil, fmt.Errorf("failed to list postings: %w", err)
+	}
+	return postings, nil
+}
+
+// GetWalletBalanceFromPostings sums the signed posting amounts in the
+// database, matching models.BalanceFromPostings.
+func (db *PostgreSQL) GetWalletBalanceFromPostings(walletID int) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := db.DB.Model(&models.Posting{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN -amount ELSE amount END), 0)", models.PostingDirectionDebit).
+		Where("account_type = ? AND wallet_id = ?", models.AccountTypeWallet, walletID).
+		Scan(&balance).Error
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum wallet postings: %w", err)
+	}
+	return balance, nil
+}
+
+// GetWalletBalanceAt returns the balance of the wallet after every posting
+// made at or before at. It starts from the latest balance snapshot taken up to
+// that point and only sums the postings after it.
+func (db *PostgreSQL) GetWalletBalanceAt(walletID int, at time.Time) (decimal.Decimal, error) {
+	var snapshots []*models.BalanceSnapshot
+	err := db.DB.Where("wallet_id = ? AND posted_at <= ?", walletID, at).
+		Order("posted_at DESC, posting_id DESC").
+		Limit(1).
+		Find(&snapshots).Error
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to retrieve balance snapshot: %w", err)
+	}
+
+	tx := db.DB.Model(&models.Posting{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN -amount ELSE amount END), 0)", models.PostingDirectionDebit).
+		Where("account_type = ? AND wallet_id = ? AND created_at <= ?", models.AccountTypeWallet, walletID, at)
+	balance := decimal.Zero
+	if len(snapshots) > 0 {
+		tx = tx.Where("(created_at, id) > (?, ?)", snapshots[0].PostedAt, snapshots[0].PostingID)
+		balance = snapshots[0].Balance
+	}
+
+	var sum decimal.Decimal
+	if err := tx.Scan(&sum).Error; err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum wallet postings: %w", err)
+	}
+	return balance.Add(sum), nil
+}
+
+// CreateBalanceSnapshots snapshots the balance at at of every wallet with
+// postings after its latest snapshot, and returns the number of snapshots
+// taken. at should lie far enough in the past for every transaction that
+// stamped its postings before it to have committed.
+func (db *PostgreSQL) CreateBalanceSnapshots(at time.Time) (int64, error) {
+	result := db.DB.Exec(`
+		WITH latest AS (
+			SELECT DISTINCT ON (wallet_id) wallet_id, posting_id, posted_at, balance
+			FROM balance_snapshots
+			WHERE posted_at <= @at
+			ORDER BY wallet_id, posted_at DESC, posting_id DESC
+		)
+		INSERT INTO balance_snapshots (wallet_id, posting_id, posted_at, balance, created_at)
+		SELECT DISTINCT ON (p.wallet_id)
+			p.wallet_id, p.id, p.created_at,
+			COALESCE(latest.balance, 0) + SUM(CASE WHEN p.direction = @debit THEN -p.amount ELSE p.amount END) OVER (PARTITION BY p.wallet_id),
+			@now
+		FROM postings p
+		LEFT JOIN latest ON latest.wallet_id = p.wallet_id
+		WHERE p.account_type = @wallet AND p.created_at <= @at
+			AND (latest.wallet_id IS NULL OR (p.created_at, p.id) > (latest.posted_at, latest.posting_id))
+		ORDER BY p.wallet_id, p.created_at DESC, p.id DESC`,
+		sql.Named("at", at),
+		sql.Named("now", time.Now()),
+		sql.Named("debit", models.PostingDirectionDebit),
+		sql.Named("wallet", models.AccountTypeWallet),
+	)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to create balance snapshots: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SumDebits totals the debits the query selects by currency.
+func (db *PostgreSQL) SumDebits(query DebitQuery) ([]DebitTotal, error) {
+	var totals []DebitTotal
+	err := db.DB.Model(&models.Posting{}).
+		Select("currency, SUM(amount) AS amount, COUNT(DISTINCT journal_entry_id) AS count").
+		Where("account_type = ? AND user_id = ? AND direction = ?", models.AccountTypeWallet, query.UserID, models.PostingDirectionDebit).
+		Where("transaction_type = ? AND created_at >= ? AND COALESCE(counterparty_user_id, 0) <> user_id", query.TransactionType, query.Since).
+		Group("currency").
+		Order("currency").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum debits: %w", err)
+	}
+	return totals, nil
+}
+
+func stampJournalEntry(entry *models.JournalEntry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	for _, posting := range entry.Postings {
+		posting.CreatedAt = entry.CreatedAt
+		posting.TransactionType = entry.TransactionType
+		posting.ReversedEntryID = entry.ReversedEntryID
+	}
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"nikwallet/repository/models"
 	"nikwallet/repository/money"
 	"testing"
@@ -11,65 +12,120 @@ import (
 )
 
 func TestLedger(t *testing.T) {
-	t.Run("CreateLedgerEntry method to successfully creates new entry in ledger", func(t *testing.T) {
-		transferAmount := &money.Money{Amount: decimal.NewFromFloat(100.0), Currency: money.INR}
-		newEntry := &models.Ledger{
-			SenderUserID:    1,
-			ReceiverUserID:  2,
-			Amount:          transferAmount,
+	wallet := &models.Wallet{ID: 1, UserID: 1}
+	recipientWallet := &models.Wallet{ID: 2, UserID: 2}
+
+	transferEntry := func(amount float64, createdAt time.Time) *models.JournalEntry {
+		transferAmount := &money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+		return &models.JournalEntry{
 			TransactionType: string(models.TransactionTypeTransfer),
-			CreatedAt:       time.Now(),
+			Postings: []*models.Posting{
+				models.NewWalletPosting(wallet, models.PostingDirectionDebit, transferAmount),
+				models.NewWalletPosting(recipientWallet, models.PostingDirectionCredit, transferAmount),
+			},
+			CreatedAt: createdAt,
 		}
-		err := db.CreateLedgerEntry(newEntry)
+	}
+
+	t.Run("CreateJournalEntry method to successfully create a balanced entry with its postings", func(t *testing.T) {
+		newEntry := transferEntry(100.0, time.Now())
+		err := db.CreateJournalEntry(newEntry)
+		assert.NoError(t, err)
+		assert.NotZero(t, newEntry.ID)
+
+		stored, err := db.GetJournalEntryByID(newEntry.ID)
 		assert.NoError(t, err)
+		assert.Len(t, stored.Postings, 2)
+		for _, posting := range stored.Postings {
+			assert.Equal(t, newEntry.ID, posting.JournalEntryID)
+			assert.Equal(t, string(models.TransactionTypeTransfer), posting.TransactionType)
+		}
 	})
 
-	t.Run("GetLatestLedgerEntry method to retrieve the latest ledger entry", func(t *testing.T) {
-		transferAmount := &money.Money{Amount: decimal.NewFromFloat(100.0), Currency: money.INR}
-		newEntry := &models.Ledger{
-			SenderUserID:    1,
-			ReceiverUserID:  2,
-			Amount:          transferAmount,
+	t.Run("CreateJournalEntry method to reject an entry whose postings do not sum to zero", func(t *testing.T) {
+		newEntry := &models.JournalEntry{
+			TransactionType: string(models.TransactionTypeAdd),
+			Postings: []*models.Posting{
+				models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, &money.Money{Amount: decimal.NewFromFloat(100.0), Currency: money.INR}),
+				models.NewWalletPosting(wallet, models.PostingDirectionCredit, &money.Money{Amount: decimal.NewFromFloat(90.0), Currency: money.INR}),
+			},
+		}
+
+		err := db.CreateJournalEntry(newEntry)
+		assert.True(t, errors.Is(err, models.ErrUnbalancedJournalEntry))
+	})
+
+	t.Run("CreateJournalEntry method to reject an entry balanced only across currencies", func(t *testing.T) {
+		newEntry := &models.JournalEntry{
 			TransactionType: string(models.TransactionTypeTransfer),
-			CreatedAt:       time.Now(),
+			Postings: []*models.Posting{
+				models.NewWalletPosting(wallet, models.PostingDirectionDebit, &money.Money{Amount: decimal.NewFromFloat(10.0), Currency: money.USD}),
+				models.NewWalletPosting(recipientWallet, models.PostingDirectionCredit, &money.Money{Amount: decimal.NewFromFloat(10.0), Currency: money.EUR}),
+			},
 		}
-		db.CreateLedgerEntry(newEntry)
 
-		userID := 1
-		ledger, err := db.GetLatestLedgerEntry(userID)
+		err := db.CreateJournalEntry(newEntry)
+		assert.True(t, errors.Is(err, models.ErrUnbalancedJournalEntry))
+	})
 
-		assert.NoError(t, err)
-		assert.NotNil(t, ledger)
+	t.Run("GetLatestJournalEntry method to retrieve the latest entry of the user", func(t *testing.T) {
+		newEntry := transferEntry(100.0, time.Now().Add(time.Minute))
+		db.CreateJournalEntry(newEntry)
 
-		assert.Equal(t, userID, ledger.SenderUserID)
+		entry, err := db.GetLatestJournalEntry(recipientWallet.UserID)
+
+		assert.NoError(t, err)
+		assert.Equal(t, newEntry.ID, entry.ID)
+		assert.NotNil(t, entry.PostingForUser(wallet.UserID))
+		assert.Equal(t, models.PostingDirectionCredit, entry.PostingForUser(recipientWallet.UserID).Direction)
 	})
 
-	t.Run("GetLastNLedgerEntries method to retrieve the last N ledger entries", func(t *testing.T) {
-		userID := 1
+	t.Run("GetLastNPostings method to retrieve the last N postings", func(t *testing.T) {
+		userID := wallet.UserID
 		numEntries := 4
 
 		for i := 1; i <= 5; i++ {
-			transferAmount := &money.Money{Amount: decimal.NewFromFloat(float64(i)), Currency: money.INR}
-			newEntry := &models.Ledger{
-				SenderUserID:    userID,
-				ReceiverUserID:  2,
-				Amount:          transferAmount,
-				TransactionType: string(models.TransactionTypeTransfer),
-				CreatedAt:       time.Now().Add(time.Duration(-i) * time.Minute),
-			}
-			err := db.CreateLedgerEntry(newEntry)
+			err := db.CreateJournalEntry(transferEntry(float64(i), time.Now().Add(time.Duration(-i)*time.Minute)))
 			assert.NoError(t, err)
 		}
 
-		entries, err := db.GetLastNLedgerEntries(userID, numEntries)
+		postings, err := db.GetLastNPostings(userID, numEntries)
 
 		assert.NoError(t, err)
-		assert.NotEmpty(t, entries)
+		assert.Len(t, postings, numEntries)
 
-		assert.Len(t, entries, numEntries)
+		for _, posting := range postings {
+			assert.Equal(t, userID, posting.UserID)
+			assert.Equal(t, models.PostingDirectionDebit, posting.Direction)
+		}
+	})
 
-		for _, entry := range entries {
-			assert.Equal(t, userID, entry.SenderUserID)
+	t.Run("GetWalletBalanceFromPostings method to derive the balance from credits minus debits", func(t *testing.T) {
+		fundedWallet := &models.Wallet{ID: 42, UserID: 42}
+		inr := func(amount float64) *money.Money {
+			return &money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
 		}
+
+		err := db.CreateJournalEntry(&models.JournalEntry{
+			TransactionType: string(models.TransactionTypeAdd),
+			Postings: []*models.Posting{
+				models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, inr(80)),
+				models.NewWalletPosting(fundedWallet, models.PostingDirectionCredit, inr(80)),
+			},
+		})
+		assert.NoError(t, err)
+
+		err = db.CreateJournalEntry(&models.JournalEntry{
+			TransactionType: string(models.TransactionTypeWithdraw),
+			Postings: []*models.Posting{
+				models.NewWalletPosting(fundedWallet, models.PostingDirectionDebit, inr(30)),
+				models.NewSystemPosting(models.SystemAccountExternalPayout, models.PostingDirectionCredit, inr(30)),
+			},
+		})
+		assert.NoError(t, err)
+
+		balance, err := db.GetWalletBalanceFromPostings(fundedWallet.ID)
+		assert.NoError(t, err)
+		assert.True(t, balance.Equal(decimal.NewFromFloat(50)), "got %s, want 50", balance)
 	})
 }
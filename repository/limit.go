@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"fmt"
+
+	"nikwallet/repository/models"
+
+	"gorm.io/gorm/clause"
+)
+
+func (db *PostgreSQL) ListUserLimits(userID int) ([]*models.UserLimit, error) {
+	var limits []*models.UserLimit
+	err := db.DB.Where("user_id = ?", userID).Order("transaction_type ASC").Find(&limits).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limits of user %d: %w", userID, err)
+	}
+	return limits, nil
+}
+
+// SaveUserLimit creates the override of the user for its transaction type or
+// replaces the one that exists.
+func (db *PostgreSQL) SaveUserLimit(limit *models.UserLimit) error {
+	err := db.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "transaction_type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"min_amount", "max_amount", "daily_amount", "monthly_amount", "max_per_hour", "updated_at"}),
+	}).Create(limit).Error
+	if err != nil {
+		return fmt.Errorf("failed to save limit: %w", err)
+	}
+	return nil
+}
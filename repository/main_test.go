@@ -2,29 +2,22 @@ package repository
 
 import (
 	"log"
-	"nikwallet/config"
 	"os"
 	"testing"
 )
 
-var db *PostgreSQL
+var db Store
 
 func TestMain(m *testing.M) {
-	c, err := config.LoadConfig()
-
-	if err != nil {
-		log.Fatalln("Failed at config", err)
-	}
-
-	db = &PostgreSQL{}
-	err = db.Connect(&c)
+	store, closeStore, err := OpenTestStore()
 	if err != nil {
-		log.Panic("failed to connect to database:", err)
+		log.Fatalln("failed to open test store:", err)
 	}
+	db = store
 
 	exitCode := m.Run()
 
-	db.Close()
+	closeStore()
 
 	os.Exit(exitCode)
 }
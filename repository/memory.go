@@ -0,0 +1,1357 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// MemoryStore is an in-process Store with the same observable semantics as
+// PostgreSQL. Transactions are serialized behind a single mutex and roll back
+// by restoring a snapshot of the state taken when they began.
+type MemoryStore struct {
+	mu    *sync.Mutex
+	state *memoryState
+	inTx  bool
+}
+
+type memoryState struct {
+	users          map[int]*models.User
+	sessions       map[int]*models.Session
+	refreshTokens  map[int]*models.RefreshToken
+	revokedTokens  map[string]*models.RevokedToken
+	wallets        map[int]*models.Wallet
+	journalEntries map[int]*models.JournalEntry
+	postings       map[int]*models.Posting
+	exchangeRates  map[int]*models.ExchangeRate
+	fxQuotes       map[string]*models.FXQuote
+	idempotency    map[int]*models.IdempotencyKey
+	snapshots      map[int]*models.BalanceSnapshot
+	holds          map[int]*models.Hold
+	userLimits     map[int]*models.UserLimit
+	kycSubmissions map[int]*models.KYCSubmission
+	kycEvents      map[int]*models.KYCEvent
+	roleChanges    map[int]*models.RoleChange
+
+	lastUserID           int
+	lastSessionID        int
+	lastRefreshTokenID   int
+	lastWalletID         int
+	lastJournalEntryID   int
+	lastPostingID        int
+	lastExchangeRateID   int
+	lastIdempotencyKeyID int
+	lastSnapshotID       int
+	lastHoldID           int
+	lastUserLimitID      int
+	lastKYCSubmissionID  int
+	lastKYCEventID       int
+	lastRoleChangeID     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu: &sync.Mutex{},
+		state: &memoryState{
+			users:          map[int]*models.User{},
+			sessions:       map[int]*models.Session{},
+			refreshTokens:  map[int]*models.RefreshToken{},
+			revokedTokens:  map[string]*models.RevokedToken{},
+			wallets:        map[int]*models.Wallet{},
+			journalEntries: map[int]*models.JournalEntry{},
+			postings:       map[int]*models.Posting{},
+			exchangeRates:  map[int]*models.ExchangeRate{},
+			fxQuotes:       map[string]*models.FXQuote{},
+			idempotency:    map[int]*models.IdempotencyKey{},
+			snapshots:      map[int]*models.BalanceSnapshot{},
+			holds:          map[int]*models.Hold{},
+			userLimits:     map[int]*models.UserLimit{},
+			kycSubmissions: map[int]*models.KYCSubmission{},
+			kycEvents:      map[int]*models.KYCEvent{},
+			roleChanges:    map[int]*models.RoleChange{},
+		},
+	}
+}
+
+func (m *MemoryStore) lock() func() {
+	if m.inTx {
+		return func() {}
+	}
+	m.mu.Lock()
+	return m.mu.Unlock
+}
+
+func (m *MemoryStore) Transaction(fn func(tx Store) error) (err error) {
+	if !m.inTx {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+	}
+
+	// A nested transaction rolls back on its own, like a savepoint does.
+	snapshot := m.state.clone()
+	defer func() {
+		if r := recover(); r != nil {
+			*m.state = *snapshot
+			panic(r)
+		}
+		if err != nil {
+			*m.state = *snapshot
+		}
+	}()
+
+	return fn(&MemoryStore{mu: m.mu, state: m.state, inTx: true})
+}
+
+func (m *MemoryStore) CreateUser(newUser *models.User) (int, error) {
+	defer m.lock()()
+
+	for _, user := range m.state.users {
+		if user.EmailID == newUser.EmailID {
+			return 0, fmt.Errorf("failed to create user: duplicate key value violates unique constraint on email_id")
+		}
+	}
+
+	now := time.Now()
+	m.state.lastUserID++
+	newUser.ID = uint(m.state.lastUserID)
+	if newUser.KYCLevel == "" {
+		newUser.KYCLevel = models.KYCLevelUnverified
+	}
+	if newUser.Role == "" {
+		newUser.Role = models.RoleCustomer
+	}
+	if newUser.CreatedAt.IsZero() {
+		newUser.CreatedAt = now
+	}
+	if newUser.UpdatedAt.IsZero() {
+		newUser.UpdatedAt = now
+	}
+
+	m.state.users[m.state.lastUserID] = cloneUser(newUser)
+	return int(newUser.ID), nil
+}
+
+func (m *MemoryStore) GetUserByID(id int) (*models.User, error) {
+	defer m.lock()()
+
+	user, ok := m.state.users[id]
+	if !ok || user.DeletedAt.Valid {
+		return nil, fmt.Errorf("failed to get user: %w", gorm.ErrRecordNotFound)
+	}
+	return cloneUser(user), nil
+}
+
+func (m *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+	defer m.lock()()
+
+	for _, id := range sortedKeys(m.state.users) {
+		user := m.state.users[id]
+		if user.EmailID == email && !user.DeletedAt.Valid {
+			return cloneUser(user), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get user: %v", email)
+}
+
+func (m *MemoryStore) ListUsers(afterID int, limit int) ([]*models.User, error) {
+	defer m.lock()()
+
+	users := []*models.User{}
+	for _, id := range sortedKeys(m.state.users) {
+		user := m.state.users[id]
+		if id <= afterID || user.DeletedAt.Valid {
+			continue
+		}
+		if len(users) == limit {
+			break
+		}
+		users = append(users, cloneUser(user))
+	}
+	return users, nil
+}
+
+func (m *MemoryStore) UpdateUserPassword(userID int, password string) error {
+	defer m.lock()()
+
+	user, ok := m.state.users[userID]
+	if !ok || user.DeletedAt.Valid {
+		return fmt.Errorf("failed to update user password: %w", gorm.ErrRecordNotFound)
+	}
+	user.Password = password
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) SearchUsers(query UserQuery) ([]*models.User, error) {
+	defer m.lock()()
+
+	users := []*models.User{}
+	for _, id := range sortedKeys(m.state.users) {
+		user := m.state.users[id]
+		if id <= query.AfterID || user.DeletedAt.Valid {
+			continue
+		}
+		if query.Email != "" && !strings.Contains(strings.ToLower(user.EmailID), strings.ToLower(query.Email)) {
+			continue
+		}
+		if query.Role != "" && user.Role != query.Role {
+			continue
+		}
+		if len(users) == query.Limit {
+			break
+		}
+		users = append(users, cloneUser(user))
+	}
+	return users, nil
+}
+
+func (m *MemoryStore) UpdateUserRole(userID int, role models.Role) error {
+	defer m.lock()()
+
+	user, ok := m.state.users[userID]
+	if !ok || user.DeletedAt.Valid {
+		return fmt.Errorf("failed to update user role: %w", gorm.ErrRecordNotFound)
+	}
+	user.Role = role
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) CreateRoleChange(newChange *models.RoleChange) error {
+	defer m.lock()()
+
+	if _, ok := m.state.users[newChange.UserID]; !ok {
+		return fmt.Errorf("failed to create role change: user %d does not exist", newChange.UserID)
+	}
+
+	m.state.lastRoleChangeID++
+	newChange.ID = m.state.lastRoleChangeID
+	if newChange.CreatedAt.IsZero() {
+		newChange.CreatedAt = time.Now()
+	}
+	copied := *newChange
+	m.state.roleChanges[newChange.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) ListRoleChanges(userID int) ([]*models.RoleChange, error) {
+	defer m.lock()()
+
+	var changes []*models.RoleChange
+	for _, id := range sortedKeys(m.state.roleChanges) {
+		if change := m.state.roleChanges[id]; change.UserID == userID {
+			copied := *change
+			changes = append(changes, &copied)
+		}
+	}
+	return changes, nil
+}
+
+// LockUsersForUpdate only checks that the users exist; transactions of the
+// memory store already run one at a time.
+func (m *MemoryStore) LockUsersForUpdate(userIDs ...int) error {
+	defer m.lock()()
+
+	for _, id := range userIDs {
+		if user, ok := m.state.users[id]; !ok || user.DeletedAt.Valid {
+			return fmt.Errorf("failed to lock user %d: %w", id, gorm.ErrRecordNotFound)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserFrozen(userID int, frozenAt *time.Time, frozenBy int, reason string) error {
+	defer m.lock()()
+
+	user, ok := m.state.users[userID]
+	if !ok || user.DeletedAt.Valid {
+		return fmt.Errorf("failed to update user frozen state: %w", gorm.ErrRecordNotFound)
+	}
+	user.FrozenAt = cloneTime(frozenAt)
+	user.FrozenBy = frozenBy
+	user.FrozenReason = reason
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) CreateSession(newSession *models.Session) error {
+	defer m.lock()()
+
+	m.state.lastSessionID++
+	newSession.ID = m.state.lastSessionID
+	if newSession.CreatedAt.IsZero() {
+		newSession.CreatedAt = time.Now()
+	}
+	m.state.sessions[newSession.ID] = cloneSession(newSession)
+	return nil
+}
+
+func (m *MemoryStore) GetSessionByID(id int) (*models.Session, error) {
+	defer m.lock()()
+
+	session, ok := m.state.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get session: %w", gorm.ErrRecordNotFound)
+	}
+	return cloneSession(session), nil
+}
+
+func (m *MemoryStore) ListActiveSessions(userID int, now time.Time) ([]*models.Session, error) {
+	defer m.lock()()
+
+	sessions := []*models.Session{}
+	for _, session := range m.state.sessions {
+		if session.UserID == userID && session.Active(now) {
+			sessions = append(sessions, cloneSession(session))
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastUsedAt.Equal(sessions[j].LastUsedAt) {
+			return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions, nil
+}
+
+func (m *MemoryStore) UpdateSession(changedSession *models.Session) error {
+	defer m.lock()()
+
+	m.state.sessions[changedSession.ID] = cloneSession(changedSession)
+	return nil
+}
+
+func (m *MemoryStore) CreateRefreshToken(newToken *models.RefreshToken) error {
+	defer m.lock()()
+
+	for _, token := range m.state.refreshTokens {
+		if token.TokenHash == newToken.TokenHash {
+			return fmt.Errorf("failed to create refresh token: duplicate key value violates unique constraint on token_hash")
+		}
+	}
+
+	m.state.lastRefreshTokenID++
+	newToken.ID = m.state.lastRefreshTokenID
+	if newToken.CreatedAt.IsZero() {
+		newToken.CreatedAt = time.Now()
+	}
+	m.state.refreshTokens[newToken.ID] = cloneRefreshToken(newToken)
+	return nil
+}
+
+func (m *MemoryStore) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	defer m.lock()()
+
+	for _, token := range m.state.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return cloneRefreshToken(token), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get refresh token: %w", gorm.ErrRecordNotFound)
+}
+
+func (m *MemoryStore) MarkRefreshTokenUsed(id int, usedAt time.Time) (bool, error) {
+	defer m.lock()()
+
+	token, ok := m.state.refreshTokens[id]
+	if !ok || token.UsedAt != nil {
+		return false, nil
+	}
+	token.UsedAt = &usedAt
+	return true, nil
+}
+
+func (m *MemoryStore) RevokeToken(revokedToken *models.RevokedToken) error {
+	defer m.lock()()
+
+	if _, ok := m.state.revokedTokens[revokedToken.TokenID]; !ok {
+		c := *revokedToken
+		m.state.revokedTokens[revokedToken.TokenID] = &c
+	}
+	return nil
+}
+
+func (m *MemoryStore) IsTokenRevoked(tokenID string, now time.Time) (bool, error) {
+	defer m.lock()()
+
+	revokedToken, ok := m.state.revokedTokens[tokenID]
+	return ok && revokedToken.ExpiresAt.After(now), nil
+}
+
+func (m *MemoryStore) DeleteExpiredRevokedTokens(now time.Time) (int64, error) {
+	defer m.lock()()
+
+	var deleted int64
+	for id, revokedToken := range m.state.revokedTokens {
+		if !revokedToken.ExpiresAt.After(now) {
+			delete(m.state.revokedTokens, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (m *MemoryStore) CreateWallet(newWallet *models.Wallet) (*models.Wallet, error) {
+	defer m.lock()()
+
+	if err := checkWalletAmount(newWallet); err != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	}
+
+	for _, wallet := range m.state.wallets {
+		if wallet.UserID != newWallet.UserID {
+			continue
+		}
+		if wallet.Money.Currency == newWallet.Money.Currency && wallet.Name == newWallet.Name {
+			return nil, ErrWalletExists
+		}
+		if wallet.IsDefault && newWallet.IsDefault {
+			return nil, fmt.Errorf("failed to create wallet: duplicate key value violates unique constraint on default wallet")
+		}
+	}
+
+	m.insertWallet(newWallet)
+	return newWallet, nil
+}
+
+func (m *MemoryStore) GetWalletByID(id int) (*models.Wallet, error) {
+	defer m.lock()()
+
+	wallet, ok := m.state.wallets[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get wallet %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return cloneWallet(wallet), nil
+}
+
+func (m *MemoryStore) GetWalletByUserID(userID int) (*models.Wallet, error) {
+	defer m.lock()()
+
+	var found *models.Wallet
+	for _, id := range sortedKeys(m.state.wallets) {
+		wallet := m.state.wallets[id]
+		if wallet.UserID != userID {
+			continue
+		}
+		if wallet.IsDefault {
+			return cloneWallet(wallet), nil
+		}
+		if found == nil {
+			found = wallet
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no wallets found for user with ID %d", userID)
+	}
+	return cloneWallet(found), nil
+}
+
+func (m *MemoryStore) ListWalletsByUserID(userID int) ([]*models.Wallet, error) {
+	defer m.lock()()
+
+	wallets := []*models.Wallet{}
+	for _, id := range sortedKeys(m.state.wallets) {
+		if wallet := m.state.wallets[id]; wallet.UserID == userID {
+			wallets = append(wallets, cloneWallet(wallet))
+		}
+	}
+	return wallets, nil
+}
+
+func (m *MemoryStore) SetDefaultWallet(userID int, walletID int) error {
+	defer m.lock()()
+
+	target, ok := m.state.wallets[walletID]
+	if !ok || target.UserID != userID {
+		return fmt.Errorf("failed to set default wallet: %w", gorm.ErrRecordNotFound)
+	}
+
+	for _, wallet := range m.state.wallets {
+		if wallet.UserID == userID {
+			wallet.IsDefault = wallet.ID == walletID
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) LockWalletsForUpdate(walletIDs ...int) (map[int]*models.Wallet, error) {
+	defer m.lock()()
+
+	wallets := make(map[int]*models.Wallet, len(walletIDs))
+	for _, id := range walletIDs {
+		wallet, ok := m.state.wallets[id]
+		if !ok {
+			return nil, fmt.Errorf("failed to lock wallet %d: %w", id, gorm.ErrRecordNotFound)
+		}
+		wallets[id] = cloneWallet(wallet)
+	}
+	return wallets, nil
+}
+
+func (m *MemoryStore) UpdateWallet(changedWallet *models.Wallet) (*models.Wallet, error) {
+	defer m.lock()()
+
+	if err := checkWalletAmount(changedWallet); err != nil {
+		return nil, fmt.Errorf("failed to update wallet: %w", err)
+	}
+
+	if _, ok := m.state.wallets[changedWallet.ID]; !ok {
+		m.insertWallet(changedWallet)
+		return changedWallet, nil
+	}
+
+	changedWallet.UpdatedAt = time.Now()
+	m.state.wallets[changedWallet.ID] = cloneWallet(changedWallet)
+	return changedWallet, nil
+}
+
+// walletAmountConstraint is added by the 0007_money_columns migration and
+// walletHeldAmountConstraint by 0010_holds.
+const (
+	walletAmountConstraint     = "chk_wallets_amount_non_negative"
+	walletHeldAmountConstraint = "chk_wallets_held_amount"
+)
+
+// checkWalletAmount mirrors the CHECK constraints on wallets.amount and
+// wallets.held_amount.
+func checkWalletAmount(wallet *models.Wallet) error {
+	if wallet.Money != nil && wallet.Money.Amount.IsNegative() {
+		return fmt.Errorf("new row violates check constraint %q", walletAmountConstraint)
+	}
+	if wallet.HeldAmount.IsNegative() || (wallet.Money != nil && wallet.HeldAmount.GreaterThan(wallet.Money.Amount)) {
+		return fmt.Errorf("new row violates check constraint %q", walletHeldAmountConstraint)
+	}
+	return nil
+}
+
+func (m *MemoryStore) insertWallet(newWallet *models.Wallet) {
+	now := time.Now()
+	if newWallet.ID == 0 {
+		m.state.lastWalletID++
+		newWallet.ID = m.state.lastWalletID
+	} else if newWallet.ID > m.state.lastWalletID {
+		m.state.lastWalletID = newWallet.ID
+	}
+	if newWallet.CreatedAt.IsZero() {
+		newWallet.CreatedAt = now
+	}
+	if newWallet.UpdatedAt.IsZero() {
+		newWallet.UpdatedAt = now
+	}
+	m.state.wallets[newWallet.ID] = cloneWallet(newWallet)
+}
+
+func (m *MemoryStore) CreateJournalEntry(newEntry *models.JournalEntry) error {
+	if err := newEntry.Validate(); err != nil {
+		return fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	defer m.lock()()
+
+	stampJournalEntry(newEntry)
+
+	m.state.lastJournalEntryID++
+	newEntry.ID = m.state.lastJournalEntryID
+	for _, posting := range newEntry.Postings {
+		m.state.lastPostingID++
+		posting.ID = m.state.lastPostingID
+		posting.JournalEntryID = newEntry.ID
+		m.state.postings[posting.ID] = clonePosting(posting)
+	}
+
+	header := *newEntry
+	header.Postings = nil
+	m.state.journalEntries[newEntry.ID] = &header
+	return nil
+}
+
+func (m *MemoryStore) GetJournalEntryByID(id int) (*models.JournalEntry, error) {
+	defer m.lock()()
+
+	return m.journalEntryByID(id)
+}
+
+func (m *MemoryStore) ListReversingEntries(entryID int) ([]*models.JournalEntry, error) {
+	defer m.lock()()
+
+	var entries []*models.JournalEntry
+	for _, id := range sortedKeys(m.state.journalEntries) {
+		if m.state.journalEntries[id].ReversedEntryID != entryID {
+			continue
+		}
+		entry, err := m.journalEntryByID(id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (m *MemoryStore) journalEntryByID(id int) (*models.JournalEntry, error) {
+	header, ok := m.state.journalEntries[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to retrieve journal entry: %w", gorm.ErrRecordNotFound)
+	}
+
+	entry := *header
+	entry.Postings = nil
+	for _, postingID := range sortedKeys(m.state.postings) {
+		posting := m.state.postings[postingID]
+		if posting.JournalEntryID == id {
+			entry.Postings = append(entry.Postings, clonePosting(posting))
+		}
+	}
+	return &entry, nil
+}
+
+func (m *MemoryStore) GetLatestJournalEntry(userID int) (*models.JournalEntry, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.UserID == userID
+	})
+	if len(postings) == 0 {
+		return nil, fmt.Errorf("failed to retrieve latest journal entry: %w", gorm.ErrRecordNotFound)
+	}
+	return m.journalEntryByID(postings[0].JournalEntryID)
+}
+
+func (m *MemoryStore) GetLastNPostings(userID, limit int) ([]*models.Posting, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.UserID == userID
+	})
+	if limit >= 0 && len(postings) > limit {
+		postings = postings[:limit]
+	}
+	return postings, nil
+}
+
+func (m *MemoryStore) GetLastNWalletPostings(walletID, limit int) ([]*models.Posting, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.WalletID == walletID
+	})
+	if limit >= 0 && len(postings) > limit {
+		postings = postings[:limit]
+	}
+	return postings, nil
+}
+
+func (m *MemoryStore) ListPostings(query PostingQuery) ([]*models.Posting, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.UserID == query.UserID && query.matches(p)
+	})
+	if query.Ascending {
+		for i, j := 0, len(postings)-1; i < j; i, j = i+1, j-1 {
+			postings[i], postings[j] = postings[j], postings[i]
+		}
+	}
+	if query.Limit > 0 && len(postings) > query.Limit {
+		postings = postings[:query.Limit]
+	}
+	return postings, nil
+}
+
+func (m *MemoryStore) SumDebits(query DebitQuery) ([]DebitTotal, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.UserID == query.UserID &&
+			p.Direction == models.PostingDirectionDebit && p.TransactionType == query.TransactionType &&
+			!p.CreatedAt.Before(query.Since) && p.CounterpartyUserID != p.UserID
+	})
+
+	totals := map[money.Currency]*DebitTotal{}
+	entries := map[money.Currency]map[int]bool{}
+	for _, posting := range postings {
+		currency := posting.Amount.Currency
+		if totals[currency] == nil {
+			totals[currency] = &DebitTotal{Currency: currency, Amount: decimal.Zero}
+			entries[currency] = map[int]bool{}
+		}
+		totals[currency].Amount = totals[currency].Amount.Add(posting.Amount.Amount)
+		entries[currency][posting.JournalEntryID] = true
+	}
+
+	result := make([]DebitTotal, 0, len(totals))
+	for currency, total := range totals {
+		total.Count = len(entries[currency])
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result, nil
+}
+
+// matches applies every filter of the query except the user to p, mirroring
+// the WHERE clause of the Postgres implementation.
+func (query PostingQuery) matches(p *models.Posting) bool {
+	if query.WalletID != 0 && p.WalletID != query.WalletID {
+		return false
+	}
+	if len(query.TransactionTypes) > 0 && !containsString(query.TransactionTypes, p.TransactionType) {
+		return false
+	}
+	if query.CounterpartyUserID != 0 && p.CounterpartyUserID != query.CounterpartyUserID {
+		return false
+	}
+	if query.Currency != "" && p.Amount.Currency != query.Currency {
+		return false
+	}
+	if query.MinAmount.Valid && p.Amount.Amount.LessThan(query.MinAmount.Decimal) {
+		return false
+	}
+	if query.MaxAmount.Valid && p.Amount.Amount.GreaterThan(query.MaxAmount.Decimal) {
+		return false
+	}
+	if !query.From.IsZero() && p.CreatedAt.Before(query.From) {
+		return false
+	}
+	if !query.To.IsZero() && !p.CreatedAt.Before(query.To) {
+		return false
+	}
+	if query.After != nil {
+		after := query.After
+		newer := p.CreatedAt.After(after.CreatedAt) || (p.CreatedAt.Equal(after.CreatedAt) && p.ID > after.ID)
+		older := p.CreatedAt.Before(after.CreatedAt) || (p.CreatedAt.Equal(after.CreatedAt) && p.ID < after.ID)
+		if (query.Ascending && !newer) || (!query.Ascending && !older) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) GetWalletBalanceFromPostings(walletID int) (decimal.Decimal, error) {
+	defer m.lock()()
+
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && p.WalletID == walletID
+	})
+	return models.BalanceFromPostings(postings), nil
+}
+
+func (m *MemoryStore) GetWalletBalanceAt(walletID int, at time.Time) (decimal.Decimal, error) {
+	defer m.lock()()
+
+	return m.walletBalanceAt(walletID, at), nil
+}
+
+func (m *MemoryStore) CreateBalanceSnapshots(at time.Time) (int64, error) {
+	defer m.lock()()
+
+	latest := map[int]*models.BalanceSnapshot{}
+	for _, posting := range m.postingsWhere(func(p *models.Posting) bool {
+		return p.AccountType == models.AccountTypeWallet && !p.CreatedAt.After(at)
+	}) {
+		if _, ok := latest[posting.WalletID]; !ok {
+			latest[posting.WalletID] = &models.BalanceSnapshot{WalletID: posting.WalletID, PostingID: posting.ID, PostedAt: posting.CreatedAt}
+		}
+	}
+
+	var created int64
+	now := time.Now()
+	for _, walletID := range sortedKeys(latest) {
+		snapshot := latest[walletID]
+		if previous := m.latestSnapshot(walletID, at); previous != nil && previous.PostingID == snapshot.PostingID {
+			continue
+		}
+		snapshot.Balance = m.walletBalanceAt(walletID, at)
+		snapshot.CreatedAt = now
+		m.state.lastSnapshotID++
+		snapshot.ID = m.state.lastSnapshotID
+		m.state.snapshots[snapshot.ID] = snapshot
+		created++
+	}
+	return created, nil
+}
+
+// walletBalanceAt mirrors GetWalletBalanceAt of the Postgres store: the latest
+// snapshot up to at plus the postings after it.
+func (m *MemoryStore) walletBalanceAt(walletID int, at time.Time) decimal.Decimal {
+	snapshot := m.latestSnapshot(walletID, at)
+	postings := m.postingsWhere(func(p *models.Posting) bool {
+		if p.AccountType != models.AccountTypeWallet || p.WalletID != walletID || p.CreatedAt.After(at) {
+			return false
+		}
+		return snapshot == nil || p.CreatedAt.After(snapshot.PostedAt) || (p.CreatedAt.Equal(snapshot.PostedAt) && p.ID > snapshot.PostingID)
+	})
+
+	balance := models.BalanceFromPostings(postings)
+	if snapshot != nil {
+		balance = balance.Add(snapshot.Balance)
+	}
+	return balance
+}
+
+func (m *MemoryStore) latestSnapshot(walletID int, at time.Time) *models.BalanceSnapshot {
+	var latest *models.BalanceSnapshot
+	for _, snapshot := range m.state.snapshots {
+		if snapshot.WalletID != walletID || snapshot.PostedAt.After(at) {
+			continue
+		}
+		if latest == nil || snapshot.PostedAt.After(latest.PostedAt) || (snapshot.PostedAt.Equal(latest.PostedAt) && snapshot.PostingID > latest.PostingID) {
+			latest = snapshot
+		}
+	}
+	return latest
+}
+
+// postingsWhere returns copies of the matching postings, newest first.
+func (m *MemoryStore) postingsWhere(match func(*models.Posting) bool) []*models.Posting {
+	var postings []*models.Posting
+	for _, posting := range m.state.postings {
+		if match(posting) {
+			postings = append(postings, clonePosting(posting))
+		}
+	}
+
+	sort.Slice(postings, func(i, j int) bool {
+		if !postings[i].CreatedAt.Equal(postings[j].CreatedAt) {
+			return postings[i].CreatedAt.After(postings[j].CreatedAt)
+		}
+		return postings[i].ID > postings[j].ID
+	})
+	return postings
+}
+
+func (m *MemoryStore) ListUserLimits(userID int) ([]*models.UserLimit, error) {
+	defer m.lock()()
+
+	var limits []*models.UserLimit
+	for _, id := range sortedKeys(m.state.userLimits) {
+		if limit := m.state.userLimits[id]; limit.UserID == userID {
+			limits = append(limits, cloneUserLimit(limit))
+		}
+	}
+	sort.SliceStable(limits, func(i, j int) bool {
+		return limits[i].TransactionType < limits[j].TransactionType
+	})
+	return limits, nil
+}
+
+func (m *MemoryStore) SaveUserLimit(limit *models.UserLimit) error {
+	defer m.lock()()
+
+	if _, ok := m.state.users[limit.UserID]; !ok {
+		return fmt.Errorf("failed to save limit: user %d does not exist", limit.UserID)
+	}
+
+	now := time.Now()
+	if limit.UpdatedAt.IsZero() {
+		limit.UpdatedAt = now
+	}
+	for _, existing := range m.state.userLimits {
+		if existing.UserID == limit.UserID && existing.TransactionType == limit.TransactionType {
+			limit.ID = existing.ID
+			limit.CreatedAt = existing.CreatedAt
+			m.state.userLimits[limit.ID] = cloneUserLimit(limit)
+			return nil
+		}
+	}
+
+	m.state.lastUserLimitID++
+	limit.ID = m.state.lastUserLimitID
+	if limit.CreatedAt.IsZero() {
+		limit.CreatedAt = now
+	}
+	m.state.userLimits[limit.ID] = cloneUserLimit(limit)
+	return nil
+}
+
+func (m *MemoryStore) UpdateUserKYCLevel(userID int, level models.KYCLevel) error {
+	defer m.lock()()
+
+	user, ok := m.state.users[userID]
+	if !ok || user.DeletedAt.Valid {
+		return fmt.Errorf("failed to update kyc level: %w", gorm.ErrRecordNotFound)
+	}
+	user.KYCLevel = level
+	user.UpdatedAt = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) CreateKYCSubmission(newSubmission *models.KYCSubmission) error {
+	defer m.lock()()
+
+	if _, ok := m.state.users[newSubmission.UserID]; !ok {
+		return fmt.Errorf("failed to create kyc submission: user %d does not exist", newSubmission.UserID)
+	}
+	if newSubmission.Status == models.KYCSubmissionPending {
+		for _, submission := range m.state.kycSubmissions {
+			if submission.UserID == newSubmission.UserID && submission.Status == models.KYCSubmissionPending {
+				return ErrKYCSubmissionPending
+			}
+		}
+	}
+
+	m.state.lastKYCSubmissionID++
+	newSubmission.ID = m.state.lastKYCSubmissionID
+	if newSubmission.CreatedAt.IsZero() {
+		newSubmission.CreatedAt = time.Now()
+	}
+	m.state.kycSubmissions[newSubmission.ID] = cloneKYCSubmission(newSubmission)
+	return nil
+}
+
+func (m *MemoryStore) GetKYCSubmissionByID(id int) (*models.KYCSubmission, error) {
+	defer m.lock()()
+
+	submission, ok := m.state.kycSubmissions[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get kyc submission %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return cloneKYCSubmission(submission), nil
+}
+
+func (m *MemoryStore) ListKYCSubmissions(query KYCSubmissionQuery) ([]*models.KYCSubmission, error) {
+	defer m.lock()()
+
+	var submissions []*models.KYCSubmission
+	for _, id := range sortedKeys(m.state.kycSubmissions) {
+		submission := m.state.kycSubmissions[id]
+		if id <= query.AfterID ||
+			(query.UserID != 0 && submission.UserID != query.UserID) ||
+			(query.Status != "" && submission.Status != query.Status) {
+			continue
+		}
+		submissions = append(submissions, cloneKYCSubmission(submission))
+		if query.Limit > 0 && len(submissions) == query.Limit {
+			break
+		}
+	}
+	return submissions, nil
+}
+
+func (m *MemoryStore) MarkKYCSubmissionReviewed(reviewed *models.KYCSubmission) (bool, error) {
+	defer m.lock()()
+
+	submission, ok := m.state.kycSubmissions[reviewed.ID]
+	if !ok || submission.Status != models.KYCSubmissionPending {
+		return false, nil
+	}
+	submission.Status = reviewed.Status
+	submission.ReviewerID = reviewed.ReviewerID
+	submission.ReviewNote = reviewed.ReviewNote
+	submission.ReviewedAt = cloneTime(reviewed.ReviewedAt)
+	return true, nil
+}
+
+func (m *MemoryStore) CreateKYCEvent(newEvent *models.KYCEvent) error {
+	defer m.lock()()
+
+	if _, ok := m.state.kycSubmissions[newEvent.SubmissionID]; !ok {
+		return fmt.Errorf("failed to create kyc event: submission %d does not exist", newEvent.SubmissionID)
+	}
+
+	m.state.lastKYCEventID++
+	newEvent.ID = m.state.lastKYCEventID
+	if newEvent.CreatedAt.IsZero() {
+		newEvent.CreatedAt = time.Now()
+	}
+	copied := *newEvent
+	m.state.kycEvents[newEvent.ID] = &copied
+	return nil
+}
+
+func (m *MemoryStore) ListKYCEvents(userID int) ([]*models.KYCEvent, error) {
+	defer m.lock()()
+
+	var events []*models.KYCEvent
+	for _, id := range sortedKeys(m.state.kycEvents) {
+		if event := m.state.kycEvents[id]; event.UserID == userID {
+			copied := *event
+			events = append(events, &copied)
+		}
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) CreateHold(newHold *models.Hold) error {
+	defer m.lock()()
+
+	if _, ok := m.state.wallets[newHold.WalletID]; !ok {
+		return fmt.Errorf("failed to create hold: wallet %d does not exist", newHold.WalletID)
+	}
+
+	m.state.lastHoldID++
+	newHold.ID = m.state.lastHoldID
+	now := time.Now()
+	if newHold.CreatedAt.IsZero() {
+		newHold.CreatedAt = now
+	}
+	if newHold.UpdatedAt.IsZero() {
+		newHold.UpdatedAt = now
+	}
+	m.state.holds[newHold.ID] = cloneHold(newHold)
+	return nil
+}
+
+func (m *MemoryStore) GetHoldByID(id int) (*models.Hold, error) {
+	defer m.lock()()
+
+	hold, ok := m.state.holds[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get hold %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return cloneHold(hold), nil
+}
+
+func (m *MemoryStore) UpdateHold(changedHold *models.Hold) error {
+	defer m.lock()()
+
+	if _, ok := m.state.holds[changedHold.ID]; !ok {
+		return fmt.Errorf("failed to update hold: %w", gorm.ErrRecordNotFound)
+	}
+	changedHold.UpdatedAt = time.Now()
+	m.state.holds[changedHold.ID] = cloneHold(changedHold)
+	return nil
+}
+
+func (m *MemoryStore) ListExpiredHolds(now time.Time, limit int) ([]*models.Hold, error) {
+	defer m.lock()()
+
+	var holds []*models.Hold
+	for _, id := range sortedKeys(m.state.holds) {
+		hold := m.state.holds[id]
+		if hold.Status == models.HoldStatusActive && !hold.ExpiresAt.After(now) {
+			holds = append(holds, cloneHold(hold))
+		}
+	}
+	sort.SliceStable(holds, func(i, j int) bool {
+		return holds[i].ExpiresAt.Before(holds[j].ExpiresAt)
+	})
+	if limit > 0 && len(holds) > limit {
+		holds = holds[:limit]
+	}
+	return holds, nil
+}
+
+func (m *MemoryStore) CreateExchangeRate(newRate *models.ExchangeRate) error {
+	defer m.lock()()
+
+	m.state.lastExchangeRateID++
+	newRate.ID = m.state.lastExchangeRateID
+	if newRate.CreatedAt.IsZero() {
+		newRate.CreatedAt = time.Now()
+	}
+	m.state.exchangeRates[newRate.ID] = cloneExchangeRate(newRate)
+	return nil
+}
+
+func (m *MemoryStore) GetLatestExchangeRate(base, quote money.Currency, at time.Time) (*models.ExchangeRate, error) {
+	defer m.lock()()
+
+	var latest *models.ExchangeRate
+	for _, id := range sortedKeys(m.state.exchangeRates) {
+		rate := m.state.exchangeRates[id]
+		if rate.BaseCurrency != base || rate.QuoteCurrency != quote || rate.EffectiveAt.After(at) {
+			continue
+		}
+		if latest == nil || !rate.EffectiveAt.Before(latest.EffectiveAt) {
+			latest = rate
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("failed to get exchange rate: %w", gorm.ErrRecordNotFound)
+	}
+	return cloneExchangeRate(latest), nil
+}
+
+func (m *MemoryStore) CreateFXQuote(newQuote *models.FXQuote) error {
+	defer m.lock()()
+
+	if _, ok := m.state.fxQuotes[newQuote.ID]; ok {
+		return fmt.Errorf("failed to create fx quote: duplicate key value violates unique constraint on id")
+	}
+	if newQuote.CreatedAt.IsZero() {
+		newQuote.CreatedAt = time.Now()
+	}
+	m.state.fxQuotes[newQuote.ID] = cloneFXQuote(newQuote)
+	return nil
+}
+
+func (m *MemoryStore) GetFXQuote(id string) (*models.FXQuote, error) {
+	defer m.lock()()
+
+	quote, ok := m.state.fxQuotes[id]
+	if !ok {
+		return nil, fmt.Errorf("failed to get fx quote: %w", gorm.ErrRecordNotFound)
+	}
+	return cloneFXQuote(quote), nil
+}
+
+func (m *MemoryStore) MarkFXQuoteUsed(id string, usedAt time.Time) (bool, error) {
+	defer m.lock()()
+
+	quote, ok := m.state.fxQuotes[id]
+	if !ok || !quote.Usable(usedAt) {
+		return false, nil
+	}
+	quote.UsedAt = &usedAt
+	return true, nil
+}
+
+func (m *MemoryStore) CreateIdempotencyKey(newKey *models.IdempotencyKey) error {
+	defer m.lock()()
+
+	for _, key := range m.state.idempotency {
+		if key.UserID == newKey.UserID && key.Key == newKey.Key {
+			return ErrIdempotencyKeyExists
+		}
+	}
+
+	m.state.lastIdempotencyKeyID++
+	newKey.ID = m.state.lastIdempotencyKeyID
+	if newKey.CreatedAt.IsZero() {
+		newKey.CreatedAt = time.Now()
+	}
+	m.state.idempotency[newKey.ID] = cloneIdempotencyKey(newKey)
+	return nil
+}
+
+func (m *MemoryStore) GetIdempotencyKey(userID int, key string) (*models.IdempotencyKey, error) {
+	defer m.lock()()
+
+	for _, idempotencyKey := range m.state.idempotency {
+		if idempotencyKey.UserID == userID && idempotencyKey.Key == key {
+			return cloneIdempotencyKey(idempotencyKey), nil
+		}
+	}
+	return nil, fmt.Errorf("failed to get idempotency key: %w", gorm.ErrRecordNotFound)
+}
+
+func (m *MemoryStore) UpdateIdempotencyKey(changedKey *models.IdempotencyKey) error {
+	defer m.lock()()
+
+	if _, ok := m.state.idempotency[changedKey.ID]; !ok {
+		return fmt.Errorf("failed to update idempotency key: %w", gorm.ErrRecordNotFound)
+	}
+	m.state.idempotency[changedKey.ID] = cloneIdempotencyKey(changedKey)
+	return nil
+}
+
+func (m *MemoryStore) DeleteIdempotencyKey(id int) error {
+	defer m.lock()()
+
+	delete(m.state.idempotency, id)
+	return nil
+}
+
+func (m *MemoryStore) DeleteExpiredIdempotencyKeys(now time.Time) (int64, error) {
+	defer m.lock()()
+
+	var deleted int64
+	for id, key := range m.state.idempotency {
+		if !key.ExpiresAt.After(now) {
+			delete(m.state.idempotency, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryState) clone() *memoryState {
+	c := &memoryState{
+		users:                make(map[int]*models.User, len(s.users)),
+		sessions:             make(map[int]*models.Session, len(s.sessions)),
+		refreshTokens:        make(map[int]*models.RefreshToken, len(s.refreshTokens)),
+		revokedTokens:        make(map[string]*models.RevokedToken, len(s.revokedTokens)),
+		wallets:              make(map[int]*models.Wallet, len(s.wallets)),
+		journalEntries:       make(map[int]*models.JournalEntry, len(s.journalEntries)),
+		postings:             make(map[int]*models.Posting, len(s.postings)),
+		exchangeRates:        make(map[int]*models.ExchangeRate, len(s.exchangeRates)),
+		fxQuotes:             make(map[string]*models.FXQuote, len(s.fxQuotes)),
+		idempotency:          make(map[int]*models.IdempotencyKey, len(s.idempotency)),
+		snapshots:            make(map[int]*models.BalanceSnapshot, len(s.snapshots)),
+		holds:                make(map[int]*models.Hold, len(s.holds)),
+		userLimits:           make(map[int]*models.UserLimit, len(s.userLimits)),
+		kycSubmissions:       make(map[int]*models.KYCSubmission, len(s.kycSubmissions)),
+		kycEvents:            make(map[int]*models.KYCEvent, len(s.kycEvents)),
+		roleChanges:          make(map[int]*models.RoleChange, len(s.roleChanges)),
+		lastUserID:           s.lastUserID,
+		lastSessionID:        s.lastSessionID,
+		lastRefreshTokenID:   s.lastRefreshTokenID,
+		lastWalletID:         s.lastWalletID,
+		lastJournalEntryID:   s.lastJournalEntryID,
+		lastPostingID:        s.lastPostingID,
+		lastExchangeRateID:   s.lastExchangeRateID,
+		lastIdempotencyKeyID: s.lastIdempotencyKeyID,
+		lastSnapshotID:       s.lastSnapshotID,
+		lastHoldID:           s.lastHoldID,
+		lastUserLimitID:      s.lastUserLimitID,
+		lastKYCSubmissionID:  s.lastKYCSubmissionID,
+		lastKYCEventID:       s.lastKYCEventID,
+		lastRoleChangeID:     s.lastRoleChangeID,
+	}
+	for id, user := range s.users {
+		c.users[id] = cloneUser(user)
+	}
+	for id, session := range s.sessions {
+		c.sessions[id] = cloneSession(session)
+	}
+	for id, token := range s.refreshTokens {
+		c.refreshTokens[id] = cloneRefreshToken(token)
+	}
+	for id, token := range s.revokedTokens {
+		revoked := *token
+		c.revokedTokens[id] = &revoked
+	}
+	for id, wallet := range s.wallets {
+		c.wallets[id] = cloneWallet(wallet)
+	}
+	for id, entry := range s.journalEntries {
+		header := *entry
+		c.journalEntries[id] = &header
+	}
+	for id, posting := range s.postings {
+		c.postings[id] = clonePosting(posting)
+	}
+	for id, rate := range s.exchangeRates {
+		c.exchangeRates[id] = cloneExchangeRate(rate)
+	}
+	for id, quote := range s.fxQuotes {
+		c.fxQuotes[id] = cloneFXQuote(quote)
+	}
+	for id, key := range s.idempotency {
+		c.idempotency[id] = cloneIdempotencyKey(key)
+	}
+	for id, snapshot := range s.snapshots {
+		copied := *snapshot
+		c.snapshots[id] = &copied
+	}
+	for id, hold := range s.holds {
+		c.holds[id] = cloneHold(hold)
+	}
+	for id, limit := range s.userLimits {
+		c.userLimits[id] = cloneUserLimit(limit)
+	}
+	for id, submission := range s.kycSubmissions {
+		c.kycSubmissions[id] = cloneKYCSubmission(submission)
+	}
+	for id, event := range s.kycEvents {
+		copied := *event
+		c.kycEvents[id] = &copied
+	}
+	for id, change := range s.roleChanges {
+		copied := *change
+		c.roleChanges[id] = &copied
+	}
+	return c
+}
+
+func sortedKeys[V any](items map[int]V) []int {
+	keys := make([]int, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func cloneUser(user *models.User) *models.User {
+	c := *user
+	c.FrozenAt = cloneTime(user.FrozenAt)
+	return &c
+}
+
+func cloneSession(session *models.Session) *models.Session {
+	c := *session
+	c.RevokedAt = cloneTime(session.RevokedAt)
+	return &c
+}
+
+func cloneRefreshToken(token *models.RefreshToken) *models.RefreshToken {
+	c := *token
+	c.UsedAt = cloneTime(token.UsedAt)
+	return &c
+}
+
+func cloneTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func cloneWallet(wallet *models.Wallet) *models.Wallet {
+	c := *wallet
+	c.Money = cloneMoney(wallet.Money)
+	return &c
+}
+
+func clonePosting(posting *models.Posting) *models.Posting {
+	c := *posting
+	c.Amount = cloneMoney(posting.Amount)
+	return &c
+}
+
+func cloneHold(hold *models.Hold) *models.Hold {
+	c := *hold
+	c.Amount = cloneMoney(hold.Amount)
+	return &c
+}
+
+func cloneUserLimit(limit *models.UserLimit) *models.UserLimit {
+	c := *limit
+	if limit.MaxPerHour != nil {
+		maxPerHour := *limit.MaxPerHour
+		c.MaxPerHour = &maxPerHour
+	}
+	return &c
+}
+
+func cloneKYCSubmission(submission *models.KYCSubmission) *models.KYCSubmission {
+	c := *submission
+	c.DocumentExpiresOn = cloneTime(submission.DocumentExpiresOn)
+	c.ReviewedAt = cloneTime(submission.ReviewedAt)
+	return &c
+}
+
+func cloneExchangeRate(rate *models.ExchangeRate) *models.ExchangeRate {
+	c := *rate
+	return &c
+}
+
+func cloneFXQuote(quote *models.FXQuote) *models.FXQuote {
+	c := *quote
+	c.UsedAt = cloneTime(quote.UsedAt)
+	return &c
+}
+
+func cloneIdempotencyKey(key *models.IdempotencyKey) *models.IdempotencyKey {
+	c := *key
+	c.ResponseBody = append([]byte(nil), key.ResponseBody...)
+	return &c
+}
+
+func cloneMoney(m *money.Money) *money.Money {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	return &c
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMigrations(t *testing.T) {
+	t.Run("Migrations to return the embedded migrations in version order", func(t *testing.T) {
+		migrations, err := Migrations()
+		if err != nil {
+			t.Fatalf("failed to load migrations: %s", err)
+		}
+		if len(migrations) == 0 {
+			t.Fatal("expected embedded migrations, got none")
+		}
+		for i, migration := range migrations {
+			if migration.Version != int64(i+1) {
+				t.Errorf("migration %d has version %d, want %d", i, migration.Version, i+1)
+			}
+			if migration.Up == "" || migration.Down == "" {
+				t.Errorf("migration %d_%s is missing its up or down SQL", migration.Version, migration.Name)
+			}
+		}
+	})
+
+	t.Run("loadMigrations to reject misnamed files and migrations without a down file", func(t *testing.T) {
+		cases := map[string]fstest.MapFS{
+			"missing down": {
+				"migrations/0001_users.up.sql": {Data: []byte("CREATE TABLE users ();")},
+			},
+			"misnamed": {
+				"migrations/users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+				"migrations/users.down.sql": {Data: []byte("DROP TABLE users;")},
+			},
+			"duplicate version": {
+				"migrations/0001_users.up.sql":     {Data: []byte("CREATE TABLE users ();")},
+				"migrations/0001_users.down.sql":   {Data: []byte("DROP TABLE users;")},
+				"migrations/0001_wallets.up.sql":   {Data: []byte("CREATE TABLE wallets ();")},
+				"migrations/0001_wallets.down.sql": {Data: []byte("DROP TABLE wallets;")},
+			},
+		}
+		for name, fsys := range cases {
+			if _, err := loadMigrations(fsys, "migrations"); err == nil {
+				t.Errorf("%s: expected an error, got nil", name)
+			}
+		}
+	})
+}
+
+func TestPostgreSQLMigrations(t *testing.T) {
+	if os.Getenv("NIKWALLET_TEST_STORE") != "postgres" {
+		t.Skip("set NIKWALLET_TEST_STORE=postgres to run against a real database")
+	}
+	postgres := db.(*PostgreSQL)
+
+	t.Run("MigrateUp to apply nothing once the schema is current", func(t *testing.T) {
+		applied, err := postgres.MigrateUp()
+		if err != nil {
+			t.Fatalf("error migrating up: %s", err)
+		}
+		if len(applied) != 0 {
+			t.Errorf("expected no pending migrations, applied %d", len(applied))
+		}
+
+		statuses, err := postgres.MigrationStatus()
+		if err != nil {
+			t.Fatalf("error getting migration status: %s", err)
+		}
+		for _, status := range statuses {
+			if status.AppliedAt == nil {
+				t.Errorf("migration %d_%s is not applied", status.Version, status.Name)
+			}
+		}
+	})
+
+	t.Run("MigrateDown to revert the last migration and MigrateUp to reapply it", func(t *testing.T) {
+		reverted, err := postgres.MigrateDown(1)
+		if err != nil {
+			t.Fatalf("error migrating down: %s", err)
+		}
+		if len(reverted) != 1 {
+			t.Fatalf("expected 1 reverted migration, got %d", len(reverted))
+		}
+
+		applied, err := postgres.MigrateUp()
+		if err != nil {
+			t.Fatalf("error migrating up: %s", err)
+		}
+		if len(applied) != 1 || applied[0].Version != reverted[0].Version {
+			t.Errorf("expected migration %d to be reapplied, got %d migrations", reverted[0].Version, len(applied))
+		}
+	})
+}
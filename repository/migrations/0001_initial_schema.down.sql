@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS ledgers;
+DROP TABLE IF EXISTS wallets;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS users (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    email_id text UNIQUE,
+    password text
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS wallets (
+    id bigserial PRIMARY KEY,
+    user_id bigint,
+    amount text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS ledgers (
+    id bigserial PRIMARY KEY,
+    sender_user_id bigint,
+    receiver_user_id bigint,
+    amount text,
+    transaction_type text,
+    created_at timestamptz
+);
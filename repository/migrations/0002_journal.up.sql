@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS journal_entries (
+    id bigserial PRIMARY KEY,
+    transaction_type text,
+    created_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS postings (
+    id bigserial PRIMARY KEY,
+    journal_entry_id bigint REFERENCES journal_entries (id),
+    account_type text,
+    wallet_id bigint,
+    user_id bigint,
+    system_account text,
+    counterparty_user_id bigint,
+    direction text,
+    amount text,
+    transaction_type text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_postings_journal_entry_id ON postings (journal_entry_id);
+CREATE INDEX IF NOT EXISTS idx_postings_wallet_id ON postings (wallet_id);
+CREATE INDEX IF NOT EXISTS idx_postings_user_id ON postings (user_id);
+
+-- The single-row ledgers cannot be replayed: a transfer wrote a withdraw, an
+-- add and a transfer row for the same money, and adds could be in another
+-- currency than the wallet. Each wallet with money instead gets one entry that
+-- carries its balance forward, dated at its last change, so that history and
+-- balances derived from postings agree with the stored balance.
+CREATE TEMPORARY TABLE legacy_wallet_balances AS
+SELECT nextval(pg_get_serial_sequence('journal_entries', 'id')) AS entry_id,
+       w.id AS wallet_id,
+       w.user_id,
+       w.amount::text AS amount,
+       COALESCE(w.updated_at, w.created_at, now()) AS posted_at
+FROM wallets w
+WHERE split_part(w.amount::text, ' ', 1)::numeric > 0
+  AND NOT EXISTS (SELECT 1 FROM postings p WHERE p.wallet_id = w.id);
+
+INSERT INTO journal_entries (id, transaction_type, created_at)
+SELECT entry_id, 'legacy_balance', posted_at FROM legacy_wallet_balances;
+
+INSERT INTO postings (journal_entry_id, account_type, wallet_id, user_id, system_account, direction, amount, transaction_type, created_at)
+SELECT entry_id, 'wallet', wallet_id, user_id, '', 'credit', amount, 'legacy_balance', posted_at FROM legacy_wallet_balances
+UNION ALL
+SELECT entry_id, 'system', 0, 0, 'legacy_balances', 'debit', amount, 'legacy_balance', posted_at FROM legacy_wallet_balances;
+
+DROP TABLE legacy_wallet_balances;
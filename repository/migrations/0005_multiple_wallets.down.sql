@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_wallets_user_currency_name;
+DROP INDEX IF EXISTS idx_wallets_user_default;
+DROP INDEX IF EXISTS idx_wallets_user_id;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
+ALTER TABLE wallets DROP COLUMN IF EXISTS is_default;
+ALTER TABLE wallets DROP COLUMN IF EXISTS name;
@@ -0,0 +1,14 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS name text NOT NULL DEFAULT '';
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS is_default boolean NOT NULL DEFAULT false;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency text;
+
+-- Wallets created before the currency column existed only carry their
+-- currency inside the amount column, and are the single wallet of their user.
+UPDATE wallets SET currency = split_part(amount::text, ' ', 2) WHERE currency IS NULL OR currency = '';
+UPDATE wallets w SET is_default = true
+WHERE w.id = (SELECT min(id) FROM wallets WHERE user_id = w.user_id)
+  AND NOT EXISTS (SELECT 1 FROM wallets d WHERE d.user_id = w.user_id AND d.is_default);
+
+CREATE INDEX IF NOT EXISTS idx_wallets_user_id ON wallets (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_default ON wallets (user_id) WHERE is_default;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_wallets_user_currency_name ON wallets (user_id, currency, name);
@@ -0,0 +1,5 @@
+ALTER TABLE postings DROP COLUMN IF EXISTS fx_rate_source;
+ALTER TABLE postings DROP COLUMN IF EXISTS fx_rate;
+
+DROP TABLE IF EXISTS fx_quotes;
+DROP TABLE IF EXISTS exchange_rates;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id bigserial PRIMARY KEY,
+    base_currency text,
+    quote_currency text,
+    rate numeric,
+    source text,
+    effective_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_exchange_rates_pair ON exchange_rates (base_currency, quote_currency, effective_at);
+
+CREATE TABLE IF NOT EXISTS fx_quotes (
+    id text PRIMARY KEY,
+    user_id bigint,
+    from_currency text,
+    to_currency text,
+    rate numeric,
+    source text,
+    created_at timestamptz,
+    expires_at timestamptz,
+    used_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_fx_quotes_user_id ON fx_quotes (user_id);
+
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS fx_rate numeric;
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS fx_rate_source text;
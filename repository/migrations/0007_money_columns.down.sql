@@ -0,0 +1,6 @@
+ALTER TABLE postings DROP CONSTRAINT IF EXISTS chk_postings_amount_non_negative;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS chk_wallets_amount_non_negative;
+
+ALTER TABLE postings ALTER COLUMN amount TYPE text USING amount::text || ' ' || currency;
+ALTER TABLE postings DROP COLUMN IF EXISTS currency;
+ALTER TABLE wallets ALTER COLUMN amount TYPE text USING amount::text || ' ' || currency;
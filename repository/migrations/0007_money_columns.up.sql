@@ -0,0 +1,20 @@
+-- Amounts used to be stored as "<amount> <currency>" text.
+DO $$
+DECLARE
+    money_table text;
+BEGIN
+    FOREACH money_table IN ARRAY ARRAY['wallets', 'postings'] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS currency text', money_table);
+        IF (SELECT data_type FROM information_schema.columns
+            WHERE table_schema = current_schema() AND table_name = money_table AND column_name = 'amount') = 'text' THEN
+            EXECUTE format('UPDATE %I SET currency = split_part(amount, '' '', 2) WHERE currency IS NULL OR currency = ''''', money_table);
+            EXECUTE format('ALTER TABLE %I ALTER COLUMN amount TYPE numeric(20,4) USING split_part(amount, '' '', 1)::numeric', money_table);
+        END IF;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS chk_wallets_amount_non_negative;
+ALTER TABLE wallets ADD CONSTRAINT chk_wallets_amount_non_negative CHECK (amount >= 0);
+ALTER TABLE postings DROP CONSTRAINT IF EXISTS chk_postings_amount_non_negative;
+ALTER TABLE postings ADD CONSTRAINT chk_postings_amount_non_negative CHECK (amount >= 0);
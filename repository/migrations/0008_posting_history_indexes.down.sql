@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_postings_wallet_history;
+DROP INDEX IF EXISTS idx_postings_user_history;
@@ -0,0 +1,5 @@
+-- The history API pages through the wallet postings of a user, or of one of
+-- their wallets, by (created_at, id) in either direction. Both indexes match
+-- that order so a page is an index range scan however long the ledger grows.
+CREATE INDEX IF NOT EXISTS idx_postings_user_history ON postings (user_id, created_at, id) WHERE account_type = 'wallet';
+CREATE INDEX IF NOT EXISTS idx_postings_wallet_history ON postings (wallet_id, created_at, id) WHERE account_type = 'wallet';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS balance_snapshots;
+ALTER TABLE postings DROP COLUMN IF EXISTS balance_after;
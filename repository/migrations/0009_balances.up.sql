@@ -0,0 +1,23 @@
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS balance_after numeric(20,4);
+
+-- Wallet postings written before the column existed get the running sum of
+-- their wallet in (created_at, id) order.
+UPDATE postings
+SET balance_after = running.balance
+FROM (
+    SELECT id, SUM(CASE WHEN direction = 'debit' THEN -amount ELSE amount END)
+               OVER (PARTITION BY wallet_id ORDER BY created_at, id) AS balance
+    FROM postings
+    WHERE account_type = 'wallet'
+) AS running
+WHERE postings.id = running.id AND postings.balance_after IS NULL;
+
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    id bigserial PRIMARY KEY,
+    wallet_id bigint NOT NULL,
+    posting_id bigint NOT NULL,
+    posted_at timestamptz NOT NULL,
+    balance numeric(20,4) NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_wallet ON balance_snapshots (wallet_id, posted_at, posting_id);
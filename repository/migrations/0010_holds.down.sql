@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS holds;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS chk_wallets_held_amount;
+ALTER TABLE wallets DROP COLUMN IF EXISTS held_amount;
@@ -0,0 +1,22 @@
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS held_amount numeric(20,4) NOT NULL DEFAULT 0;
+ALTER TABLE wallets DROP CONSTRAINT IF EXISTS chk_wallets_held_amount;
+ALTER TABLE wallets ADD CONSTRAINT chk_wallets_held_amount CHECK (held_amount >= 0 AND held_amount <= amount);
+
+CREATE TABLE IF NOT EXISTS holds (
+    id bigserial PRIMARY KEY,
+    wallet_id bigint NOT NULL REFERENCES wallets (id),
+    user_id bigint NOT NULL,
+    amount numeric(20,4) NOT NULL CHECK (amount > 0),
+    currency text NOT NULL,
+    captured_amount numeric(20,4) NOT NULL DEFAULT 0,
+    status text NOT NULL,
+    reference text,
+    journal_entry_id bigint,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_holds_wallet_id ON holds (wallet_id);
+CREATE INDEX IF NOT EXISTS idx_holds_user_id ON holds (user_id);
+-- The expiry job looks for active holds past their expiry.
+CREATE INDEX IF NOT EXISTS idx_holds_active_expiry ON holds (expires_at) WHERE status = 'active';
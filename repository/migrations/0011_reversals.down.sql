@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_journal_entries_reversed_entry_id;
+ALTER TABLE postings DROP COLUMN IF EXISTS reversed_entry_id;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS reversed_entry_id;
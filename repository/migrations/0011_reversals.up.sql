@@ -0,0 +1,5 @@
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS reversed_entry_id bigint;
+ALTER TABLE postings ADD COLUMN IF NOT EXISTS reversed_entry_id bigint;
+
+-- Refunding an entry sums the refunds already made against it.
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reversed_entry_id ON journal_entries (reversed_entry_id) WHERE reversed_entry_id <> 0;
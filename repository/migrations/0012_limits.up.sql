@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS user_limits (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id),
+    transaction_type text NOT NULL,
+    min_amount numeric(20,4) CHECK (min_amount >= 0),
+    max_amount numeric(20,4) CHECK (max_amount >= 0),
+    daily_amount numeric(20,4) CHECK (daily_amount >= 0),
+    monthly_amount numeric(20,4) CHECK (monthly_amount >= 0),
+    max_per_hour integer CHECK (max_per_hour >= 0),
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT uq_user_limits_user_type UNIQUE (user_id, transaction_type)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS kyc_events;
+DROP TABLE IF EXISTS kyc_submissions;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_level;
@@ -0,0 +1,32 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS kyc_level text NOT NULL DEFAULT 'unverified';
+
+CREATE TABLE IF NOT EXISTS kyc_submissions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id),
+    level text NOT NULL,
+    document_type text NOT NULL,
+    document_number_last4 text,
+    issuing_country text,
+    document_expires_on date,
+    status text NOT NULL,
+    reviewer_id bigint,
+    review_note text,
+    reviewed_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_kyc_submissions_user_id ON kyc_submissions (user_id);
+-- Reviewers work through the pending submissions, oldest first.
+CREATE INDEX IF NOT EXISTS idx_kyc_submissions_pending ON kyc_submissions (id) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS kyc_events (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id),
+    submission_id bigint NOT NULL REFERENCES kyc_submissions (id),
+    actor_id bigint NOT NULL,
+    action text NOT NULL,
+    from_level text NOT NULL,
+    to_level text NOT NULL,
+    note text,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_kyc_events_user_id ON kyc_events (user_id, id);
@@ -0,0 +1,7 @@
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS reason;
+ALTER TABLE journal_entries DROP COLUMN IF EXISTS actor_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS frozen_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS frozen_by;
+ALTER TABLE users DROP COLUMN IF EXISTS frozen_at;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'customer';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS frozen_at timestamptz;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS frozen_by bigint;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS frozen_reason text;
+
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS actor_id bigint;
+ALTER TABLE journal_entries ADD COLUMN IF NOT EXISTS reason text;
@@ -0,0 +1,5 @@
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS locked_until timestamptz;
+
+-- Keys claimed before leases existed can be taken over straight away.
+UPDATE idempotency_keys SET locked_until = created_at WHERE locked_until IS NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN locked_until SET NOT NULL;
@@ -0,0 +1,2 @@
+-- In-progress keys are no longer taken over, so they need no lease.
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS locked_until;
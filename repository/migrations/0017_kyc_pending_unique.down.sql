@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_kyc_submissions_user_pending;
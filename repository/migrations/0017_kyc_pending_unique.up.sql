@@ -0,0 +1,2 @@
+-- A user can have one submission pending review at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_kyc_submissions_user_pending ON kyc_submissions (user_id) WHERE status = 'pending';
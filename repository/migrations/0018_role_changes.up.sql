@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS role_changes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id),
+    actor_id bigint NOT NULL,
+    from_role text NOT NULL,
+    to_role text NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_role_changes_user_id ON role_changes (user_id, id);
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"nikwallet/repository/money"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate is the number of units of QuoteCurrency one unit of
+// BaseCurrency buys, as published by Source at EffectiveAt.
+type ExchangeRate struct {
+	ID            int             `gorm:"column:id"`
+	BaseCurrency  money.Currency  `gorm:"column:base_currency;index:idx_exchange_rates_pair"`
+	QuoteCurrency money.Currency  `gorm:"column:quote_currency;index:idx_exchange_rates_pair"`
+	Rate          decimal.Decimal `gorm:"column:rate;type:numeric"`
+	Source        string          `gorm:"column:source"`
+	EffectiveAt   time.Time       `gorm:"column:effective_at;index:idx_exchange_rates_pair"`
+	CreatedAt     time.Time       `gorm:"column:created_at"`
+}
+
+// FXQuote locks a rate for one user until ExpiresAt. A quote can be used by a
+// single transfer.
+type FXQuote struct {
+	ID           string          `gorm:"column:id;primaryKey"`
+	UserID       int             `gorm:"column:user_id;index"`
+	FromCurrency money.Currency  `gorm:"column:from_currency"`
+	ToCurrency   money.Currency  `gorm:"column:to_currency"`
+	Rate         decimal.Decimal `gorm:"column:rate;type:numeric"`
+	Source       string          `gorm:"column:source"`
+	CreatedAt    time.Time       `gorm:"column:created_at"`
+	ExpiresAt    time.Time       `gorm:"column:expires_at"`
+	UsedAt       *time.Time      `gorm:"column:used_at"`
+}
+
+func (q *FXQuote) Usable(now time.Time) bool {
+	return q.UsedAt == nil && q.ExpiresAt.After(now)
+}
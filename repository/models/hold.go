@@ -0,0 +1,40 @@
+package models
+
+import (
+	"nikwallet/repository/money"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusVoided   HoldStatus = "voided"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// Hold reserves Amount of a wallet until it is captured, voided or expires.
+// While it is active the amount counts towards the HeldAmount of the wallet.
+// A capture debits up to Amount through the journal entry JournalEntryID and
+// releases the rest.
+type Hold struct {
+	ID             int             `gorm:"column:id"`
+	WalletID       int             `gorm:"column:wallet_id;index"`
+	UserID         int             `gorm:"column:user_id;index"`
+	Amount         *money.Money    `gorm:"embedded"`
+	CapturedAmount decimal.Decimal `gorm:"column:captured_amount;type:numeric"`
+	Status         HoldStatus      `gorm:"column:status"`
+	Reference      string          `gorm:"column:reference"`
+	JournalEntryID int             `gorm:"column:journal_entry_id"`
+	ExpiresAt      time.Time       `gorm:"column:expires_at"`
+	CreatedAt      time.Time       `gorm:"column:created_at"`
+	UpdatedAt      time.Time       `gorm:"column:updated_at"`
+}
+
+// IsActive reports whether the hold can still be captured or voided at now.
+func (h *Hold) IsActive(now time.Time) bool {
+	return h.Status == HoldStatusActive && h.ExpiresAt.After(now)
+}
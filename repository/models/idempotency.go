@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+type IdempotencyKeyState string
+
+const (
+	IdempotencyKeyStateInProgress IdempotencyKeyState = "in_progress"
+	IdempotencyKeyStateCompleted  IdempotencyKeyState = "completed"
+)
+
+type IdempotencyKey struct {
+	ID                  int                 `gorm:"column:id"`
+	UserID              int                 `gorm:"column:user_id;uniqueIndex:idx_idempotency_keys_user_key"`
+	Key                 string              `gorm:"column:key;uniqueIndex:idx_idempotency_keys_user_key"`
+	Fingerprint         string              `gorm:"column:fingerprint"`
+	State               IdempotencyKeyState `gorm:"column:state"`
+	ResponseStatus      int                 `gorm:"column:response_status"`
+	ResponseContentType string              `gorm:"column:response_content_type"`
+	ResponseBody        []byte              `gorm:"column:response_body"`
+	CreatedAt           time.Time           `gorm:"column:created_at"`
+	ExpiresAt           time.Time           `gorm:"column:expires_at;index"`
+}
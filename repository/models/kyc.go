@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// KYCLevel is how far the identity of a user has been verified.
+type KYCLevel string
+
+const (
+	KYCLevelUnverified KYCLevel = "unverified"
+	KYCLevelMinimal    KYCLevel = "minimal"
+	KYCLevelFull       KYCLevel = "full"
+)
+
+func (l KYCLevel) IsValid() bool {
+	return l.Rank() >= 0
+}
+
+// Rank orders the levels from unverified at 0 to full, and is -1 for an
+// unknown level.
+func (l KYCLevel) Rank() int {
+	switch l {
+	case KYCLevelUnverified:
+		return 0
+	case KYCLevelMinimal:
+		return 1
+	case KYCLevelFull:
+		return 2
+	}
+	return -1
+}
+
+type KYCSubmissionStatus string
+
+const (
+	KYCSubmissionPending  KYCSubmissionStatus = "pending"
+	KYCSubmissionApproved KYCSubmissionStatus = "approved"
+	KYCSubmissionRejected KYCSubmissionStatus = "rejected"
+)
+
+// KYCSubmission asks for the user to be verified at Level. It only holds the
+// metadata of the document; of its number just the last four characters are
+// kept.
+type KYCSubmission struct {
+	ID                  int                 `gorm:"column:id"`
+	UserID              int                 `gorm:"column:user_id"`
+	Level               KYCLevel            `gorm:"column:level"`
+	DocumentType        string              `gorm:"column:document_type"`
+	DocumentNumberLast4 string              `gorm:"column:document_number_last4"`
+	IssuingCountry      string              `gorm:"column:issuing_country"`
+	DocumentExpiresOn   *time.Time          `gorm:"column:document_expires_on"`
+	Status              KYCSubmissionStatus `gorm:"column:status"`
+	ReviewerID          int                 `gorm:"column:reviewer_id"`
+	ReviewNote          string              `gorm:"column:review_note"`
+	ReviewedAt          *time.Time          `gorm:"column:reviewed_at"`
+	CreatedAt           time.Time           `gorm:"column:created_at"`
+}
+
+type KYCAction string
+
+const (
+	KYCActionSubmitted KYCAction = "submitted"
+	KYCActionApproved  KYCAction = "approved"
+	KYCActionRejected  KYCAction = "rejected"
+)
+
+// KYCEvent records a change of the verification of a user in its audit
+// trail. ActorID is the user who made the change: the user themselves for a
+// submission and the reviewer for a decision.
+type KYCEvent struct {
+	ID           int       `gorm:"column:id"`
+	UserID       int       `gorm:"column:user_id"`
+	SubmissionID int       `gorm:"column:submission_id"`
+	ActorID      int       `gorm:"column:actor_id"`
+	Action       KYCAction `gorm:"column:action"`
+	FromLevel    KYCLevel  `gorm:"column:from_level"`
+	ToLevel      KYCLevel  `gorm:"column:to_level"`
+	Note         string    `gorm:"column:note"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+}
@@ -1,23 +1,199 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"nikwallet/repository/money"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 type TransactionType string
 
 const (
-	TransactionTypeAdd      TransactionType = "add"
-	TransactionTypeWithdraw TransactionType = "withdraw"
-	TransactionTypeTransfer TransactionType = "transfer"
+	TransactionTypeAdd        TransactionType = "add"
+	TransactionTypeWithdraw   TransactionType = "withdraw"
+	TransactionTypeTransfer   TransactionType = "transfer"
+	TransactionTypeCapture    TransactionType = "capture"
+	TransactionTypeRefund     TransactionType = "refund"
+	TransactionTypeReversal   TransactionType = "reversal"
+	TransactionTypeAdjustment TransactionType = "adjustment"
+	// TransactionTypeLegacyBalance carries forward the balance a wallet had
+	// before the journal existed. Only the 0002 migration writes it.
+	TransactionTypeLegacyBalance TransactionType = "legacy_balance"
+)
+
+func (t TransactionType) IsValid() bool {
+	switch t {
+	case TransactionTypeAdd, TransactionTypeWithdraw, TransactionTypeTransfer, TransactionTypeCapture,
+		TransactionTypeRefund, TransactionTypeReversal, TransactionTypeAdjustment, TransactionTypeLegacyBalance:
+		return true
+	}
+	return false
+}
+
+type AccountType string
+
+const (
+	AccountTypeWallet AccountType = "wallet"
+	AccountTypeSystem AccountType = "system"
+)
+
+// System accounts sit on the other side of every posting that moves money
+// into, out of, or across currencies within the platform.
+const (
+	SystemAccountExternalFunding = "external_funding"
+	SystemAccountExternalPayout  = "external_payout"
+	SystemAccountFXClearing      = "fx_clearing"
+	SystemAccountAdjustments     = "manual_adjustments"
+	SystemAccountLegacyBalances  = "legacy_balances"
 )
 
-type Ledger struct {
-	ID              int          `gorm:"column:id"`
-	SenderUserID    int          `gorm:"column:sender_user_id"`
-	ReceiverUserID  int          `gorm:"column:receiver_user_id"`
-	Amount          *money.Money `gorm:"column:amount"`
-	TransactionType string       `gorm:"column:transaction_type"`
-	CreatedAt       time.Time    `gorm:"column:created_at"`
+type PostingDirection string
+
+const (
+	PostingDirectionDebit  PostingDirection = "debit"
+	PostingDirectionCredit PostingDirection = "credit"
+)
+
+var ErrUnbalancedJournalEntry = errors.New("journal entry does not balance")
+
+// JournalEntry is a balanced set of postings. A refund or reversal links to
+// the entry it undoes through ReversedEntryID. A manual adjustment records
+// the staff member who posted it as ActorID, and why, as Reason.
+type JournalEntry struct {
+	ID              int        `gorm:"column:id"`
+	TransactionType string     `gorm:"column:transaction_type"`
+	ReversedEntryID int        `gorm:"column:reversed_entry_id"`
+	ActorID         int        `gorm:"column:actor_id"`
+	Reason          string     `gorm:"column:reason"`
+	Postings        []*Posting `gorm:"foreignKey:JournalEntryID"`
+	CreatedAt       time.Time  `gorm:"column:created_at"`
+}
+
+// Posting is one leg of a JournalEntry. Wallet accounts are liabilities of the
+// platform, so a credit increases a wallet balance and a debit decreases it.
+// The postings of a currency conversion carry the FXRate and FXRateSource the
+// converted amount was computed with. BalanceAfter is the balance of the
+// wallet once the posting was applied; it is null on system postings.
+// ReversedEntryID repeats the link of a refund or reversal entry so that the
+// history of both parties shows it.
+type Posting struct {
+	ID                 int                 `gorm:"column:id"`
+	JournalEntryID     int                 `gorm:"column:journal_entry_id;index"`
+	AccountType        AccountType         `gorm:"column:account_type"`
+	WalletID           int                 `gorm:"column:wallet_id;index"`
+	UserID             int                 `gorm:"column:user_id;index"`
+	SystemAccount      string              `gorm:"column:system_account"`
+	CounterpartyUserID int                 `gorm:"column:counterparty_user_id"`
+	Direction          PostingDirection    `gorm:"column:direction"`
+	Amount             *money.Money        `gorm:"embedded"`
+	TransactionType    string              `gorm:"column:transaction_type"`
+	ReversedEntryID    int                 `gorm:"column:reversed_entry_id"`
+	FXRate             decimal.NullDecimal `gorm:"column:fx_rate;type:numeric"`
+	FXRateSource       string              `gorm:"column:fx_rate_source"`
+	BalanceAfter       decimal.NullDecimal `gorm:"column:balance_after;type:numeric"`
+	CreatedAt          time.Time           `gorm:"column:created_at"`
+}
+
+// BalanceSnapshot records the balance of a wallet after every posting up to
+// and including PostingID, which was posted at PostedAt, so that a historical
+// balance only has to sum the postings that came after the snapshot.
+type BalanceSnapshot struct {
+	ID        int             `gorm:"column:id"`
+	WalletID  int             `gorm:"column:wallet_id"`
+	PostingID int             `gorm:"column:posting_id"`
+	PostedAt  time.Time       `gorm:"column:posted_at"`
+	Balance   decimal.Decimal `gorm:"column:balance;type:numeric"`
+	CreatedAt time.Time       `gorm:"column:created_at"`
+}
+
+func NewWalletPosting(wallet *Wallet, direction PostingDirection, amount *money.Money) *Posting {
+	return &Posting{
+		AccountType: AccountTypeWallet,
+		WalletID:    wallet.ID,
+		UserID:      wallet.UserID,
+		Direction:   direction,
+		Amount:      amount,
+	}
+}
+
+func NewSystemPosting(account string, direction PostingDirection, amount *money.Money) *Posting {
+	return &Posting{
+		AccountType:   AccountTypeSystem,
+		SystemAccount: account,
+		Direction:     direction,
+		Amount:        amount,
+	}
+}
+
+// SignedAmount returns the effect of the posting on its account balance.
+func (p *Posting) SignedAmount() decimal.Decimal {
+	if p.Direction == PostingDirectionDebit {
+		return p.Amount.Amount.Neg()
+	}
+	return p.Amount.Amount
+}
+
+func (e *JournalEntry) Validate() error {
+	if len(e.Postings) < 2 {
+		return fmt.Errorf("journal entry needs at least two postings, got %d", len(e.Postings))
+	}
+
+	sums := map[money.Currency]decimal.Decimal{}
+	for _, posting := range e.Postings {
+		if posting.Amount == nil || posting.Amount.Amount.IsNegative() {
+			return fmt.Errorf("posting amount must be a non-negative value")
+		}
+
+		switch posting.AccountType {
+		case AccountTypeWallet:
+			if posting.WalletID == 0 {
+				return fmt.Errorf("wallet posting is missing a wallet ID")
+			}
+		case AccountTypeSystem:
+			if posting.SystemAccount == "" {
+				return fmt.Errorf("system posting is missing an account name")
+			}
+		default:
+			return fmt.Errorf("unknown account type: %s", posting.AccountType)
+		}
+
+		if posting.Direction != PostingDirectionDebit && posting.Direction != PostingDirectionCredit {
+			return fmt.Errorf("unknown posting direction: %s", posting.Direction)
+		}
+
+		currency := posting.Amount.Currency
+		sums[currency] = sums[currency].Add(posting.SignedAmount())
+	}
+
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: %s postings are off by %s", ErrUnbalancedJournalEntry, currency, sum)
+		}
+	}
+
+	return nil
+}
+
+// BalanceFromPostings derives an account balance by summing the signed
+// amounts of its postings.
+func BalanceFromPostings(postings []*Posting) decimal.Decimal {
+	balance := decimal.Zero
+	for _, posting := range postings {
+		balance = balance.Add(posting.SignedAmount())
+	}
+	return balance
+}
+
+// PostingForUser returns the first wallet posting of the entry owned by the
+// given user, or nil if the user has no wallet posting in it.
+func (e *JournalEntry) PostingForUser(userID int) *Posting {
+	for _, posting := range e.Postings {
+		if posting.AccountType == AccountTypeWallet && posting.UserID == userID {
+			return posting
+		}
+	}
+	return nil
 }
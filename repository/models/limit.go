@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// UserLimit overrides the global limits of one transaction type for a user.
+// Amounts are in the currency of the global limits. A null field keeps the
+// global limit and a zero one lifts it.
+type UserLimit struct {
+	ID              int                 `gorm:"column:id"`
+	UserID          int                 `gorm:"column:user_id"`
+	TransactionType string              `gorm:"column:transaction_type"`
+	MinAmount       decimal.NullDecimal `gorm:"column:min_amount;type:numeric"`
+	MaxAmount       decimal.NullDecimal `gorm:"column:max_amount;type:numeric"`
+	DailyAmount     decimal.NullDecimal `gorm:"column:daily_amount;type:numeric"`
+	MonthlyAmount   decimal.NullDecimal `gorm:"column:monthly_amount;type:numeric"`
+	MaxPerHour      *int                `gorm:"column:max_per_hour"`
+	CreatedAt       time.Time           `gorm:"column:created_at"`
+	UpdatedAt       time.Time           `gorm:"column:updated_at"`
+}
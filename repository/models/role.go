@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Role decides which parts of the admin API a user can reach. Every user is
+// a customer unless given another role.
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+	RoleAuditor  Role = "auditor"
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleCustomer, RoleSupport, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
+
+// RoleChange records a change of the role of a user in its audit trail.
+// ActorID is the staff member who made it, or 0 for the set-role command.
+type RoleChange struct {
+	ID        int       `gorm:"column:id"`
+	UserID    int       `gorm:"column:user_id"`
+	ActorID   int       `gorm:"column:actor_id"`
+	FromRole  Role      `gorm:"column:from_role"`
+	ToRole    Role      `gorm:"column:to_role"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+}
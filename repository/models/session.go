@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Session is one signed-in device. Every refresh token issued for it belongs
+// to the same token family, so revoking the session revokes the family.
+type Session struct {
+	ID                   int        `gorm:"column:id"`
+	UserID               int        `gorm:"column:user_id;index"`
+	UserAgent            string     `gorm:"column:user_agent"`
+	IPAddress            string     `gorm:"column:ip_address"`
+	AccessTokenID        string     `gorm:"column:access_token_id"`
+	AccessTokenExpiresAt time.Time  `gorm:"column:access_token_expires_at"`
+	CreatedAt            time.Time  `gorm:"column:created_at"`
+	LastUsedAt           time.Time  `gorm:"column:last_used_at"`
+	ExpiresAt            time.Time  `gorm:"column:expires_at"`
+	RevokedAt            *time.Time `gorm:"column:revoked_at"`
+}
+
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+// RefreshToken stores only a hash of the token handed to the client. A token
+// that is presented again after UsedAt is set has been replayed.
+type RefreshToken struct {
+	ID        int        `gorm:"column:id"`
+	SessionID int        `gorm:"column:session_id;index"`
+	UserID    int        `gorm:"column:user_id"`
+	TokenHash string     `gorm:"column:token_hash;uniqueIndex"`
+	CreatedAt time.Time  `gorm:"column:created_at"`
+	ExpiresAt time.Time  `gorm:"column:expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+}
+
+// RevokedToken keeps the ID of a revoked access token until the token would
+// have expired anyway.
+type RevokedToken struct {
+	TokenID   string    `gorm:"column:token_id;primaryKey"`
+	UserID    int       `gorm:"column:user_id"`
+	ExpiresAt time.Time `gorm:"column:expires_at;index"`
+}
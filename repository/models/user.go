@@ -1,9 +1,24 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
+	"gorm.io/gorm"
+)
+
+// User is a customer or a member of staff. A frozen user cannot sign in or
+// move money; FrozenBy is the staff member who froze them.
 type User struct {
 	gorm.Model
-	EmailID  string `gorm:"unique"`
-	Password string
+	EmailID      string `gorm:"unique"`
+	Password     string
+	KYCLevel     KYCLevel   `gorm:"column:kyc_level"`
+	Role         Role       `gorm:"column:role"`
+	FrozenAt     *time.Time `gorm:"column:frozen_at"`
+	FrozenBy     int        `gorm:"column:frozen_by"`
+	FrozenReason string     `gorm:"column:frozen_reason"`
+}
+
+func (u *User) IsFrozen() bool {
+	return u.FrozenAt != nil
 }
@@ -1,16 +1,45 @@
 package models
 
 import (
+	"encoding/json"
 	"nikwallet/repository/money"
 	"time"
-	// pq "github.com/lib/pq"
+
+	"github.com/shopspring/decimal"
 )
 
+// Wallet is either the main wallet of a user for one currency, which has an
+// empty Name, or a named pocket. A user holds at most one wallet per currency
+// and name, and at most one of them is the default; the migrations create the
+// unique indexes that enforce both. Money is embedded as the amount and
+// currency columns. HeldAmount is the sum of the active holds on the wallet,
+// which Money still includes.
 type Wallet struct {
-	ID     int          `gorm:"column:id"`
-	UserID int          `gorm:"column:user_id"`
-	Money  *money.Money `gorm:"column:amount"`
-	// LedgerEntryIDs pq.Int64Array `gorm:"type:integer[]"`
-	CreatedAt time.Time `gorm:"column:created_at"`
-	UpdatedAt time.Time `gorm:"column:updated_at"`
+	ID         int             `gorm:"column:id"`
+	UserID     int             `gorm:"column:user_id;index"`
+	Name       string          `gorm:"column:name"`
+	IsDefault  bool            `gorm:"column:is_default"`
+	Money      *money.Money    `gorm:"embedded"`
+	HeldAmount decimal.Decimal `gorm:"column:held_amount;type:numeric"`
+	CreatedAt  time.Time       `gorm:"column:created_at"`
+	UpdatedAt  time.Time       `gorm:"column:updated_at"`
+}
+
+// AvailableBalance is the part of the ledger balance that is not held.
+func (w *Wallet) AvailableBalance() *money.Money {
+	if w.Money == nil {
+		return nil
+	}
+	return &money.Money{Amount: w.Money.Amount.Sub(w.HeldAmount), Currency: w.Money.Currency}
+}
+
+// MarshalJSON adds the ledger_balance and available_balance of the wallet to
+// its fields.
+func (w Wallet) MarshalJSON() ([]byte, error) {
+	type wallet Wallet
+	return json.Marshal(struct {
+		wallet
+		LedgerBalance    *money.Money `json:"ledger_balance"`
+		AvailableBalance *money.Money `json:"available_balance"`
+	}{wallet(w), w.Money, w.AvailableBalance()})
 }
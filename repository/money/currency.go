@@ -0,0 +1,230 @@
+package money
+
+import "sort"
+
+// CurrencyInfo describes an ISO 4217 currency. MinorUnits is the number of
+// decimal places an amount in the currency can carry.
+type CurrencyInfo struct {
+	Code       Currency
+	Numeric    string
+	MinorUnits int32
+	Symbol     string
+	Name       string
+}
+
+// currencyTable lists the active ISO 4217 currencies. Precious metals, SDRs
+// and the testing codes have no minor units and are left out.
+var currencyTable = []CurrencyInfo{
+	{"AED", "784", 2, "د.إ", "UAE Dirham"},
+	{"AFN", "971", 2, "؋", "Afghani"},
+	{"ALL", "008", 2, "L", "Lek"},
+	{"AMD", "051", 2, "֏", "Armenian Dram"},
+	{"ANG", "532", 2, "ƒ", "Netherlands Antillean Guilder"},
+	{"AOA", "973", 2, "Kz", "Kwanza"},
+	{"ARS", "032", 2, "$", "Argentine Peso"},
+	{"AUD", "036", 2, "A$", "Australian Dollar"},
+	{"AWG", "533", 2, "ƒ", "Aruban Florin"},
+	{"AZN", "944", 2, "₼", "Azerbaijan Manat"},
+	{"BAM", "977", 2, "KM", "Convertible Mark"},
+	{"BBD", "052", 2, "$", "Barbados Dollar"},
+	{"BDT", "050", 2, "৳", "Taka"},
+	{"BGN", "975", 2, "лв", "Bulgarian Lev"},
+	{"BHD", "048", 3, ".د.ب", "Bahraini Dinar"},
+	{"BIF", "108", 0, "FBu", "Burundi Franc"},
+	{"BMD", "060", 2, "$", "Bermudian Dollar"},
+	{"BND", "096", 2, "$", "Brunei Dollar"},
+	{"BOB", "068", 2, "Bs", "Boliviano"},
+	{"BOV", "984", 2, "", "Mvdol"},
+	{"BRL", "986", 2, "R$", "Brazilian Real"},
+	{"BSD", "044", 2, "$", "Bahamian Dollar"},
+	{"BTN", "064", 2, "Nu.", "Ngultrum"},
+	{"BWP", "072", 2, "P", "Pula"},
+	{"BYN", "933", 2, "Br", "Belarusian Ruble"},
+	{"BZD", "084", 2, "$", "Belize Dollar"},
+	{"CAD", "124", 2, "CA$", "Canadian Dollar"},
+	{"CDF", "976", 2, "FC", "Congolese Franc"},
+	{"CHE", "947", 2, "", "WIR Euro"},
+	{"CHF", "756", 2, "CHF", "Swiss Franc"},
+	{"CHW", "948", 2, "", "WIR Franc"},
+	{"CLF", "990", 4, "UF", "Unidad de Fomento"},
+	{"CLP", "152", 0, "$", "Chilean Peso"},
+	{"CNY", "156", 2, "¥", "Yuan Renminbi"},
+	{"COP", "170", 2, "$", "Colombian Peso"},
+	{"COU", "970", 2, "", "Unidad de Valor Real"},
+	{"CRC", "188", 2, "₡", "Costa Rican Colon"},
+	{"CUP", "192", 2, "$", "Cuban Peso"},
+	{"CVE", "132", 2, "$", "Cabo Verde Escudo"},
+	{"CZK", "203", 2, "Kč", "Czech Koruna"},
+	{"DJF", "262", 0, "Fdj", "Djibouti Franc"},
+	{"DKK", "208", 2, "kr", "Danish Krone"},
+	{"DOP", "214", 2, "RD$", "Dominican Peso"},
+	{"DZD", "012", 2, "د.ج", "Algerian Dinar"},
+	{"EGP", "818", 2, "E£", "Egyptian Pound"},
+	{"ERN", "232", 2, "Nfk", "Nakfa"},
+	{"ETB", "230", 2, "Br", "Ethiopian Birr"},
+	{"EUR", "978", 2, "€", "Euro"},
+	{"FJD", "242", 2, "$", "Fiji Dollar"},
+	{"FKP", "238", 2, "£", "Falkland Islands Pound"},
+	{"GBP", "826", 2, "£", "Pound Sterling"},
+	{"GEL", "981", 2, "₾", "Lari"},
+	{"GHS", "936", 2, "GH₵", "Ghana Cedi"},
+	{"GIP", "292", 2, "£", "Gibraltar Pound"},
+	{"GMD", "270", 2, "D", "Dalasi"},
+	{"GNF", "324", 0, "FG", "Guinean Franc"},
+	{"GTQ", "320", 2, "Q", "Quetzal"},
+	{"GYD", "328", 2, "$", "Guyana Dollar"},
+	{"HKD", "344", 2, "HK$", "Hong Kong Dollar"},
+	{"HNL", "340", 2, "L", "Lempira"},
+	{"HTG", "332", 2, "G", "Gourde"},
+	{"HUF", "348", 2, "Ft", "Forint"},
+	{"IDR", "360", 2, "Rp", "Rupiah"},
+	{"ILS", "376", 2, "₪", "New Israeli Sheqel"},
+	{"INR", "356", 2, "₹", "Indian Rupee"},
+	{"IQD", "368", 3, "ع.د", "Iraqi Dinar"},
+	{"IRR", "364", 2, "﷼", "Iranian Rial"},
+	{"ISK", "352", 0, "kr", "Iceland Krona"},
+	{"JMD", "388", 2, "J$", "Jamaican Dollar"},
+	{"JOD", "400", 3, "د.ا", "Jordanian Dinar"},
+	{"JPY", "392", 0, "¥", "Yen"},
+	{"KES", "404", 2, "KSh", "Kenyan Shilling"},
+	{"KGS", "417", 2, "с", "Som"},
+	{"KHR", "116", 2, "៛", "Riel"},
+	{"KMF", "174", 0, "CF", "Comorian Franc"},
+	{"KPW", "408", 2, "₩", "North Korean Won"},
+	{"KRW", "410", 0, "₩", "Won"},
+	{"KWD", "414", 3, "د.ك", "Kuwaiti Dinar"},
+	{"KYD", "136", 2, "$", "Cayman Islands Dollar"},
+	{"KZT", "398", 2, "₸", "Tenge"},
+	{"LAK", "418", 2, "₭", "Lao Kip"},
+	{"LBP", "422", 2, "ل.ل", "Lebanese Pound"},
+	{"LKR", "144", 2, "Rs", "Sri Lanka Rupee"},
+	{"LRD", "430", 2, "$", "Liberian Dollar"},
+	{"LSL", "426", 2, "L", "Loti"},
+	{"LYD", "434", 3, "ل.د", "Libyan Dinar"},
+	{"MAD", "504", 2, "د.م.", "Moroccan Dirham"},
+	{"MDL", "498", 2, "L", "Moldovan Leu"},
+	{"MGA", "969", 2, "Ar", "Malagasy Ariary"},
+	{"MKD", "807", 2, "ден", "Denar"},
+	{"MMK", "104", 2, "K", "Kyat"},
+	{"MNT", "496", 2, "₮", "Tugrik"},
+	{"MOP", "446", 2, "MOP$", "Pataca"},
+	{"MRU", "929", 2, "UM", "Ouguiya"},
+	{"MUR", "480", 2, "₨", "Mauritius Rupee"},
+	{"MVR", "462", 2, "Rf", "Rufiyaa"},
+	{"MWK", "454", 2, "MK", "Malawi Kwacha"},
+	{"MXN", "484", 2, "MX$", "Mexican Peso"},
+	{"MXV", "979", 2, "", "Mexican Unidad de Inversion (UDI)"},
+	{"MYR", "458", 2, "RM", "Malaysian Ringgit"},
+	{"MZN", "943", 2, "MT", "Mozambique Metical"},
+	{"NAD", "516", 2, "$", "Namibia Dollar"},
+	{"NGN", "566", 2, "₦", "Naira"},
+	{"NIO", "558", 2, "C$", "Cordoba Oro"},
+	{"NOK", "578", 2, "kr", "Norwegian Krone"},
+	{"NPR", "524", 2, "₨", "Nepalese Rupee"},
+	{"NZD", "554", 2, "NZ$", "New Zealand Dollar"},
+	{"OMR", "512", 3, "ر.ع.", "Rial Omani"},
+	{"PAB", "590", 2, "B/.", "Balboa"},
+	{"PEN", "604", 2, "S/", "Sol"},
+	{"PGK", "598", 2, "K", "Kina"},
+	{"PHP", "608", 2, "₱", "Philippine Peso"},
+	{"PKR", "586", 2, "₨", "Pakistan Rupee"},
+	{"PLN", "985", 2, "zł", "Zloty"},
+	{"PYG", "600", 0, "₲", "Guarani"},
+	{"QAR", "634", 2, "ر.ق", "Qatari Rial"},
+	{"RON", "946", 2, "lei", "Romanian Leu"},
+	{"RSD", "941", 2, "дин.", "Serbian Dinar"},
+	{"RUB", "643", 2, "₽", "Russian Ruble"},
+	{"RWF", "646", 0, "FRw", "Rwanda Franc"},
+	{"SAR", "682", 2, "ر.س", "Saudi Riyal"},
+	{"SBD", "090", 2, "$", "Solomon Islands Dollar"},
+	{"SCR", "690", 2, "₨", "Seychelles Rupee"},
+	{"SDG", "938", 2, "ج.س.", "Sudanese Pound"},
+	{"SEK", "752", 2, "kr", "Swedish Krona"},
+	{"SGD", "702", 2, "S$", "Singapore Dollar"},
+	{"SHP", "654", 2, "£", "Saint Helena Pound"},
+	{"SLE", "925", 2, "Le", "Leone"},
+	{"SOS", "706", 2, "Sh", "Somali Shilling"},
+	{"SRD", "968", 2, "$", "Surinam Dollar"},
+	{"SSP", "728", 2, "£", "South Sudanese Pound"},
+	{"STN", "930", 2, "Db", "Dobra"},
+	{"SVC", "222", 2, "₡", "El Salvador Colon"},
+	{"SYP", "760", 2, "£", "Syrian Pound"},
+	{"SZL", "748", 2, "L", "Lilangeni"},
+	{"THB", "764", 2, "฿", "Baht"},
+	{"TJS", "972", 2, "SM", "Somoni"},
+	{"TMT", "934", 2, "m", "Turkmenistan New Manat"},
+	{"TND", "788", 3, "د.ت", "Tunisian Dinar"},
+	{"TOP", "776", 2, "T$", "Pa'anga"},
+	{"TRY", "949", 2, "₺", "Turkish Lira"},
+	{"TTD", "780", 2, "TT$", "Trinidad and Tobago Dollar"},
+	{"TWD", "901", 2, "NT$", "New Taiwan Dollar"},
+	{"TZS", "834", 2, "TSh", "Tanzanian Shilling"},
+	{"UAH", "980", 2, "₴", "Hryvnia"},
+	{"UGX", "800", 0, "USh", "Uganda Shilling"},
+	{"USD", "840", 2, "$", "US Dollar"},
+	{"USN", "997", 2, "", "US Dollar (Next day)"},
+	{"UYI", "940", 0, "", "Uruguay Peso en Unidades Indexadas (UI)"},
+	{"UYU", "858", 2, "$U", "Peso Uruguayo"},
+	{"UYW", "927", 4, "", "Unidad Previsional"},
+	{"UZS", "860", 2, "soʻm", "Uzbekistan Sum"},
+	{"VED", "926", 2, "Bs.D", "Bolívar Soberano"},
+	{"VES", "928", 2, "Bs.S", "Bolívar Soberano"},
+	{"VND", "704", 0, "₫", "Dong"},
+	{"VUV", "548", 0, "VT", "Vatu"},
+	{"WST", "882", 2, "WS$", "Tala"},
+	{"XAF", "950", 0, "FCFA", "CFA Franc BEAC"},
+	{"XCD", "951", 2, "EC$", "East Caribbean Dollar"},
+	{"XOF", "952", 0, "CFA", "CFA Franc BCEAO"},
+	{"XPF", "953", 0, "₣", "CFP Franc"},
+	{"YER", "886", 2, "﷼", "Yemeni Rial"},
+	{"ZAR", "710", 2, "R", "Rand"},
+	{"ZMW", "967", 2, "ZK", "Zambian Kwacha"},
+	{"ZWG", "924", 2, "ZiG", "Zimbabwe Gold"},
+	{"ZWL", "932", 2, "Z$", "Zimbabwe Dollar"},
+}
+
+var currencies = func() map[Currency]CurrencyInfo {
+	registry := make(map[Currency]CurrencyInfo, len(currencyTable))
+	for _, info := range currencyTable {
+		registry[info.Code] = info
+	}
+	return registry
+}()
+
+// LookupCurrency returns the registry entry for an ISO 4217 code.
+func LookupCurrency(code Currency) (CurrencyInfo, bool) {
+	info, ok := currencies[code]
+	return info, ok
+}
+
+// Currencies returns every registered currency ordered by code.
+func Currencies() []CurrencyInfo {
+	list := make([]CurrencyInfo, 0, len(currencies))
+	for _, info := range currencies {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+func (c Currency) IsValid() bool {
+	_, ok := currencies[c]
+	return ok
+}
+
+// MinorUnits returns the number of decimal places of the currency, or 2 for
+// a code that is not in the registry.
+func (c Currency) MinorUnits() int32 {
+	if info, ok := currencies[c]; ok {
+		return info.MinorUnits
+	}
+	return 2
+}
+
+// Symbol returns the local symbol of the currency, falling back to its code.
+func (c Currency) Symbol() string {
+	if info, ok := currencies[c]; ok && info.Symbol != "" {
+		return info.Symbol
+	}
+	return string(c)
+}
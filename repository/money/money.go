@@ -1,9 +1,8 @@
 package money
 
 import (
-	"database/sql/driver"
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/shopspring/decimal"
 )
@@ -16,16 +15,21 @@ const (
 	INR Currency = "INR"
 )
 
-var ConversionFactors = map[Currency]decimal.Decimal{
-	USD: decimal.NewFromFloat(0.012),
-	EUR: decimal.NewFromFloat(0.011),
-	INR: decimal.NewFromFloat(1.0),
-}
+var (
+	ErrCurrencyMismatch    = errors.New("currencies do not match")
+	ErrTooPrecise          = errors.New("amount has more decimal places than the currency allows")
+	ErrNegativeAmount      = errors.New("amount cannot be negative")
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrInvalidRate         = errors.New("exchange rate must be positive")
+)
 
 var ZeroAmountValue = decimal.NewFromFloat(0.0)
 
+// Money is stored as two columns when embedded in a model: a NUMERIC amount
+// wide enough for every minor unit in the registry, and the currency code.
 type Money struct {
-	Amount   decimal.Decimal
+	Amount   decimal.Decimal `gorm:"type:numeric(20,4)"`
 	Currency Currency
 }
 
@@ -33,121 +37,87 @@ func (m Money) Equals(other Money) bool {
 	return m.Amount.Equal(other.Amount) && m.Currency == other.Currency
 }
 
-func (m *Money) Value() (driver.Value, error) {
-	return fmt.Sprintf("%s %s", m.Amount.String(), m.Currency), nil
-}
-
-func (m *Money) Scan(value interface{}) error {
-	if value == nil {
-		return nil
-	}
-
-	strValue, ok := value.(string)
-	if !ok {
-		return fmt.Errorf("unexpected value type: %T", value)
-	}
-
-	parts := strings.Split(strValue, " ")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid value format: %s", strValue)
+func NewMoney(amount decimal.Decimal, currency Currency) (*Money, error) {
+	m := &Money{
+		Amount:   amount,
+		Currency: currency,
 	}
-
-	amount, err := decimal.NewFromString(parts[0])
-	if err != nil {
-		return fmt.Errorf("failed to parse amount: %w", err)
+	if err := m.Validate(); err != nil {
+		return nil, err
 	}
 
-	m.Amount = amount
-	m.Currency = Currency(parts[1])
-
-	return nil
+	return m, nil
 }
 
-func NewMoney(amount decimal.Decimal, currency Currency) (*Money, error) {
-	if amount.LessThan(ZeroAmountValue) {
-		return nil, fmt.Errorf("amount cannot be negative")
+// Validate rejects negative amounts, currencies outside the ISO 4217
+// registry and amounts finer than the minor unit of their currency.
+func (m Money) Validate() error {
+	if m.Amount.LessThan(ZeroAmountValue) {
+		return fmt.Errorf("%w: %s", ErrNegativeAmount, m.Amount)
 	}
-	_, ok := ConversionFactors[currency]
-	if !ok {
-		return nil, fmt.Errorf("unsupported currency: %s", currency)
+	if !m.Currency.IsValid() {
+		return fmt.Errorf("%w: %s", ErrUnsupportedCurrency, m.Currency)
 	}
-
-	return &Money{
-		Amount:   amount,
-		Currency: currency,
-	}, nil
-}
-
-func (mon *Money) ToBaseCurrency() (*Money, error) {
-	baseFactor, err := ConversionFactors[mon.Currency]
-	if !err {
-		return nil, fmt.Errorf("unsupported currency conversion")
+	if minorUnits := m.Currency.MinorUnits(); !m.Amount.Equal(m.Amount.Truncate(minorUnits)) {
+		return fmt.Errorf("%w: %s %s allows %d", ErrTooPrecise, m.Amount, m.Currency, minorUnits)
 	}
+	return nil
+}
 
-	convertedAmount := mon.Amount.Mul(baseFactor)
+// Round rounds the amount to the minor unit of its currency with the default
+// rounding mode.
+func (m Money) Round() *Money {
+	return m.RoundWith(DefaultRoundingMode())
+}
 
+func (m Money) RoundWith(mode RoundingMode) *Money {
 	return &Money{
-		Amount:   convertedAmount,
-		Currency: INR,
-	}, nil
+		Amount:   mode.Round(m.Amount, m.Currency.MinorUnits()),
+		Currency: m.Currency,
+	}
 }
 
+// Add returns the sum of both amounts. Money in different currencies has to
+// be converted explicitly at a known rate before it can be added.
 func (mon *Money) Add(money *Money) (*Money, error) {
-	baseCurrencyMoney, err := mon.ToBaseCurrency()
-	if err != nil {
-		return nil, err
-	}
-
-	otherBaseCurrencyMoney, err := money.ToBaseCurrency()
-	if err != nil {
-		return nil, err
-	}
-
-	addedAmount := baseCurrencyMoney.Amount.Add(otherBaseCurrencyMoney.Amount)
-
-	conversionFactor, ok := ConversionFactors[mon.Currency]
-	if !ok {
-		return nil, fmt.Errorf("unsupported currency conversion")
+	if mon.Currency != money.Currency {
+		return nil, fmt.Errorf("cannot add %s to %s: %w", money.Currency, mon.Currency, ErrCurrencyMismatch)
 	}
 
-	convertedAmount := addedAmount.Div(conversionFactor).Round(2)
-
-	return &Money{
-		Amount:   convertedAmount,
+	sum := Money{
+		Amount:   mon.Amount.Add(money.Amount),
 		Currency: mon.Currency,
-	}, nil
+	}
+	return sum.Round(), nil
 }
 
 func (mon *Money) Subtract(money *Money) (*Money, error) {
 	if mon.Currency != money.Currency {
-		return nil, fmt.Errorf("cannot subtract money with different currency")
+		return nil, fmt.Errorf("cannot subtract %s from %s: %w", money.Currency, mon.Currency, ErrCurrencyMismatch)
 	}
 
-	baseCurrencyMoney, err := mon.ToBaseCurrency()
-	if err != nil {
-		return nil, err
+	if mon.Amount.LessThan(money.Amount) {
+		return nil, fmt.Errorf("%w: cannot deduct %s from %s %s", ErrInsufficientFunds, money.Amount, mon.Amount, mon.Currency)
 	}
 
-	otherBaseCurrencyMoney, err := money.ToBaseCurrency()
-	if err != nil {
-		return nil, err
+	difference := Money{
+		Amount:   mon.Amount.Sub(money.Amount),
+		Currency: mon.Currency,
 	}
+	return difference.Round(), nil
+}
 
-	if baseCurrencyMoney.Amount.LessThan(otherBaseCurrencyMoney.Amount) {
-		return nil, fmt.Errorf("not enough money to deduct")
+// Convert returns the amount in currency to, where rate is the number of
+// units of to bought by one unit of mon.Currency, rounded to the minor unit
+// of to.
+func (mon *Money) Convert(to Currency, rate decimal.Decimal) (*Money, error) {
+	if !rate.IsPositive() {
+		return nil, fmt.Errorf("%w, got %s", ErrInvalidRate, rate)
 	}
 
-	subtractedAmount := baseCurrencyMoney.Amount.Sub(otherBaseCurrencyMoney.Amount)
-
-	conversionFactor, ok := ConversionFactors[mon.Currency]
-	if !ok {
-		return nil, fmt.Errorf("unsupported currency conversion")
+	converted := Money{
+		Amount:   mon.Amount.Mul(rate),
+		Currency: to,
 	}
-
-	convertedAmount := subtractedAmount.Div(conversionFactor).Round(2)
-
-	return &Money{
-		Amount:   convertedAmount,
-		Currency: mon.Currency,
-	}, nil
+	return converted.Round(), nil
 }
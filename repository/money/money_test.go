@@ -1,6 +1,7 @@
 package money
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/shopspring/decimal"
@@ -32,63 +33,16 @@ func TestMoney(t *testing.T) {
 
 		_, err := NewMoney(amount, currency)
 
-		if err == nil {
-			t.Errorf("NewMoney() error = nil, want non-nil")
-			return
+		if !errors.Is(err, ErrNegativeAmount) {
+			t.Errorf("NewMoney() error = %v, want %v", err, ErrNegativeAmount)
 		}
 	})
 
 	t.Run("NewMoney to return error for invalid currency", func(t *testing.T) {
 		_, err := NewMoney(decimal.NewFromFloat(100.0), Currency("DIR"))
 
-		if err == nil {
-			t.Errorf("NewMoney() error = nil, want non-nil")
-			return
-		}
-	})
-
-	t.Run("ToBaseCurrency to convert USD to INR", func(t *testing.T) {
-		hundredDollars, _ := NewMoney(decimal.NewFromFloat(100.0), USD)
-		conversionToRupees, _ := NewMoney(decimal.NewFromFloat(1.2), INR)
-		result1, err := hundredDollars.ToBaseCurrency()
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if !result1.Amount.Equal(conversionToRupees.Amount) {
-			t.Errorf("Expected amount: %s, but got: %s", conversionToRupees.Amount.String(), result1.Amount.String())
-		}
-		if result1.Currency != INR {
-			t.Errorf("Expected currency: %s, but got: %s", INR, result1.Currency)
-		}
-	})
-	t.Run("ToBaseCurrency to convert USD to INR", func(t *testing.T) {
-		hundredEuros, _ := NewMoney(decimal.NewFromFloat(100.0), EUR)
-		conversionToRupees, _ := NewMoney(decimal.NewFromFloat(1.1), INR)
-		result2, err := hundredEuros.ToBaseCurrency()
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if !result2.Amount.Equal(conversionToRupees.Amount) {
-			t.Errorf("Expected amount: %s, but got: %s", conversionToRupees.Amount.String(), result2.Amount.String())
-		}
-		if result2.Currency != INR {
-			t.Errorf("Expected currency: %s, but got: %s", INR, result2.Currency)
-		}
-	})
-
-	t.Run("ToBaseCurrency to convert USD to INR", func(t *testing.T) {
-
-		hundredRupees, _ := NewMoney(decimal.NewFromFloat(100.0), INR)
-		conversionToRupees, _ := NewMoney(decimal.NewFromFloat(100.0), INR)
-		result3, err := hundredRupees.ToBaseCurrency()
-		if err != nil {
-			t.Errorf("Unexpected error: %v", err)
-		}
-		if !result3.Amount.Equal(conversionToRupees.Amount) {
-			t.Errorf("Expected amount: %s, but got: %s", conversionToRupees.Amount.String(), result3.Amount.String())
-		}
-		if result3.Currency != INR {
-			t.Errorf("Expected currency: %s, but got: %s", INR, result3.Currency)
+		if !errors.Is(err, ErrUnsupportedCurrency) {
+			t.Errorf("NewMoney() error = %v, want %v", err, ErrUnsupportedCurrency)
 		}
 	})
 
@@ -107,23 +61,31 @@ func TestMoney(t *testing.T) {
 		}
 	})
 
-	t.Run("Add method to add two different currency, USD and EUR and return USD", func(t *testing.T) {
+	t.Run("Add method to return error for two different currencies", func(t *testing.T) {
 		hundredDollars, _ := NewMoney(decimal.NewFromFloat(100.0), USD)
 		fiftyEuros, _ := NewMoney(decimal.NewFromFloat(50.0), EUR)
 
-		expectedAmount, _ := NewMoney(decimal.NewFromFloat(145.83), USD)
+		_, err := hundredDollars.Add(fiftyEuros)
+		if !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("Money.Add() error = %v, want %v", err, ErrCurrencyMismatch)
+		}
+	})
+
+	t.Run("Convert method to convert money at the given rate", func(t *testing.T) {
+		hundredDollars, _ := NewMoney(decimal.NewFromFloat(100.0), USD)
 
-		result, err := hundredDollars.Add(fiftyEuros)
+		result, err := hundredDollars.Convert(EUR, decimal.RequireFromString("0.9167"))
 		if err != nil {
-			t.Fatalf("Money.Add() error: %v", err)
+			t.Fatalf("Money.Convert() error: %v", err)
 		}
 
-		if !result.Amount.Equal(expectedAmount.Amount) {
-			t.Errorf("Money.Add() got = %s, want = %s", result.Amount.String(), expectedAmount.Amount.String())
+		expected, _ := NewMoney(decimal.NewFromFloat(91.67), EUR)
+		if !result.Equals(*expected) {
+			t.Errorf("Money.Convert() got = %v, want = %v", result, expected)
 		}
 
-		if result.Currency != USD {
-			t.Errorf("Money.Add() currency got = %s, want = %s", result.Currency, USD)
+		if _, err := hundredDollars.Convert(EUR, decimal.Zero); !errors.Is(err, ErrInvalidRate) {
+			t.Errorf("Money.Convert() error = %v, want %v for a zero rate", err, ErrInvalidRate)
 		}
 	})
 
@@ -145,8 +107,152 @@ func TestMoney(t *testing.T) {
 
 		_, err := hundredRupees.Subtract(fiftyRupees)
 
-		if err == nil {
-			t.Errorf("Money.Subtract() error = nil, want non-nil")
+		if !errors.Is(err, ErrInsufficientFunds) {
+			t.Errorf("Money.Subtract() error = %v, want %v", err, ErrInsufficientFunds)
+		}
+	})
+
+	t.Run("Subtract method to return error for two different currencies", func(t *testing.T) {
+		hundredDollars, _ := NewMoney(decimal.NewFromFloat(100.0), USD)
+		fiftyEuros, _ := NewMoney(decimal.NewFromFloat(50.0), EUR)
+
+		_, err := hundredDollars.Subtract(fiftyEuros)
+
+		if !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("Money.Subtract() error = %v, want %v", err, ErrCurrencyMismatch)
+		}
+	})
+}
+
+func TestCurrencyRegistry(t *testing.T) {
+	t.Run("LookupCurrency to return the ISO 4217 entry with its minor units", func(t *testing.T) {
+		tests := []struct {
+			code       Currency
+			numeric    string
+			minorUnits int32
+		}{
+			{USD, "840", 2},
+			{Currency("JPY"), "392", 0},
+			{Currency("KWD"), "414", 3},
+			{Currency("CLF"), "990", 4},
+		}
+		for _, tt := range tests {
+			info, ok := LookupCurrency(tt.code)
+			if !ok {
+				t.Fatalf("LookupCurrency(%s) ok = false, want true", tt.code)
+			}
+			if info.Numeric != tt.numeric || info.MinorUnits != tt.minorUnits {
+				t.Errorf("LookupCurrency(%s) = %+v, want numeric %s and %d minor units", tt.code, info, tt.numeric, tt.minorUnits)
+			}
+		}
+
+		if _, ok := LookupCurrency(Currency("XAU")); ok {
+			t.Errorf("LookupCurrency(XAU) ok = true, want false")
+		}
+		if INR.Symbol() != "₹" || Currency("BOV").Symbol() != "BOV" {
+			t.Errorf("Symbol() = %s and %s, want ₹ and BOV", INR.Symbol(), Currency("BOV").Symbol())
+		}
+	})
+
+	t.Run("Currencies to list every code once in order", func(t *testing.T) {
+		list := Currencies()
+		for i := 1; i < len(list); i++ {
+			if list[i-1].Code >= list[i].Code {
+				t.Fatalf("Currencies() is not sorted at %s, %s", list[i-1].Code, list[i].Code)
+			}
+		}
+		if len(list) != len(currencyTable) {
+			t.Errorf("Currencies() has %d entries, want %d", len(list), len(currencyTable))
+		}
+	})
+
+	t.Run("NewMoney to reject amounts finer than the minor unit of the currency", func(t *testing.T) {
+		tests := []struct {
+			amount   string
+			currency Currency
+			valid    bool
+		}{
+			{"10.50", INR, true},
+			{"10.500", INR, true},
+			{"10.005", INR, false},
+			{"1500", Currency("JPY"), true},
+			{"1500.5", Currency("JPY"), false},
+			{"1.234", Currency("KWD"), true},
+			{"1.2345", Currency("KWD"), false},
+		}
+		for _, tt := range tests {
+			_, err := NewMoney(decimal.RequireFromString(tt.amount), tt.currency)
+			if tt.valid && err != nil {
+				t.Errorf("NewMoney(%s %s) error = %v, want nil", tt.amount, tt.currency, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrTooPrecise) {
+				t.Errorf("NewMoney(%s %s) error = %v, want %v", tt.amount, tt.currency, err, ErrTooPrecise)
+			}
+		}
+	})
+}
+
+func TestRounding(t *testing.T) {
+	t.Run("RoundingMode Round method to round ties as configured", func(t *testing.T) {
+		tests := []struct {
+			mode       RoundingMode
+			value      string
+			want       string
+			wantNeg    string
+			wantOddTie string
+		}{
+			{RoundHalfEven, "2.345", "2.34", "-2.34", "2.36"},
+			{RoundHalfUp, "2.345", "2.35", "-2.35", "2.36"},
+			{RoundDown, "2.345", "2.34", "-2.34", "2.35"},
+			{RoundUp, "2.345", "2.35", "-2.35", "2.36"},
+			{RoundCeiling, "2.345", "2.35", "-2.34", "2.36"},
+			{RoundFloor, "2.345", "2.34", "-2.35", "2.35"},
+		}
+		for _, tt := range tests {
+			value := decimal.RequireFromString(tt.value)
+			if got := tt.mode.Round(value, 2).String(); got != tt.want {
+				t.Errorf("%s.Round(%s) = %s, want %s", tt.mode, tt.value, got, tt.want)
+			}
+			if got := tt.mode.Round(value.Neg(), 2).String(); got != tt.wantNeg {
+				t.Errorf("%s.Round(-%s) = %s, want %s", tt.mode, tt.value, got, tt.wantNeg)
+			}
+			if got := tt.mode.Round(decimal.RequireFromString("2.355"), 2).String(); got != tt.wantOddTie {
+				t.Errorf("%s.Round(2.355) = %s, want %s", tt.mode, got, tt.wantOddTie)
+			}
+
+			parsed, err := ParseRoundingMode(tt.mode.String())
+			if err != nil || parsed != tt.mode {
+				t.Errorf("ParseRoundingMode(%s) = %v, %v", tt.mode, parsed, err)
+			}
+		}
+
+		if _, err := ParseRoundingMode("sideways"); err == nil {
+			t.Errorf("ParseRoundingMode(sideways) error = nil, want non-nil")
+		}
+	})
+
+	t.Run("Convert method to round to the minor unit of the target currency with the default mode", func(t *testing.T) {
+		tenDollars, _ := NewMoney(decimal.NewFromInt(10), USD)
+
+		yen, err := tenDollars.Convert(Currency("JPY"), decimal.RequireFromString("0.25"))
+		if err != nil {
+			t.Fatalf("Money.Convert() error: %v", err)
+		}
+		if yen.Amount.String() != "2" {
+			t.Errorf("Money.Convert() got = %s, want 2 with half-even rounding", yen.Amount)
+		}
+
+		SetRoundingMode(RoundHalfUp)
+		defer SetRoundingMode(RoundHalfEven)
+
+		yen, _ = tenDollars.Convert(Currency("JPY"), decimal.RequireFromString("0.25"))
+		if yen.Amount.String() != "3" {
+			t.Errorf("Money.Convert() got = %s, want 3 with half-up rounding", yen.Amount)
+		}
+
+		dinars, _ := tenDollars.Convert(Currency("KWD"), decimal.RequireFromString("0.30745"))
+		if dinars.Amount.String() != "3.075" {
+			t.Errorf("Money.Convert() got = %s, want 3.075", dinars.Amount)
 		}
 	})
 }
@@ -0,0 +1,82 @@
+package money
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/shopspring/decimal"
+)
+
+type RoundingMode int32
+
+const (
+	// RoundHalfEven rounds ties to the nearest even digit (banker's rounding).
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates towards zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+	RoundCeiling
+	RoundFloor
+)
+
+var roundingModeNames = map[RoundingMode]string{
+	RoundHalfEven: "half_even",
+	RoundHalfUp:   "half_up",
+	RoundDown:     "down",
+	RoundUp:       "up",
+	RoundCeiling:  "ceiling",
+	RoundFloor:    "floor",
+}
+
+var defaultRoundingMode atomic.Int32
+
+// SetRoundingMode changes the mode every Money operation rounds with. It is
+// meant to be called once at startup.
+func SetRoundingMode(mode RoundingMode) {
+	defaultRoundingMode.Store(int32(mode))
+}
+
+func DefaultRoundingMode() RoundingMode {
+	return RoundingMode(defaultRoundingMode.Load())
+}
+
+// ParseRoundingMode accepts the names returned by RoundingMode.String. An
+// empty name selects RoundHalfEven.
+func ParseRoundingMode(name string) (RoundingMode, error) {
+	if name == "" {
+		return RoundHalfEven, nil
+	}
+	for mode, modeName := range roundingModeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown rounding mode: %s", name)
+}
+
+func (mode RoundingMode) String() string {
+	if name, ok := roundingModeNames[mode]; ok {
+		return name
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int32(mode))
+}
+
+func (mode RoundingMode) Round(d decimal.Decimal, places int32) decimal.Decimal {
+	switch mode {
+	case RoundHalfUp:
+		return d.Round(places)
+	case RoundDown:
+		return d.RoundDown(places)
+	case RoundUp:
+		return d.RoundUp(places)
+	case RoundCeiling:
+		return d.RoundCeil(places)
+	case RoundFloor:
+		return d.RoundFloor(places)
+	default:
+		return d.RoundBank(places)
+	}
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"nikwallet/repository/models"
+
+	"gorm.io/gorm/clause"
+)
+
+func (db *PostgreSQL) CreateSession(newSession *models.Session) error {
+	err := db.DB.Create(newSession).Error
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetSessionByID(id int) (*models.Session, error) {
+	session := &models.Session{}
+	err := db.DB.First(session, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	return session, nil
+}
+
+func (db *PostgreSQL) ListActiveSessions(userID int, now time.Time) ([]*models.Session, error) {
+	sessions := []*models.Session{}
+	err := db.DB.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC, id DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (db *PostgreSQL) UpdateSession(changedSession *models.Session) error {
+	err := db.DB.Save(changedSession).Error
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) CreateRefreshToken(newToken *models.RefreshToken) error {
+	err := db.DB.Create(newToken).Error
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	refreshToken := &models.RefreshToken{}
+	err := db.DB.Where("token_hash = ?", tokenHash).First(refreshToken).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return refreshToken, nil
+}
+
+// MarkRefreshTokenUsed reports false when the token had already been used,
+// so that of two concurrent refreshes with the same token only one wins.
+func (db *PostgreSQL) MarkRefreshTokenUsed(id int, usedAt time.Time) (bool, error) {
+	result := db.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (db *PostgreSQL) RevokeToken(revokedToken *models.RevokedToken) error {
+	err := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(revokedToken).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) IsTokenRevoked(tokenID string, now time.Time) (bool, error) {
+	var count int64
+	err := db.DB.Model(&models.RevokedToken{}).
+		Where("token_id = ? AND expires_at > ?", tokenID, now).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (db *PostgreSQL) DeleteExpiredRevokedTokens(now time.Time) (int64, error) {
+	result := db.DB.Where("expires_at <= ?", now).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
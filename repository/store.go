@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"time"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+)
+
+type UserStore interface {
+	CreateUser(newUser *models.User) (int, error)
+	GetUserByID(id int) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	ListUsers(afterID int, limit int) ([]*models.User, error)
+	UpdateUserPassword(userID int, password string) error
+	SearchUsers(query UserQuery) ([]*models.User, error)
+	UpdateUserRole(userID int, role models.Role) error
+	CreateRoleChange(newChange *models.RoleChange) error
+	ListRoleChanges(userID int) ([]*models.RoleChange, error)
+	UpdateUserFrozen(userID int, frozenAt *time.Time, frozenBy int, reason string) error
+	// LockUsersForUpdate holds the rows of the users until the transaction
+	// ends, so that checks spanning every wallet of a user run one at a time.
+	LockUsersForUpdate(userIDs ...int) error
+}
+
+// UserQuery pages through users by id. Email matches any part of the email
+// address, ignoring case; zero fields do not filter.
+type UserQuery struct {
+	Email   string
+	Role    models.Role
+	AfterID int
+	Limit   int
+}
+
+type SessionStore interface {
+	CreateSession(newSession *models.Session) error
+	GetSessionByID(id int) (*models.Session, error)
+	ListActiveSessions(userID int, now time.Time) ([]*models.Session, error)
+	UpdateSession(changedSession *models.Session) error
+	CreateRefreshToken(newToken *models.RefreshToken) error
+	GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(id int, usedAt time.Time) (bool, error)
+	RevokeToken(revokedToken *models.RevokedToken) error
+	IsTokenRevoked(tokenID string, now time.Time) (bool, error)
+	DeleteExpiredRevokedTokens(now time.Time) (int64, error)
+}
+
+type WalletStore interface {
+	CreateWallet(newWallet *models.Wallet) (*models.Wallet, error)
+	GetWalletByID(id int) (*models.Wallet, error)
+	GetWalletByUserID(userID int) (*models.Wallet, error)
+	ListWalletsByUserID(userID int) ([]*models.Wallet, error)
+	SetDefaultWallet(userID int, walletID int) error
+	LockWalletsForUpdate(walletIDs ...int) (map[int]*models.Wallet, error)
+	UpdateWallet(changedWallet *models.Wallet) (*models.Wallet, error)
+}
+
+type LedgerStore interface {
+	CreateJournalEntry(newEntry *models.JournalEntry) error
+	GetJournalEntryByID(id int) (*models.JournalEntry, error)
+	GetLatestJournalEntry(userID int) (*models.JournalEntry, error)
+	ListReversingEntries(entryID int) ([]*models.JournalEntry, error)
+	GetLastNPostings(userID, limit int) ([]*models.Posting, error)
+	GetLastNWalletPostings(walletID, limit int) ([]*models.Posting, error)
+	ListPostings(query PostingQuery) ([]*models.Posting, error)
+	GetWalletBalanceFromPostings(walletID int) (decimal.Decimal, error)
+	GetWalletBalanceAt(walletID int, at time.Time) (decimal.Decimal, error)
+	CreateBalanceSnapshots(at time.Time) (int64, error)
+	SumDebits(query DebitQuery) ([]DebitTotal, error)
+}
+
+// PostingQuery selects the wallet postings of a user, ordered by created_at
+// and ID. Zero fields do not filter. After continues the listing behind the
+// posting it points at, in the direction of the order.
+type PostingQuery struct {
+	UserID             int
+	WalletID           int
+	TransactionTypes   []string
+	CounterpartyUserID int
+	Currency           money.Currency
+	MinAmount          decimal.NullDecimal
+	MaxAmount          decimal.NullDecimal
+	From               time.Time
+	To                 time.Time
+	Ascending          bool
+	After              *PostingCursor
+	Limit              int
+}
+
+// PostingCursor is the position of a posting in the created_at, ID order.
+type PostingCursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// DebitQuery selects the wallet debits of a user of one transaction type
+// made at or after Since. Transfers between wallets of the user are left out.
+type DebitQuery struct {
+	UserID          int
+	TransactionType string
+	Since           time.Time
+}
+
+// DebitTotal sums the debits of one currency and counts the journal entries
+// they belong to.
+type DebitTotal struct {
+	Currency money.Currency
+	Amount   decimal.Decimal
+	Count    int
+}
+
+type HoldStore interface {
+	CreateHold(newHold *models.Hold) error
+	GetHoldByID(id int) (*models.Hold, error)
+	UpdateHold(changedHold *models.Hold) error
+	ListExpiredHolds(now time.Time, limit int) ([]*models.Hold, error)
+}
+
+type LimitStore interface {
+	ListUserLimits(userID int) ([]*models.UserLimit, error)
+	SaveUserLimit(limit *models.UserLimit) error
+}
+
+type KYCStore interface {
+	UpdateUserKYCLevel(userID int, level models.KYCLevel) error
+	CreateKYCSubmission(newSubmission *models.KYCSubmission) error
+	GetKYCSubmissionByID(id int) (*models.KYCSubmission, error)
+	ListKYCSubmissions(query KYCSubmissionQuery) ([]*models.KYCSubmission, error)
+	MarkKYCSubmissionReviewed(reviewed *models.KYCSubmission) (bool, error)
+	CreateKYCEvent(newEvent *models.KYCEvent) error
+	ListKYCEvents(userID int) ([]*models.KYCEvent, error)
+}
+
+// KYCSubmissionQuery selects submissions by ID order. Zero fields do not
+// filter; AfterID continues the listing behind that submission.
+type KYCSubmissionQuery struct {
+	UserID  int
+	Status  models.KYCSubmissionStatus
+	AfterID int
+	Limit   int
+}
+
+type FXStore interface {
+	CreateExchangeRate(newRate *models.ExchangeRate) error
+	GetLatestExchangeRate(base, quote money.Currency, at time.Time) (*models.ExchangeRate, error)
+	CreateFXQuote(newQuote *models.FXQuote) error
+	GetFXQuote(id string) (*models.FXQuote, error)
+	MarkFXQuoteUsed(id string, usedAt time.Time) (bool, error)
+}
+
+type IdempotencyStore interface {
+	CreateIdempotencyKey(newKey *models.IdempotencyKey) error
+	GetIdempotencyKey(userID int, key string) (*models.IdempotencyKey, error)
+	UpdateIdempotencyKey(changedKey *models.IdempotencyKey) error
+	DeleteIdempotencyKey(id int) error
+	DeleteExpiredIdempotencyKeys(now time.Time) (int64, error)
+}
+
+// Store is the persistence boundary used by the services. Transaction runs fn
+// against a Store bound to a single transaction: every write made through tx
+// is committed when fn returns nil and rolled back otherwise.
+type Store interface {
+	UserStore
+	SessionStore
+	WalletStore
+	LedgerStore
+	HoldStore
+	LimitStore
+	KYCStore
+	FXStore
+	IdempotencyStore
+
+	Transaction(fn func(tx Store) error) error
+}
+
+var (
+	_ Store = (*PostgreSQL)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,879 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"nikwallet/config"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestStoreConformance(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testStoreConformance(t, NewMemoryStore())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		if os.Getenv("NIKWALLET_TEST_STORE") != "postgres" {
+			t.Skip("set NIKWALLET_TEST_STORE=postgres to run against a real database")
+		}
+
+		c, err := config.LoadConfig()
+		if err != nil {
+			t.Fatalf("failed at config: %v", err)
+		}
+
+		pg := &PostgreSQL{}
+		if err := pg.Connect(&c); err != nil {
+			t.Fatalf("failed to connect to database: %v", err)
+		}
+		defer func() {
+			if sqlDB, err := pg.DB.DB(); err == nil {
+				sqlDB.Close()
+			}
+		}()
+
+		testStoreConformance(t, pg)
+	})
+}
+
+func testStoreConformance(t *testing.T, store Store) {
+	suffix := time.Now().UnixNano()
+	email := func(name string) string {
+		return fmt.Sprintf("%s_%d@conformance.example.com", name, suffix)
+	}
+	inr := func(amount float64) *money.Money {
+		return &money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+
+	t.Run("CreateUser method to reject a duplicate EmailID", func(t *testing.T) {
+		userID, err := store.CreateUser(&models.User{EmailID: email("unique"), Password: "secret"})
+		assert.NoError(t, err)
+		assert.NotZero(t, userID)
+
+		duplicateID, err := store.CreateUser(&models.User{EmailID: email("unique"), Password: "other"})
+		assert.Error(t, err)
+		assert.Zero(t, duplicateID)
+	})
+
+	t.Run("GetUserByEmail and GetUserByID methods to return the stored user", func(t *testing.T) {
+		userID, err := store.CreateUser(&models.User{EmailID: email("lookup"), Password: "secret"})
+		assert.NoError(t, err)
+
+		byEmail, err := store.GetUserByEmail(email("lookup"))
+		assert.NoError(t, err)
+		assert.Equal(t, userID, int(byEmail.ID))
+
+		byID, err := store.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, email("lookup"), byID.EmailID)
+
+		_, err = store.GetUserByEmail(email("missing"))
+		assert.EqualError(t, err, fmt.Sprintf("failed to get user: %s", email("missing")))
+	})
+
+	t.Run("ListUsers and UpdateUserPassword methods to page through users and replace a password", func(t *testing.T) {
+		firstID, _ := store.CreateUser(&models.User{EmailID: email("list_first"), Password: "secret"})
+		secondID, _ := store.CreateUser(&models.User{EmailID: email("list_second"), Password: "secret"})
+
+		users, err := store.ListUsers(firstID-1, 1)
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, firstID, int(users[0].ID))
+
+		users, err = store.ListUsers(firstID, 10)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, users)
+		assert.Equal(t, secondID, int(users[0].ID))
+
+		err = store.UpdateUserPassword(secondID, "$2a$hash")
+		assert.NoError(t, err)
+
+		stored, err := store.GetUserByID(secondID)
+		assert.NoError(t, err)
+		assert.Equal(t, "$2a$hash", stored.Password)
+
+		err = store.UpdateUserPassword(-1, "$2a$hash")
+		assert.Error(t, err)
+	})
+
+	t.Run("SearchUsers method to match part of the email, ignoring case, and the role", func(t *testing.T) {
+		firstID, _ := store.CreateUser(&models.User{EmailID: email("search_first"), Password: "secret"})
+		secondID, _ := store.CreateUser(&models.User{EmailID: email("search_second"), Password: "secret", Role: models.RoleAuditor})
+
+		users, err := store.SearchUsers(UserQuery{Email: fmt.Sprintf("SEARCH_FIRST_%d", suffix), Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, firstID, int(users[0].ID))
+		assert.Equal(t, models.RoleCustomer, users[0].Role)
+
+		users, err = store.SearchUsers(UserQuery{Email: fmt.Sprint(suffix), Role: models.RoleAuditor, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, secondID, int(users[0].ID))
+
+		users, err = store.SearchUsers(UserQuery{Email: fmt.Sprintf("first%%%d", suffix), Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, users)
+
+		users, err = store.SearchUsers(UserQuery{Email: "search_", AfterID: firstID, Limit: 1})
+		assert.NoError(t, err)
+		assert.Len(t, users, 1)
+		assert.Equal(t, secondID, int(users[0].ID))
+	})
+
+	t.Run("UpdateUserRole and UpdateUserFrozen methods to change only their own columns", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("frozen"), Password: "secret"})
+		frozenAt := time.Now().Truncate(time.Microsecond)
+
+		assert.NoError(t, store.UpdateUserRole(userID, models.RoleSupport))
+		assert.NoError(t, store.UpdateUserFrozen(userID, &frozenAt, 7, "chargebacks"))
+		stored, err := store.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleSupport, stored.Role)
+		assert.True(t, stored.IsFrozen())
+		assert.True(t, frozenAt.Equal(*stored.FrozenAt))
+		assert.Equal(t, 7, stored.FrozenBy)
+		assert.Equal(t, "chargebacks", stored.FrozenReason)
+		assert.Equal(t, "secret", stored.Password)
+
+		assert.NoError(t, store.UpdateUserFrozen(userID, nil, 0, ""))
+		stored, _ = store.GetUserByID(userID)
+		assert.False(t, stored.IsFrozen())
+		assert.Empty(t, stored.FrozenReason)
+
+		assert.Error(t, store.UpdateUserRole(-1, models.RoleAdmin))
+		assert.Error(t, store.UpdateUserFrozen(-1, nil, 0, ""))
+	})
+
+	t.Run("CreateRoleChange and ListRoleChanges methods to keep the audit trail of a user", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("role_changes"), Password: "secret"})
+
+		first := &models.RoleChange{UserID: userID, ActorID: 7, FromRole: models.RoleCustomer, ToRole: models.RoleSupport, CreatedAt: time.Now()}
+		assert.NoError(t, store.CreateRoleChange(first))
+		assert.NotZero(t, first.ID)
+		assert.NoError(t, store.CreateRoleChange(&models.RoleChange{UserID: userID, ActorID: 7, FromRole: models.RoleSupport, ToRole: models.RoleAdmin, CreatedAt: time.Now()}))
+
+		changes, err := store.ListRoleChanges(userID)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, first.ID, changes[0].ID)
+		assert.Equal(t, models.RoleAdmin, changes[1].ToRole)
+		assert.Error(t, store.CreateRoleChange(&models.RoleChange{UserID: -1, FromRole: models.RoleCustomer, ToRole: models.RoleAdmin}))
+	})
+
+	t.Run("MarkRefreshTokenUsed method to claim a refresh token only once", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("refresh"), Password: "secret"})
+		now := time.Now()
+
+		session := &models.Session{UserID: userID, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+		assert.NoError(t, store.CreateSession(session))
+		assert.NotZero(t, session.ID)
+
+		refreshToken := &models.RefreshToken{SessionID: session.ID, UserID: userID, TokenHash: email("hash"), ExpiresAt: now.Add(time.Hour)}
+		assert.NoError(t, store.CreateRefreshToken(refreshToken))
+
+		stored, err := store.GetRefreshTokenByHash(email("hash"))
+		assert.NoError(t, err)
+		assert.Equal(t, refreshToken.ID, stored.ID)
+		assert.Nil(t, stored.UsedAt)
+
+		claimed, err := store.MarkRefreshTokenUsed(refreshToken.ID, now)
+		assert.NoError(t, err)
+		assert.True(t, claimed)
+
+		claimed, err = store.MarkRefreshTokenUsed(refreshToken.ID, now)
+		assert.NoError(t, err)
+		assert.False(t, claimed)
+
+		stored, _ = store.GetRefreshTokenByHash(email("hash"))
+		assert.NotNil(t, stored.UsedAt)
+	})
+
+	t.Run("ListActiveSessions method to skip revoked and expired sessions", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("sessions"), Password: "secret"})
+		now := time.Now()
+
+		older := &models.Session{UserID: userID, LastUsedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)}
+		newer := &models.Session{UserID: userID, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+		expired := &models.Session{UserID: userID, LastUsedAt: now, ExpiresAt: now.Add(-time.Minute)}
+		revoked := &models.Session{UserID: userID, LastUsedAt: now, ExpiresAt: now.Add(time.Hour)}
+		for _, session := range []*models.Session{older, newer, expired, revoked} {
+			assert.NoError(t, store.CreateSession(session))
+		}
+
+		revoked.RevokedAt = &now
+		assert.NoError(t, store.UpdateSession(revoked))
+
+		sessions, err := store.ListActiveSessions(userID, now)
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 2)
+		assert.Equal(t, newer.ID, sessions[0].ID)
+		assert.Equal(t, older.ID, sessions[1].ID)
+	})
+
+	t.Run("RevokeToken method to deny a token until it expires", func(t *testing.T) {
+		now := time.Now()
+
+		assert.NoError(t, store.RevokeToken(&models.RevokedToken{TokenID: email("live"), ExpiresAt: now.Add(time.Hour)}))
+		assert.NoError(t, store.RevokeToken(&models.RevokedToken{TokenID: email("live"), ExpiresAt: now.Add(time.Hour)}))
+		assert.NoError(t, store.RevokeToken(&models.RevokedToken{TokenID: email("dead"), ExpiresAt: now.Add(-time.Second)}))
+
+		revoked, err := store.IsTokenRevoked(email("live"), now)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+
+		revoked, err = store.IsTokenRevoked(email("unknown"), now)
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+
+		deleted, err := store.DeleteExpiredRevokedTokens(now)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		revoked, _ = store.IsTokenRevoked(email("live"), now)
+		assert.True(t, revoked)
+	})
+
+	t.Run("CreateWallet method to allow one wallet per currency and name", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("wallets"), Password: "secret"})
+
+		main, err := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+		assert.NoError(t, err)
+		assert.Equal(t, money.INR, main.Money.Currency)
+
+		_, err = store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+		assert.ErrorIs(t, err, ErrWalletExists)
+
+		pocket, err := store.CreateWallet(&models.Wallet{UserID: userID, Name: "Savings", Money: inr(0)})
+		assert.NoError(t, err)
+
+		wallets, err := store.ListWalletsByUserID(userID)
+		assert.NoError(t, err)
+		assert.Len(t, wallets, 2)
+		assert.Equal(t, main.ID, wallets[0].ID)
+
+		stored, err := store.GetWalletByID(pocket.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Savings", stored.Name)
+	})
+
+	t.Run("SetDefaultWallet method to change the wallet GetWalletByUserID returns", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("default"), Password: "secret"})
+		first, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+		second, _ := store.CreateWallet(&models.Wallet{UserID: userID, Name: "Pocket", Money: inr(0)})
+
+		wallet, err := store.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, first.ID, wallet.ID)
+
+		assert.NoError(t, store.SetDefaultWallet(userID, second.ID))
+		wallet, _ = store.GetWalletByUserID(userID)
+		assert.Equal(t, second.ID, wallet.ID)
+
+		assert.NoError(t, store.SetDefaultWallet(userID, first.ID))
+		wallet, _ = store.GetWalletByUserID(userID)
+		assert.Equal(t, first.ID, wallet.ID)
+
+		assert.Error(t, store.SetDefaultWallet(userID+1, second.ID))
+	})
+
+	t.Run("UpdateWallet method to reject a negative balance", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("negative"), Password: "secret"})
+		wallet, err := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(5)})
+		assert.NoError(t, err)
+
+		wallet.Money = &money.Money{Amount: decimal.NewFromInt(-1), Currency: money.INR}
+		_, err = store.UpdateWallet(wallet)
+		assert.Error(t, err)
+
+		stored, err := store.GetWalletByID(wallet.ID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Money.Equals(*inr(5)))
+	})
+
+	t.Run("UpdateWallet method to reject holding more than the balance", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("overheld"), Password: "secret"})
+		wallet, err := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(5)})
+		assert.NoError(t, err)
+
+		wallet.HeldAmount = decimal.NewFromInt(6)
+		_, err = store.UpdateWallet(wallet)
+		assert.Error(t, err)
+
+		wallet.HeldAmount = decimal.NewFromInt(5)
+		_, err = store.UpdateWallet(wallet)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ListExpiredHolds method to return only active holds past their expiry", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("holds"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(100)})
+
+		now := time.Now()
+		newHold := func(expiresAt time.Time) *models.Hold {
+			hold := &models.Hold{WalletID: wallet.ID, UserID: userID, Amount: inr(10), Status: models.HoldStatusActive, ExpiresAt: expiresAt}
+			assert.NoError(t, store.CreateHold(hold))
+			return hold
+		}
+		expired := newHold(now.Add(-time.Minute))
+		voided := newHold(now.Add(-time.Hour))
+		newHold(now.Add(time.Hour))
+
+		voided.Status = models.HoldStatusVoided
+		assert.NoError(t, store.UpdateHold(voided))
+
+		holds, err := store.ListExpiredHolds(now, 10)
+		assert.NoError(t, err)
+		var ids []int
+		for _, hold := range holds {
+			if hold.WalletID == wallet.ID {
+				ids = append(ids, hold.ID)
+			}
+		}
+		assert.Equal(t, []int{expired.ID}, ids)
+
+		stored, err := store.GetHoldByID(voided.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusVoided, stored.Status)
+		assert.True(t, stored.Amount.Equals(*inr(10)))
+	})
+
+	t.Run("UpdateWallet method to persist a copy that later mutations do not leak into", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("wallet"), Password: "secret"})
+		wallet, err := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+		assert.NoError(t, err)
+
+		wallet.Money = inr(25)
+		_, err = store.UpdateWallet(wallet)
+		assert.NoError(t, err)
+
+		wallet.Money.Amount = decimal.NewFromFloat(999)
+
+		stored, err := store.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Money.Equals(*inr(25)))
+	})
+
+	t.Run("GetLastNPostings method to order postings by created_at descending", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("ledger"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+
+		now := time.Now()
+		for _, offset := range []int{3, 1, 2} {
+			err := store.CreateJournalEntry(&models.JournalEntry{
+				TransactionType: string(models.TransactionTypeAdd),
+				Postings: []*models.Posting{
+					models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, inr(float64(offset))),
+					models.NewWalletPosting(wallet, models.PostingDirectionCredit, inr(float64(offset))),
+				},
+				CreatedAt: now.Add(-time.Duration(offset) * time.Minute),
+			})
+			assert.NoError(t, err)
+		}
+
+		postings, err := store.GetLastNPostings(userID, 2)
+		assert.NoError(t, err)
+		assert.Len(t, postings, 2)
+		assert.True(t, postings[0].Amount.Equals(*inr(1)))
+		assert.True(t, postings[1].Amount.Equals(*inr(2)))
+
+		walletPostings, err := store.GetLastNWalletPostings(wallet.ID, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, postings, walletPostings)
+
+		latest, err := store.GetLatestJournalEntry(userID)
+		assert.NoError(t, err)
+		assert.Len(t, latest.Postings, 2)
+		assert.True(t, latest.PostingForUser(userID).Amount.Equals(*inr(1)))
+
+		balance, err := store.GetWalletBalanceFromPostings(wallet.ID)
+		assert.NoError(t, err)
+		assert.True(t, balance.Equal(decimal.NewFromFloat(6)), "got %s, want 6", balance)
+	})
+
+	t.Run("ListPostings method to filter and page postings after a cursor", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("listing"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+
+		now := time.Now().Truncate(time.Second)
+		for offset, transactionType := range []models.TransactionType{models.TransactionTypeAdd, models.TransactionTypeWithdraw, models.TransactionTypeAdd, models.TransactionTypeAdd} {
+			amount := inr(float64(10 * (offset + 1)))
+			systemDirection, walletDirection := models.PostingDirectionDebit, models.PostingDirectionCredit
+			if transactionType == models.TransactionTypeWithdraw {
+				systemDirection, walletDirection = walletDirection, systemDirection
+			}
+			err := store.CreateJournalEntry(&models.JournalEntry{
+				TransactionType: string(transactionType),
+				Postings: []*models.Posting{
+					models.NewSystemPosting(models.SystemAccountExternalFunding, systemDirection, amount),
+					models.NewWalletPosting(wallet, walletDirection, amount),
+				},
+				CreatedAt: now.Add(time.Duration(offset) * time.Minute),
+			})
+			assert.NoError(t, err)
+		}
+
+		amounts := func(postings []*models.Posting) []string {
+			var values []string
+			for _, posting := range postings {
+				values = append(values, posting.Amount.Amount.String())
+			}
+			return values
+		}
+
+		firstPage, err := store.ListPostings(PostingQuery{UserID: userID, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"40", "30"}, amounts(firstPage))
+
+		last := firstPage[1]
+		secondPage, err := store.ListPostings(PostingQuery{UserID: userID, Limit: 2, After: &PostingCursor{CreatedAt: last.CreatedAt, ID: last.ID}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"20", "10"}, amounts(secondPage))
+
+		ascending, err := store.ListPostings(PostingQuery{UserID: userID, Ascending: true, Limit: 3})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10", "20", "30"}, amounts(ascending))
+
+		filtered, err := store.ListPostings(PostingQuery{
+			UserID:           userID,
+			WalletID:         wallet.ID,
+			TransactionTypes: []string{string(models.TransactionTypeAdd)},
+			Currency:         money.INR,
+			MinAmount:        decimal.NewNullDecimal(decimal.NewFromInt(15)),
+			MaxAmount:        decimal.NewNullDecimal(decimal.NewFromInt(40)),
+			From:             now,
+			To:               now.Add(3 * time.Minute),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"30"}, amounts(filtered))
+
+		none, err := store.ListPostings(PostingQuery{UserID: userID, Currency: money.USD})
+		assert.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("GetWalletBalanceAt method to add the postings after the latest snapshot", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("balanceat"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+
+		start := time.Now().Add(-time.Hour).Truncate(time.Second)
+		deposit := func(amount float64, at time.Time) {
+			err := store.CreateJournalEntry(&models.JournalEntry{
+				TransactionType: string(models.TransactionTypeAdd),
+				Postings: []*models.Posting{
+					models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, inr(amount)),
+					models.NewWalletPosting(wallet, models.PostingDirectionCredit, inr(amount)),
+				},
+				CreatedAt: at,
+			})
+			assert.NoError(t, err)
+		}
+		balanceAt := func(at time.Time) string {
+			balance, err := store.GetWalletBalanceAt(wallet.ID, at)
+			assert.NoError(t, err)
+			return balance.String()
+		}
+
+		deposit(10, start)
+		deposit(20, start.Add(time.Minute))
+		assert.Equal(t, "0", balanceAt(start.Add(-time.Second)))
+		assert.Equal(t, "10", balanceAt(start))
+		assert.Equal(t, "30", balanceAt(start.Add(time.Minute)))
+
+		created, err := store.CreateBalanceSnapshots(start.Add(90 * time.Second))
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, created, int64(1))
+		created, err = store.CreateBalanceSnapshots(start.Add(90 * time.Second))
+		assert.NoError(t, err)
+		assert.Zero(t, created, "wallets without new postings must not be snapshotted again")
+
+		deposit(40, start.Add(2*time.Minute))
+		assert.Equal(t, "10", balanceAt(start))
+		assert.Equal(t, "30", balanceAt(start.Add(90*time.Second)))
+		assert.Equal(t, "70", balanceAt(start.Add(2*time.Minute)))
+
+		_, err = store.CreateBalanceSnapshots(start.Add(3 * time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, "70", balanceAt(start.Add(3*time.Minute)))
+		assert.Equal(t, "30", balanceAt(start.Add(time.Minute)))
+	})
+
+	t.Run("CreateJournalEntry method to reject an unbalanced entry without storing it", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("unbalanced"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+
+		err := store.CreateJournalEntry(&models.JournalEntry{
+			TransactionType: string(models.TransactionTypeAdd),
+			Postings: []*models.Posting{
+				models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, inr(5)),
+				models.NewWalletPosting(wallet, models.PostingDirectionCredit, inr(4)),
+			},
+		})
+		assert.ErrorIs(t, err, models.ErrUnbalancedJournalEntry)
+
+		postings, err := store.GetLastNPostings(userID, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, postings)
+	})
+
+	t.Run("ListReversingEntries method to return the entries linked to an entry with their postings", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("reversing"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+
+		original := &models.JournalEntry{
+			TransactionType: string(models.TransactionTypeAdd),
+			Postings: []*models.Posting{
+				models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, inr(5)),
+				models.NewWalletPosting(wallet, models.PostingDirectionCredit, inr(5)),
+			},
+		}
+		assert.NoError(t, store.CreateJournalEntry(original))
+
+		var refunds []*models.JournalEntry
+		for i := 0; i < 2; i++ {
+			refund := &models.JournalEntry{
+				TransactionType: string(models.TransactionTypeRefund),
+				ReversedEntryID: original.ID,
+				Postings: []*models.Posting{
+					models.NewWalletPosting(wallet, models.PostingDirectionDebit, inr(2)),
+					models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionCredit, inr(2)),
+				},
+			}
+			assert.NoError(t, store.CreateJournalEntry(refund))
+			refunds = append(refunds, refund)
+		}
+
+		entries, err := store.ListReversingEntries(original.ID)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		for i, entry := range entries {
+			assert.Equal(t, refunds[i].ID, entry.ID)
+			assert.Equal(t, original.ID, entry.ReversedEntryID)
+			assert.Len(t, entry.Postings, 2)
+			for _, posting := range entry.Postings {
+				assert.Equal(t, original.ID, posting.ReversedEntryID)
+			}
+		}
+
+		entries, err = store.ListReversingEntries(refunds[0].ID)
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("SumDebits method to total the debits of a type since a time by currency", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("debits"), Password: "secret"})
+		friendID, _ := store.CreateUser(&models.User{EmailID: email("debits_friend"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(0)})
+		pocket, _ := store.CreateWallet(&models.Wallet{UserID: userID, Name: "pocket", Money: inr(0)})
+		friendWallet, _ := store.CreateWallet(&models.Wallet{UserID: friendID, Money: inr(0)})
+
+		transfer := func(to *models.Wallet, amount float64, at time.Time) {
+			debit := models.NewWalletPosting(wallet, models.PostingDirectionDebit, inr(amount))
+			debit.CounterpartyUserID = to.UserID
+			err := store.CreateJournalEntry(&models.JournalEntry{
+				TransactionType: string(models.TransactionTypeTransfer),
+				Postings:        []*models.Posting{debit, models.NewWalletPosting(to, models.PostingDirectionCredit, inr(amount))},
+				CreatedAt:       at,
+			})
+			assert.NoError(t, err)
+		}
+		since := time.Now().Add(-time.Hour)
+		transfer(friendWallet, 7, since.Add(-time.Minute))
+		transfer(friendWallet, 10, since.Add(time.Minute))
+		transfer(friendWallet, 2.5, since.Add(2*time.Minute))
+		transfer(pocket, 50, since.Add(3*time.Minute))
+
+		totals, err := store.SumDebits(DebitQuery{UserID: userID, TransactionType: string(models.TransactionTypeTransfer), Since: since})
+		assert.NoError(t, err)
+		assert.Len(t, totals, 1)
+		assert.Equal(t, money.INR, totals[0].Currency)
+		assert.True(t, totals[0].Amount.Equal(decimal.NewFromFloat(12.5)), "got %s", totals[0].Amount)
+		assert.Equal(t, 2, totals[0].Count)
+
+		totals, err = store.SumDebits(DebitQuery{UserID: userID, TransactionType: string(models.TransactionTypeWithdraw), Since: since})
+		assert.NoError(t, err)
+		assert.Empty(t, totals)
+	})
+
+	t.Run("SaveUserLimit method to replace the override of a user for a transaction type", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("limits"), Password: "secret"})
+		maxPerHour := 3
+
+		first := &models.UserLimit{UserID: userID, TransactionType: "transfer", DailyAmount: decimal.NewNullDecimal(decimal.NewFromInt(500))}
+		assert.NoError(t, store.SaveUserLimit(first))
+		assert.NoError(t, store.SaveUserLimit(&models.UserLimit{UserID: userID, TransactionType: "withdraw", MaxPerHour: &maxPerHour}))
+		assert.NoError(t, store.SaveUserLimit(&models.UserLimit{UserID: userID, TransactionType: "transfer", MaxAmount: decimal.NewNullDecimal(decimal.NewFromInt(50))}))
+
+		limits, err := store.ListUserLimits(userID)
+		assert.NoError(t, err)
+		assert.Len(t, limits, 2)
+		assert.Equal(t, "transfer", limits[0].TransactionType)
+		assert.Equal(t, first.ID, limits[0].ID)
+		assert.False(t, limits[0].DailyAmount.Valid)
+		assert.True(t, limits[0].MaxAmount.Decimal.Equal(decimal.NewFromInt(50)))
+		assert.Equal(t, "withdraw", limits[1].TransactionType)
+		assert.Equal(t, 3, *limits[1].MaxPerHour)
+	})
+
+	t.Run("MarkKYCSubmissionReviewed method to decide on a pending submission only once", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("kyc"), Password: "secret"})
+		user, err := store.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelUnverified, user.KYCLevel)
+
+		submission := &models.KYCSubmission{
+			UserID:              userID,
+			Level:               models.KYCLevelMinimal,
+			DocumentType:        "passport",
+			DocumentNumberLast4: "6789",
+			IssuingCountry:      "IN",
+			Status:              models.KYCSubmissionPending,
+			CreatedAt:           time.Now(),
+		}
+		assert.NoError(t, store.CreateKYCSubmission(submission))
+		assert.NotZero(t, submission.ID)
+
+		pending, err := store.ListKYCSubmissions(KYCSubmissionQuery{Status: models.KYCSubmissionPending, AfterID: submission.ID - 1, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "6789", pending[0].DocumentNumberLast4)
+
+		second := *submission
+		second.ID = 0
+		assert.ErrorIs(t, store.CreateKYCSubmission(&second), ErrKYCSubmissionPending)
+
+		reviewedAt := time.Now()
+		submission.Status = models.KYCSubmissionApproved
+		submission.ReviewerID = userID + 1
+		submission.ReviewedAt = &reviewedAt
+		reviewed, err := store.MarkKYCSubmissionReviewed(submission)
+		assert.NoError(t, err)
+		assert.True(t, reviewed)
+		submission.Status = models.KYCSubmissionRejected
+		reviewed, err = store.MarkKYCSubmissionReviewed(submission)
+		assert.NoError(t, err)
+		assert.False(t, reviewed)
+
+		stored, err := store.GetKYCSubmissionByID(submission.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCSubmissionApproved, stored.Status)
+		assert.Equal(t, userID+1, stored.ReviewerID)
+
+		assert.NoError(t, store.UpdateUserKYCLevel(userID, models.KYCLevelMinimal))
+		user, err = store.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelMinimal, user.KYCLevel)
+
+		assert.NoError(t, store.CreateKYCEvent(&models.KYCEvent{
+			UserID:       userID,
+			SubmissionID: submission.ID,
+			ActorID:      userID + 1,
+			Action:       models.KYCActionApproved,
+			FromLevel:    models.KYCLevelUnverified,
+			ToLevel:      models.KYCLevelMinimal,
+			CreatedAt:    reviewedAt,
+		}))
+		events, err := store.ListKYCEvents(userID)
+		assert.NoError(t, err)
+		assert.Len(t, events, 1)
+		assert.Equal(t, models.KYCActionApproved, events[0].Action)
+
+		second = *submission
+		second.ID = 0
+		second.Status = models.KYCSubmissionPending
+		second.Level = models.KYCLevelFull
+		assert.NoError(t, store.CreateKYCSubmission(&second))
+	})
+
+	t.Run("Transaction method to commit every write when fn succeeds", func(t *testing.T) {
+		var userID int
+		err := store.Transaction(func(tx Store) error {
+			var err error
+			userID, err = tx.CreateUser(&models.User{EmailID: email("commit"), Password: "secret"})
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateWallet(&models.Wallet{UserID: userID, Money: inr(10)})
+			return err
+		})
+		assert.NoError(t, err)
+
+		wallet, err := store.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, wallet.Money.Equals(*inr(10)))
+	})
+
+	t.Run("Transaction method to roll back every write when fn fails", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("rollback"), Password: "secret"})
+		wallet, _ := store.CreateWallet(&models.Wallet{UserID: userID, Money: inr(10)})
+
+		errBoom := errors.New("boom")
+		err := store.Transaction(func(tx Store) error {
+			locked, err := tx.LockWalletsForUpdate(wallet.ID)
+			if err != nil {
+				return err
+			}
+			locked[wallet.ID].Money = inr(0)
+			if _, err := tx.UpdateWallet(locked[wallet.ID]); err != nil {
+				return err
+			}
+			if err := tx.CreateJournalEntry(&models.JournalEntry{
+				TransactionType: string(models.TransactionTypeWithdraw),
+				Postings: []*models.Posting{
+					models.NewWalletPosting(locked[wallet.ID], models.PostingDirectionDebit, inr(10)),
+					models.NewSystemPosting(models.SystemAccountExternalPayout, models.PostingDirectionCredit, inr(10)),
+				},
+			}); err != nil {
+				return err
+			}
+			if _, err := tx.CreateUser(&models.User{EmailID: email("rolledback"), Password: "secret"}); err != nil {
+				return err
+			}
+			return errBoom
+		})
+		assert.ErrorIs(t, err, errBoom)
+
+		stored, err := store.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, stored.Money.Equals(*inr(10)))
+
+		postings, err := store.GetLastNPostings(userID, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, postings)
+
+		_, err = store.GetUserByEmail(email("rolledback"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Transaction method to roll back only the nested transaction when its fn fails", func(t *testing.T) {
+		errBoom := errors.New("boom")
+		err := store.Transaction(func(tx Store) error {
+			if _, err := tx.CreateUser(&models.User{EmailID: email("outer"), Password: "secret"}); err != nil {
+				return err
+			}
+			err := tx.Transaction(func(nested Store) error {
+				if _, err := nested.CreateUser(&models.User{EmailID: email("nested"), Password: "secret"}); err != nil {
+					return err
+				}
+				return errBoom
+			})
+			assert.ErrorIs(t, err, errBoom)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		_, err = store.GetUserByEmail(email("outer"))
+		assert.NoError(t, err)
+		_, err = store.GetUserByEmail(email("nested"))
+		assert.Error(t, err)
+	})
+
+	t.Run("CreateIdempotencyKey method to reject a duplicate key for the same user", func(t *testing.T) {
+		userID, _ := store.CreateUser(&models.User{EmailID: email("idempotency"), Password: "secret"})
+		now := time.Now()
+
+		err := store.CreateIdempotencyKey(&models.IdempotencyKey{UserID: userID, Key: "key", Fingerprint: "a", ExpiresAt: now.Add(-time.Second)})
+		assert.NoError(t, err)
+
+		err = store.CreateIdempotencyKey(&models.IdempotencyKey{UserID: userID, Key: "key", Fingerprint: "b", ExpiresAt: now.Add(time.Hour)})
+		assert.ErrorIs(t, err, ErrIdempotencyKeyExists)
+
+		err = store.CreateIdempotencyKey(&models.IdempotencyKey{UserID: userID, Key: "other", Fingerprint: "c", ExpiresAt: now.Add(time.Hour)})
+		assert.NoError(t, err)
+
+		stored, err := store.GetIdempotencyKey(userID, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, "a", stored.Fingerprint)
+
+		deleted, err := store.DeleteExpiredIdempotencyKeys(now)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		_, err = store.GetIdempotencyKey(userID, "key")
+		assert.Error(t, err)
+		other, err := store.GetIdempotencyKey(userID, "other")
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.DeleteIdempotencyKey(other.ID))
+		other.State = models.IdempotencyKeyStateCompleted
+		assert.ErrorIs(t, store.UpdateIdempotencyKey(other), gorm.ErrRecordNotFound)
+	})
+
+	t.Run("GetLatestExchangeRate method to return the newest rate already in effect", func(t *testing.T) {
+		now := time.Now()
+		rates := []*models.ExchangeRate{
+			{BaseCurrency: money.USD, QuoteCurrency: money.EUR, Rate: decimal.RequireFromString("0.91"), Source: "old", EffectiveAt: now.Add(-2 * time.Hour)},
+			{BaseCurrency: money.USD, QuoteCurrency: money.EUR, Rate: decimal.RequireFromString("0.92"), Source: "current", EffectiveAt: now.Add(-time.Hour)},
+			{BaseCurrency: money.USD, QuoteCurrency: money.EUR, Rate: decimal.RequireFromString("0.93"), Source: "future", EffectiveAt: now.Add(time.Hour)},
+		}
+		for _, rate := range rates {
+			assert.NoError(t, store.CreateExchangeRate(rate))
+		}
+
+		latest, err := store.GetLatestExchangeRate(money.USD, money.EUR, now)
+		assert.NoError(t, err)
+		assert.Equal(t, "current", latest.Source)
+		assert.True(t, latest.Rate.Equal(decimal.RequireFromString("0.92")))
+
+		_, err = store.GetLatestExchangeRate(money.EUR, money.USD, now)
+		assert.Error(t, err)
+	})
+
+	t.Run("MarkFXQuoteUsed method to claim an unexpired quote only once", func(t *testing.T) {
+		now := time.Now()
+		quote := &models.FXQuote{ID: fmt.Sprintf("quote-%d", now.UnixNano()), UserID: 1, FromCurrency: money.USD, ToCurrency: money.INR, Rate: decimal.RequireFromString("83.3"), ExpiresAt: now.Add(time.Minute)}
+		assert.NoError(t, store.CreateFXQuote(quote))
+
+		expired := &models.FXQuote{ID: quote.ID + "-expired", UserID: 1, FromCurrency: money.USD, ToCurrency: money.INR, Rate: decimal.RequireFromString("83.3"), ExpiresAt: now.Add(-time.Second)}
+		assert.NoError(t, store.CreateFXQuote(expired))
+
+		used, err := store.MarkFXQuoteUsed(quote.ID, now)
+		assert.NoError(t, err)
+		assert.True(t, used)
+
+		used, err = store.MarkFXQuoteUsed(quote.ID, now)
+		assert.NoError(t, err)
+		assert.False(t, used)
+
+		used, err = store.MarkFXQuoteUsed(expired.ID, now)
+		assert.NoError(t, err)
+		assert.False(t, used)
+
+		stored, err := store.GetFXQuote(quote.ID)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored.UsedAt)
+		assert.True(t, stored.Rate.Equal(quote.Rate))
+	})
+
+	t.Run("LockWalletsForUpdate method to return error for unknown wallet", func(t *testing.T) {
+		err := store.Transaction(func(tx Store) error {
+			_, err := tx.LockWalletsForUpdate(-1)
+			return err
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("LockUsersForUpdate method to lock every user once and return error for unknown user", func(t *testing.T) {
+		firstID, _ := store.CreateUser(&models.User{EmailID: email("lock_first"), Password: "secret"})
+		secondID, _ := store.CreateUser(&models.User{EmailID: email("lock_second"), Password: "secret"})
+
+		err := store.Transaction(func(tx Store) error {
+			return tx.LockUsersForUpdate(secondID, firstID, secondID)
+		})
+		assert.NoError(t, err)
+
+		err = store.Transaction(func(tx Store) error {
+			return tx.LockUsersForUpdate(firstID, -1)
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"fmt"
+	"os"
+
+	"nikwallet/config"
+)
+
+// OpenTestStore returns the Store that test suites run against. The in-memory
+// backend is used unless NIKWALLET_TEST_STORE=postgres is set, in which case
+// the configured Postgres database is migrated up and used. The returned
+// teardown function releases the backend; for Postgres it drops every table
+// the tests created.
+func OpenTestStore() (Store, func() error, error) {
+	if os.Getenv("NIKWALLET_TEST_STORE") != "postgres" {
+		return NewMemoryStore(), func() error { return nil }, nil
+	}
+
+	c, err := config.LoadConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed at config: %w", err)
+	}
+
+	db := &PostgreSQL{}
+	if err := db.Connect(&c); err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if _, err := db.MigrateUp(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return db, func() error { return dropTestTables(db) }, nil
+}
+
+// dropTestTables reverts every migration and drops the schema_migrations
+// table before closing the connection. It is the only place tables are
+// dropped outside of down migrations, and must never run against a database
+// that holds real data.
+func dropTestTables(db *PostgreSQL) error {
+	defer db.Close()
+
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if _, err := db.MigrateDown(len(migrations)); err != nil {
+		return err
+	}
+	if err := db.DB.Exec("DROP TABLE IF EXISTS schema_migrations").Error; err != nil {
+		return fmt.Errorf("failed to drop schema_migrations: %w", err)
+	}
+	return nil
+}
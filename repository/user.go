@@ -3,9 +3,21 @@ package repository
 import (
 	"fmt"
 	"nikwallet/repository/models"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func (db *PostgreSQL) CreateUser(newUser *models.User) (int, error) {
+	if newUser.KYCLevel == "" {
+		newUser.KYCLevel = models.KYCLevelUnverified
+	}
+	if newUser.Role == "" {
+		newUser.Role = models.RoleCustomer
+	}
 	err := db.DB.Create(newUser).Error
 	if err != nil {
 		return 0, fmt.Errorf("failed to create user: %w", err)
@@ -30,3 +42,108 @@ func (db *PostgreSQL) GetUserByEmail(email string) (*models.User, error) {
 	}
 	return user, nil
 }
+
+func (db *PostgreSQL) ListUsers(afterID int, limit int) ([]*models.User, error) {
+	users := []*models.User{}
+	err := db.DB.Where("id > ?", afterID).Order("id ASC").Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (db *PostgreSQL) UpdateUserPassword(userID int, password string) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Update("password", password)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user password: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update user password: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) SearchUsers(query UserQuery) ([]*models.User, error) {
+	users := []*models.User{}
+	tx := db.DB.Where("id > ?", query.AfterID)
+	if query.Email != "" {
+		tx = tx.Where("email_id ILIKE ?", "%"+escapeLike(query.Email)+"%")
+	}
+	if query.Role != "" {
+		tx = tx.Where("role = ?", query.Role)
+	}
+	err := tx.Order("id ASC").Limit(query.Limit).Find(&users).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+	return users, nil
+}
+
+func (db *PostgreSQL) UpdateUserRole(userID int, role models.Role) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Update("role", role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user role: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update user role: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (db *PostgreSQL) CreateRoleChange(newChange *models.RoleChange) error {
+	err := db.DB.Create(newChange).Error
+	if err != nil {
+		return fmt.Errorf("failed to create role change: %w", err)
+	}
+	return nil
+}
+
+// ListRoleChanges returns the role changes of the user, oldest first.
+func (db *PostgreSQL) ListRoleChanges(userID int) ([]*models.RoleChange, error) {
+	var changes []*models.RoleChange
+	err := db.DB.Where("user_id = ?", userID).Order("id ASC").Find(&changes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role changes: %w", err)
+	}
+	return changes, nil
+}
+
+// UpdateUserFrozen freezes the user when frozenAt is set and unfreezes them
+// otherwise.
+func (db *PostgreSQL) UpdateUserFrozen(userID int, frozenAt *time.Time, frozenBy int, reason string) error {
+	result := db.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"frozen_at":     frozenAt,
+		"frozen_by":     frozenBy,
+		"frozen_reason": reason,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user frozen state: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update user frozen state: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// LockUsersForUpdate locks the users in id order, so that two transactions
+// locking the same users cannot deadlock.
+func (db *PostgreSQL) LockUsersForUpdate(userIDs ...int) error {
+	ids := append([]int(nil), userIDs...)
+	sort.Ints(ids)
+
+	for i, id := range ids {
+		if i > 0 && ids[i-1] == id {
+			continue
+		}
+		err := db.DB.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, id).Error
+		if err != nil {
+			return fmt.Errorf("failed to lock user %d: %w", id, err)
+		}
+	}
+	return nil
+}
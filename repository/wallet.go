@@ -1,22 +1,43 @@
 package repository
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 
 	"nikwallet/repository/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrWalletExists = errors.New("wallet already exists")
+
 func (db *PostgreSQL) CreateWallet(newWallet *models.Wallet) (*models.Wallet, error) {
-	err := db.DB.Create(newWallet).Error
-	if err != nil {
-		return nil, fmt.Errorf("failed to create wallet: %w", err)
+	result := db.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(newWallet)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create wallet: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWalletExists
 	}
 	return newWallet, nil
 }
 
+func (db *PostgreSQL) GetWalletByID(id int) (*models.Wallet, error) {
+	wallet := &models.Wallet{}
+	err := db.DB.First(wallet, id).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet %d: %w", id, err)
+	}
+	return wallet, nil
+}
+
+// GetWalletByUserID returns the default wallet of the user, falling back to
+// the oldest wallet for users who have not picked a default.
 func (db *PostgreSQL) GetWalletByUserID(userID int) (*models.Wallet, error) {
 	wallet := &models.Wallet{}
-	err := db.DB.Where("user_id = ?", userID).First(wallet).Error
+	err := db.DB.Where("user_id = ?", userID).Order("is_default DESC, id ASC").First(wallet).Error
 	if err != nil {
 		return nil, fmt.Errorf("no wallets found for user with ID %d", userID)
 	}
@@ -24,6 +45,61 @@ func (db *PostgreSQL) GetWalletByUserID(userID int) (*models.Wallet, error) {
 	return wallet, nil
 }
 
+func (db *PostgreSQL) ListWalletsByUserID(userID int) ([]*models.Wallet, error) {
+	wallets := []*models.Wallet{}
+	err := db.DB.Where("user_id = ?", userID).Order("id ASC").Find(&wallets).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return wallets, nil
+}
+
+// SetDefaultWallet makes walletID the only default wallet of its user.
+func (db *PostgreSQL) SetDefaultWallet(userID int, walletID int) error {
+	return db.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Wallet{}).
+			Where("user_id = ? AND is_default", userID).
+			Update("is_default", false).Error
+		if err != nil {
+			return fmt.Errorf("failed to clear default wallet: %w", err)
+		}
+
+		result := tx.Model(&models.Wallet{}).
+			Where("id = ? AND user_id = ?", walletID, userID).
+			Update("is_default", true)
+		if result.Error != nil {
+			return fmt.Errorf("failed to set default wallet: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("failed to set default wallet: %w", gorm.ErrRecordNotFound)
+		}
+		return nil
+	})
+}
+
+// LockWalletsForUpdate row-locks the given wallets in ascending ID order so
+// that concurrent transactions touching the same wallets cannot deadlock.
+// It must be called inside a transaction.
+func (db *PostgreSQL) LockWalletsForUpdate(walletIDs ...int) (map[int]*models.Wallet, error) {
+	ids := append([]int(nil), walletIDs...)
+	sort.Ints(ids)
+
+	wallets := make(map[int]*models.Wallet, len(ids))
+	for _, id := range ids {
+		if _, ok := wallets[id]; ok {
+			continue
+		}
+		wallet := &models.Wallet{}
+		err := db.DB.Clauses(clause.Locking{Strength: "UPDATE"}).First(wallet, id).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock wallet %d: %w", id, err)
+		}
+		wallets[id] = wallet
+	}
+
+	return wallets, nil
+}
+
 func (db *PostgreSQL) UpdateWallet(changedWallet *models.Wallet) (*models.Wallet, error) {
 	err := db.DB.Save(changedWallet).Error
 	if err != nil {
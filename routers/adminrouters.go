@@ -0,0 +1,34 @@
+package routers
+
+import (
+	"net/http"
+
+	"nikwallet/handlers"
+	"nikwallet/services"
+
+	"github.com/gorilla/mux"
+)
+
+// NewAdminRouter serves the staff endpoints. Each route requires the scope
+// that services.RoleScopes grants to the roles allowed to use it, checked
+// against the current role of the user.
+func NewAdminRouter(auth *Authenticator, handlers *handlers.AdminHandlers, walletHandlers *handlers.WalletHandlers, kycHandlers *handlers.KYCHandlers) *mux.Router {
+	router := mux.NewRouter()
+
+	router.Handle("/users", auth.RequireStaff(handlers.SearchUsersHandler, services.ScopeUsersRead)).Methods(http.MethodGet)
+	router.Handle("/users/{id:[0-9]+}", auth.RequireStaff(handlers.GetUserHandler, services.ScopeUsersRead)).Methods(http.MethodGet)
+	router.Handle("/users/{id:[0-9]+}/role", auth.RequireStaff(handlers.SetUserRoleHandler, services.ScopeRolesWrite)).Methods(http.MethodPut)
+	router.Handle("/users/{id:[0-9]+}/freeze", auth.RequireStaff(handlers.FreezeUserHandler, services.ScopeAccountsFreeze)).Methods(http.MethodPost)
+	router.Handle("/users/{id:[0-9]+}/unfreeze", auth.RequireStaff(handlers.UnfreezeUserHandler, services.ScopeAccountsFreeze)).Methods(http.MethodPost)
+	router.Handle("/users/{id:[0-9]+}/wallets", auth.RequireStaff(handlers.ListUserWalletsHandler, services.ScopeLedgerRead)).Methods(http.MethodGet)
+	router.Handle("/users/{id:[0-9]+}/history", auth.RequireStaff(handlers.GetUserHistoryHandler, services.ScopeLedgerRead)).Methods(http.MethodGet)
+	router.Handle("/entries/{id:[0-9]+}", auth.RequireStaff(handlers.GetEntryHandler, services.ScopeLedgerRead)).Methods(http.MethodGet)
+	router.Handle("/wallets/{id:[0-9]+}/adjustments", auth.RequireStaff(walletHandlers.Idempotent(handlers.AdjustWalletHandler), services.ScopeAdjustmentsWrite)).Methods(http.MethodPost)
+
+	router.Handle("/kyc/submissions", auth.RequireStaff(kycHandlers.ListKYCSubmissionsHandler, services.ScopeKYCReview)).Methods(http.MethodGet)
+	router.Handle("/kyc/submissions/{id:[0-9]+}/approve", auth.RequireStaff(kycHandlers.ApproveKYCSubmissionHandler, services.ScopeKYCReview)).Methods(http.MethodPost)
+	router.Handle("/kyc/submissions/{id:[0-9]+}/reject", auth.RequireStaff(kycHandlers.RejectKYCSubmissionHandler, services.ScopeKYCReview)).Methods(http.MethodPost)
+	router.Handle("/kyc/users/{id:[0-9]+}/events", auth.RequireStaff(kycHandlers.ListKYCEventsHandler, services.ScopeUsersRead)).Methods(http.MethodGet)
+
+	return router
+}
@@ -0,0 +1,114 @@
+package routers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nikwallet/handlers"
+	"nikwallet/services"
+)
+
+const (
+	authRealm = "nikwallet"
+
+	// legacyTokenHeader is still accepted so existing clients keep working
+	// until they move to the Authorization header.
+	legacyTokenHeader = "id_token"
+)
+
+type Authenticator struct {
+	authService *services.AuthService
+}
+
+func NewAuthenticator(authService *services.AuthService) *Authenticator {
+	return &Authenticator{authService: authService}
+}
+
+// Require authenticates the request, rejects it unless the token grants all
+// of scopes, and passes the principal on to next in the request context.
+func (a *Authenticator) Require(next http.HandlerFunc, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(respWriter http.ResponseWriter, req *http.Request) {
+		tokenString, deprecated, err := bearerToken(req)
+		if err != nil {
+			unauthorized(respWriter, "invalid_request", err.Error())
+			return
+		}
+		if tokenString == "" {
+			unauthorized(respWriter, "", "missing access token")
+			return
+		}
+		if deprecated {
+			respWriter.Header().Set("Deprecation", "true")
+		}
+
+		principal, err := a.authService.Authenticate(tokenString)
+		if err != nil {
+			unauthorized(respWriter, "invalid_token", err.Error())
+			return
+		}
+
+		if missing := principal.MissingScopes(scopes...); len(missing) > 0 {
+			insufficientScope(respWriter, scopes, missing)
+			return
+		}
+
+		next(respWriter, req.WithContext(services.ContextWithPrincipal(req.Context(), principal)))
+	})
+}
+
+// RequireStaff works like Require, but also re-loads the user behind the
+// token. It rejects frozen users and checks scopes against the role the user
+// holds now, not the one the token was issued for.
+func (a *Authenticator) RequireStaff(next http.HandlerFunc, scopes ...string) http.Handler {
+	return a.Require(func(respWriter http.ResponseWriter, req *http.Request) {
+		principal, _ := services.PrincipalFromContext(req.Context())
+		current, err := a.authService.CurrentPrincipal(principal)
+		if err != nil {
+			handlers.WriteError(respWriter, err)
+			return
+		}
+		if missing := current.MissingScopes(scopes...); len(missing) > 0 {
+			insufficientScope(respWriter, scopes, missing)
+			return
+		}
+
+		next(respWriter, req.WithContext(services.ContextWithPrincipal(req.Context(), current)))
+	}, scopes...)
+}
+
+func insufficientScope(respWriter http.ResponseWriter, scopes []string, missing []string) {
+	respWriter.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope", scope=%q`, authRealm, strings.Join(scopes, " ")))
+	handlers.WriteProblem(respWriter, http.StatusForbidden, handlers.CodeInsufficientScope, "token is missing scope: "+strings.Join(missing, " "))
+}
+
+func bearerToken(req *http.Request) (tokenString string, deprecated bool, err error) {
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		scheme, token, ok := strings.Cut(authorization, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+			return "", false, fmt.Errorf("authorization header must use the Bearer scheme")
+		}
+		return strings.TrimSpace(token), false, nil
+	}
+
+	if tokenString := req.Header.Get(legacyTokenHeader); tokenString != "" {
+		return tokenString, true, nil
+	}
+
+	return "", false, nil
+}
+
+// unauthorized follows RFC 6750: a request without credentials gets a bare
+// challenge, one with bad credentials also gets the error code, which doubles
+// as the problem code.
+func unauthorized(respWriter http.ResponseWriter, errorCode string, description string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	problemCode := handlers.CodeUnauthorized
+	if errorCode != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", errorCode, description)
+		problemCode = errorCode
+	}
+
+	respWriter.Header().Set("WWW-Authenticate", challenge)
+	handlers.WriteProblem(respWriter, http.StatusUnauthorized, problemCode, description)
+}
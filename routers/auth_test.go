@@ -0,0 +1,180 @@
+package routers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+
+	"nikwallet/handlers"
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/services"
+	"nikwallet/services/password"
+)
+
+func TestAuthenticator(t *testing.T) {
+	store := repository.NewMemoryStore()
+	passwords := password.NewManager(password.NewBcryptHasher(bcrypt.MinCost))
+	keys, err := services.NewEphemeralKeySet()
+	assert.NoError(t, err)
+	authService := services.NewAuthService(store, passwords, services.TokenOptions{Keys: keys})
+	auth := NewAuthenticator(authService)
+
+	userID, err := store.CreateUser(&models.User{EmailID: "router_auth@example.com", Password: "password"})
+	assert.NoError(t, err)
+	IDToken, err := authService.AuthenticateUser("router_auth@example.com", "password")
+	assert.NoError(t, err)
+
+	whoami := func(respWriter http.ResponseWriter, req *http.Request) {
+		principal, ok := services.PrincipalFromContext(req.Context())
+		if !ok {
+			respWriter.WriteHeader(http.StatusTeapot)
+			return
+		}
+		respWriter.Write([]byte(strconv.Itoa(principal.UserID)))
+	}
+
+	serve := func(handler http.Handler, header string, value string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	t.Run("Require method to put the principal of a Bearer token into the context", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami, services.ScopeWalletRead), "Authorization", "Bearer "+IDToken)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, strconv.Itoa(userID), recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get("Deprecation"))
+	})
+
+	t.Run("Require method to accept the deprecated id_token header", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami), "id_token", IDToken)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, strconv.Itoa(userID), recorder.Body.String())
+		assert.Equal(t, "true", recorder.Header().Get("Deprecation"))
+	})
+
+	t.Run("Require method to return 401 with a bare challenge when no token is sent", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami), "", "")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Equal(t, `Bearer realm="nikwallet"`, recorder.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Require method to return 401 with invalid_token for a bad token", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami), "Authorization", "Bearer not-a-jwt")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+
+	t.Run("Require method to return 401 with invalid_request for another scheme", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami), "Authorization", "Basic dXNlcjpwYXNz")
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="invalid_request"`)
+	})
+
+	t.Run("Require method to return 403 with insufficient_scope for a missing scope", func(t *testing.T) {
+		recorder := serve(auth.Require(whoami, "admin"), "Authorization", "Bearer "+IDToken)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="insufficient_scope", scope="admin"`)
+		assert.Equal(t, handlers.ProblemContentType, recorder.Header().Get("Content-Type"))
+	})
+
+	t.Run("RequireStaff method to check the current role and frozen state of the user", func(t *testing.T) {
+		staffID, err := store.CreateUser(&models.User{EmailID: "router_staff@example.com", Password: "password", Role: models.RoleAdmin})
+		assert.NoError(t, err)
+		staffToken, err := authService.AuthenticateUser("router_staff@example.com", "password")
+		assert.NoError(t, err)
+
+		recorder := serve(auth.RequireStaff(whoami, services.ScopeRolesWrite), "Authorization", "Bearer "+staffToken)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		assert.NoError(t, store.UpdateUserRole(staffID, models.RoleAuditor))
+		recorder = serve(auth.Require(whoami, services.ScopeRolesWrite), "Authorization", "Bearer "+staffToken)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		recorder = serve(auth.RequireStaff(whoami, services.ScopeRolesWrite), "Authorization", "Bearer "+staffToken)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="insufficient_scope"`)
+		recorder = serve(auth.RequireStaff(whoami, services.ScopeUsersRead), "Authorization", "Bearer "+staffToken)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		frozenAt := time.Now()
+		assert.NoError(t, store.UpdateUserFrozen(staffID, &frozenAt, userID, "testing"))
+		recorder = serve(auth.RequireStaff(whoami, services.ScopeUsersRead), "Authorization", "Bearer "+staffToken)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "account_frozen")
+	})
+
+	t.Run("NewRouter to protect wallet routes and leave sign-in public", func(t *testing.T) {
+		walletService := services.NewWalletService(store, services.DefaultStaticRateProvider(), 0, services.LimitPolicy{})
+		userService := services.NewUserService(store, passwords)
+		router := NewRouter(
+			authService,
+			handlers.NewHealthHandlers(services.NewHealthService()),
+			handlers.NewUserHandlers(userService, authService),
+			handlers.NewWalletHandlers(walletService, userService, services.NewIdempotencyService(store, 0)),
+			handlers.NewFXHandlers(services.NewFXService(store, services.DefaultStaticRateProvider(), 0)),
+			handlers.NewKYCHandlers(services.NewKYCService(store)),
+			handlers.NewAdminHandlers(userService, walletService),
+		)
+
+		req, _ := http.NewRequest("GET", "/wallet/history?limit=5", nil)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+
+		req, _ = http.NewRequest("GET", "/user/sessions", nil)
+		req.Header.Set("Authorization", "Bearer "+IDToken)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		req, _ = http.NewRequest("GET", "/admin/kyc/submissions", nil)
+		req.Header.Set("Authorization", "Bearer "+IDToken)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		_, err = store.CreateUser(&models.User{EmailID: "router_auditor@example.com", Password: "password", Role: models.RoleAuditor})
+		assert.NoError(t, err)
+		auditorToken, err := authService.AuthenticateUser("router_auditor@example.com", "password")
+		assert.NoError(t, err)
+
+		req, _ = http.NewRequest("GET", "/admin/users?email=router_", nil)
+		req.Header.Set("Authorization", "Bearer "+auditorToken)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		req, _ = http.NewRequest("POST", "/admin/users/"+strconv.Itoa(userID)+"/freeze", nil)
+		req.Header.Set("Authorization", "Bearer "+auditorToken)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		req, _ = http.NewRequest("GET", "/.well-known/jwks.json", nil)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		req, _ = http.NewRequest("GET", "/readyz", nil)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
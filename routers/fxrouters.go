@@ -0,0 +1,18 @@
+package routers
+
+import (
+	"net/http"
+
+	"nikwallet/handlers"
+	"nikwallet/services"
+
+	"github.com/gorilla/mux"
+)
+
+func NewFXRouter(auth *Authenticator, handlers *handlers.FXHandlers) *mux.Router {
+	router := mux.NewRouter()
+
+	router.Handle("/quote", auth.Require(handlers.QuoteHandler, services.ScopeWalletWrite)).Methods(http.MethodPost)
+
+	return router
+}
@@ -4,18 +4,42 @@ import (
 	"net/http"
 
 	"nikwallet/handlers"
+	"nikwallet/services"
 
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(userHandlers *handlers.UserHandlers, walletHandlers *handlers.WalletHandlers) *mux.Router {
+func NewRouter(authService *services.AuthService, healthHandlers *handlers.HealthHandlers, userHandlers *handlers.UserHandlers, walletHandlers *handlers.WalletHandlers, fxHandlers *handlers.FXHandlers, kycHandlers *handlers.KYCHandlers, adminHandlers *handlers.AdminHandlers) *mux.Router {
 	router := mux.NewRouter()
+	auth := NewAuthenticator(authService)
 
-	userRouter := NewUserRouter(userHandlers)
-	router.PathPrefix("/user").Handler(http.StripPrefix("/user", userRouter))
+	router.HandleFunc("/healthz", healthHandlers.LivenessHandler).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", healthHandlers.ReadinessHandler).Methods(http.MethodGet)
 
-	walletRouter := NewWalletRouter(walletHandlers)
-	router.PathPrefix("/wallet").Handler(http.StripPrefix("/wallet", walletRouter))
+	router.HandleFunc("/.well-known/jwks.json", userHandlers.JWKSHandler).Methods(http.MethodGet)
+
+	userRouter := NewUserRouter(auth, userHandlers, kycHandlers)
+	router.PathPrefix("/user").Handler(stripPrefix("/user", userRouter))
+
+	walletRouter := NewWalletRouter(auth, walletHandlers)
+	router.PathPrefix("/wallet").Handler(stripPrefix("/wallet", walletRouter))
+
+	fxRouter := NewFXRouter(auth, fxHandlers)
+	router.PathPrefix("/fx").Handler(stripPrefix("/fx", fxRouter))
+
+	adminRouter := NewAdminRouter(auth, adminHandlers, walletHandlers, kycHandlers)
+	router.PathPrefix("/admin").Handler(stripPrefix("/admin", adminRouter))
 
 	return router
 }
+
+// stripPrefix works like http.StripPrefix but maps the bare prefix to "/", so
+// that both /wallet and /wallet/ reach the "/" routes of the sub-router.
+func stripPrefix(prefix string, handler http.Handler) http.Handler {
+	return http.StripPrefix(prefix, http.HandlerFunc(func(respWriter http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "" {
+			req.URL.Path = "/"
+		}
+		handler.ServeHTTP(respWriter, req)
+	}))
+}
@@ -4,15 +4,22 @@ import (
 	"net/http"
 
 	"nikwallet/handlers"
+	"nikwallet/services"
 
 	"github.com/gorilla/mux"
 )
 
-func NewUserRouter(handlers *handlers.UserHandlers) *mux.Router {
+func NewUserRouter(auth *Authenticator, handlers *handlers.UserHandlers, kycHandlers *handlers.KYCHandlers) *mux.Router {
 	router := mux.NewRouter()
 
 	router.HandleFunc("/signup", handlers.SignupHandler).Methods(http.MethodPost)
 	router.HandleFunc("/signin", handlers.SigninHandler).Methods(http.MethodPost)
+	router.HandleFunc("/token/refresh", handlers.RefreshTokenHandler).Methods(http.MethodPost)
+	router.Handle("/logout", auth.Require(handlers.LogoutHandler)).Methods(http.MethodPost)
+	router.Handle("/logout/all", auth.Require(handlers.LogoutAllHandler, services.ScopeSessions)).Methods(http.MethodPost)
+	router.Handle("/sessions", auth.Require(handlers.ListSessionsHandler, services.ScopeSessions)).Methods(http.MethodGet)
+	router.Handle("/kyc", auth.Require(kycHandlers.SubmitKYCHandler)).Methods(http.MethodPost)
+	router.Handle("/kyc", auth.Require(kycHandlers.GetKYCStatusHandler)).Methods(http.MethodGet)
 
 	return router
 }
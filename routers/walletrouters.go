@@ -4,18 +4,30 @@ import (
 	"net/http"
 
 	"nikwallet/handlers"
+	"nikwallet/services"
 
 	"github.com/gorilla/mux"
 )
 
-func NewWalletRouter(handlers *handlers.WalletHandlers) *mux.Router {
+func NewWalletRouter(auth *Authenticator, handlers *handlers.WalletHandlers) *mux.Router {
 	router := mux.NewRouter()
 
-	router.HandleFunc("/", handlers.CreateWalletHandler).Methods(http.MethodPost)
-	router.HandleFunc("/", handlers.AddMoneyToWalletHandler).Methods(http.MethodPut)
-	router.HandleFunc("/withdraw", handlers.WithdrawMoneyFromWalletHandler).Methods(http.MethodPut)
-	router.HandleFunc("/transfer", handlers.WithdrawMoneyFromWalletHandler).Methods(http.MethodPut)
-	router.HandleFunc("/history", handlers.GetWalletHistoryHandler).Methods(http.MethodGet)
+	router.Handle("/", auth.Require(handlers.ListWalletsHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
+	router.Handle("/", auth.Require(handlers.CreateWalletHandler, services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/", auth.Require(handlers.Idempotent(handlers.AddMoneyToWalletHandler), services.ScopeWalletWrite)).Methods(http.MethodPut)
+	router.Handle("/default", auth.Require(handlers.SetDefaultWalletHandler, services.ScopeWalletWrite)).Methods(http.MethodPut)
+	router.Handle("/withdraw", auth.Require(handlers.Idempotent(handlers.WithdrawMoneyFromWalletHandler), services.ScopeWalletWrite)).Methods(http.MethodPut)
+	router.Handle("/transfer", auth.Require(handlers.Idempotent(handlers.TransferMoneyHandler), services.ScopeWalletWrite)).Methods(http.MethodPut)
+	router.Handle("/holds", auth.Require(handlers.Idempotent(handlers.AuthorizeHoldHandler), services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/holds/{id:[0-9]+}", auth.Require(handlers.GetHoldHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
+	router.Handle("/holds/{id:[0-9]+}/capture", auth.Require(handlers.Idempotent(handlers.CaptureHoldHandler), services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/holds/{id:[0-9]+}/void", auth.Require(handlers.Idempotent(handlers.VoidHoldHandler), services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/entries/{id:[0-9]+}/refund", auth.Require(handlers.Idempotent(handlers.RefundTransferHandler), services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/entries/{id:[0-9]+}/reverse", auth.Require(handlers.Idempotent(handlers.ReverseTransferHandler), services.ScopeWalletWrite)).Methods(http.MethodPost)
+	router.Handle("/limits", auth.Require(handlers.GetLimitsHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
+	router.Handle("/balance", auth.Require(handlers.GetWalletBalanceHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
+	router.Handle("/statement", auth.Require(handlers.GetWalletStatementHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
+	router.Handle("/history", auth.Require(handlers.GetWalletHistoryHandler, services.ScopeWalletRead)).Methods(http.MethodGet)
 
 	return router
 }
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"nikwallet/logger"
+	"nikwallet/services"
+)
+
+// workerGroup runs the background jobs of the server until it shuts down.
+type workerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkerGroup() *workerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workerGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go runs job until Stop is called; job must return once ctx is done.
+func (w *workerGroup) Go(job func(ctx context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		job(w.ctx)
+	}()
+}
+
+func (w *workerGroup) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// serve answers requests on listener until ctx is done. It then reports not
+// ready, stops accepting connections, waits up to shutdownTimeout for
+// in-flight requests and stops the workers.
+func serve(ctx context.Context, server *http.Server, listener net.Listener, health *services.HealthService, workers *workerGroup, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		err = fmt.Errorf("server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+		logger.Infof("shutting down, draining in-flight requests for up to %s", shutdownTimeout)
+		health.SetShuttingDown()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if shutdownErr := server.Shutdown(shutdownCtx); shutdownErr != nil {
+			server.Close()
+			err = fmt.Errorf("failed to drain in-flight requests: %w", shutdownErr)
+		}
+		if serveErr := <-serveErr; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+			err = serveErr
+		}
+	}
+
+	workers.Stop()
+	return err
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"nikwallet/services"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServe(t *testing.T) {
+	t.Run("serve to drain in-flight requests and stop the workers on shutdown", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+
+		started := make(chan struct{})
+		release := make(chan struct{})
+		server := &http.Server{Handler: http.HandlerFunc(func(respWriter http.ResponseWriter, req *http.Request) {
+			close(started)
+			<-release
+			io.WriteString(respWriter, "done")
+		})}
+
+		workerStopped := make(chan struct{})
+		workers := newWorkerGroup()
+		workers.Go(func(ctx context.Context) {
+			<-ctx.Done()
+			close(workerStopped)
+		})
+
+		health := services.NewHealthService()
+		ctx, cancel := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() {
+			served <- serve(ctx, server, listener, health, workers, 5*time.Second)
+		}()
+
+		responses := make(chan *http.Response, 1)
+		go func() {
+			resp, err := http.Get("http://" + listener.Addr().String())
+			assert.NoError(t, err)
+			responses <- resp
+		}()
+
+		<-started
+		cancel()
+		assert.Eventually(t, func() bool {
+			return len(health.Ready(context.Background())) > 0
+		}, time.Second, 10*time.Millisecond)
+
+		close(release)
+		resp := <-responses
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, "done", string(body))
+
+		assert.NoError(t, <-served)
+		<-workerStopped
+
+		_, err = http.Get("http://" + listener.Addr().String())
+		assert.Error(t, err)
+	})
+}
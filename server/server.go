@@ -1,20 +1,221 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"nikwallet/config"
 	"nikwallet/handlers"
+	"nikwallet/logger"
 	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
 	"nikwallet/routers"
 	"nikwallet/services"
+	"nikwallet/services/password"
+
+	"github.com/shopspring/decimal"
 )
 
-func StartServer() {
-	c, err := config.LoadConfig()
+var ErrMigrateUsage = errors.New("usage: nikwallet migrate up|down [steps]|status")
+
+func StartServer(c config.Config) {
+	db := &repository.PostgreSQL{}
+	err := db.Connect(&c)
+	if err != nil {
+		log.Fatalln("failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	if c.DbSkipMigrations {
+		logger.Warnf("DB_SKIP_MIGRATIONS is set, not applying pending migrations")
+	} else if err := migrateUp(db); err != nil {
+		log.Fatalln("Failed to migrate database", err)
+	}
+
+	passwords, err := password.NewManagerFor(c.PasswordHashAlgorithm)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
+
+	tokenOptions, err := loadTokenOptions(&c)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
+
+	roundingMode, err := money.ParseRoundingMode(c.MoneyRoundingMode)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
+	money.SetRoundingMode(roundingMode)
+
+	rates, err := loadRateProvider(&c, db)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
+
+	limits, err := loadLimitPolicy(&c)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
+
+	userService := services.NewUserService(db, passwords)
+	authService := services.NewAuthService(db, passwords, tokenOptions)
+	walletService := services.NewWalletService(db, rates, c.HistoryMaxLimit, limits)
+	fxService := services.NewFXService(db, rates, c.FXQuoteTTL)
+	idempotencyService := services.NewIdempotencyService(db, c.IdempotencyKeyRetention)
+	kycService := services.NewKYCService(db)
+
+	healthService := services.NewHealthService()
+	healthService.AddCheck("database", db.Ping)
+	healthService.AddCheck("migrations", db.CheckMigrations)
+
+	workers := newWorkerGroup()
+	workers.Go(func(ctx context.Context) { idempotencyService.RunCleanup(ctx, time.Hour) })
+	workers.Go(func(ctx context.Context) { authService.RunCleanup(ctx, time.Hour) })
+	workers.Go(func(ctx context.Context) { walletService.RunBalanceSnapshots(ctx, time.Hour) })
+	workers.Go(func(ctx context.Context) { walletService.RunHoldExpiry(ctx, time.Minute) })
+
+	healthHandlers := handlers.NewHealthHandlers(healthService)
+	userHandlers := handlers.NewUserHandlers(userService, authService)
+	walletHandlers := handlers.NewWalletHandlers(walletService, userService, idempotencyService)
+	fxHandlers := handlers.NewFXHandlers(fxService)
+	kycHandlers := handlers.NewKYCHandlers(kycService)
+	adminHandlers := handlers.NewAdminHandlers(userService, walletService)
+
+	router := routers.NewRouter(authService, healthHandlers, userHandlers, walletHandlers, fxHandlers, kycHandlers, adminHandlers)
+
+	server := &http.Server{
+		Addr:         c.HTTPAddr,
+		Handler:      http.MaxBytesHandler(router, c.MaxRequestBodyBytes),
+		ReadTimeout:  c.HTTPReadTimeout,
+		WriteTimeout: c.HTTPWriteTimeout,
+		IdleTimeout:  c.HTTPIdleTimeout,
+	}
+
+	listener, err := net.Listen("tcp", c.HTTPAddr)
+	if err != nil {
+		workers.Stop()
+		log.Fatalln("failed to listen:", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Infof("server listening on %s", listener.Addr())
+	if err := serve(ctx, server, listener, healthService, workers, c.HTTPShutdownTimeout); err != nil {
+		db.Close()
+		log.Fatalln(err)
+	}
+	logger.Infof("server stopped")
+}
+
+func loadTokenOptions(c *config.Config) (services.TokenOptions, error) {
+	options := services.TokenOptions{
+		Issuer:     c.JWTIssuer,
+		Audience:   c.JWTAudience,
+		TTL:        c.JWTTTL,
+		RefreshTTL: c.JWTRefreshTTL,
+	}
+
+	var err error
+	if c.JWTSigningKeys == "" {
+		logger.Warnf("JWT_SIGNING_KEYS is not set, signing tokens with an ephemeral key")
+		options.Keys, err = services.NewEphemeralKeySet()
+	} else {
+		options.Keys, err = services.LoadKeySet(c.JWTSigningKeyID, c.JWTSigningKeys)
+	}
+
+	return options, err
+}
+
+// loadRateProvider serves rates from FX_RATES_FILE, or from the built-in
+// table when it is unset, unless FX_RATES_SOURCE is "database".
+func loadRateProvider(c *config.Config, store repository.Store) (services.ExchangeRateProvider, error) {
+	switch c.FXRatesSource {
+	case "", "static":
+		if c.FXRatesFile == "" {
+			logger.Warnf("FX_RATES_FILE is not set, converting at the built-in static rates")
+			return services.DefaultStaticRateProvider(), nil
+		}
+		return services.LoadStaticRateProvider(c.FXRatesFile)
+	case "database":
+		return services.NewStoreRateProvider(store, c.FXRateMaxAge), nil
+	default:
+		return nil, fmt.Errorf("unknown FX_RATES_SOURCE %q", c.FXRatesSource)
+	}
+}
+
+// loadLimitPolicy builds the global transaction limits and the KYC tiers from
+// the config.
+func loadLimitPolicy(c *config.Config) (services.LimitPolicy, error) {
+	var err error
+	amount := func(key, value string) decimal.Decimal {
+		parsed, parseErr := decimal.NewFromString(value)
+		if parseErr != nil && err == nil {
+			err = fmt.Errorf("invalid %s %q: %w", key, value, parseErr)
+		}
+		return parsed
+	}
 
+	policy := services.LimitPolicy{
+		Currency: money.Currency(c.LimitCurrency),
+		Limits: map[models.TransactionType]services.TransactionLimits{
+			models.TransactionTypeWithdraw: {
+				MinAmount:     amount("WITHDRAW_MIN_AMOUNT", c.WithdrawMinAmount),
+				MaxAmount:     amount("WITHDRAW_MAX_AMOUNT", c.WithdrawMaxAmount),
+				DailyAmount:   amount("WITHDRAW_DAILY_LIMIT", c.WithdrawDailyLimit),
+				MonthlyAmount: amount("WITHDRAW_MONTHLY_LIMIT", c.WithdrawMonthlyLimit),
+				MaxPerHour:    c.WithdrawMaxPerHour,
+			},
+			models.TransactionTypeTransfer: {
+				MinAmount:     amount("TRANSFER_MIN_AMOUNT", c.TransferMinAmount),
+				MaxAmount:     amount("TRANSFER_MAX_AMOUNT", c.TransferMaxAmount),
+				DailyAmount:   amount("TRANSFER_DAILY_LIMIT", c.TransferDailyLimit),
+				MonthlyAmount: amount("TRANSFER_MONTHLY_LIMIT", c.TransferMonthlyLimit),
+				MaxPerHour:    c.TransferMaxPerHour,
+			},
+		},
+		Tiers: map[models.KYCLevel]services.KYCTier{
+			models.KYCLevelUnverified: {
+				MaxBalance: amount("KYC_UNVERIFIED_MAX_BALANCE", c.KYCUnverifiedMaxBalance),
+				Limits: services.TransactionLimits{
+					MaxAmount:     amount("KYC_UNVERIFIED_MAX_AMOUNT", c.KYCUnverifiedMaxAmount),
+					MonthlyAmount: amount("KYC_UNVERIFIED_MONTHLY_LIMIT", c.KYCUnverifiedMonthlyLimit),
+				},
+			},
+			models.KYCLevelMinimal: {
+				MaxBalance: amount("KYC_MINIMAL_MAX_BALANCE", c.KYCMinimalMaxBalance),
+				Limits: services.TransactionLimits{
+					MaxAmount:     amount("KYC_MINIMAL_MAX_AMOUNT", c.KYCMinimalMaxAmount),
+					MonthlyAmount: amount("KYC_MINIMAL_MONTHLY_LIMIT", c.KYCMinimalMonthlyLimit),
+				},
+			},
+			models.KYCLevelFull: {
+				MaxBalance: amount("KYC_FULL_MAX_BALANCE", c.KYCFullMaxBalance),
+				Limits: services.TransactionLimits{
+					MaxAmount:     amount("KYC_FULL_MAX_AMOUNT", c.KYCFullMaxAmount),
+					MonthlyAmount: amount("KYC_FULL_MONTHLY_LIMIT", c.KYCFullMonthlyLimit),
+				},
+			},
+		},
+	}
+	return policy, err
+}
+
+// HashPasswords converts every plaintext password in the database to a hash
+// and exits.
+func HashPasswords(c config.Config) {
+	passwords, err := password.NewManagerFor(c.PasswordHashAlgorithm)
 	if err != nil {
 		log.Fatalln("Failed at config", err)
 	}
@@ -22,22 +223,118 @@ func StartServer() {
 	db := &repository.PostgreSQL{}
 	err = db.Connect(&c)
 	if err != nil {
-		log.Panic("failed to connect to database:", err)
+		log.Fatalln("failed to connect to database:", err)
 	}
 	defer db.Close()
 
-	userService := services.NewUserService(db.DB)
-	authService := services.NewAuthService(db.DB)
-	walletService := services.NewWalletService(db.DB)
+	converted, err := services.NewUserService(db, passwords).HashPlaintextPasswords()
+	if err != nil {
+		db.Close()
+		log.Fatalf("failed to hash passwords after converting %d: %v", converted, err)
+	}
+	fmt.Printf("Hashed %d plaintext passwords\n", converted)
+}
+
+// SetUserRole gives the user with email role and exits. It is how the first
+// admin gets their role; later changes can go through the admin API.
+func SetUserRole(c config.Config, email string, role string) {
+	passwords, err := password.NewManagerFor(c.PasswordHashAlgorithm)
+	if err != nil {
+		log.Fatalln("Failed at config", err)
+	}
 
-	userHandlers := handlers.NewUserHandlers(userService, authService)
-	walletHandlers := handlers.NewWalletHandlers(walletService, authService, userService)
+	db := &repository.PostgreSQL{}
+	err = db.Connect(&c)
+	if err != nil {
+		log.Fatalln("failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	err = services.NewUserService(db, passwords).SetRoleByEmail(email, models.Role(role))
+	if err != nil {
+		db.Close()
+		log.Fatalf("failed to set the role of %s: %v", email, err)
+	}
+	fmt.Printf("%s is now %s; the role applies from their next sign-in or token refresh\n", email, role)
+}
+
+// ImportExchangeRates records every rate of a static rates file in the
+// database, where a server with FX_RATES_SOURCE=database picks them up.
+func ImportExchangeRates(c config.Config, path string) {
+	rates, err := services.LoadStaticRateProvider(path)
+	if err != nil {
+		log.Fatalln("Failed to load rates", err)
+	}
+
+	db := &repository.PostgreSQL{}
+	err = db.Connect(&c)
+	if err != nil {
+		log.Fatalln("failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	imported := rates.Rates()
+	err = services.NewStoreRateProvider(db, 0).RecordRates(imported)
+	if err != nil {
+		db.Close()
+		log.Fatalf("failed to import exchange rates: %v", err)
+	}
+	fmt.Printf("Imported %d exchange rates\n", len(imported))
+}
+
+func migrateUp(db *repository.PostgreSQL) error {
+	applied, err := db.MigrateUp()
+	for _, migration := range applied {
+		logger.Infof("applied migration %d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
 
-	router := routers.NewRouter(userHandlers, walletHandlers)
+// Migrate runs `nikwallet migrate up`, `migrate down [steps]` or
+// `migrate status`. Down reverts a single migration unless steps is given.
+func Migrate(c config.Config, args []string) error {
+	if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[0] != "down") {
+		return ErrMigrateUsage
+	}
+	steps := 1
+	if len(args) == 2 {
+		var err error
+		steps, err = strconv.Atoi(args[1])
+		if err != nil || steps < 1 {
+			return fmt.Errorf("%w: steps must be a positive number, got %q", ErrMigrateUsage, args[1])
+		}
+	}
 
-	fmt.Println("Server listening on port 8080...")
-	err = http.ListenAndServe(":8080", router)
+	db := &repository.PostgreSQL{}
+	err := db.Connect(&c)
 	if err != nil {
-		log.Fatal(err)
+		log.Fatalln("failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(db)
+	case "down":
+		reverted, err := db.MigrateDown(steps)
+		for _, migration := range reverted {
+			logger.Infof("reverted migration %d_%s", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+		return nil
+	default:
+		return ErrMigrateUsage
 	}
 }
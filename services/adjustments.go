@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+)
+
+var ErrInvalidAdjustment = errors.New("invalid adjustment")
+
+// AdjustWallet posts a manual credit or debit of amount to any wallet,
+// balanced against the manual adjustments system account. The entry records
+// actorID and reason. Adjustments are not limited and may touch a frozen
+// account, but cannot take a wallet below what it has on hold, and staff
+// cannot adjust their own wallets.
+func (ws *WalletService) AdjustWallet(actorID int, walletID int, direction models.PostingDirection, amount money.Money, reason string) (*models.JournalEntry, error) {
+	if err := amount.Validate(); err != nil {
+		return nil, err
+	}
+	if direction != models.PostingDirectionCredit && direction != models.PostingDirectionDebit {
+		return nil, fmt.Errorf("%w: direction must be credit or debit, got %q", ErrInvalidAdjustment, direction)
+	}
+	reason, err := adminReason(reason)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ws.GetWalletByID(walletID); err != nil {
+		return nil, err
+	}
+
+	var entry *models.JournalEntry
+	err = ws.store.Transaction(func(tx repository.Store) error {
+		lockedWallets, err := tx.LockWalletsForUpdate(walletID)
+		if err != nil {
+			return err
+		}
+		wallet := lockedWallets[walletID]
+		if wallet.UserID == actorID {
+			return ErrSelfAdmin
+		}
+		if amount.Currency != wallet.Money.Currency {
+			return fmt.Errorf("cannot adjust a %s wallet by %s: %w", wallet.Money.Currency, amount.Currency, money.ErrCurrencyMismatch)
+		}
+
+		var postings []*models.Posting
+		if direction == models.PostingDirectionCredit {
+			postings, err = creditWallet(tx, wallet, amount, nil)
+		} else {
+			postings, err = debitWallet(tx, wallet, amount)
+		}
+		if err != nil {
+			return err
+		}
+		opposite := models.PostingDirectionDebit
+		if direction == models.PostingDirectionDebit {
+			opposite = models.PostingDirectionCredit
+		}
+		postings = append(postings, models.NewSystemPosting(models.SystemAccountAdjustments, opposite, &amount))
+
+		entry = &models.JournalEntry{
+			TransactionType: string(models.TransactionTypeAdjustment),
+			ActorID:         actorID,
+			Reason:          reason,
+			Postings:        postings,
+			CreatedAt:       time.Now(),
+		}
+		if err := tx.CreateJournalEntry(entry); err != nil {
+			return fmt.Errorf("failed to create ledger entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetJournalEntry returns any ledger entry with its postings, for staff.
+func (ws *WalletService) GetJournalEntry(entryID int) (*models.JournalEntry, error) {
+	entry, err := ws.store.GetJournalEntryByID(entryID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrEntryNotFound, entryID)
+	}
+	return entry, nil
+}
+
+// GetWalletByID returns any wallet, for staff.
+func (ws *WalletService) GetWalletByID(walletID int) (*models.Wallet, error) {
+	wallet, err := ws.store.GetWalletByID(walletID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrWalletNotFound, walletID)
+	}
+	return wallet, nil
+}
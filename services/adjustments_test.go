@@ -0,0 +1,70 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdjustments(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+	newWallet := func(t *testing.T, email string, balance float64) (int, *models.Wallet) {
+		userID, _ := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		wallet, err = walletService.AddMoneyToWallet(userID, wallet.ID, inr(balance))
+		assert.NoError(t, err)
+		return userID, wallet
+	}
+	adminID, adminWallet := newWallet(t, "adjustments_admin@example.com", 0)
+
+	t.Run("AdjustWallet method to post a balanced entry that records the admin and reason", func(t *testing.T) {
+		userID, wallet := newWallet(t, "adjustments_credit@example.com", 100)
+
+		entry, err := walletService.AdjustWallet(adminID, wallet.ID, models.PostingDirectionCredit, inr(25), "  goodwill for ticket 42 ")
+		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeAdjustment), entry.TransactionType)
+		assert.Equal(t, adminID, entry.ActorID)
+		assert.Equal(t, "goodwill for ticket 42", entry.Reason)
+		assert.Len(t, entry.Postings, 2)
+		assert.Equal(t, models.SystemAccountAdjustments, entry.Postings[1].SystemAccount)
+
+		stored, err := walletService.GetJournalEntry(entry.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, adminID, stored.ActorID)
+		assert.Equal(t, entry.Reason, stored.Reason)
+
+		_, err = walletService.AdjustWallet(adminID, wallet.ID, models.PostingDirectionDebit, inr(100), "duplicate top up")
+		assert.NoError(t, err)
+		updated, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, "25", updated.Money.Amount.String())
+	})
+
+	t.Run("AdjustWallet method to reject a missing reason, own wallet or overdraft", func(t *testing.T) {
+		_, wallet := newWallet(t, "adjustments_reject@example.com", 10)
+
+		_, err := walletService.AdjustWallet(adminID, wallet.ID, models.PostingDirectionCredit, inr(5), " ")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		_, err = walletService.AdjustWallet(adminID, wallet.ID, "sideways", inr(5), "typo")
+		assert.ErrorIs(t, err, ErrInvalidAdjustment)
+		_, err = walletService.AdjustWallet(adminID, adminWallet.ID, models.PostingDirectionCredit, inr(5), "bonus")
+		assert.ErrorIs(t, err, ErrSelfAdmin)
+		_, err = walletService.AdjustWallet(adminID, wallet.ID, models.PostingDirectionDebit, inr(11), "chargeback")
+		assert.ErrorIs(t, err, money.ErrInsufficientFunds)
+		_, err = walletService.AdjustWallet(adminID, wallet.ID, models.PostingDirectionCredit, money.Money{Amount: decimal.NewFromInt(5), Currency: money.USD}, "bonus")
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+		_, err = walletService.AdjustWallet(adminID, 1<<30, models.PostingDirectionCredit, inr(5), "bonus")
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+}
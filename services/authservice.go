@@ -1,72 +1,509 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"nikwallet/logger"
 	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/services/password"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
-	"gorm.io/gorm"
 )
 
-var signingKey = []byte("secret-key")
+const (
+	DefaultTokenIssuer     = "nikwallet"
+	DefaultTokenAudience   = "nikwallet"
+	DefaultTokenTTL        = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
 
 type Claims struct {
-	UserID int `json:"user_id"`
+	UserID    int    `json:"user_id"`
+	SessionID int    `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+	Role      string `json:"role,omitempty"`
 	jwt.StandardClaims
 }
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, the session has been revoked")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrInvalidToken        = errors.New("token is invalid")
+)
+
+type TokenOptions struct {
+	Keys       *KeySet
+	Issuer     string
+	Audience   string
+	TTL        time.Duration
+	RefreshTTL time.Duration
+}
+
+// ClientInfo describes the device a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type TokenPair struct {
+	AccessToken          string
+	AccessTokenExpiresAt time.Time
+	RefreshToken         string
+	SessionID            int
+}
+
 type AuthService struct {
-	db *gorm.DB
+	store     repository.Store
+	passwords *password.Manager
+	tokens    TokenOptions
 }
 
-func NewAuthService(db *gorm.DB) *AuthService {
-	return &AuthService{db: db}
+func NewAuthService(store repository.Store, passwords *password.Manager, tokens TokenOptions) *AuthService {
+	if tokens.Issuer == "" {
+		tokens.Issuer = DefaultTokenIssuer
+	}
+	if tokens.Audience == "" {
+		tokens.Audience = DefaultTokenAudience
+	}
+	if tokens.TTL <= 0 {
+		tokens.TTL = DefaultTokenTTL
+	}
+	if tokens.RefreshTTL <= 0 {
+		tokens.RefreshTTL = DefaultRefreshTokenTTL
+	}
+	return &AuthService{store: store, passwords: passwords, tokens: tokens}
 }
 
 func (as *AuthService) AuthenticateUser(email string, password string) (string, error) {
-	db := repository.PostgreSQL{DB: as.db}
-	user, err := db.GetUserByEmail(email)
+	tokenPair, err := as.SignIn(email, password, ClientInfo{})
 	if err != nil {
 		return "", err
 	}
-	if password != user.Password {
-		return "", errors.New("invalid email or password")
+	return tokenPair.AccessToken, nil
+}
+
+// SignIn checks the credentials and starts a new session for the client.
+func (as *AuthService) SignIn(email string, password string, client ClientInfo) (*TokenPair, error) {
+	user, err := as.store.GetUserByEmail(email)
+	if err != nil {
+		as.passwords.VerifyDummy(password)
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, needsRehash, err := as.passwords.Verify(password, user.Password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		as.rehashPassword(int(user.ID), password)
+	}
+
+	var tokenPair *TokenPair
+	err = as.store.Transaction(func(tx repository.Store) error {
+		now := time.Now()
+		session := &models.Session{
+			UserID:     int(user.ID),
+			UserAgent:  client.UserAgent,
+			IPAddress:  client.IPAddress,
+			CreatedAt:  now,
+			LastUsedAt: now,
+		}
+		if err := tx.CreateSession(session); err != nil {
+			return err
+		}
+
+		tokenPair, err = as.issueTokens(tx, session, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokenPair, nil
+}
+
+// RefreshTokens exchanges a refresh token for a new token pair. Each refresh
+// token can be used once; presenting it again revokes its whole session,
+// since either the client or an attacker is holding a stolen copy.
+func (as *AuthService) RefreshTokens(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	var tokenPair *TokenPair
+	reused := false
+
+	err := as.store.Transaction(func(tx repository.Store) error {
+		now := time.Now()
+
+		storedToken, err := tx.GetRefreshTokenByHash(hashRefreshToken(refreshToken))
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		session, err := tx.GetSessionByID(storedToken.SessionID)
+		if err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !session.Active(now) || !storedToken.ExpiresAt.After(now) {
+			return ErrInvalidRefreshToken
+		}
+
+		claimed, err := tx.MarkRefreshTokenUsed(storedToken.ID, now)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			// Commit the revocation and report the reuse after the transaction.
+			reused = true
+			return revokeSession(tx, session, now)
+		}
+
+		if client.UserAgent != "" {
+			session.UserAgent = client.UserAgent
+		}
+		if client.IPAddress != "" {
+			session.IPAddress = client.IPAddress
+		}
+
+		tokenPair, err = as.issueTokens(tx, session, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return tokenPair, nil
+}
+
+// Logout ends the session the access token belongs to and revokes the token.
+func (as *AuthService) Logout(claims *Claims) error {
+	return as.store.Transaction(func(tx repository.Store) error {
+		now := time.Now()
+
+		if claims.SessionID != 0 {
+			session, err := tx.GetSessionByID(claims.SessionID)
+			if err != nil {
+				return err
+			}
+			if session.UserID != claims.UserID {
+				return fmt.Errorf("session %d does not belong to user %d", session.ID, claims.UserID)
+			}
+			if err := revokeSession(tx, session, now); err != nil {
+				return err
+			}
+		}
+
+		return as.revokeAccessToken(tx, claims)
+	})
+}
+
+// LogoutAllSessions ends every active session of the token's user.
+func (as *AuthService) LogoutAllSessions(claims *Claims) error {
+	return as.store.Transaction(func(tx repository.Store) error {
+		now := time.Now()
+
+		if err := revokeUserSessions(tx, claims.UserID, now); err != nil {
+			return err
+		}
+
+		return as.revokeAccessToken(tx, claims)
+	})
+}
+
+func (as *AuthService) ListSessions(userID int) ([]*models.Session, error) {
+	return as.store.ListActiveSessions(userID, time.Now())
+}
+
+// revokeUserSessions ends every active session of the user, which also
+// voids their refresh tokens and current access tokens.
+func revokeUserSessions(tx repository.Store, userID int, now time.Time) error {
+	sessions, err := tx.ListActiveSessions(userID, now)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if err := revokeSession(tx, session, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revokeSession(tx repository.Store, session *models.Session, now time.Time) error {
+	if session.RevokedAt == nil {
+		session.RevokedAt = &now
+		if err := tx.UpdateSession(session); err != nil {
+			return err
+		}
+	}
+
+	if session.AccessTokenID == "" || !session.AccessTokenExpiresAt.After(now) {
+		return nil
+	}
+	return tx.RevokeToken(&models.RevokedToken{
+		TokenID:   session.AccessTokenID,
+		UserID:    session.UserID,
+		ExpiresAt: session.AccessTokenExpiresAt,
+	})
+}
+
+func (as *AuthService) revokeAccessToken(tx repository.Store, claims *Claims) error {
+	if claims.Id == "" {
+		return nil
+	}
+	return tx.RevokeToken(&models.RevokedToken{
+		TokenID:   claims.Id,
+		UserID:    claims.UserID,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	})
+}
+
+// issueTokens mints an access token and a fresh refresh token for session and
+// slides the session expiry forward.
+func (as *AuthService) issueTokens(tx repository.Store, session *models.Session, now time.Time) (*TokenPair, error) {
+	// The role is read again on every refresh, so a change of role or a
+	// freeze takes effect once the current access token expires.
+	user, err := tx.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsFrozen() {
+		return nil, ErrAccountFrozen
+	}
+	accessToken, tokenID, expiresAt, err := as.signAccessToken(session.UserID, session.ID, user.Role, now)
+	if err != nil {
+		return nil, err
+	}
+
+	session.AccessTokenID = tokenID
+	session.AccessTokenExpiresAt = expiresAt
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(as.tokens.RefreshTTL)
+	if err := tx.UpdateSession(session); err != nil {
+		return nil, err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-		UserID: int(user.ID),
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.CreateRefreshToken(&models.RefreshToken{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		TokenHash: hashRefreshToken(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: session.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: expiresAt,
+		RefreshToken:         refreshToken,
+		SessionID:            session.ID,
+	}, nil
+}
+
+func (as *AuthService) signAccessToken(userID int, sessionID int, role models.Role, now time.Time) (tokenString string, tokenID string, expiresAt time.Time, err error) {
+	tokenID, err = randomToken(16)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+
+	expiresAt = now.Add(as.tokens.TTL)
+	key := as.tokens.Keys.Active()
+
+	token := jwt.NewWithClaims(key.Method, Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Scope:     strings.Join(ScopesForRole(role), " "),
+		Role:      string(role),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
+			Id:        tokenID,
+			Subject:   strconv.Itoa(userID),
+			Issuer:    as.tokens.Issuer,
+			Audience:  as.tokens.Audience,
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
 		},
 	})
+	token.Header["kid"] = key.ID
 
-	tokenString, err := token.SignedString(signingKey)
+	tokenString, err = token.SignedString(key.PrivateKey)
 	if err != nil {
-		return "", fmt.Errorf("error generating token: %v", err)
+		return "", "", time.Time{}, fmt.Errorf("error generating token: %v", err)
 	}
 
-	return tokenString, nil
+	return tokenString, tokenID, expiresAt, nil
 }
 
 func (as *AuthService) VerifyToken(tokenString string) (*Claims, int, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return signingKey, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, as.verificationKey)
 
 	if err != nil {
-		return nil, 0, fmt.Errorf("error parsing token: %v", err)
+		return nil, 0, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
 
 	claims, ok := token.Claims.(*Claims)
 	if !ok {
-		return nil, 0, errors.New("error parsing claims")
+		return nil, 0, fmt.Errorf("%w: unexpected claims", ErrInvalidToken)
 	}
 
 	if !token.Valid {
-		return nil, 0, errors.New("token is invalid")
+		return nil, 0, ErrInvalidToken
+	}
+
+	now := time.Now().Unix()
+	if !claims.VerifyIssuer(as.tokens.Issuer, true) {
+		return nil, 0, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if !claims.VerifyAudience(as.tokens.Audience, true) {
+		return nil, 0, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	if !claims.VerifyNotBefore(now, true) {
+		return nil, 0, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+
+	if claims.Id == "" {
+		return nil, 0, fmt.Errorf("%w: no token ID", ErrInvalidToken)
+	}
+	revoked, err := as.store.IsTokenRevoked(claims.Id, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+	if revoked {
+		return nil, 0, ErrTokenRevoked
 	}
 
 	return claims, claims.UserID, nil
 }
+
+// verificationKey picks the key named by the token's kid header and refuses
+// tokens whose alg does not match that key, so that a public key can never
+// be used as an HMAC secret.
+func (as *AuthService) verificationKey(token *jwt.Token) (interface{}, error) {
+	keyID, _ := token.Header["kid"].(string)
+	if keyID == "" {
+		return nil, errors.New("token has no key ID")
+	}
+
+	key, err := as.tokens.Keys.Get(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("token is signed with %s but key %s uses %s", token.Method.Alg(), key.ID, key.Method.Alg())
+	}
+
+	return key.PublicKey, nil
+}
+
+func (as *AuthService) JWKS() JSONWebKeySet {
+	return as.tokens.Keys.JWKS()
+}
+
+// rehashPassword upgrades a stored password to the preferred hasher. Failing
+// to do so must not fail the sign-in, so errors are only logged.
+func (as *AuthService) rehashPassword(userID int, password string) {
+	passwordHash, err := as.passwords.Hash(password)
+	if err == nil {
+		err = as.store.UpdateUserPassword(userID, passwordHash)
+	}
+	if err != nil {
+		logger.Warnf("failed to rehash password of user %d: %s", userID, err)
+	}
+}
+
+// Authenticate verifies an access token and returns the principal it
+// identifies.
+func (as *AuthService) Authenticate(tokenString string) (*Principal, error) {
+	claims, _, err := as.VerifyToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrincipal(claims), nil
+}
+
+// CurrentPrincipal checks principal against the user as stored now. It fails
+// for a frozen user and keeps only the scopes the current role of the user
+// still grants, so that freezing or demoting staff takes effect before their
+// tokens expire.
+func (as *AuthService) CurrentPrincipal(principal *Principal) (*Principal, error) {
+	user, err := as.store.GetUserByID(principal.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: user %d no longer exists", ErrInvalidToken, principal.UserID)
+	}
+	if user.IsFrozen() {
+		return nil, ErrAccountFrozen
+	}
+
+	current := *principal
+	current.Role = user.Role
+	current.Scopes = nil
+	granted := &Principal{Scopes: ScopesForRole(user.Role)}
+	for _, scope := range principal.Scopes {
+		if granted.HasScope(scope) {
+			current.Scopes = append(current.Scopes, scope)
+		}
+	}
+	return &current, nil
+}
+
+func (as *AuthService) DeleteExpiredRevocations() (int64, error) {
+	return as.store.DeleteExpiredRevokedTokens(time.Now())
+}
+
+// RunCleanup drops denylist entries of tokens that have expired anyway every
+// interval until ctx is cancelled.
+func (as *AuthService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := as.DeleteExpiredRevocations()
+			if err != nil {
+				logger.Errorf("revoked token cleanup failed: %s", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Debugf("deleted %d expired revoked tokens", deleted)
+			}
+		}
+	}
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
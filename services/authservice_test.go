@@ -1,18 +1,26 @@
 package services
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
 	"nikwallet/repository/models"
+	"nikwallet/services/password"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestAuth(t *testing.T) {
-	authService := &AuthService{
-		db: db.DB,
-	}
+	authService := NewAuthService(db, passwords, tokenOptions)
 
 	t.Run("Authenticate method to authenticate user with correct credentials", func(t *testing.T) {
 		email := "testw51@example.com"
@@ -56,19 +64,62 @@ func TestAuth(t *testing.T) {
 		_, _ = db.CreateUser(newUser)
 
 		token, err := authService.AuthenticateUser("wrong_email", password)
-		assert.NotNil(t, err)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
 		assert.Equal(t, "", token)
 	})
 
-	t.Run("VerifyToken method to successfully verify valid token", func(t *testing.T) {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-			UserID: 123,
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-			},
+	t.Run("Authenticate method to replace a plaintext password with a hash", func(t *testing.T) {
+		userID, err := db.CreateUser(&models.User{
+			EmailID:  "plaintext_auth@example.com",
+			Password: "password",
+		})
+		assert.NoError(t, err)
+
+		token, err := authService.AuthenticateUser("plaintext_auth@example.com", "password")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "", token)
+
+		user, err := db.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.NotEqual(t, "password", user.Password)
+
+		ok, needsRehash, err := passwords.Verify("password", user.Password)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, needsRehash)
+
+		token, err = authService.AuthenticateUser("plaintext_auth@example.com", "password")
+		assert.NoError(t, err)
+		assert.NotEqual(t, "", token)
+	})
+
+	t.Run("Authenticate method to upgrade a hash made with weaker parameters", func(t *testing.T) {
+		weakHash, _ := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+		userID, err := db.CreateUser(&models.User{
+			EmailID:  "weak_hash_auth@example.com",
+			Password: string(weakHash),
 		})
+		assert.NoError(t, err)
+
+		strongAuthService := NewAuthService(db, password.NewManager(password.NewBcryptHasher(bcrypt.MinCost+1)), tokenOptions)
+
+		_, err = strongAuthService.AuthenticateUser("weak_hash_auth@example.com", "wrong_password")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		user, _ := db.GetUserByID(userID)
+		assert.Equal(t, string(weakHash), user.Password)
 
-		tokenString, _ := token.SignedString(signingKey)
+		_, err = strongAuthService.AuthenticateUser("weak_hash_auth@example.com", "password")
+		assert.NoError(t, err)
+
+		user, _ = db.GetUserByID(userID)
+		cost, err := bcrypt.Cost([]byte(user.Password))
+		assert.NoError(t, err)
+		assert.Equal(t, bcrypt.MinCost+1, cost)
+	})
+
+	t.Run("VerifyToken method to successfully verify valid token", func(t *testing.T) {
+		tokenString := signTestToken(t, tokenOptions.Keys.Active(), validTestClaims(123))
 
 		claims, userID, err := authService.VerifyToken(tokenString)
 
@@ -78,15 +129,11 @@ func TestAuth(t *testing.T) {
 	})
 
 	t.Run("VerifyToken method to return error for invalid token key", func(t *testing.T) {
-		invalidKey := []byte("invalid key")
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-			UserID: 123,
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: time.Now().Add(24 * time.Hour).Unix(),
-			},
-		})
+		otherKeys, _ := NewEphemeralKeySet()
+		signingKey := *otherKeys.Active()
+		signingKey.ID = tokenOptions.Keys.Active().ID
 
-		tokenString, _ := token.SignedString(invalidKey)
+		tokenString := signTestToken(t, &signingKey, validTestClaims(123))
 
 		claims, userID, err := authService.VerifyToken(tokenString)
 
@@ -96,14 +143,10 @@ func TestAuth(t *testing.T) {
 	})
 
 	t.Run("VerifyToken method to return error for expired token key", func(t *testing.T) {
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
-			UserID: 123,
-			StandardClaims: jwt.StandardClaims{
-				ExpiresAt: time.Now().Add(-24 * time.Hour).Unix(),
-			},
-		})
+		expiredClaims := validTestClaims(123)
+		expiredClaims.ExpiresAt = time.Now().Add(-24 * time.Hour).Unix()
 
-		tokenString, _ := token.SignedString(signingKey)
+		tokenString := signTestToken(t, tokenOptions.Keys.Active(), expiredClaims)
 
 		claims, userID, err := authService.VerifyToken(tokenString)
 
@@ -111,4 +154,170 @@ func TestAuth(t *testing.T) {
 		assert.Nil(t, claims)
 		assert.Equal(t, 0, userID)
 	})
+
+	t.Run("VerifyToken method to return error for wrong issuer, audience or not-before", func(t *testing.T) {
+		wrongIssuer := validTestClaims(123)
+		wrongIssuer.Issuer = "someone-else"
+
+		wrongAudience := validTestClaims(123)
+		wrongAudience.Audience = "someone-else"
+
+		notYetValid := validTestClaims(123)
+		notYetValid.NotBefore = time.Now().Add(time.Hour).Unix()
+
+		missingNotBefore := validTestClaims(123)
+		missingNotBefore.NotBefore = 0
+
+		for _, claims := range []Claims{wrongIssuer, wrongAudience, notYetValid, missingNotBefore} {
+			_, userID, err := authService.VerifyToken(signTestToken(t, tokenOptions.Keys.Active(), claims))
+			assert.ErrorIs(t, err, ErrInvalidToken)
+			assert.Equal(t, 0, userID)
+		}
+	})
+
+	t.Run("VerifyToken method to return error for missing kid or mismatched alg", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, validTestClaims(123))
+		tokenString, _ := token.SignedString(tokenOptions.Keys.Active().PrivateKey)
+
+		_, _, err := authService.VerifyToken(tokenString)
+		assert.Error(t, err)
+
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, validTestClaims(123))
+		token.Header["kid"] = tokenOptions.Keys.Active().ID
+		tokenString, _ = token.SignedString([]byte("secret-key"))
+
+		_, _, err = authService.VerifyToken(tokenString)
+		assert.Error(t, err)
+	})
+}
+
+func TestAuthKeyRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	oldKey, err := NewSigningKey("2023-01", rsaKey)
+	assert.NoError(t, err)
+	newKey, err := NewSigningKey("2023-02", ecKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", oldKey.Method.Alg())
+	assert.Equal(t, "ES256", newKey.Method.Alg())
+
+	userID, _ := db.CreateUser(&models.User{EmailID: "rotation@example.com", Password: "password"})
+
+	beforeKeys, err := NewKeySet(oldKey.ID, oldKey)
+	assert.NoError(t, err)
+	before := NewAuthService(db, passwords, TokenOptions{Keys: beforeKeys})
+
+	oldToken, err := before.AuthenticateUser("rotation@example.com", "password")
+	assert.NoError(t, err)
+
+	retiredKey := &SigningKey{ID: oldKey.ID, Method: oldKey.Method, PublicKey: oldKey.PublicKey}
+	afterKeys, err := NewKeySet(newKey.ID, newKey, retiredKey)
+	assert.NoError(t, err)
+	after := NewAuthService(db, passwords, TokenOptions{Keys: afterKeys})
+
+	t.Run("VerifyToken method to accept tokens signed by a retired key", func(t *testing.T) {
+		_, verifiedUserID, err := after.VerifyToken(oldToken)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, verifiedUserID)
+	})
+
+	t.Run("AuthenticateUser method to sign with the active key", func(t *testing.T) {
+		newToken, err := after.AuthenticateUser("rotation@example.com", "password")
+		assert.NoError(t, err)
+
+		parsed, _, err := new(jwt.Parser).ParseUnverified(newToken, &Claims{})
+		assert.NoError(t, err)
+		assert.Equal(t, "2023-02", parsed.Header["kid"])
+		assert.Equal(t, "ES256", parsed.Header["alg"])
+
+		_, _, err = before.VerifyToken(newToken)
+		assert.ErrorContains(t, err, ErrUnknownSigningKey.Error())
+	})
+
+	t.Run("JWKS method to publish the public half of every key", func(t *testing.T) {
+		jwks := after.JWKS()
+		assert.Len(t, jwks.Keys, 2)
+
+		assert.Equal(t, "2023-01", jwks.Keys[0].KeyID)
+		assert.Equal(t, "RSA", jwks.Keys[0].KeyType)
+		assert.Equal(t, "RS256", jwks.Keys[0].Algorithm)
+		assert.Equal(t, "AQAB", jwks.Keys[0].E)
+
+		assert.Equal(t, "2023-02", jwks.Keys[1].KeyID)
+		assert.Equal(t, "EC", jwks.Keys[1].KeyType)
+		assert.Equal(t, "P-256", jwks.Keys[1].Curve)
+		assert.Len(t, jwks.Keys[1].X, 43)
+	})
+
+	t.Run("NewKeySet method to return error when the active key cannot sign", func(t *testing.T) {
+		_, err := NewKeySet(retiredKey.ID, retiredKey)
+		assert.Error(t, err)
+
+		_, err = NewKeySet("missing", newKey)
+		assert.ErrorIs(t, err, ErrUnknownSigningKey)
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	path := filepath.Join(t.TempDir(), "current.pem")
+	assert.NoError(t, os.WriteFile(path, privatePEM, 0o600))
+	t.Setenv("NIKWALLET_TEST_RETIRED_KEY", string(publicPEM))
+
+	t.Run("LoadKeySet method to read keys from files and environment variables", func(t *testing.T) {
+		keys, err := LoadKeySet("current", "current="+path+", retired=env:NIKWALLET_TEST_RETIRED_KEY")
+		assert.NoError(t, err)
+		assert.Equal(t, "current", keys.Active().ID)
+		assert.Equal(t, "ES256", keys.Active().Method.Alg())
+
+		retired, err := keys.Get("retired")
+		assert.NoError(t, err)
+		assert.Equal(t, "RS256", retired.Method.Alg())
+		assert.Nil(t, retired.PrivateKey)
+	})
+
+	t.Run("LoadKeySet method to return error for malformed entries", func(t *testing.T) {
+		_, err := LoadKeySet("current", "current")
+		assert.Error(t, err)
+
+		_, err = LoadKeySet("current", "current=env:NIKWALLET_TEST_MISSING_KEY")
+		assert.Error(t, err)
+
+		_, err = LoadKeySet("retired", "retired=env:NIKWALLET_TEST_RETIRED_KEY")
+		assert.Error(t, err)
+	})
+}
+
+func validTestClaims(userID int) Claims {
+	now := time.Now()
+	tokenID, _ := randomToken(16)
+	return Claims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
+			Issuer:    DefaultTokenIssuer,
+			Audience:  DefaultTokenAudience,
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(24 * time.Hour).Unix(),
+		},
+	}
+}
+
+func signTestToken(t *testing.T, key *SigningKey, claims Claims) string {
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	assert.NoError(t, err)
+	return tokenString
 }
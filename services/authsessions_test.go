@@ -0,0 +1,96 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthSessions(t *testing.T) {
+	authService := NewAuthService(db, passwords, tokenOptions)
+	userID, _ := db.CreateUser(&models.User{EmailID: "sessions@example.com", Password: "password"})
+	laptop := ClientInfo{UserAgent: "laptop", IPAddress: "10.0.0.1"}
+	phone := ClientInfo{UserAgent: "phone", IPAddress: "10.0.0.2"}
+
+	t.Run("RefreshTokens method to rotate the refresh token and keep the session", func(t *testing.T) {
+		first, err := authService.SignIn("sessions@example.com", "password", laptop)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, first.RefreshToken)
+
+		second, err := authService.RefreshTokens(first.RefreshToken, laptop)
+		assert.NoError(t, err)
+		assert.Equal(t, first.SessionID, second.SessionID)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+		claims, verifiedUserID, err := authService.VerifyToken(second.AccessToken)
+		assert.NoError(t, err)
+		assert.Equal(t, userID, verifiedUserID)
+		assert.Equal(t, second.SessionID, claims.SessionID)
+	})
+
+	t.Run("RefreshTokens method to revoke the token family when a refresh token is reused", func(t *testing.T) {
+		first, _ := authService.SignIn("sessions@example.com", "password", laptop)
+		second, err := authService.RefreshTokens(first.RefreshToken, laptop)
+		assert.NoError(t, err)
+
+		_, err = authService.RefreshTokens(first.RefreshToken, laptop)
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		_, err = authService.RefreshTokens(second.RefreshToken, laptop)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		_, _, err = authService.VerifyToken(second.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+
+		session, err := db.GetSessionByID(first.SessionID)
+		assert.NoError(t, err)
+		assert.NotNil(t, session.RevokedAt)
+	})
+
+	t.Run("RefreshTokens method to return error for an unknown refresh token", func(t *testing.T) {
+		_, err := authService.RefreshTokens("not-a-refresh-token", laptop)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("Logout method to revoke the access token and its session only", func(t *testing.T) {
+		laptopTokens, _ := authService.SignIn("sessions@example.com", "password", laptop)
+		phoneTokens, _ := authService.SignIn("sessions@example.com", "password", phone)
+
+		claims, _, err := authService.VerifyToken(laptopTokens.AccessToken)
+		assert.NoError(t, err)
+		assert.NoError(t, authService.Logout(claims))
+
+		_, _, err = authService.VerifyToken(laptopTokens.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+		_, err = authService.RefreshTokens(laptopTokens.RefreshToken, laptop)
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		_, _, err = authService.VerifyToken(phoneTokens.AccessToken)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ListSessions and LogoutAllSessions methods to list and end every session", func(t *testing.T) {
+		laptopTokens, _ := authService.SignIn("sessions@example.com", "password", laptop)
+		phoneTokens, _ := authService.SignIn("sessions@example.com", "password", phone)
+
+		sessions, err := authService.ListSessions(userID)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, len(sessions), 2)
+		assert.Equal(t, phoneTokens.SessionID, sessions[0].ID)
+		assert.Equal(t, "phone", sessions[0].UserAgent)
+		assert.Equal(t, "10.0.0.2", sessions[0].IPAddress)
+
+		claims, _, _ := authService.VerifyToken(phoneTokens.AccessToken)
+		assert.NoError(t, authService.LogoutAllSessions(claims))
+
+		sessions, err = authService.ListSessions(userID)
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+
+		_, _, err = authService.VerifyToken(laptopTokens.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+		_, _, err = authService.VerifyToken(phoneTokens.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+	})
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"nikwallet/logger"
+	"nikwallet/repository/money"
+)
+
+// balanceSnapshotDelay keeps snapshots clear of transactions that stamped
+// their postings but have not committed yet.
+const balanceSnapshotDelay = time.Minute
+
+// GetBalanceAt returns the balance of the wallet walletID of the user, or of
+// the default wallet when walletID is 0, after every posting made at or
+// before at.
+func (ws *WalletService) GetBalanceAt(userID int, walletID int, at time.Time) (*money.Money, error) {
+	wallet, err := resolveWallet(ws.store, userID, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := ws.store.GetWalletBalanceAt(wallet.ID, at)
+	if err != nil {
+		return nil, err
+	}
+	return &money.Money{Amount: balance, Currency: wallet.Money.Currency}, nil
+}
+
+// SnapshotBalances records the balance of every wallet that changed since its
+// latest snapshot.
+func (ws *WalletService) SnapshotBalances() (int64, error) {
+	return ws.store.CreateBalanceSnapshots(time.Now().Add(-balanceSnapshotDelay))
+}
+
+// RunBalanceSnapshots snapshots wallet balances every interval until ctx is
+// cancelled.
+func (ws *WalletService) RunBalanceSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			created, err := ws.SnapshotBalances()
+			if err != nil {
+				logger.Errorf("balance snapshot failed: %s", err)
+				continue
+			}
+			if created > 0 {
+				logger.Debugf("snapshotted %d wallet balances", created)
+			}
+		}
+	}
+}
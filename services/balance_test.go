@@ -0,0 +1,78 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalances(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+
+	ownerID, _ := db.CreateUser(&models.User{EmailID: "balances_owner@example.com", Password: "password"})
+	friendID, _ := db.CreateUser(&models.User{EmailID: "balances_friend@example.com", Password: "password"})
+	wallet, err := walletService.CreateWallet(ownerID, money.INR)
+	assert.NoError(t, err)
+	friendWallet, err := walletService.CreateWallet(friendID, money.INR)
+	assert.NoError(t, err)
+
+	_, err = walletService.AddMoneyToWallet(ownerID, wallet.ID, inr(100))
+	assert.NoError(t, err)
+	afterDeposit := time.Now()
+	_, err = walletService.WithdrawMoneyFromWallet(ownerID, wallet.ID, inr(30))
+	assert.NoError(t, err)
+	assert.NoError(t, walletService.TransferMoney(ownerID, wallet.ID, "balances_friend@example.com", 0, inr(20)))
+
+	t.Run("postings to record the balance of their wallet after each transaction", func(t *testing.T) {
+		postings, err := walletService.GetLastNPostings(ownerID, wallet.ID, 10)
+		assert.NoError(t, err)
+
+		var balances []string
+		for _, posting := range postings {
+			assert.True(t, posting.BalanceAfter.Valid)
+			balances = append(balances, posting.BalanceAfter.Decimal.String())
+		}
+		assert.Equal(t, []string{"50", "70", "100"}, balances)
+
+		received, err := walletService.GetLastNPostings(friendID, friendWallet.ID, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "20", received[0].BalanceAfter.Decimal.String())
+	})
+
+	t.Run("GetBalanceAt method to return the balance as it stood at a point in time", func(t *testing.T) {
+		balance, err := walletService.GetBalanceAt(ownerID, 0, afterDeposit)
+		assert.NoError(t, err)
+		assert.True(t, balance.Equals(inr(100)), "got %v", balance)
+
+		balance, err = walletService.GetBalanceAt(ownerID, wallet.ID, time.Now())
+		assert.NoError(t, err)
+		assert.True(t, balance.Equals(inr(50)), "got %v", balance)
+
+		_, err = walletService.GetBalanceAt(ownerID, friendWallet.ID, time.Now())
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+
+	t.Run("GetBalanceAt method to return the same balances once snapshots exist", func(t *testing.T) {
+		_, err := db.CreateBalanceSnapshots(time.Now())
+		assert.NoError(t, err)
+
+		balance, err := walletService.GetBalanceAt(ownerID, wallet.ID, afterDeposit)
+		assert.NoError(t, err)
+		assert.True(t, balance.Equals(inr(100)), "got %v", balance)
+
+		balance, err = walletService.GetBalanceAt(ownerID, wallet.ID, time.Now())
+		assert.NoError(t, err)
+		assert.True(t, balance.Equals(inr(50)), "got %v", balance)
+	})
+}
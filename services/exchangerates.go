@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// exchangeRatePrecision is the number of decimal places derived rates are
+// rounded to.
+const exchangeRatePrecision = 8
+
+var ErrExchangeRateUnavailable = errors.New("exchange rate unavailable")
+
+// ExchangeRate is the number of units of To one unit of From buys.
+type ExchangeRate struct {
+	From   money.Currency
+	To     money.Currency
+	Rate   decimal.Decimal
+	Source string
+	AsOf   time.Time
+}
+
+type ExchangeRateProvider interface {
+	Rate(from, to money.Currency) (*ExchangeRate, error)
+}
+
+// StaticRateProvider serves a fixed table of rates against a base currency
+// and derives every other pair from it.
+type StaticRateProvider struct {
+	source string
+	base   money.Currency
+	rates  map[money.Currency]decimal.Decimal
+	asOf   time.Time
+}
+
+// NewStaticRateProvider takes rates as the number of units of each currency
+// one unit of base buys.
+func NewStaticRateProvider(source string, base money.Currency, rates map[money.Currency]decimal.Decimal, asOf time.Time) (*StaticRateProvider, error) {
+	table := map[money.Currency]decimal.Decimal{base: decimal.NewFromInt(1)}
+	for currency, rate := range rates {
+		if !rate.IsPositive() {
+			return nil, fmt.Errorf("rate for %s must be positive, got %s", currency, rate)
+		}
+		table[currency] = rate
+	}
+	return &StaticRateProvider{source: source, base: base, rates: table, asOf: asOf}, nil
+}
+
+// DefaultStaticRateProvider serves the built-in rates used when no rates file
+// is configured.
+func DefaultStaticRateProvider() *StaticRateProvider {
+	provider, _ := NewStaticRateProvider("static:default", money.INR, map[money.Currency]decimal.Decimal{
+		money.USD: decimal.RequireFromString("0.012"),
+		money.EUR: decimal.RequireFromString("0.011"),
+	}, time.Time{})
+	return provider
+}
+
+type staticRatesFile struct {
+	Source string                             `json:"source"`
+	Base   money.Currency                     `json:"base"`
+	AsOf   time.Time                          `json:"as_of"`
+	Rates  map[money.Currency]decimal.Decimal `json:"rates"`
+}
+
+// LoadStaticRateProvider reads a JSON rates file such as
+//
+//	{"source": "ecb", "base": "INR", "as_of": "2023-06-01T00:00:00Z", "rates": {"USD": "0.012"}}
+func LoadStaticRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	var file staticRatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rates file %s: %w", path, err)
+	}
+	if file.Base == "" {
+		return nil, fmt.Errorf("rates file %s has no base currency", path)
+	}
+	if file.Source == "" {
+		file.Source = "static:" + path
+	}
+
+	return NewStaticRateProvider(file.Source, file.Base, file.Rates, file.AsOf)
+}
+
+func (sp *StaticRateProvider) Rate(from, to money.Currency) (*ExchangeRate, error) {
+	fromRate, ok := sp.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateUnavailable, from, to)
+	}
+	toRate, ok := sp.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateUnavailable, from, to)
+	}
+
+	return &ExchangeRate{
+		From:   from,
+		To:     to,
+		Rate:   toRate.DivRound(fromRate, exchangeRatePrecision),
+		Source: sp.source,
+		AsOf:   sp.asOf,
+	}, nil
+}
+
+// Rates returns every pair the provider can convert between.
+func (sp *StaticRateProvider) Rates() []*ExchangeRate {
+	currencies := make([]string, 0, len(sp.rates))
+	for currency := range sp.rates {
+		currencies = append(currencies, string(currency))
+	}
+	sort.Strings(currencies)
+
+	var rates []*ExchangeRate
+	for _, from := range currencies {
+		for _, to := range currencies {
+			if from == to {
+				continue
+			}
+			rate, _ := sp.Rate(money.Currency(from), money.Currency(to))
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+// StoreRateProvider serves the latest rates recorded in the store. When only
+// the reverse pair has been recorded its inverse is used. Rates older than
+// maxAge are ignored, unless maxAge is 0.
+type StoreRateProvider struct {
+	store  repository.Store
+	maxAge time.Duration
+}
+
+func NewStoreRateProvider(store repository.Store, maxAge time.Duration) *StoreRateProvider {
+	return &StoreRateProvider{store: store, maxAge: maxAge}
+}
+
+func (sp *StoreRateProvider) Rate(from, to money.Currency) (*ExchangeRate, error) {
+	now := time.Now()
+
+	if rate, err := sp.store.GetLatestExchangeRate(from, to, now); err == nil && sp.fresh(rate, now) {
+		return &ExchangeRate{From: from, To: to, Rate: rate.Rate, Source: rate.Source, AsOf: rate.EffectiveAt}, nil
+	}
+
+	if rate, err := sp.store.GetLatestExchangeRate(to, from, now); err == nil && sp.fresh(rate, now) && rate.Rate.IsPositive() {
+		inverse := decimal.NewFromInt(1).DivRound(rate.Rate, exchangeRatePrecision)
+		return &ExchangeRate{From: from, To: to, Rate: inverse, Source: rate.Source, AsOf: rate.EffectiveAt}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateUnavailable, from, to)
+}
+
+func (sp *StoreRateProvider) fresh(rate *models.ExchangeRate, now time.Time) bool {
+	return sp.maxAge <= 0 || now.Sub(rate.EffectiveAt) <= sp.maxAge
+}
+
+// RecordRates stores rates so that a StoreRateProvider serves them from now
+// on, or from their AsOf time when it is set.
+func (sp *StoreRateProvider) RecordRates(rates []*ExchangeRate) error {
+	now := time.Now()
+	return sp.store.Transaction(func(tx repository.Store) error {
+		for _, rate := range rates {
+			effectiveAt := rate.AsOf
+			if effectiveAt.IsZero() {
+				effectiveAt = now
+			}
+			err := tx.CreateExchangeRate(&models.ExchangeRate{
+				BaseCurrency:  rate.From,
+				QuoteCurrency: rate.To,
+				Rate:          rate.Rate,
+				Source:        rate.Source,
+				EffectiveAt:   effectiveAt,
+				CreatedAt:     now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+)
+
+const DefaultFXQuoteTTL = 30 * time.Second
+
+var (
+	ErrFXQuoteNotFound = errors.New("fx quote not found")
+	ErrFXQuoteExpired  = errors.New("fx quote has expired or was already used")
+	ErrFXQuoteMismatch = errors.New("fx quote does not match the currencies of the transfer")
+	ErrSameCurrency    = errors.New("cannot quote a currency against itself")
+)
+
+type FXService struct {
+	store    repository.Store
+	rates    ExchangeRateProvider
+	quoteTTL time.Duration
+}
+
+func NewFXService(store repository.Store, rates ExchangeRateProvider, quoteTTL time.Duration) *FXService {
+	if quoteTTL <= 0 {
+		quoteTTL = DefaultFXQuoteTTL
+	}
+	return &FXService{store: store, rates: rates, quoteTTL: quoteTTL}
+}
+
+// Quote locks the current rate from one currency to another for userID. A
+// transfer that passes the quote ID within the quote TTL converts at that
+// rate instead of the live one.
+func (fs *FXService) Quote(userID int, from, to money.Currency) (*models.FXQuote, error) {
+	if !from.IsValid() {
+		return nil, fmt.Errorf("%w: %s", money.ErrUnsupportedCurrency, from)
+	}
+	if !to.IsValid() {
+		return nil, fmt.Errorf("%w: %s", money.ErrUnsupportedCurrency, to)
+	}
+	if from == to {
+		return nil, fmt.Errorf("%w: %s", ErrSameCurrency, from)
+	}
+
+	rate, err := fs.rates.Rate(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	quote := &models.FXQuote{
+		ID:           id,
+		UserID:       userID,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         rate.Rate,
+		Source:       rate.Source,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(fs.quoteTTL),
+	}
+	if err := fs.store.CreateFXQuote(quote); err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+// quotedRate returns the rate locked by quote id if it belongs to userID and
+// converts from one currency to the other.
+func quotedRate(store repository.Store, userID int, id string, from, to money.Currency) (*ExchangeRate, error) {
+	quote, err := store.GetFXQuote(id)
+	if err != nil || quote.UserID != userID {
+		return nil, ErrFXQuoteNotFound
+	}
+	if !quote.Usable(time.Now()) {
+		return nil, ErrFXQuoteExpired
+	}
+	if quote.FromCurrency != from || quote.ToCurrency != to {
+		return nil, ErrFXQuoteMismatch
+	}
+
+	return &ExchangeRate{
+		From:   quote.FromCurrency,
+		To:     quote.ToCurrency,
+		Rate:   quote.Rate,
+		Source: quote.Source,
+		AsOf:   quote.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExchangeRateProviders(t *testing.T) {
+	t.Run("StaticRateProvider to derive cross rates from the base currency", func(t *testing.T) {
+		provider := DefaultStaticRateProvider()
+
+		rate, err := provider.Rate(money.INR, money.USD)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.012", rate.Rate.String())
+		assert.Equal(t, "static:default", rate.Source)
+
+		rate, err = provider.Rate(money.USD, money.EUR)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.91666667", rate.Rate.String())
+
+		_, err = provider.Rate(money.USD, money.Currency("GBP"))
+		assert.ErrorIs(t, err, ErrExchangeRateUnavailable)
+
+		assert.Len(t, provider.Rates(), 6)
+	})
+
+	t.Run("LoadStaticRateProvider to read the source, base and rates from a file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "rates.json")
+		err := os.WriteFile(path, []byte(`{"source": "ecb", "base": "EUR", "rates": {"USD": "1.08", "INR": 89.5}}`), 0o600)
+		assert.NoError(t, err)
+
+		provider, err := LoadStaticRateProvider(path)
+		assert.NoError(t, err)
+
+		rate, err := provider.Rate(money.EUR, money.INR)
+		assert.NoError(t, err)
+		assert.Equal(t, "89.5", rate.Rate.String())
+		assert.Equal(t, "ecb", rate.Source)
+
+		err = os.WriteFile(path, []byte(`{"rates": {"USD": "-1"}}`), 0o600)
+		assert.NoError(t, err)
+		_, err = LoadStaticRateProvider(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("StoreRateProvider to serve the latest recorded rate, its inverse, and skip stale rates", func(t *testing.T) {
+		provider := NewStoreRateProvider(db, time.Hour)
+
+		_, err := provider.Rate(money.EUR, money.INR)
+		assert.ErrorIs(t, err, ErrExchangeRateUnavailable)
+
+		err = provider.RecordRates([]*ExchangeRate{
+			{From: money.EUR, To: money.INR, Rate: decimal.RequireFromString("80"), Source: "stale", AsOf: time.Now().Add(-2 * time.Hour)},
+		})
+		assert.NoError(t, err)
+		_, err = provider.Rate(money.EUR, money.INR)
+		assert.ErrorIs(t, err, ErrExchangeRateUnavailable)
+
+		err = provider.RecordRates([]*ExchangeRate{
+			{From: money.EUR, To: money.INR, Rate: decimal.RequireFromString("90"), Source: "feed"},
+		})
+		assert.NoError(t, err)
+
+		rate, err := provider.Rate(money.EUR, money.INR)
+		assert.NoError(t, err)
+		assert.Equal(t, "90", rate.Rate.String())
+		assert.Equal(t, "feed", rate.Source)
+
+		rate, err = provider.Rate(money.INR, money.EUR)
+		assert.NoError(t, err)
+		assert.Equal(t, "0.01111111", rate.Rate.String())
+	})
+}
+
+func TestFXQuotes(t *testing.T) {
+	fxService := NewFXService(db, DefaultStaticRateProvider(), time.Minute)
+	walletService := &WalletService{
+		store: db,
+		rates: DefaultStaticRateProvider(),
+	}
+
+	newFundedUser := func(t *testing.T, email string, currency money.Currency, amount float64) int {
+		userID, err := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		assert.NoError(t, err)
+		_, err = walletService.CreateWallet(userID, currency)
+		assert.NoError(t, err)
+		if amount > 0 {
+			_, err = walletService.AddMoneyToWallet(userID, 0, money.Money{Amount: decimal.NewFromFloat(amount), Currency: currency})
+			assert.NoError(t, err)
+		}
+		return userID
+	}
+
+	t.Run("Quote method to lock the live rate until the quote TTL", func(t *testing.T) {
+		quote, err := fxService.Quote(1, money.USD, money.INR)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, quote.ID)
+		assert.Equal(t, "83.33333333", quote.Rate.String())
+		assert.Equal(t, "static:default", quote.Source)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), quote.ExpiresAt, 5*time.Second)
+
+		_, err = fxService.Quote(1, money.USD, money.USD)
+		assert.ErrorIs(t, err, ErrSameCurrency)
+		_, err = fxService.Quote(1, money.USD, money.Currency("XYZ"))
+		assert.ErrorIs(t, err, money.ErrUnsupportedCurrency)
+		_, err = fxService.Quote(1, money.USD, money.Currency("GBP"))
+		assert.ErrorIs(t, err, ErrExchangeRateUnavailable)
+	})
+
+	t.Run("TransferMoneyWithQuote method to convert at the quoted rate and use the quote up", func(t *testing.T) {
+		senderID := newFundedUser(t, "fx_quote_sender@example.com", money.USD, 100)
+		newFundedUser(t, "fx_quote_recipient@example.com", money.INR, 0)
+
+		lockedRate := decimal.RequireFromString("80")
+		quotedRates, err := NewStaticRateProvider("quoted", money.USD, map[money.Currency]decimal.Decimal{money.INR: lockedRate}, time.Time{})
+		assert.NoError(t, err)
+
+		quote, err := NewFXService(db, quotedRates, time.Minute).Quote(senderID, money.USD, money.INR)
+		assert.NoError(t, err)
+
+		ten := money.Money{Amount: decimal.NewFromFloat(10), Currency: money.USD}
+		err = walletService.TransferMoneyWithQuote(senderID, 0, "fx_quote_recipient@example.com", 0, quote.ID, ten)
+		assert.NoError(t, err)
+
+		entry, err := db.GetLatestJournalEntry(senderID)
+		assert.NoError(t, err)
+		assert.NoError(t, entry.Validate())
+		for _, posting := range entry.Postings {
+			if posting.Amount.Currency == money.INR {
+				assert.True(t, posting.Amount.Amount.Equal(decimal.NewFromInt(800)), "got %s, want 800", posting.Amount.Amount)
+				assert.True(t, posting.FXRate.Valid)
+				assert.True(t, posting.FXRate.Decimal.Equal(lockedRate))
+				assert.Equal(t, "quoted", posting.FXRateSource)
+			}
+		}
+
+		err = walletService.TransferMoneyWithQuote(senderID, 0, "fx_quote_recipient@example.com", 0, quote.ID, ten)
+		assert.ErrorIs(t, err, ErrFXQuoteExpired)
+
+		wallet, _ := db.GetWalletByUserID(senderID)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromInt(90)), "got %s, want 90", wallet.Money.Amount)
+	})
+
+	t.Run("TransferMoneyWithQuote method to reject a quote of another user or for other currencies", func(t *testing.T) {
+		senderID := newFundedUser(t, "fx_quote_owner@example.com", money.EUR, 100)
+		otherID := newFundedUser(t, "fx_quote_other@example.com", money.EUR, 0)
+
+		quote, err := fxService.Quote(otherID, money.EUR, money.USD)
+		assert.NoError(t, err)
+		mismatched, err := fxService.Quote(senderID, money.EUR, money.INR)
+		assert.NoError(t, err)
+		newFundedUser(t, "fx_quote_usd@example.com", money.USD, 0)
+
+		ten := money.Money{Amount: decimal.NewFromFloat(10), Currency: money.EUR}
+		err = walletService.TransferMoneyWithQuote(senderID, 0, "fx_quote_usd@example.com", 0, quote.ID, ten)
+		assert.ErrorIs(t, err, ErrFXQuoteNotFound)
+
+		err = walletService.TransferMoneyWithQuote(senderID, 0, "fx_quote_usd@example.com", 0, mismatched.ID, ten)
+		assert.ErrorIs(t, err, ErrFXQuoteMismatch)
+
+		err = walletService.TransferMoneyWithQuote(senderID, 0, "fx_quote_nobody@example.com", 0, "", ten)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		wallet, _ := db.GetWalletByUserID(senderID)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromInt(100)), "got %s, want 100", wallet.Money.Amount)
+	})
+}
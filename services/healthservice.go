@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// HealthCheck reports why a dependency of the server cannot be used, or nil.
+type HealthCheck func(ctx context.Context) error
+
+// HealthService backs the liveness and readiness probes. The server is ready
+// while every registered check passes and it has not started shutting down.
+type HealthService struct {
+	mu           sync.Mutex
+	names        []string
+	checks       map[string]HealthCheck
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService() *HealthService {
+	return &HealthService{checks: map[string]HealthCheck{}}
+}
+
+func (hs *HealthService) AddCheck(name string, check HealthCheck) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	if _, exists := hs.checks[name]; !exists {
+		hs.names = append(hs.names, name)
+	}
+	hs.checks[name] = check
+}
+
+// SetShuttingDown makes the server report not ready, so that load balancers
+// stop routing new requests to it while in-flight ones drain.
+func (hs *HealthService) SetShuttingDown() {
+	hs.shuttingDown.Store(true)
+}
+
+// Ready runs every check and returns the failures by check name. The server
+// is ready when the map is empty.
+func (hs *HealthService) Ready(ctx context.Context) map[string]error {
+	hs.mu.Lock()
+	names := append([]string(nil), hs.names...)
+	checks := make([]HealthCheck, 0, len(names))
+	for _, name := range names {
+		checks = append(checks, hs.checks[name])
+	}
+	hs.mu.Unlock()
+
+	failures := map[string]error{}
+	if hs.shuttingDown.Load() {
+		failures["shutdown"] = ErrShuttingDown
+	}
+	for i, check := range checks {
+		if err := check(ctx); err != nil {
+			failures[names[i]] = err
+		}
+	}
+	return failures
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultHistoryPageSize is the page size of a history request that does not
+// ask for one.
+const DefaultHistoryPageSize = 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// HistoryQuery filters and pages the wallet history of a user. Zero fields do
+// not filter, and a zero WalletID spans every wallet of the user. From is
+// inclusive and To exclusive. Cursor is the NextCursor of the previous page.
+type HistoryQuery struct {
+	WalletID          int
+	TransactionTypes  []models.TransactionType
+	CounterpartyEmail string
+	Currency          money.Currency
+	MinAmount         decimal.NullDecimal
+	MaxAmount         decimal.NullDecimal
+	From              time.Time
+	To                time.Time
+	Ascending         bool
+	Cursor            string
+	Limit             int
+}
+
+// HistoryPage is one page of postings. NextCursor is empty on the last page.
+type HistoryPage struct {
+	Postings   []*models.Posting
+	NextCursor string
+}
+
+// ListHistory returns a page of the wallet postings of userID matching query,
+// newest first unless query.Ascending is set.
+func (ws *WalletService) ListHistory(userID int, query HistoryQuery) (*HistoryPage, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = DefaultHistoryPageSize
+		if limit > ws.historyMaxLimit {
+			limit = ws.historyMaxLimit
+		}
+	}
+	if limit < 1 || limit > ws.historyMaxLimit {
+		return nil, fmt.Errorf("%w: must be between 1 and %d, got %d", ErrInvalidLimit, ws.historyMaxLimit, limit)
+	}
+
+	storeQuery := repository.PostingQuery{
+		UserID:    userID,
+		Currency:  query.Currency,
+		MinAmount: query.MinAmount,
+		MaxAmount: query.MaxAmount,
+		From:      query.From,
+		To:        query.To,
+		Ascending: query.Ascending,
+		Limit:     limit + 1,
+	}
+	for _, transactionType := range query.TransactionTypes {
+		storeQuery.TransactionTypes = append(storeQuery.TransactionTypes, string(transactionType))
+	}
+
+	if query.Cursor != "" {
+		cursor, err := decodeHistoryCursor(query.Cursor, query.Ascending)
+		if err != nil {
+			return nil, err
+		}
+		storeQuery.After = cursor
+	}
+
+	if query.WalletID != 0 {
+		wallet, err := resolveWallet(ws.store, userID, query.WalletID)
+		if err != nil {
+			return nil, err
+		}
+		storeQuery.WalletID = wallet.ID
+	}
+
+	if query.CounterpartyEmail != "" {
+		counterparty, err := ws.store.GetUserByEmail(query.CounterpartyEmail)
+		if err != nil {
+			// Nobody by that email can have traded with the user.
+			return &HistoryPage{Postings: []*models.Posting{}}, nil
+		}
+		storeQuery.CounterpartyUserID = int(counterparty.ID)
+	}
+
+	postings, err := ws.store.ListPostings(storeQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &HistoryPage{Postings: postings}
+	if len(postings) > limit {
+		page.Postings = postings[:limit]
+		last := page.Postings[limit-1]
+		page.NextCursor = encodeHistoryCursor(repository.PostingCursor{CreatedAt: last.CreatedAt, ID: last.ID}, query.Ascending)
+	}
+	return page, nil
+}
+
+// encodeHistoryCursor packs the position of a posting and the sort direction
+// into an opaque string, so that clients cannot depend on its layout.
+func encodeHistoryCursor(cursor repository.PostingCursor, ascending bool) string {
+	direction := "d"
+	if ascending {
+		direction = "a"
+	}
+	raw := fmt.Sprintf("%s:%d:%d", direction, cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(encoded string, ascending bool) (*repository.PostingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != "a" && parts[0] != "d") {
+		return nil, ErrInvalidCursor
+	}
+	if (parts[0] == "a") != ascending {
+		return nil, fmt.Errorf("%w: the cursor belongs to a listing in the other order", ErrInvalidCursor)
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &repository.PostingCursor{CreatedAt: time.Unix(0, nanos), ID: id}, nil
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListHistory(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+	amounts := func(postings []*models.Posting) []string {
+		var values []string
+		for _, posting := range postings {
+			values = append(values, posting.Amount.Amount.String())
+		}
+		return values
+	}
+
+	ownerID, _ := db.CreateUser(&models.User{EmailID: "history_owner@example.com", Password: "password"})
+	friendID, _ := db.CreateUser(&models.User{EmailID: "history_friend@example.com", Password: "password"})
+	wallet, err := walletService.CreateWallet(ownerID, money.INR)
+	assert.NoError(t, err)
+	_, err = walletService.CreateWallet(friendID, money.INR)
+	assert.NoError(t, err)
+
+	for _, amount := range []float64{100, 200, 300} {
+		_, err := walletService.AddMoneyToWallet(ownerID, wallet.ID, inr(amount))
+		assert.NoError(t, err)
+	}
+	_, err = walletService.WithdrawMoneyFromWallet(ownerID, wallet.ID, inr(50))
+	assert.NoError(t, err)
+	assert.NoError(t, walletService.TransferMoney(ownerID, wallet.ID, "history_friend@example.com", 0, inr(25)))
+
+	t.Run("ListHistory method to page through every posting with NextCursor", func(t *testing.T) {
+		var seen []string
+		query := HistoryQuery{Limit: 2}
+		for pages := 0; pages < 5; pages++ {
+			page, err := walletService.ListHistory(ownerID, query)
+			assert.NoError(t, err)
+			seen = append(seen, amounts(page.Postings)...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"25", "50", "300", "200", "100"}, seen)
+	})
+
+	t.Run("ListHistory method to list oldest first when ascending", func(t *testing.T) {
+		page, err := walletService.ListHistory(ownerID, HistoryQuery{Ascending: true, Limit: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"100", "200"}, amounts(page.Postings))
+
+		page, err = walletService.ListHistory(ownerID, HistoryQuery{Ascending: true, Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"300", "50"}, amounts(page.Postings))
+	})
+
+	t.Run("ListHistory method to filter by type, counterparty and amount", func(t *testing.T) {
+		page, err := walletService.ListHistory(ownerID, HistoryQuery{
+			TransactionTypes: []models.TransactionType{models.TransactionTypeAdd},
+			MinAmount:        decimal.NewNullDecimal(decimal.NewFromInt(150)),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"300", "200"}, amounts(page.Postings))
+		assert.Empty(t, page.NextCursor)
+
+		page, err = walletService.ListHistory(ownerID, HistoryQuery{CounterpartyEmail: "history_friend@example.com"})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"25"}, amounts(page.Postings))
+
+		page, err = walletService.ListHistory(ownerID, HistoryQuery{CounterpartyEmail: "history_nobody@example.com"})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Postings)
+	})
+
+	t.Run("ListHistory method to reject malformed cursors and cursors of the other order", func(t *testing.T) {
+		_, err := walletService.ListHistory(ownerID, HistoryQuery{Cursor: "not a cursor"})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		page, err := walletService.ListHistory(ownerID, HistoryQuery{Limit: 1})
+		assert.NoError(t, err)
+		_, err = walletService.ListHistory(ownerID, HistoryQuery{Ascending: true, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, ErrInvalidCursor)
+
+		_, err = walletService.ListHistory(ownerID, HistoryQuery{Limit: DefaultHistoryMaxLimit + 1})
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	})
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/logger"
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+)
+
+const (
+	// DefaultHoldTTL is how long a hold lasts when the authorization does not
+	// ask for a lifetime, and MaxHoldTTL the longest lifetime it may ask for.
+	DefaultHoldTTL = 7 * 24 * time.Hour
+	MaxHoldTTL     = 30 * 24 * time.Hour
+
+	// expiredHoldBatchSize is the number of expired holds ExpireHolds
+	// releases per store round trip.
+	expiredHoldBatchSize = 100
+)
+
+var (
+	ErrHoldNotFound       = errors.New("hold not found")
+	ErrHoldNotActive      = errors.New("hold is no longer active")
+	ErrCaptureExceedsHold = errors.New("capture amount exceeds the held amount")
+	ErrInvalidHoldTTL     = errors.New("invalid hold lifetime")
+)
+
+// AuthorizeHold reserves amount of the wallet walletID of the user, or of the
+// default wallet when walletID is 0, for ttl, or DefaultHoldTTL when ttl is
+// 0. The amount leaves the available balance of the wallet at once but stays
+// in its ledger balance until the hold is captured.
+func (ws *WalletService) AuthorizeHold(userID int, walletID int, amount money.Money, reference string, ttl time.Duration) (*models.Hold, error) {
+	if err := amount.Validate(); err != nil {
+		return nil, err
+	}
+	if ttl == 0 {
+		ttl = DefaultHoldTTL
+	}
+	if ttl < 0 || ttl > MaxHoldTTL {
+		return nil, fmt.Errorf("%w: must be at most %s, got %s", ErrInvalidHoldTTL, MaxHoldTTL, ttl)
+	}
+
+	var hold *models.Hold
+	err := ws.store.Transaction(func(tx repository.Store) error {
+		wallet, err := lockWallet(tx, userID, walletID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(tx, userID); err != nil {
+			return err
+		}
+		if _, err := wallet.AvailableBalance().Subtract(&amount); err != nil {
+			return err
+		}
+		wallet.HeldAmount = wallet.HeldAmount.Add(amount.Amount)
+		if _, err := tx.UpdateWallet(wallet); err != nil {
+			return fmt.Errorf("failed to hold money: %w", err)
+		}
+
+		now := time.Now()
+		hold = &models.Hold{
+			WalletID:  wallet.ID,
+			UserID:    userID,
+			Amount:    &amount,
+			Status:    models.HoldStatusActive,
+			Reference: reference,
+			ExpiresAt: now.Add(ttl),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		return tx.CreateHold(hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+func (ws *WalletService) GetHold(userID int, holdID int) (*models.Hold, error) {
+	hold, err := ws.store.GetHoldByID(holdID)
+	if err != nil || hold.UserID != userID {
+		return nil, fmt.Errorf("%w: %d", ErrHoldNotFound, holdID)
+	}
+	return hold, nil
+}
+
+// CaptureHold debits amount of an active hold from its wallet, or the whole
+// held amount when amount is nil, and releases whatever was not captured.
+// The capture counts as a withdrawal towards the limits of the user.
+func (ws *WalletService) CaptureHold(userID int, holdID int, amount *money.Money) (*models.Hold, error) {
+	var hold *models.Hold
+	err := ws.store.Transaction(func(tx repository.Store) error {
+		var wallet *models.Wallet
+		var err error
+		hold, wallet, err = lockActiveHold(tx, userID, holdID)
+		if err != nil {
+			return err
+		}
+		if err := tx.LockUsersForUpdate(userID); err != nil {
+			return err
+		}
+		if err := checkNotFrozen(tx, userID); err != nil {
+			return err
+		}
+
+		captured := *hold.Amount
+		if amount != nil {
+			if err := amount.Validate(); err != nil {
+				return err
+			}
+			if _, err := hold.Amount.Subtract(amount); err != nil {
+				if errors.Is(err, money.ErrInsufficientFunds) {
+					return fmt.Errorf("%w: cannot capture %s of %s", ErrCaptureExceedsHold, amount.Amount, hold.Amount.Amount)
+				}
+				return err
+			}
+			captured = *amount
+		}
+		if err := ws.checkLimits(tx, userID, models.TransactionTypeWithdraw, captured); err != nil {
+			return err
+		}
+
+		wallet.HeldAmount = wallet.HeldAmount.Sub(hold.Amount.Amount)
+		postings, err := debitWallet(tx, wallet, captured)
+		if err != nil {
+			return err
+		}
+
+		payout := models.NewSystemPosting(models.SystemAccountExternalPayout, models.PostingDirectionCredit, &captured)
+		entry, err := createJournalEntry(tx, models.TransactionTypeCapture, append(postings, payout))
+		if err != nil {
+			return err
+		}
+
+		hold.Status = models.HoldStatusCaptured
+		hold.CapturedAmount = captured.Amount
+		hold.JournalEntryID = entry.ID
+		return tx.UpdateHold(hold)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// VoidHold releases an active hold without debiting its wallet.
+func (ws *WalletService) VoidHold(userID int, holdID int) (*models.Hold, error) {
+	var hold *models.Hold
+	err := ws.store.Transaction(func(tx repository.Store) error {
+		var wallet *models.Wallet
+		var err error
+		hold, wallet, err = lockActiveHold(tx, userID, holdID)
+		if err != nil {
+			return err
+		}
+		return releaseHold(tx, hold, wallet, models.HoldStatusVoided)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hold, nil
+}
+
+// ExpireHolds releases every active hold that expired by now and returns how
+// many it released.
+func (ws *WalletService) ExpireHolds(now time.Time) (int, error) {
+	expired := 0
+	for {
+		holds, err := ws.store.ListExpiredHolds(now, expiredHoldBatchSize)
+		if err != nil {
+			return expired, err
+		}
+
+		for _, hold := range holds {
+			released := false
+			err := ws.store.Transaction(func(tx repository.Store) error {
+				lockedWallets, err := tx.LockWalletsForUpdate(hold.WalletID)
+				if err != nil {
+					return err
+				}
+				// A capture or void may have won the race for the wallet lock.
+				current, err := tx.GetHoldByID(hold.ID)
+				if err != nil || current.Status != models.HoldStatusActive {
+					return err
+				}
+				released = true
+				return releaseHold(tx, current, lockedWallets[hold.WalletID], models.HoldStatusExpired)
+			})
+			if err != nil {
+				return expired, fmt.Errorf("failed to expire hold %d: %w", hold.ID, err)
+			}
+			if released {
+				expired++
+			}
+		}
+
+		if len(holds) < expiredHoldBatchSize {
+			return expired, nil
+		}
+	}
+}
+
+// RunHoldExpiry releases expired holds every interval until ctx is
+// cancelled.
+func (ws *WalletService) RunHoldExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := ws.ExpireHolds(time.Now())
+			if err != nil {
+				logger.Errorf("hold expiry failed: %s", err)
+				continue
+			}
+			if expired > 0 {
+				logger.Debugf("released %d expired holds", expired)
+			}
+		}
+	}
+}
+
+// lockActiveHold locks the wallet of a hold of userID and returns both,
+// reading the hold again under the lock so that its status is current.
+func lockActiveHold(tx repository.Store, userID int, holdID int) (*models.Hold, *models.Wallet, error) {
+	hold, err := tx.GetHoldByID(holdID)
+	if err != nil || hold.UserID != userID {
+		return nil, nil, fmt.Errorf("%w: %d", ErrHoldNotFound, holdID)
+	}
+
+	lockedWallets, err := tx.LockWalletsForUpdate(hold.WalletID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hold, err = tx.GetHoldByID(holdID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hold.IsActive(time.Now()) {
+		status := hold.Status
+		if status == models.HoldStatusActive {
+			status = models.HoldStatusExpired
+		}
+		return nil, nil, fmt.Errorf("%w: hold %d is %s", ErrHoldNotActive, holdID, status)
+	}
+	return hold, lockedWallets[hold.WalletID], nil
+}
+
+func releaseHold(tx repository.Store, hold *models.Hold, wallet *models.Wallet, status models.HoldStatus) error {
+	wallet.HeldAmount = wallet.HeldAmount.Sub(hold.Amount.Amount)
+	if _, err := tx.UpdateWallet(wallet); err != nil {
+		return fmt.Errorf("failed to release hold: %w", err)
+	}
+
+	hold.Status = status
+	return tx.UpdateHold(hold)
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolds(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+	newWallet := func(t *testing.T, email string, balance float64) (int, *models.Wallet) {
+		userID, _ := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		wallet, err = walletService.AddMoneyToWallet(userID, wallet.ID, inr(balance))
+		assert.NoError(t, err)
+		return userID, wallet
+	}
+	balances := func(t *testing.T, userID int) (string, string) {
+		wallet, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		return wallet.Money.Amount.String(), wallet.AvailableBalance().Amount.String()
+	}
+
+	t.Run("AuthorizeHold method to reduce the available but not the ledger balance", func(t *testing.T) {
+		userID, wallet := newWallet(t, "holds_authorize@example.com", 100)
+
+		hold, err := walletService.AuthorizeHold(userID, wallet.ID, inr(60), "order-1", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusActive, hold.Status)
+		assert.WithinDuration(t, time.Now().Add(DefaultHoldTTL), hold.ExpiresAt, time.Minute)
+
+		ledger, available := balances(t, userID)
+		assert.Equal(t, "100", ledger)
+		assert.Equal(t, "40", available)
+
+		_, err = walletService.AuthorizeHold(userID, 0, inr(50), "", 0)
+		assert.ErrorIs(t, err, money.ErrInsufficientFunds)
+		_, err = walletService.AuthorizeHold(userID, 0, inr(1), "", MaxHoldTTL+time.Second)
+		assert.ErrorIs(t, err, ErrInvalidHoldTTL)
+	})
+
+	t.Run("WithdrawMoneyFromWallet and TransferMoney methods to leave held money alone", func(t *testing.T) {
+		userID, wallet := newWallet(t, "holds_debit@example.com", 100)
+		newWallet(t, "holds_debit_friend@example.com", 0)
+
+		_, err := walletService.AuthorizeHold(userID, wallet.ID, inr(70), "", 0)
+		assert.NoError(t, err)
+
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, inr(31))
+		assert.ErrorIs(t, err, money.ErrInsufficientFunds)
+		err = walletService.TransferMoney(userID, wallet.ID, "holds_debit_friend@example.com", 0, inr(31))
+		assert.ErrorIs(t, err, money.ErrInsufficientFunds)
+
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, inr(30))
+		assert.NoError(t, err)
+		ledger, available := balances(t, userID)
+		assert.Equal(t, "70", ledger)
+		assert.Equal(t, "0", available)
+	})
+
+	t.Run("CaptureHold method to debit part of a hold and release the rest", func(t *testing.T) {
+		userID, wallet := newWallet(t, "holds_capture@example.com", 100)
+
+		hold, err := walletService.AuthorizeHold(userID, wallet.ID, inr(60), "", 0)
+		assert.NoError(t, err)
+
+		over := inr(61)
+		_, err = walletService.CaptureHold(userID, hold.ID, &over)
+		assert.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+		partial := inr(45)
+		captured, err := walletService.CaptureHold(userID, hold.ID, &partial)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusCaptured, captured.Status)
+		assert.True(t, captured.CapturedAmount.Equal(decimal.NewFromInt(45)))
+
+		ledger, available := balances(t, userID)
+		assert.Equal(t, "55", ledger)
+		assert.Equal(t, "55", available)
+
+		entry, err := db.GetJournalEntryByID(captured.JournalEntryID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeCapture), entry.TransactionType)
+
+		_, err = walletService.CaptureHold(userID, hold.ID, nil)
+		assert.ErrorIs(t, err, ErrHoldNotActive)
+	})
+
+	t.Run("VoidHold method to release a hold without a debit", func(t *testing.T) {
+		userID, wallet := newWallet(t, "holds_void@example.com", 100)
+		otherID, _ := newWallet(t, "holds_void_other@example.com", 0)
+
+		hold, err := walletService.AuthorizeHold(userID, wallet.ID, inr(25), "", 0)
+		assert.NoError(t, err)
+
+		_, err = walletService.VoidHold(otherID, hold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotFound)
+
+		voided, err := walletService.VoidHold(userID, hold.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusVoided, voided.Status)
+
+		ledger, available := balances(t, userID)
+		assert.Equal(t, "100", ledger)
+		assert.Equal(t, "100", available)
+
+		_, err = walletService.VoidHold(userID, hold.ID)
+		assert.ErrorIs(t, err, ErrHoldNotActive)
+	})
+
+	t.Run("ExpireHolds method to release holds past their expiry", func(t *testing.T) {
+		userID, wallet := newWallet(t, "holds_expire@example.com", 100)
+
+		stale, err := walletService.AuthorizeHold(userID, wallet.ID, inr(30), "", time.Hour)
+		assert.NoError(t, err)
+		fresh, err := walletService.AuthorizeHold(userID, wallet.ID, inr(20), "", 2*time.Hour)
+		assert.NoError(t, err)
+
+		expired, err := walletService.ExpireHolds(time.Now().Add(90 * time.Minute))
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, expired, 1)
+
+		stale, err = walletService.GetHold(userID, stale.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusExpired, stale.Status)
+		fresh, err = walletService.GetHold(userID, fresh.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.HoldStatusActive, fresh.Status)
+
+		ledger, available := balances(t, userID)
+		assert.Equal(t, "100", ledger)
+		assert.Equal(t, "80", available)
+	})
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"nikwallet/logger"
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"time"
+)
+
+const DefaultIdempotencyKeyRetention = 24 * time.Hour
+
+var (
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still in progress")
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request")
+)
+
+type IdempotencyService struct {
+	store     repository.Store
+	retention time.Duration
+}
+
+func NewIdempotencyService(store repository.Store, retention time.Duration) *IdempotencyService {
+	if retention <= 0 {
+		retention = DefaultIdempotencyKeyRetention
+	}
+	return &IdempotencyService{store: store, retention: retention}
+}
+
+// IdempotentResponse is the response stored for a completed key.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Begin claims key for userID. When the key was already completed for the
+// same fingerprint it returns the stored key with replay set, so the caller
+// can send back the original response instead of running the request again.
+// A key stays in progress until it is completed, released or expired.
+func (is *IdempotencyService) Begin(userID int, key string, fingerprint string) (idempotencyKey *models.IdempotencyKey, replay bool, err error) {
+	now := time.Now()
+	newKey := &models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Fingerprint: fingerprint,
+		State:       models.IdempotencyKeyStateInProgress,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(is.retention),
+	}
+
+	err = is.store.CreateIdempotencyKey(newKey)
+	if err == nil {
+		return newKey, false, nil
+	}
+	if !errors.Is(err, repository.ErrIdempotencyKeyExists) {
+		return nil, false, err
+	}
+
+	existing, err := is.store.GetIdempotencyKey(userID, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !existing.ExpiresAt.After(now) {
+		return is.replace(existing, newKey)
+	}
+
+	if existing.Fingerprint != fingerprint {
+		return nil, false, ErrIdempotencyKeyMismatch
+	}
+
+	if existing.State != models.IdempotencyKeyStateCompleted {
+		return nil, false, ErrIdempotencyKeyInProgress
+	}
+
+	return existing, true, nil
+}
+
+// replace swaps the expired key existing for newKey. When another request
+// replaces it first, that request owns the key and this one is told it is in
+// progress.
+func (is *IdempotencyService) replace(existing *models.IdempotencyKey, newKey *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+	if err := is.store.DeleteIdempotencyKey(existing.ID); err != nil {
+		return nil, false, err
+	}
+	if err := is.store.CreateIdempotencyKey(newKey); err != nil {
+		if errors.Is(err, repository.ErrIdempotencyKeyExists) {
+			return nil, false, ErrIdempotencyKeyInProgress
+		}
+		return nil, false, err
+	}
+	return newKey, false, nil
+}
+
+type transactionContextKey struct{}
+
+// Run runs fn in a transaction and completes idempotencyKey with the response
+// fn returns in that same transaction, so the stored response commits if and
+// only if the changes fn made do. Services pick the transaction up from the
+// context fn is given. When the transaction rolls back the key is released
+// and the client can retry. When only the commit fails the outcome is
+// unknown, so the key stays in progress until it expires.
+func (is *IdempotencyService) Run(ctx context.Context, idempotencyKey *models.IdempotencyKey, fn func(ctx context.Context) (*IdempotentResponse, error)) error {
+	rolledBack := false
+	err := is.store.Transaction(func(tx repository.Store) error {
+		rolledBack = true
+		response, err := fn(context.WithValue(ctx, transactionContextKey{}, tx))
+		if err != nil {
+			return err
+		}
+
+		completed := *idempotencyKey
+		completed.State = models.IdempotencyKeyStateCompleted
+		completed.ResponseStatus = response.Status
+		completed.ResponseContentType = response.ContentType
+		completed.ResponseBody = response.Body
+		if err := tx.UpdateIdempotencyKey(&completed); err != nil {
+			return err
+		}
+		rolledBack = false
+		return nil
+	})
+	if err != nil && rolledBack {
+		if releaseErr := is.Release(idempotencyKey); releaseErr != nil {
+			logger.Errorf("failed to release idempotency key: %s", releaseErr)
+		}
+	}
+	return err
+}
+
+// Release forgets an in-progress key so that the client can retry a request
+// that failed before it produced a response worth replaying.
+func (is *IdempotencyService) Release(idempotencyKey *models.IdempotencyKey) error {
+	return is.store.DeleteIdempotencyKey(idempotencyKey.ID)
+}
+
+func (is *IdempotencyService) DeleteExpired() (int64, error) {
+	return is.store.DeleteExpiredIdempotencyKeys(time.Now())
+}
+
+// RunCleanup deletes expired idempotency keys every interval until ctx is
+// cancelled.
+func (is *IdempotencyService) RunCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := is.DeleteExpired()
+			if err != nil {
+				logger.Errorf("idempotency key cleanup failed: %s", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Debugf("deleted %d expired idempotency keys", deleted)
+			}
+		}
+	}
+}
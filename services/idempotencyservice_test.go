@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyService(t *testing.T) {
+	idempotencyService := NewIdempotencyService(db, time.Hour)
+	complete := func(is *IdempotencyService, key *models.IdempotencyKey, status int, contentType string, body []byte) error {
+		return is.Run(context.Background(), key, func(ctx context.Context) (*IdempotentResponse, error) {
+			return &IdempotentResponse{Status: status, ContentType: contentType, Body: body}, nil
+		})
+	}
+
+	t.Run("Begin method to claim a new key and replay it once completed", func(t *testing.T) {
+		key, replay, err := idempotencyService.Begin(1, "begin-complete", "fingerprint")
+		assert.NoError(t, err)
+		assert.False(t, replay)
+		assert.Equal(t, models.IdempotencyKeyStateInProgress, key.State)
+
+		err = complete(idempotencyService, key, 200, "application/json", []byte(`{"message":"ok"}`))
+		assert.NoError(t, err)
+
+		stored, replay, err := idempotencyService.Begin(1, "begin-complete", "fingerprint")
+		assert.NoError(t, err)
+		assert.True(t, replay)
+		assert.Equal(t, 200, stored.ResponseStatus)
+		assert.Equal(t, `{"message":"ok"}`, string(stored.ResponseBody))
+	})
+
+	t.Run("Begin method to return ErrIdempotencyKeyInProgress for an in-flight duplicate", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin(1, "in-flight", "fingerprint")
+		assert.NoError(t, err)
+
+		_, _, err = idempotencyService.Begin(1, "in-flight", "fingerprint")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyInProgress)
+	})
+
+	t.Run("Begin method to return ErrIdempotencyKeyMismatch for a different fingerprint", func(t *testing.T) {
+		key, _, _ := idempotencyService.Begin(1, "mismatch", "fingerprint")
+		complete(idempotencyService, key, 200, "", nil)
+
+		_, _, err := idempotencyService.Begin(1, "mismatch", "other fingerprint")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyMismatch)
+	})
+
+	t.Run("Begin method to scope keys per user", func(t *testing.T) {
+		_, _, err := idempotencyService.Begin(1, "shared-key", "fingerprint")
+		assert.NoError(t, err)
+
+		_, replay, err := idempotencyService.Begin(2, "shared-key", "other fingerprint")
+		assert.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("Release method to allow the key to be claimed again", func(t *testing.T) {
+		key, _, _ := idempotencyService.Begin(1, "released", "fingerprint")
+		assert.NoError(t, idempotencyService.Release(key))
+
+		_, replay, err := idempotencyService.Begin(1, "released", "fingerprint")
+		assert.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("DeleteExpired method to remove keys past the retention window", func(t *testing.T) {
+		shortLived := NewIdempotencyService(db, time.Millisecond)
+		key, _, _ := shortLived.Begin(3, "expiring", "fingerprint")
+		complete(shortLived, key, 200, "", nil)
+
+		time.Sleep(5 * time.Millisecond)
+
+		deleted, err := shortLived.DeleteExpired()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+
+		_, err = db.GetIdempotencyKey(3, "expiring")
+		assert.Error(t, err)
+	})
+
+	t.Run("Begin method to treat an expired key as new", func(t *testing.T) {
+		shortLived := NewIdempotencyService(db, time.Millisecond)
+		key, _, _ := shortLived.Begin(4, "expired-reuse", "fingerprint")
+		complete(shortLived, key, 200, "", nil)
+
+		time.Sleep(5 * time.Millisecond)
+
+		_, replay, err := shortLived.Begin(4, "expired-reuse", "other fingerprint")
+		assert.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("Begin method to keep a key in progress until it expires", func(t *testing.T) {
+		shortLived := NewIdempotencyService(db, 20*time.Millisecond)
+		_, _, err := shortLived.Begin(5, "abandoned", "fingerprint")
+		assert.NoError(t, err)
+
+		_, _, err = shortLived.Begin(5, "abandoned", "fingerprint")
+		assert.ErrorIs(t, err, ErrIdempotencyKeyInProgress)
+
+		time.Sleep(30 * time.Millisecond)
+
+		_, replay, err := shortLived.Begin(5, "abandoned", "fingerprint")
+		assert.NoError(t, err)
+		assert.False(t, replay)
+	})
+
+	t.Run("Run method to commit the response together with the changes of fn", func(t *testing.T) {
+		walletService := &WalletService{store: db, rates: DefaultStaticRateProvider(), historyMaxLimit: DefaultHistoryMaxLimit}
+		userID, _ := db.CreateUser(&models.User{EmailID: "idempotency_run@example.com", Password: "password"})
+		wallet, err := walletService.CreateWallet(userID, money.INR)
+		assert.NoError(t, err)
+		ten := money.Money{Amount: decimal.NewFromInt(10), Currency: money.INR}
+
+		key, _, err := idempotencyService.Begin(userID, "run-commit", "fingerprint")
+		assert.NoError(t, err)
+		err = idempotencyService.Run(context.Background(), key, func(ctx context.Context) (*IdempotentResponse, error) {
+			_, err := walletService.In(ctx).AddMoneyToWallet(userID, wallet.ID, ten)
+			return &IdempotentResponse{Status: 200}, err
+		})
+		assert.NoError(t, err)
+		stored, replay, err := idempotencyService.Begin(userID, "run-commit", "fingerprint")
+		assert.NoError(t, err)
+		assert.True(t, replay)
+		assert.Equal(t, 200, stored.ResponseStatus)
+
+		errBoom := errors.New("boom")
+		key, _, err = idempotencyService.Begin(userID, "run-rollback", "fingerprint")
+		assert.NoError(t, err)
+		err = idempotencyService.Run(context.Background(), key, func(ctx context.Context) (*IdempotentResponse, error) {
+			if _, err := walletService.In(ctx).AddMoneyToWallet(userID, wallet.ID, ten); err != nil {
+				return nil, err
+			}
+			return nil, errBoom
+		})
+		assert.ErrorIs(t, err, errBoom)
+		_, err = db.GetIdempotencyKey(userID, "run-rollback")
+		assert.Error(t, err)
+
+		updated, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, "10", updated.Money.Amount.String())
+	})
+}
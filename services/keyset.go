@@ -0,0 +1,244 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+var ErrUnknownSigningKey = errors.New("unknown signing key")
+
+// SigningKey is one entry of a KeySet. Keys without a private half can only
+// verify tokens, which is how a retired key stays trusted until the tokens
+// it signed have expired.
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+func NewSigningKey(id string, key interface{}) (*SigningKey, error) {
+	signingKey := &SigningKey{ID: id}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signingKey.PrivateKey = k
+		signingKey.PublicKey = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		signingKey.PrivateKey = k
+		signingKey.PublicKey = &k.PublicKey
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		signingKey.PublicKey = k
+	default:
+		return nil, fmt.Errorf("key %s has unsupported type %T", id, key)
+	}
+
+	switch pub := signingKey.PublicKey.(type) {
+	case *rsa.PublicKey:
+		signingKey.Method = jwt.SigningMethodRS256
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			signingKey.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			signingKey.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			signingKey.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("key %s uses an unsupported curve", id)
+		}
+	}
+
+	return signingKey, nil
+}
+
+// ParseSigningKeyPEM accepts PKCS#1, SEC 1 and PKCS#8 private keys and
+// PKIX or PKCS#1 public keys.
+func ParseSigningKeyPEM(id string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("key %s is not PEM encoded", id)
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("key %s has unsupported PEM type %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %s: %w", id, err)
+	}
+
+	return NewSigningKey(id, key)
+}
+
+type KeySet struct {
+	activeKeyID string
+	keys        map[string]*SigningKey
+}
+
+// NewKeySet signs new tokens with the key named activeKeyID and verifies
+// tokens signed by any of keys.
+func NewKeySet(activeKeyID string, keys ...*SigningKey) (*KeySet, error) {
+	keySet := &KeySet{activeKeyID: activeKeyID, keys: map[string]*SigningKey{}}
+
+	for _, key := range keys {
+		if _, ok := keySet.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key ID: %s", key.ID)
+		}
+		keySet.keys[key.ID] = key
+	}
+
+	active, ok := keySet.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: active key %q is not configured", ErrUnknownSigningKey, activeKeyID)
+	}
+	if active.PrivateKey == nil {
+		return nil, fmt.Errorf("active key %s has no private key", activeKeyID)
+	}
+
+	return keySet, nil
+}
+
+// NewEphemeralKeySet generates a throwaway ES256 key. Tokens signed with it
+// stop verifying once the process exits.
+func NewEphemeralKeySet() (*KeySet, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	signingKey, err := NewSigningKey("ephemeral", privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySet(signingKey.ID, signingKey)
+}
+
+// LoadKeySet reads keys from a comma separated list of id=source pairs,
+// where source is either a path to a PEM file or env:NAME to read the PEM
+// from an environment variable.
+func LoadKeySet(activeKeyID string, sources string) (*KeySet, error) {
+	keys := []*SigningKey{}
+
+	for _, entry := range strings.Split(sources, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, source, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || source == "" {
+			return nil, fmt.Errorf("invalid signing key entry %q, want id=path or id=env:NAME", entry)
+		}
+
+		var data []byte
+		if name, fromEnv := strings.CutPrefix(source, "env:"); fromEnv {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				return nil, fmt.Errorf("signing key %s: environment variable %s is not set", id, name)
+			}
+			data = []byte(value)
+		} else {
+			var err error
+			data, err = os.ReadFile(source)
+			if err != nil {
+				return nil, fmt.Errorf("signing key %s: %w", id, err)
+			}
+		}
+
+		key, err := ParseSigningKeyPEM(id, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return NewKeySet(activeKeyID, keys...)
+}
+
+func (ks *KeySet) Active() *SigningKey {
+	return ks.keys[ks.activeKeyID]
+}
+
+func (ks *KeySet) Get(id string) (*SigningKey, error) {
+	key, ok := ks.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSigningKey, id)
+	}
+	return key, nil
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS publishes the public half of every key in the set, as described in
+// RFC 7517, ordered by key ID.
+func (ks *KeySet) JWKS() JSONWebKeySet {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JSONWebKey{Use: "sig", KeyID: key.ID, Algorithm: key.Method.Alg()}
+
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.KeyType = "EC"
+			jwk.Curve = pub.Curve.Params().Name
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, size)))
+			jwk.Y = base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, size)))
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+)
+
+// DefaultKYCQueueLimit caps how many submissions a single review queue
+// request returns.
+const DefaultKYCQueueLimit = 50
+
+var (
+	ErrKYCSubmissionNotFound = errors.New("kyc submission not found")
+	ErrKYCSubmissionPending  = errors.New("a kyc submission is already pending review")
+	ErrKYCSubmissionReviewed = errors.New("kyc submission was already reviewed")
+	ErrKYCSelfReview         = errors.New("reviewers cannot review their own kyc submission")
+	ErrInvalidKYCLevel       = errors.New("invalid kyc level")
+)
+
+type KYCService struct {
+	store repository.Store
+}
+
+func NewKYCService(store repository.Store) *KYCService {
+	return &KYCService{store: store}
+}
+
+// KYCStatus is the verification level of a user with their submissions,
+// oldest first.
+type KYCStatus struct {
+	Level       models.KYCLevel
+	Submissions []*models.KYCSubmission
+}
+
+// Submit asks for the user to be verified at submission.Level, which must be
+// above their current level. A user can have one submission pending review
+// at a time. Only the last four characters of documentNumber are kept.
+func (ks *KYCService) Submit(userID int, submission *models.KYCSubmission, documentNumber string) error {
+	if !submission.Level.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidKYCLevel, submission.Level)
+	}
+	documentNumber = strings.TrimSpace(documentNumber)
+	if len(documentNumber) > 4 {
+		documentNumber = documentNumber[len(documentNumber)-4:]
+	}
+
+	return ks.store.Transaction(func(tx repository.Store) error {
+		user, err := tx.GetUserByID(userID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+		}
+		if submission.Level.Rank() <= user.KYCLevel.Rank() {
+			return fmt.Errorf("%w: user is already verified at %s, cannot ask for %q", ErrInvalidKYCLevel, user.KYCLevel, submission.Level)
+		}
+
+		pending, err := tx.ListKYCSubmissions(repository.KYCSubmissionQuery{UserID: userID, Status: models.KYCSubmissionPending, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w: submission %d", ErrKYCSubmissionPending, pending[0].ID)
+		}
+
+		submission.UserID = userID
+		submission.DocumentNumberLast4 = documentNumber
+		submission.Status = models.KYCSubmissionPending
+		submission.CreatedAt = time.Now()
+		err = tx.CreateKYCSubmission(submission)
+		if errors.Is(err, repository.ErrKYCSubmissionPending) {
+			return fmt.Errorf("%w: submitted concurrently", ErrKYCSubmissionPending)
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.CreateKYCEvent(&models.KYCEvent{
+			UserID:       userID,
+			SubmissionID: submission.ID,
+			ActorID:      userID,
+			Action:       models.KYCActionSubmitted,
+			FromLevel:    user.KYCLevel,
+			ToLevel:      user.KYCLevel,
+			CreatedAt:    submission.CreatedAt,
+		})
+	})
+}
+
+func (ks *KYCService) GetStatus(userID int) (*KYCStatus, error) {
+	user, err := ks.store.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	submissions, err := ks.store.ListKYCSubmissions(repository.KYCSubmissionQuery{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return &KYCStatus{Level: user.KYCLevel, Submissions: submissions}, nil
+}
+
+// ListPending returns up to limit submissions waiting for review behind
+// afterID, oldest first.
+func (ks *KYCService) ListPending(afterID int, limit int) ([]*models.KYCSubmission, error) {
+	if limit < 1 || limit > DefaultKYCQueueLimit {
+		return nil, fmt.Errorf("%w: must be between 1 and %d, got %d", ErrInvalidLimit, DefaultKYCQueueLimit, limit)
+	}
+	return ks.store.ListKYCSubmissions(repository.KYCSubmissionQuery{
+		Status:  models.KYCSubmissionPending,
+		AfterID: afterID,
+		Limit:   limit,
+	})
+}
+
+// Approve verifies the user of a pending submission at the level it asked
+// for.
+func (ks *KYCService) Approve(reviewerID int, submissionID int, note string) (*models.KYCSubmission, error) {
+	return ks.review(reviewerID, submissionID, models.KYCSubmissionApproved, note)
+}
+
+// Reject turns down a pending submission for reason, leaving the level of
+// the user as it was.
+func (ks *KYCService) Reject(reviewerID int, submissionID int, reason string) (*models.KYCSubmission, error) {
+	return ks.review(reviewerID, submissionID, models.KYCSubmissionRejected, reason)
+}
+
+// ListEvents returns the audit trail of the verification of the user, oldest
+// first.
+func (ks *KYCService) ListEvents(userID int) ([]*models.KYCEvent, error) {
+	if _, err := ks.store.GetUserByID(userID); err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	return ks.store.ListKYCEvents(userID)
+}
+
+func (ks *KYCService) review(reviewerID int, submissionID int, status models.KYCSubmissionStatus, note string) (*models.KYCSubmission, error) {
+	var submission *models.KYCSubmission
+	err := ks.store.Transaction(func(tx repository.Store) error {
+		var err error
+		submission, err = tx.GetKYCSubmissionByID(submissionID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrKYCSubmissionNotFound, submissionID)
+		}
+		if submission.UserID == reviewerID {
+			return ErrKYCSelfReview
+		}
+
+		user, err := tx.GetUserByID(submission.UserID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		submission.Status = status
+		submission.ReviewerID = reviewerID
+		submission.ReviewNote = note
+		submission.ReviewedAt = &now
+		// Two reviewers may decide on the same submission at once; only the
+		// first decision is kept.
+		reviewed, err := tx.MarkKYCSubmissionReviewed(submission)
+		if err != nil {
+			return err
+		}
+		if !reviewed {
+			return fmt.Errorf("%w: %d", ErrKYCSubmissionReviewed, submissionID)
+		}
+
+		event := &models.KYCEvent{
+			UserID:       submission.UserID,
+			SubmissionID: submission.ID,
+			ActorID:      reviewerID,
+			Action:       models.KYCActionRejected,
+			FromLevel:    user.KYCLevel,
+			ToLevel:      user.KYCLevel,
+			Note:         note,
+			CreatedAt:    now,
+		}
+		if status == models.KYCSubmissionApproved {
+			event.Action = models.KYCActionApproved
+			// Approving never lowers the level of the user.
+			if submission.Level.Rank() > user.KYCLevel.Rank() {
+				event.ToLevel = submission.Level
+				if err := tx.UpdateUserKYCLevel(submission.UserID, submission.Level); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.CreateKYCEvent(event)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
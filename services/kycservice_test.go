@@ -0,0 +1,91 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKYCService(t *testing.T) {
+	kycService := NewKYCService(db)
+	newUser := func(email string) int {
+		userID, _ := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		return userID
+	}
+	submit := func(t *testing.T, userID int, level models.KYCLevel) *models.KYCSubmission {
+		submission := &models.KYCSubmission{Level: level, DocumentType: "passport", IssuingCountry: "IN"}
+		assert.NoError(t, kycService.Submit(userID, submission, "Z1234 5678"))
+		return submission
+	}
+	reviewerID := newUser("kyc_reviewer@example.com")
+
+	t.Run("Submit method to keep only the last four characters of the document number", func(t *testing.T) {
+		userID := newUser("kyc_submit@example.com")
+		submission := submit(t, userID, models.KYCLevelMinimal)
+
+		assert.Equal(t, "5678", submission.DocumentNumberLast4)
+		assert.Equal(t, models.KYCSubmissionPending, submission.Status)
+
+		err := kycService.Submit(userID, &models.KYCSubmission{Level: models.KYCLevelFull}, "12345678")
+		assert.ErrorIs(t, err, ErrKYCSubmissionPending)
+		err = kycService.Submit(newUser("kyc_submit_level@example.com"), &models.KYCSubmission{Level: models.KYCLevelUnverified}, "12345678")
+		assert.ErrorIs(t, err, ErrInvalidKYCLevel)
+		err = kycService.Submit(userID, &models.KYCSubmission{Level: "gold"}, "12345678")
+		assert.ErrorIs(t, err, ErrInvalidKYCLevel)
+	})
+
+	t.Run("Approve method to raise the level of the user once and record it", func(t *testing.T) {
+		userID := newUser("kyc_approve@example.com")
+		submission := submit(t, userID, models.KYCLevelFull)
+
+		_, err := kycService.Approve(userID, submission.ID, "")
+		assert.ErrorIs(t, err, ErrKYCSelfReview)
+
+		approved, err := kycService.Approve(reviewerID, submission.ID, "documents match")
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCSubmissionApproved, approved.Status)
+		assert.Equal(t, reviewerID, approved.ReviewerID)
+
+		_, err = kycService.Reject(reviewerID, submission.ID, "too late")
+		assert.ErrorIs(t, err, ErrKYCSubmissionReviewed)
+
+		status, err := kycService.GetStatus(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelFull, status.Level)
+		assert.Len(t, status.Submissions, 1)
+
+		events, err := kycService.ListEvents(userID)
+		assert.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.Equal(t, models.KYCActionSubmitted, events[0].Action)
+		assert.Equal(t, userID, events[0].ActorID)
+		assert.Equal(t, models.KYCActionApproved, events[1].Action)
+		assert.Equal(t, reviewerID, events[1].ActorID)
+		assert.Equal(t, models.KYCLevelUnverified, events[1].FromLevel)
+		assert.Equal(t, models.KYCLevelFull, events[1].ToLevel)
+	})
+
+	t.Run("Reject method to leave the level as it was and allow a new submission", func(t *testing.T) {
+		userID := newUser("kyc_reject@example.com")
+		submission := submit(t, userID, models.KYCLevelMinimal)
+
+		pending, err := kycService.ListPending(submission.ID-1, DefaultKYCQueueLimit)
+		assert.NoError(t, err)
+		assert.Equal(t, submission.ID, pending[0].ID)
+
+		rejected, err := kycService.Reject(reviewerID, submission.ID, "document expired")
+		assert.NoError(t, err)
+		assert.Equal(t, "document expired", rejected.ReviewNote)
+
+		status, err := kycService.GetStatus(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelUnverified, status.Level)
+		submit(t, userID, models.KYCLevelMinimal)
+
+		_, err = kycService.ListPending(0, DefaultKYCQueueLimit+1)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+		_, err = kycService.Approve(reviewerID, 1<<30, "")
+		assert.ErrorIs(t, err, ErrKYCSubmissionNotFound)
+	})
+}
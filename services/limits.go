@@ -0,0 +1,422 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrLimitExceeded           = errors.New("transaction limit exceeded")
+	ErrInvalidTransactionLimit = errors.New("invalid transaction limit")
+	ErrBalanceCapExceeded      = errors.New("balance cap of the kyc tier exceeded")
+)
+
+// LimitedTransactionTypes are the transaction types that limits apply to.
+// Captures of holds pay money out like withdrawals and count towards the
+// withdraw limits.
+var LimitedTransactionTypes = []models.TransactionType{
+	models.TransactionTypeWithdraw,
+	models.TransactionTypeTransfer,
+}
+
+// countedTypes returns the transaction types whose debits count towards the
+// limits of transactionType.
+func countedTypes(transactionType models.TransactionType) []models.TransactionType {
+	if transactionType == models.TransactionTypeWithdraw {
+		return []models.TransactionType{models.TransactionTypeWithdraw, models.TransactionTypeCapture}
+	}
+	return []models.TransactionType{transactionType}
+}
+
+// TransactionLimits bounds the debits of one transaction type. A zero field
+// is not enforced.
+type TransactionLimits struct {
+	MinAmount     decimal.Decimal
+	MaxAmount     decimal.Decimal
+	DailyAmount   decimal.Decimal
+	MonthlyAmount decimal.Decimal
+	MaxPerHour    int
+}
+
+// KYCTier caps the total balance of the wallets of a user at a KYC level and
+// tightens the limits of every limited transaction type for them.
+type KYCTier struct {
+	MaxBalance decimal.Decimal
+	Limits     TransactionLimits
+}
+
+// LimitPolicy holds the limits of users without an override. Amounts are in
+// Currency; debits in another currency are converted at the live rate before
+// they are compared. The tier of the KYC level of a user applies on top of
+// Limits, and an override of the user on top of both.
+type LimitPolicy struct {
+	Currency money.Currency
+	Limits   map[models.TransactionType]TransactionLimits
+	Tiers    map[models.KYCLevel]KYCTier
+}
+
+// LimitSummary is what the user can still do within their limits. Balance is
+// the total of their wallets in Currency; a zero MaxBalance is not enforced.
+type LimitSummary struct {
+	KYCLevel   models.KYCLevel
+	Currency   money.Currency
+	Balance    decimal.Decimal
+	MaxBalance decimal.Decimal
+	Statuses   []*LimitStatus
+}
+
+// LimitStatus is how much of the limits of a transaction type the user has
+// used. Days and months are calendar days and months in UTC; the hourly count
+// is over the last hour.
+type LimitStatus struct {
+	TransactionType models.TransactionType
+	Currency        money.Currency
+	Limits          TransactionLimits
+	UsedToday       decimal.Decimal
+	UsedThisMonth   decimal.Decimal
+	CountLastHour   int
+	DayResetsAt     time.Time
+	MonthResetsAt   time.Time
+}
+
+// tightenedBy returns the stricter of each limit of l and other.
+func (l TransactionLimits) tightenedBy(other TransactionLimits) TransactionLimits {
+	lower := func(a, b decimal.Decimal) decimal.Decimal {
+		if !b.IsPositive() || (a.IsPositive() && a.LessThan(b)) {
+			return a
+		}
+		return b
+	}
+	if other.MinAmount.GreaterThan(l.MinAmount) {
+		l.MinAmount = other.MinAmount
+	}
+	l.MaxAmount = lower(l.MaxAmount, other.MaxAmount)
+	l.DailyAmount = lower(l.DailyAmount, other.DailyAmount)
+	l.MonthlyAmount = lower(l.MonthlyAmount, other.MonthlyAmount)
+	if other.MaxPerHour > 0 && (l.MaxPerHour == 0 || other.MaxPerHour < l.MaxPerHour) {
+		l.MaxPerHour = other.MaxPerHour
+	}
+	return l
+}
+
+// RemainingToday is what the user can still debit today, or nil when there
+// is no daily limit.
+func (s *LimitStatus) RemainingToday() *decimal.Decimal {
+	return remainingAllowance(s.Limits.DailyAmount, s.UsedToday)
+}
+
+// RemainingThisMonth is what the user can still debit this month, or nil when
+// there is no monthly limit.
+func (s *LimitStatus) RemainingThisMonth() *decimal.Decimal {
+	return remainingAllowance(s.Limits.MonthlyAmount, s.UsedThisMonth)
+}
+
+// RemainingThisHour is how many more debits the user can make within the
+// hour, or -1 when their number is not limited.
+func (s *LimitStatus) RemainingThisHour() int {
+	if s.Limits.MaxPerHour == 0 {
+		return -1
+	}
+	if s.CountLastHour >= s.Limits.MaxPerHour {
+		return 0
+	}
+	return s.Limits.MaxPerHour - s.CountLastHour
+}
+
+// GetLimits returns the balance cap of the user and the status of every
+// limited transaction type.
+func (ws *WalletService) GetLimits(userID int) (*LimitSummary, error) {
+	user, err := ws.store.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+
+	balance, err := ws.totalBalance(ws.store, userID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &LimitSummary{
+		KYCLevel:   user.KYCLevel,
+		Currency:   ws.limitCurrency(),
+		Balance:    balance,
+		MaxBalance: ws.limits.Tiers[user.KYCLevel].MaxBalance,
+		Statuses:   make([]*LimitStatus, 0, len(LimitedTransactionTypes)),
+	}
+	for _, transactionType := range LimitedTransactionTypes {
+		status, err := ws.limitStatus(ws.store, userID, transactionType, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		summary.Statuses = append(summary.Statuses, status)
+	}
+	return summary, nil
+}
+
+// SetUserLimit overrides the global limits of a transaction type for the
+// user. A null field of the override keeps the global limit.
+func (ws *WalletService) SetUserLimit(limit *models.UserLimit) error {
+	if !isLimited(models.TransactionType(limit.TransactionType)) {
+		return fmt.Errorf("%w: %q transactions are not limited", ErrInvalidTransactionLimit, limit.TransactionType)
+	}
+	for _, amount := range []decimal.NullDecimal{limit.MinAmount, limit.MaxAmount, limit.DailyAmount, limit.MonthlyAmount} {
+		if amount.Valid && amount.Decimal.IsNegative() {
+			return fmt.Errorf("%w: amounts cannot be negative", ErrInvalidTransactionLimit)
+		}
+	}
+	if limit.MaxPerHour != nil && *limit.MaxPerHour < 0 {
+		return fmt.Errorf("%w: max per hour cannot be negative", ErrInvalidTransactionLimit)
+	}
+	if _, err := ws.store.GetUserByID(limit.UserID); err != nil {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, limit.UserID)
+	}
+
+	limit.UpdatedAt = time.Now()
+	return ws.store.SaveUserLimit(limit)
+}
+
+// checkLimits fails with ErrLimitExceeded when debiting amount as a
+// transaction of transactionType would break a limit of the user. The limits
+// span every wallet of the user, so it runs inside the transaction of the
+// debit after the user is locked with LockUsersForUpdate; the lock on the
+// debited wallet alone lets debits from other wallets pass at the same time.
+func (ws *WalletService) checkLimits(tx repository.Store, userID int, transactionType models.TransactionType, amount money.Money) error {
+	if !isLimited(transactionType) {
+		return nil
+	}
+
+	status, err := ws.limitStatus(tx, userID, transactionType, time.Now())
+	if err != nil {
+		return err
+	}
+	limits := status.Limits
+
+	value, err := ws.limitValue(amount)
+	if err != nil {
+		return err
+	}
+	exceeded := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %s of %s %s %s", ErrLimitExceeded, transactionType, value, status.Currency, fmt.Sprintf(format, args...))
+	}
+
+	if limits.MinAmount.IsPositive() && value.LessThan(limits.MinAmount) {
+		return exceeded("is below the minimum of %s", limits.MinAmount)
+	}
+	if limits.MaxAmount.IsPositive() && value.GreaterThan(limits.MaxAmount) {
+		return exceeded("is above the maximum of %s", limits.MaxAmount)
+	}
+	if remaining := status.RemainingToday(); remaining != nil && value.GreaterThan(*remaining) {
+		return exceeded("exceeds the %s left of the daily limit", remaining)
+	}
+	if remaining := status.RemainingThisMonth(); remaining != nil && value.GreaterThan(*remaining) {
+		return exceeded("exceeds the %s left of the monthly limit", remaining)
+	}
+	if status.RemainingThisHour() == 0 {
+		return exceeded("exceeds the limit of %d per hour", limits.MaxPerHour)
+	}
+	return nil
+}
+
+// checkBalanceCap fails with ErrBalanceCapExceeded when crediting amount to
+// a wallet of the user would take the total of their wallets past the
+// balance cap of their KYC tier. Like checkLimits, it runs after the user is
+// locked with LockUsersForUpdate.
+func (ws *WalletService) checkBalanceCap(tx repository.Store, userID int, amount money.Money) error {
+	user, err := tx.GetUserByID(userID)
+	if err != nil {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	maxBalance := ws.limits.Tiers[user.KYCLevel].MaxBalance
+	if !maxBalance.IsPositive() {
+		return nil
+	}
+
+	balance, err := ws.totalBalance(tx, userID)
+	if err != nil {
+		return err
+	}
+	value, err := ws.limitValue(amount)
+	if err != nil {
+		return err
+	}
+	if balance.Add(value).GreaterThan(maxBalance) {
+		return fmt.Errorf("%w: the %s tier allows at most %s %s", ErrBalanceCapExceeded, user.KYCLevel, maxBalance, ws.limitCurrency())
+	}
+	return nil
+}
+
+// totalBalance sums the ledger balances of the wallets of the user in the
+// limit currency.
+func (ws *WalletService) totalBalance(store repository.Store, userID int) (decimal.Decimal, error) {
+	wallets, err := store.ListWalletsByUserID(userID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	total := decimal.Zero
+	for _, wallet := range wallets {
+		value, err := ws.limitValue(*wallet.Money)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(value)
+	}
+	return total, nil
+}
+
+// limitStatus sums the debits of the user that count towards the limits of
+// transactionType at now. Only the sums of enforced limits are computed.
+func (ws *WalletService) limitStatus(store repository.Store, userID int, transactionType models.TransactionType, now time.Time) (*LimitStatus, error) {
+	limits, err := ws.userLimits(store, userID, transactionType)
+	if err != nil {
+		return nil, err
+	}
+
+	utc := now.UTC()
+	dayStart := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(utc.Year(), utc.Month(), 1, 0, 0, 0, 0, time.UTC)
+	status := &LimitStatus{
+		TransactionType: transactionType,
+		Currency:        ws.limitCurrency(),
+		Limits:          limits,
+		UsedToday:       decimal.Zero,
+		UsedThisMonth:   decimal.Zero,
+		DayResetsAt:     dayStart.AddDate(0, 0, 1),
+		MonthResetsAt:   monthStart.AddDate(0, 1, 0),
+	}
+
+	if limits.DailyAmount.IsPositive() {
+		status.UsedToday, _, err = ws.sumDebits(store, userID, transactionType, dayStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limits.MonthlyAmount.IsPositive() {
+		status.UsedThisMonth, _, err = ws.sumDebits(store, userID, transactionType, monthStart)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limits.MaxPerHour > 0 {
+		_, status.CountLastHour, err = ws.sumDebits(store, userID, transactionType, now.Add(-time.Hour))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// userLimits applies the tier of the user and then their override, if any,
+// to the global limits of transactionType.
+func (ws *WalletService) userLimits(store repository.Store, userID int, transactionType models.TransactionType) (TransactionLimits, error) {
+	limits := ws.limits.Limits[transactionType]
+
+	user, err := store.GetUserByID(userID)
+	if err != nil {
+		return limits, fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	limits = limits.tightenedBy(ws.limits.Tiers[user.KYCLevel].Limits)
+
+	overrides, err := store.ListUserLimits(userID)
+	if err != nil {
+		return limits, err
+	}
+	for _, override := range overrides {
+		if override.TransactionType != string(transactionType) {
+			continue
+		}
+		if override.MinAmount.Valid {
+			limits.MinAmount = override.MinAmount.Decimal
+		}
+		if override.MaxAmount.Valid {
+			limits.MaxAmount = override.MaxAmount.Decimal
+		}
+		if override.DailyAmount.Valid {
+			limits.DailyAmount = override.DailyAmount.Decimal
+		}
+		if override.MonthlyAmount.Valid {
+			limits.MonthlyAmount = override.MonthlyAmount.Decimal
+		}
+		if override.MaxPerHour != nil {
+			limits.MaxPerHour = *override.MaxPerHour
+		}
+	}
+	return limits, nil
+}
+
+// sumDebits returns the total, in the limit currency, and the number of the
+// debits that count towards the limits of transactionType the user made
+// since.
+func (ws *WalletService) sumDebits(store repository.Store, userID int, transactionType models.TransactionType, since time.Time) (decimal.Decimal, int, error) {
+	sum, count := decimal.Zero, 0
+	for _, counted := range countedTypes(transactionType) {
+		totals, err := store.SumDebits(repository.DebitQuery{
+			UserID:          userID,
+			TransactionType: string(counted),
+			Since:           since,
+		})
+		if err != nil {
+			return decimal.Zero, 0, err
+		}
+
+		for _, total := range totals {
+			value, err := ws.limitValue(money.Money{Amount: total.Amount, Currency: total.Currency})
+			if err != nil {
+				return decimal.Zero, 0, err
+			}
+			sum = sum.Add(value)
+			count += total.Count
+		}
+	}
+	return sum, count, nil
+}
+
+// limitValue converts amount into the limit currency at the live rate.
+func (ws *WalletService) limitValue(amount money.Money) (decimal.Decimal, error) {
+	currency := ws.limitCurrency()
+	if amount.Currency == currency {
+		return amount.Amount, nil
+	}
+	rate, err := ws.rates.Rate(amount.Currency, currency)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	converted, err := amount.Convert(currency, rate.Rate)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return converted.Amount, nil
+}
+
+func (ws *WalletService) limitCurrency() money.Currency {
+	if ws.limits.Currency == "" {
+		return money.INR
+	}
+	return ws.limits.Currency
+}
+
+func isLimited(transactionType models.TransactionType) bool {
+	for _, limited := range LimitedTransactionTypes {
+		if limited == transactionType {
+			return true
+		}
+	}
+	return false
+}
+
+func remainingAllowance(limit, used decimal.Decimal) *decimal.Decimal {
+	if !limit.IsPositive() {
+		return nil
+	}
+	remaining := limit.Sub(used)
+	if remaining.IsNegative() {
+		remaining = decimal.Zero
+	}
+	return &remaining
+}
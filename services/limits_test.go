@@ -0,0 +1,215 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+		limits: LimitPolicy{
+			Currency: money.INR,
+			Limits: map[models.TransactionType]TransactionLimits{
+				models.TransactionTypeWithdraw: {
+					MinAmount:   decimal.NewFromInt(10),
+					MaxAmount:   decimal.NewFromInt(500),
+					DailyAmount: decimal.NewFromInt(800),
+				},
+				models.TransactionTypeTransfer: {
+					MonthlyAmount: decimal.NewFromInt(1000),
+					MaxPerHour:    2,
+				},
+			},
+		},
+	}
+	amount := func(value int64, currency money.Currency) money.Money {
+		return money.Money{Amount: decimal.NewFromInt(value), Currency: currency}
+	}
+	newWallet := func(t *testing.T, email string, currency money.Currency, balance int64) (int, *models.Wallet) {
+		userID, _ := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		wallet, err := walletService.CreateWallet(userID, currency)
+		assert.NoError(t, err)
+		if balance > 0 {
+			wallet, err = walletService.AddMoneyToWallet(userID, wallet.ID, amount(balance, currency))
+			assert.NoError(t, err)
+		}
+		return userID, wallet
+	}
+
+	t.Run("WithdrawMoneyFromWallet method to enforce the amount and daily limits", func(t *testing.T) {
+		userID, wallet := newWallet(t, "limits_withdraw@example.com", money.INR, 5000)
+
+		_, err := walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(5, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(501, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(500, money.INR))
+		assert.NoError(t, err)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(301, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(300, money.INR))
+		assert.NoError(t, err)
+	})
+
+	t.Run("WithdrawMoneyFromWallet method to convert other currencies into the limit currency", func(t *testing.T) {
+		userID, _ := newWallet(t, "limits_withdraw_usd@example.com", money.INR, 0)
+		wallet, err := walletService.CreatePocket(userID, money.USD, "travel")
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(userID, wallet.ID, amount(100, money.USD))
+		assert.NoError(t, err)
+
+		// 7 USD is 583.33 INR at the static rate.
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(7, money.USD))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(5, money.USD))
+		assert.NoError(t, err)
+	})
+
+	t.Run("CaptureHold method to count as a withdrawal towards the daily limit", func(t *testing.T) {
+		userID, wallet := newWallet(t, "limits_capture@example.com", money.INR, 5000)
+		_, err := walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(500, money.INR))
+		assert.NoError(t, err)
+
+		hold, err := walletService.AuthorizeHold(userID, wallet.ID, amount(400, money.INR), "", 0)
+		assert.NoError(t, err)
+		_, err = walletService.CaptureHold(userID, hold.ID, nil)
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+		assert.Contains(t, err.Error(), "daily limit")
+
+		partial := amount(300, money.INR)
+		_, err = walletService.CaptureHold(userID, hold.ID, &partial)
+		assert.NoError(t, err)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(10, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("TransferMoney method to enforce the hourly count but not between own wallets", func(t *testing.T) {
+		userID, wallet := newWallet(t, "limits_transfer@example.com", money.INR, 900)
+		newWallet(t, "limits_transfer_friend@example.com", money.INR, 0)
+		pocket, err := walletService.CreatePocket(userID, money.INR, "savings")
+		assert.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, walletService.TransferMoney(userID, wallet.ID, "limits_transfer_friend@example.com", 0, amount(10, money.INR)))
+		}
+		err = walletService.TransferMoney(userID, wallet.ID, "limits_transfer_friend@example.com", 0, amount(10, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		assert.NoError(t, walletService.TransferMoney(userID, wallet.ID, "limits_transfer@example.com", pocket.ID, amount(10, money.INR)))
+	})
+
+	t.Run("SetUserLimit method to override the global limits of one user", func(t *testing.T) {
+		userID, wallet := newWallet(t, "limits_override@example.com", money.INR, 3000)
+		newWallet(t, "limits_override_friend@example.com", money.INR, 0)
+
+		unlimited := 0
+		err := walletService.SetUserLimit(&models.UserLimit{
+			UserID:          userID,
+			TransactionType: string(models.TransactionTypeTransfer),
+			MonthlyAmount:   decimal.NewNullDecimal(decimal.NewFromInt(2500)),
+			MaxPerHour:      &unlimited,
+		})
+		assert.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, walletService.TransferMoney(userID, wallet.ID, "limits_override_friend@example.com", 0, amount(800, money.INR)))
+		}
+		err = walletService.TransferMoney(userID, wallet.ID, "limits_override_friend@example.com", 0, amount(101, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		err = walletService.SetUserLimit(&models.UserLimit{UserID: userID, TransactionType: string(models.TransactionTypeAdd)})
+		assert.ErrorIs(t, err, ErrInvalidTransactionLimit)
+	})
+
+	t.Run("GetLimits method to report what is left of every limit", func(t *testing.T) {
+		userID, wallet := newWallet(t, "limits_status@example.com", money.INR, 1000)
+		newWallet(t, "limits_status_friend@example.com", money.INR, 0)
+		_, err := walletService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(200, money.INR))
+		assert.NoError(t, err)
+		assert.NoError(t, walletService.TransferMoney(userID, wallet.ID, "limits_status_friend@example.com", 0, amount(150, money.INR)))
+
+		summary, err := walletService.GetLimits(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelUnverified, summary.KYCLevel)
+		assert.Equal(t, "650", summary.Balance.String())
+		assert.Len(t, summary.Statuses, 2)
+
+		withdraw, transfer := summary.Statuses[0], summary.Statuses[1]
+		assert.Equal(t, models.TransactionTypeWithdraw, withdraw.TransactionType)
+		assert.Equal(t, "600", withdraw.RemainingToday().String())
+		assert.Nil(t, withdraw.RemainingThisMonth())
+		assert.Equal(t, -1, withdraw.RemainingThisHour())
+		assert.False(t, withdraw.DayResetsAt.After(withdraw.MonthResetsAt))
+
+		assert.Equal(t, models.TransactionTypeTransfer, transfer.TransactionType)
+		assert.Nil(t, transfer.RemainingToday())
+		assert.Equal(t, "850", transfer.RemainingThisMonth().String())
+		assert.Equal(t, 1, transfer.RemainingThisHour())
+	})
+
+	t.Run("KYC tiers to tighten the limits and cap the balance of a user", func(t *testing.T) {
+		tieredService := &WalletService{
+			store:           db,
+			rates:           DefaultStaticRateProvider(),
+			historyMaxLimit: DefaultHistoryMaxLimit,
+			limits: LimitPolicy{
+				Currency: money.INR,
+				Limits:   walletService.limits.Limits,
+				Tiers: map[models.KYCLevel]KYCTier{
+					models.KYCLevelUnverified: {
+						MaxBalance: decimal.NewFromInt(1000),
+						Limits:     TransactionLimits{MaxAmount: decimal.NewFromInt(100)},
+					},
+					models.KYCLevelFull: {
+						Limits: TransactionLimits{MaxAmount: decimal.NewFromInt(2000)},
+					},
+				},
+			},
+		}
+		userID, wallet := newWallet(t, "limits_tier@example.com", money.INR, 900)
+		friendID, _ := newWallet(t, "limits_tier_friend@example.com", money.INR, 950)
+
+		_, err := tieredService.AddMoneyToWallet(userID, wallet.ID, amount(101, money.INR))
+		assert.ErrorIs(t, err, ErrBalanceCapExceeded)
+		_, err = tieredService.AddMoneyToWallet(userID, wallet.ID, amount(100, money.INR))
+		assert.NoError(t, err)
+
+		_, err = tieredService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(101, money.INR))
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+
+		err = tieredService.TransferMoney(userID, wallet.ID, "limits_tier_friend@example.com", 0, amount(60, money.INR))
+		assert.ErrorIs(t, err, ErrBalanceCapExceeded)
+		assert.NotContains(t, err.Error(), "950")
+
+		assert.NoError(t, db.UpdateUserKYCLevel(userID, models.KYCLevelFull))
+		assert.NoError(t, db.UpdateUserKYCLevel(friendID, models.KYCLevelFull))
+		_, err = tieredService.WithdrawMoneyFromWallet(userID, wallet.ID, amount(400, money.INR))
+		assert.NoError(t, err, "the global limit is looser than the full tier")
+		assert.NoError(t, tieredService.TransferMoney(userID, wallet.ID, "limits_tier_friend@example.com", 0, amount(60, money.INR)))
+
+		summary, err := tieredService.GetLimits(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.KYCLevelFull, summary.KYCLevel)
+		assert.True(t, summary.MaxBalance.IsZero())
+		assert.Equal(t, "500", summary.Statuses[0].Limits.MaxAmount.String())
+
+		assert.NoError(t, db.UpdateUserKYCLevel(userID, models.KYCLevelUnverified))
+		_, err = tieredService.AddMoneyToWallet(userID, wallet.ID, amount(401, money.INR))
+		assert.NoError(t, err)
+		postings, err := db.GetLastNPostings(friendID, 1)
+		assert.NoError(t, err)
+		_, err = tieredService.ReverseTransfer(friendID, postings[0].JournalEntryID)
+		assert.ErrorIs(t, err, ErrBalanceCapExceeded)
+		assert.NotContains(t, err.Error(), "unverified")
+		_, err = tieredService.RefundTransfer(friendID, postings[0].JournalEntryID, amount(59, money.INR))
+		assert.NoError(t, err)
+	})
+}
@@ -2,30 +2,37 @@ package services
 
 import (
 	"log"
-	"nikwallet/config"
 	"nikwallet/repository"
+	"nikwallet/services/password"
 	"os"
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
-var db *repository.PostgreSQL
+var db repository.Store
 
-func TestMain(m *testing.M) {
-	c, err := config.LoadConfig()
+var tokenOptions TokenOptions
 
+// passwords uses the cheapest bcrypt cost to keep the tests fast.
+var passwords = password.NewManager(password.NewBcryptHasher(bcrypt.MinCost))
+
+func TestMain(m *testing.M) {
+	store, closeStore, err := repository.OpenTestStore()
 	if err != nil {
-		log.Fatalln("Failed at config", err)
+		log.Fatalln("failed to open test store:", err)
 	}
+	db = store
 
-	db = &repository.PostgreSQL{}
-	err = db.Connect(&c)
+	keys, err := NewEphemeralKeySet()
 	if err != nil {
-		log.Panic("failed to connect to database:", err)
+		log.Fatalln("failed to generate signing key:", err)
 	}
+	tokenOptions = TokenOptions{Keys: keys}
 
 	exitCode := m.Run()
 
-	db.Close()
+	closeStore()
 
 	os.Exit(exitCode)
 }
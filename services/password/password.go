@@ -0,0 +1,111 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// Hasher hashes passwords into self-describing strings that carry the
+// algorithm and its parameters, so that stored hashes can be verified and
+// upgraded after the configured parameters change.
+type Hasher interface {
+	Algorithm() string
+	Hash(password string) (string, error)
+	Verify(password string, encoded string) (bool, error)
+	Identifies(encoded string) bool
+	NeedsRehash(encoded string) bool
+}
+
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+
+	dummyOnce sync.Once
+	dummy     string
+}
+
+// NewManager hashes new passwords with preferred and additionally verifies
+// hashes produced by any of the legacy hashers.
+func NewManager(preferred Hasher, legacy ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, legacy...),
+	}
+}
+
+func NewManagerFor(algorithm string) (*Manager, error) {
+	bcryptHasher := NewBcryptHasher(DefaultBcryptCost)
+	argon2idHasher := NewArgon2idHasher(DefaultArgon2idParams)
+
+	switch algorithm {
+	case "", AlgorithmArgon2id:
+		return NewManager(argon2idHasher, bcryptHasher), nil
+	case AlgorithmBcrypt:
+		return NewManager(bcryptHasher, argon2idHasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm: %s", algorithm)
+	}
+}
+
+func (m *Manager) Hash(password string) (string, error) {
+	return m.preferred.Hash(password)
+}
+
+// Verify checks password against encoded. needsRehash reports whether the
+// hash should be replaced with one produced by the preferred hasher. Values
+// that are not hashes at all are treated as legacy plaintext passwords.
+func (m *Manager) Verify(password string, encoded string) (ok bool, needsRehash bool, err error) {
+	if !IsHashed(encoded) {
+		ok = subtle.ConstantTimeCompare([]byte(password), []byte(encoded)) == 1
+		return ok, ok, nil
+	}
+
+	for _, hasher := range m.hashers {
+		if !hasher.Identifies(encoded) {
+			continue
+		}
+
+		ok, err = hasher.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		needsRehash = hasher.Algorithm() != m.preferred.Algorithm() || hasher.NeedsRehash(encoded)
+		return true, needsRehash, nil
+	}
+
+	return false, false, ErrUnknownHashFormat
+}
+
+// VerifyDummy verifies password against a hash made by the preferred hasher
+// and ignores the result. Callers use it when there is no user to check the
+// password of, so that the response takes as long as for a real user and
+// does not reveal which accounts exist.
+func (m *Manager) VerifyDummy(password string) {
+	m.dummyOnce.Do(func() {
+		m.dummy, _ = m.preferred.Hash("not the password of anyone")
+	})
+	if m.dummy != "" {
+		m.preferred.Verify(password, m.dummy)
+	}
+}
+
+// hashPrefixes are the prefixes of the hashes the package can produce or
+// verify. A plaintext password may well start with "$" on its own.
+var hashPrefixes = []string{"$2a$", "$2b$", "$2y$", "$argon2id$"}
+
+// IsHashed reports whether encoded is a hash rather than a legacy plaintext
+// password.
+func IsHashed(encoded string) bool {
+	for _, prefix := range hashPrefixes {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testArgon2idParams = Argon2idParams{
+	Memory:      8 * 1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// countingHasher counts the passwords it verifies.
+type countingHasher struct {
+	Hasher
+	verified int
+}
+
+func (ch *countingHasher) Verify(password string, encoded string) (bool, error) {
+	ch.verified++
+	return ch.Hasher.Verify(password, encoded)
+}
+
+func TestHashers(t *testing.T) {
+	hashers := []Hasher{
+		NewBcryptHasher(bcrypt.MinCost),
+		NewArgon2idHasher(testArgon2idParams),
+	}
+
+	for _, hasher := range hashers {
+		t.Run(hasher.Algorithm()+" Hash method to produce a hash that Verify accepts", func(t *testing.T) {
+			encoded, err := hasher.Hash("password123")
+			assert.NoError(t, err)
+			assert.NotContains(t, encoded, "password123")
+			assert.True(t, hasher.Identifies(encoded))
+			assert.False(t, hasher.NeedsRehash(encoded))
+
+			ok, err := hasher.Verify("password123", encoded)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = hasher.Verify("wrong_password", encoded)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+
+	t.Run("argon2id Hash method to encode the parameters in PHC format", func(t *testing.T) {
+		encoded, err := NewArgon2idHasher(testArgon2idParams).Hash("password123")
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=8192,t=1,p=1$"), encoded)
+	})
+}
+
+func TestManager(t *testing.T) {
+	weakBcrypt := NewBcryptHasher(bcrypt.MinCost)
+	strongBcrypt := NewBcryptHasher(bcrypt.MinCost + 1)
+	argon2id := NewArgon2idHasher(testArgon2idParams)
+
+	t.Run("Verify method to accept a legacy plaintext password and ask for a rehash", func(t *testing.T) {
+		manager := NewManager(argon2id, weakBcrypt)
+
+		ok, needsRehash, err := manager.Verify("password123", "password123")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+
+		ok, needsRehash, err = manager.Verify("wrong_password", "password123")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.False(t, needsRehash)
+	})
+
+	t.Run("Verify method to ask for a rehash when the hash uses another algorithm", func(t *testing.T) {
+		encoded, _ := weakBcrypt.Hash("password123")
+
+		ok, needsRehash, err := NewManager(argon2id, weakBcrypt).Verify("password123", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("Verify method to ask for a rehash when the hash parameters are weaker", func(t *testing.T) {
+		encoded, _ := weakBcrypt.Hash("password123")
+
+		ok, needsRehash, err := NewManager(strongBcrypt).Verify("password123", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+
+		weakArgon2id := testArgon2idParams
+		weakArgon2id.Iterations = 1
+		strongArgon2id := testArgon2idParams
+		strongArgon2id.Iterations = 2
+		encoded, _ = NewArgon2idHasher(weakArgon2id).Hash("password123")
+
+		ok, needsRehash, err = NewManager(NewArgon2idHasher(strongArgon2id)).Verify("password123", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+	})
+
+	t.Run("Verify method to not ask for a rehash of a current hash", func(t *testing.T) {
+		manager := NewManager(argon2id, weakBcrypt)
+		encoded, err := manager.Hash("password123")
+		assert.NoError(t, err)
+
+		ok, needsRehash, err := manager.Verify("password123", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, needsRehash)
+	})
+
+	t.Run("Verify method to accept a legacy plaintext password that starts with a dollar sign", func(t *testing.T) {
+		ok, needsRehash, err := NewManager(argon2id).Verify("$ecret123", "$ecret123")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, needsRehash)
+		assert.False(t, IsHashed("$argon2$notquite"))
+	})
+
+	t.Run("Verify method to return error for a hash no hasher of the manager knows", func(t *testing.T) {
+		encoded, _ := weakBcrypt.Hash("password123")
+
+		ok, _, err := NewManager(argon2id).Verify("password123", encoded)
+		assert.ErrorIs(t, err, ErrUnknownHashFormat)
+		assert.False(t, ok)
+	})
+
+	t.Run("NewManagerFor method to return error for an unsupported algorithm", func(t *testing.T) {
+		_, err := NewManagerFor("md5")
+		assert.Error(t, err)
+
+		manager, err := NewManagerFor(AlgorithmBcrypt)
+		assert.NoError(t, err)
+		assert.Equal(t, AlgorithmBcrypt, manager.preferred.Algorithm())
+	})
+
+	t.Run("VerifyDummy method to verify against a hash of the preferred hasher", func(t *testing.T) {
+		hasher := &countingHasher{Hasher: NewBcryptHasher(bcrypt.MinCost)}
+		manager := NewManager(hasher)
+
+		manager.VerifyDummy("password123")
+		manager.VerifyDummy("password456")
+		assert.Equal(t, 2, hasher.verified)
+		assert.True(t, hasher.Identifies(manager.dummy))
+	})
+
+	t.Run("CheckPolicy method to reject short, long and letter- or digit-only passwords", func(t *testing.T) {
+		assert.NoError(t, CheckPolicy("password123"))
+		for _, weak := range []string{"pass1", strings.Repeat("a1", 37), "passwordonly", "12345678"} {
+			assert.ErrorIs(t, CheckPolicy(weak), ErrWeakPassword, weak)
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// MinLength and MaxLength bound new passwords. MaxLength is the number of
+// bytes bcrypt reads, so no part of a longer password is silently ignored.
+const (
+	MinLength = 8
+	MaxLength = 72
+)
+
+var ErrWeakPassword = errors.New("password does not meet the password policy")
+
+// CheckPolicy returns ErrWeakPassword unless password is between MinLength
+// and MaxLength bytes long and contains both a letter and a digit.
+func CheckPolicy(password string) error {
+	if len(password) < MinLength || len(password) > MaxLength {
+		return fmt.Errorf("%w: must be between %d and %d characters long", ErrWeakPassword, MinLength, MaxLength)
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range password {
+		hasLetter = hasLetter || unicode.IsLetter(r)
+		hasDigit = hasDigit || unicode.IsDigit(r)
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("%w: must contain a letter and a digit", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"nikwallet/repository/models"
+)
+
+const (
+	ScopeWalletRead  = "wallet:read"
+	ScopeWalletWrite = "wallet:write"
+	ScopeSessions    = "sessions"
+)
+
+// Scopes of the admin API, granted by the role of the user.
+const (
+	ScopeKYCReview        = "kyc:review"
+	ScopeUsersRead        = "admin:users:read"
+	ScopeLedgerRead       = "admin:ledger:read"
+	ScopeAccountsFreeze   = "admin:accounts:freeze"
+	ScopeAdjustmentsWrite = "admin:adjustments:write"
+	ScopeRolesWrite       = "admin:roles:write"
+)
+
+// DefaultScopes are granted to every token issued on sign-in.
+var DefaultScopes = []string{ScopeWalletRead, ScopeWalletWrite, ScopeSessions}
+
+// RoleScopes is the permission matrix of the admin API: the scopes each role
+// is granted on top of DefaultScopes. Customers get none.
+var RoleScopes = map[models.Role][]string{
+	models.RoleSupport: {ScopeUsersRead, ScopeLedgerRead, ScopeAccountsFreeze, ScopeKYCReview},
+	models.RoleAdmin:   {ScopeUsersRead, ScopeLedgerRead, ScopeAccountsFreeze, ScopeKYCReview, ScopeAdjustmentsWrite, ScopeRolesWrite},
+	models.RoleAuditor: {ScopeUsersRead, ScopeLedgerRead},
+}
+
+// ScopesForRole returns every scope a token of a user with role carries.
+func ScopesForRole(role models.Role) []string {
+	scopes := append([]string(nil), DefaultScopes...)
+	return append(scopes, RoleScopes[role]...)
+}
+
+// RoleOutranks reports whether actor holds every scope of target, which staff
+// need to act on the account of a user with target.
+func RoleOutranks(actor models.Role, target models.Role) bool {
+	for _, scope := range RoleScopes[target] {
+		granted := false
+		for _, held := range RoleScopes[actor] {
+			granted = granted || held == scope
+		}
+		if !granted {
+			return false
+		}
+	}
+	return true
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID    int
+	SessionID int
+	Role      models.Role
+	Scopes    []string
+	Claims    *Claims
+}
+
+func NewPrincipal(claims *Claims) *Principal {
+	role := models.Role(claims.Role)
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	return &Principal{
+		UserID:    claims.UserID,
+		SessionID: claims.SessionID,
+		Role:      role,
+		Scopes:    strings.Fields(claims.Scope),
+		Claims:    claims,
+	}
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, granted := range p.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// MissingScopes returns the scopes from required that p was not granted.
+func (p *Principal) MissingScopes(required ...string) []string {
+	var missing []string
+	for _, scope := range required {
+		if !p.HasScope(scope) {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+type principalContextKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
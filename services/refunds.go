@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrEntryNotFound         = errors.New("ledger entry not found")
+	ErrNotRefundable         = errors.New("ledger entry cannot be refunded")
+	ErrRefundExceedsOriginal = errors.New("refund exceeds the amount left to refund")
+)
+
+// RefundTransfer returns amount of a transfer the user received to its
+// sender. amount is in the currency the user was credited in; the sender is
+// credited in the currency they paid in, at the rate of the transfer. The
+// refunds of a transfer can never add up to more than it credited, and
+// cannot take the sender past the balance cap of their KYC tier.
+func (ws *WalletService) RefundTransfer(userID int, entryID int, amount money.Money) (*models.JournalEntry, error) {
+	if err := amount.Validate(); err != nil {
+		return nil, err
+	}
+	return ws.refundTransfer(userID, entryID, &amount, models.TransactionTypeRefund)
+}
+
+// ReverseTransfer refunds whatever is left of a transfer the user received.
+func (ws *WalletService) ReverseTransfer(userID int, entryID int) (*models.JournalEntry, error) {
+	return ws.refundTransfer(userID, entryID, nil, models.TransactionTypeReversal)
+}
+
+// refundTransfer refunds amount of the transfer entryID, or all of what is
+// left of it when amount is nil.
+func (ws *WalletService) refundTransfer(userID int, entryID int, amount *money.Money, transactionType models.TransactionType) (*models.JournalEntry, error) {
+	original, err := ws.store.GetJournalEntryByID(entryID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrEntryNotFound, entryID)
+	}
+	debit, credit := transferLegs(original)
+	if (debit == nil || debit.UserID != userID) && (credit == nil || credit.UserID != userID) {
+		return nil, fmt.Errorf("%w: %d", ErrEntryNotFound, entryID)
+	}
+	if original.TransactionType != string(models.TransactionTypeTransfer) || debit == nil || credit.UserID != userID {
+		return nil, fmt.Errorf("%w: only the recipient of a transfer can refund it", ErrNotRefundable)
+	}
+	if amount != nil && amount.Currency != credit.Amount.Currency {
+		return nil, fmt.Errorf("cannot refund %s of a transfer credited in %s: %w", amount.Currency, credit.Amount.Currency, money.ErrCurrencyMismatch)
+	}
+
+	var entry *models.JournalEntry
+	err = ws.store.Transaction(func(tx repository.Store) error {
+		lockedWallets, err := tx.LockWalletsForUpdate(credit.WalletID, debit.WalletID)
+		if err != nil {
+			return err
+		}
+		if err := tx.LockUsersForUpdate(credit.UserID, debit.UserID); err != nil {
+			return err
+		}
+		if err := checkNotFrozen(tx, userID); err != nil {
+			return err
+		}
+
+		refunds, err := tx.ListReversingEntries(original.ID)
+		if err != nil {
+			return err
+		}
+		refundedDebit, refundedCredit := decimal.Zero, decimal.Zero
+		for _, refund := range refunds {
+			for _, posting := range refund.Postings {
+				switch {
+				case posting.AccountType != models.AccountTypeWallet:
+				case posting.WalletID == credit.WalletID:
+					refundedCredit = refundedCredit.Add(posting.Amount.Amount)
+				case posting.WalletID == debit.WalletID:
+					refundedDebit = refundedDebit.Add(posting.Amount.Amount)
+				}
+			}
+		}
+
+		remaining := credit.Amount.Amount.Sub(refundedCredit)
+		refunded := money.Money{Amount: remaining, Currency: credit.Amount.Currency}
+		if amount != nil {
+			refunded = *amount
+		}
+		if !remaining.IsPositive() || refunded.Amount.GreaterThan(remaining) {
+			return fmt.Errorf("%w: %s %s left of entry %d", ErrRefundExceedsOriginal, remaining, credit.Amount.Currency, original.ID)
+		}
+
+		// The sender gets back the share of what they paid that the refund
+		// is of what the recipient received, and the rest of it once the
+		// transfer is refunded in full.
+		returned := money.Money{Amount: debit.Amount.Amount.Sub(refundedDebit), Currency: debit.Amount.Currency}
+		if refunded.Amount.LessThan(remaining) {
+			share := debit.Amount.Amount.Mul(refunded.Amount).Div(credit.Amount.Amount)
+			returned = *money.Money{Amount: share, Currency: debit.Amount.Currency}.Round()
+		}
+		if !returned.Amount.IsPositive() {
+			return fmt.Errorf("%w: %s %s is too little to return any %s to the sender", ErrNotRefundable, refunded.Amount, refunded.Currency, returned.Currency)
+		}
+
+		// The recipient is not told the balance or tier of the sender.
+		if err := ws.checkBalanceCap(tx, debit.UserID, returned); errors.Is(err, ErrBalanceCapExceeded) {
+			return fmt.Errorf("%w: the sender cannot receive this refund", ErrBalanceCapExceeded)
+		} else if err != nil {
+			return err
+		}
+
+		debits, err := debitWallet(tx, lockedWallets[credit.WalletID], refunded)
+		if err != nil {
+			return err
+		}
+		credits, err := creditWallet(tx, lockedWallets[debit.WalletID], returned, nil)
+		if err != nil {
+			return err
+		}
+		for _, posting := range debits {
+			posting.CounterpartyUserID = debit.UserID
+		}
+		for _, posting := range credits {
+			posting.CounterpartyUserID = credit.UserID
+		}
+
+		postings := append(debits, credits...)
+		if refunded.Currency != returned.Currency {
+			conversion := []*models.Posting{
+				models.NewSystemPosting(models.SystemAccountFXClearing, models.PostingDirectionCredit, &refunded),
+				models.NewSystemPosting(models.SystemAccountFXClearing, models.PostingDirectionDebit, &returned),
+			}
+			for _, posting := range append(postings, conversion...) {
+				posting.FXRate = credit.FXRate
+				posting.FXRateSource = credit.FXRateSource
+			}
+			postings = append(postings, conversion...)
+		}
+
+		entry = &models.JournalEntry{
+			TransactionType: string(transactionType),
+			ReversedEntryID: original.ID,
+			Postings:        postings,
+			CreatedAt:       time.Now(),
+		}
+		if err := tx.CreateJournalEntry(entry); err != nil {
+			return fmt.Errorf("failed to create ledger entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// transferLegs returns the wallet debit and wallet credit of a transfer
+// entry, or nil for a leg the entry does not have.
+func transferLegs(entry *models.JournalEntry) (debit *models.Posting, credit *models.Posting) {
+	for _, posting := range entry.Postings {
+		if posting.AccountType != models.AccountTypeWallet {
+			continue
+		}
+		if posting.Direction == models.PostingDirectionDebit && debit == nil {
+			debit = posting
+		}
+		if posting.Direction == models.PostingDirectionCredit && credit == nil {
+			credit = posting
+		}
+	}
+	return debit, credit
+}
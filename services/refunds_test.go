@@ -0,0 +1,137 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefunds(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	amount := func(value string, currency money.Currency) money.Money {
+		return money.Money{Amount: decimal.RequireFromString(value), Currency: currency}
+	}
+	newWallet := func(t *testing.T, email string, currency money.Currency, balance string) (int, *models.Wallet) {
+		userID, _ := db.CreateUser(&models.User{EmailID: email, Password: "password"})
+		wallet, err := walletService.CreateWallet(userID, currency)
+		assert.NoError(t, err)
+		if balance != "0" {
+			wallet, err = walletService.AddMoneyToWallet(userID, wallet.ID, amount(balance, currency))
+			assert.NoError(t, err)
+		}
+		return userID, wallet
+	}
+	lastEntryID := func(t *testing.T, userID int) int {
+		postings, err := db.GetLastNPostings(userID, 1)
+		assert.NoError(t, err)
+		return postings[0].JournalEntryID
+	}
+	balance := func(t *testing.T, userID int) string {
+		wallet, err := walletService.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		return wallet.Money.Amount.String()
+	}
+
+	t.Run("RefundTransfer and ReverseTransfer methods to never return more than was transferred", func(t *testing.T) {
+		senderID, senderWallet := newWallet(t, "refund_sender@example.com", money.INR, "100")
+		recipientID, _ := newWallet(t, "refund_recipient@example.com", money.INR, "0")
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "refund_recipient@example.com", 0, amount("60", money.INR)))
+		transferID := lastEntryID(t, recipientID)
+
+		refund, err := walletService.RefundTransfer(recipientID, transferID, amount("25", money.INR))
+		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeRefund), refund.TransactionType)
+		assert.Equal(t, transferID, refund.ReversedEntryID)
+		assert.Equal(t, "65", balance(t, senderID))
+		assert.Equal(t, "35", balance(t, recipientID))
+
+		_, err = walletService.RefundTransfer(recipientID, transferID, amount("36", money.INR))
+		assert.ErrorIs(t, err, ErrRefundExceedsOriginal)
+
+		reversal, err := walletService.ReverseTransfer(recipientID, transferID)
+		assert.NoError(t, err)
+		assert.Equal(t, string(models.TransactionTypeReversal), reversal.TransactionType)
+		assert.Equal(t, "100", balance(t, senderID))
+		assert.Equal(t, "0", balance(t, recipientID))
+
+		_, err = walletService.ReverseTransfer(recipientID, transferID)
+		assert.ErrorIs(t, err, ErrRefundExceedsOriginal)
+	})
+
+	t.Run("RefundTransfer method to refund a conversion at the rate of the transfer", func(t *testing.T) {
+		senderID, senderWallet := newWallet(t, "refund_fx_sender@example.com", money.INR, "1000")
+		recipientID, recipientWallet := newWallet(t, "refund_fx_recipient@example.com", money.USD, "0")
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "refund_fx_recipient@example.com", recipientWallet.ID, amount("1000", money.INR)))
+		transferID := lastEntryID(t, recipientID)
+
+		_, err := walletService.RefundTransfer(recipientID, transferID, amount("5", money.INR))
+		assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+		refund, err := walletService.RefundTransfer(recipientID, transferID, amount("5", money.USD))
+		assert.NoError(t, err)
+		assert.Equal(t, "416.67", balance(t, senderID))
+		for _, posting := range refund.Postings {
+			assert.True(t, posting.FXRate.Decimal.Equal(decimal.RequireFromString("0.012")), "got %v", posting.FXRate)
+		}
+
+		_, err = walletService.ReverseTransfer(recipientID, transferID)
+		assert.NoError(t, err)
+		assert.Equal(t, "1000", balance(t, senderID))
+		assert.Equal(t, "0", balance(t, recipientID))
+	})
+
+	t.Run("RefundTransfer method to reject a refund too small to return anything to the sender", func(t *testing.T) {
+		senderID, senderWallet := newWallet(t, "refund_dust_sender@example.com", money.USD, "10")
+		recipientID, recipientWallet := newWallet(t, "refund_dust_recipient@example.com", money.INR, "0")
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "refund_dust_recipient@example.com", recipientWallet.ID, amount("10", money.USD)))
+		transferID := lastEntryID(t, recipientID)
+		received := balance(t, recipientID)
+
+		_, err := walletService.RefundTransfer(recipientID, transferID, amount("0.01", money.INR))
+		assert.ErrorIs(t, err, ErrNotRefundable)
+		assert.Equal(t, received, balance(t, recipientID))
+		assert.Equal(t, "0", balance(t, senderID))
+	})
+
+	t.Run("RefundTransfer method to only let the recipient refund a transfer", func(t *testing.T) {
+		senderID, senderWallet := newWallet(t, "refund_party_sender@example.com", money.INR, "50")
+		recipientID, _ := newWallet(t, "refund_party_recipient@example.com", money.INR, "0")
+		otherID, _ := newWallet(t, "refund_party_other@example.com", money.INR, "0")
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "refund_party_recipient@example.com", 0, amount("20", money.INR)))
+		transferID := lastEntryID(t, recipientID)
+
+		_, err := walletService.RefundTransfer(senderID, transferID, amount("20", money.INR))
+		assert.ErrorIs(t, err, ErrNotRefundable)
+		_, err = walletService.ReverseTransfer(otherID, transferID)
+		assert.ErrorIs(t, err, ErrEntryNotFound)
+
+		deposit, err := walletService.AddMoneyToWallet(recipientID, 0, amount("5", money.INR))
+		assert.NoError(t, err)
+		_, err = walletService.ReverseTransfer(recipientID, lastEntryID(t, deposit.UserID))
+		assert.ErrorIs(t, err, ErrNotRefundable)
+	})
+
+	t.Run("ListHistory method to show the link to the transfer to both parties", func(t *testing.T) {
+		senderID, senderWallet := newWallet(t, "refund_history_sender@example.com", money.INR, "50")
+		recipientID, _ := newWallet(t, "refund_history_recipient@example.com", money.INR, "0")
+		assert.NoError(t, walletService.TransferMoney(senderID, senderWallet.ID, "refund_history_recipient@example.com", 0, amount("20", money.INR)))
+		transferID := lastEntryID(t, recipientID)
+
+		_, err := walletService.ReverseTransfer(recipientID, transferID)
+		assert.NoError(t, err)
+
+		for _, userID := range []int{senderID, recipientID} {
+			postings, err := db.GetLastNPostings(userID, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, string(models.TransactionTypeReversal), postings[0].TransactionType)
+			assert.Equal(t, transferID, postings[0].ReversedEntryID)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+)
+
+// statementBatchSize is the number of postings a statement reads from the
+// store at a time, so that a long period never sits in memory at once.
+const statementBatchSize = 500
+
+var ErrInvalidStatementPeriod = errors.New("invalid statement period")
+
+// Statement describes the wallet and period of an account statement. The
+// opening balance counts every posting before From; the closing balance
+// every posting before To.
+type Statement struct {
+	Wallet         *models.Wallet
+	From           time.Time
+	To             time.Time
+	OpeningBalance money.Money
+	ClosingBalance money.Money
+}
+
+// StatementLine is one posting of a statement with the balance of the
+// wallet right after it.
+type StatementLine struct {
+	Posting           *models.Posting
+	CounterpartyEmail string
+	Balance           money.Money
+}
+
+// StatementWriter renders a statement as WriteStatement produces it: the
+// header once the opening balance is known, every line in posting order, and
+// the footer with the closing balance.
+type StatementWriter interface {
+	WriteHeader(statement *Statement) error
+	WriteLine(line *StatementLine) error
+	WriteFooter(statement *Statement) error
+}
+
+// WriteStatement streams the statement of the wallet walletID of the user, or
+// of the default wallet when walletID is 0, for the postings made from
+// from up to, but excluding, to.
+func (ws *WalletService) WriteStatement(userID int, walletID int, from, to time.Time, w StatementWriter) error {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidStatementPeriod)
+	}
+
+	wallet, err := resolveWallet(ws.store, userID, walletID)
+	if err != nil {
+		return err
+	}
+
+	opening, err := ws.store.GetWalletBalanceAt(wallet.ID, from.Add(-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+
+	currency := wallet.Money.Currency
+	statement := &Statement{
+		Wallet:         wallet,
+		From:           from,
+		To:             to,
+		OpeningBalance: money.Money{Amount: opening, Currency: currency},
+	}
+	if err := w.WriteHeader(statement); err != nil {
+		return err
+	}
+
+	balance := opening
+	emails := map[int]string{}
+	query := repository.PostingQuery{
+		UserID:    userID,
+		WalletID:  wallet.ID,
+		From:      from,
+		To:        to,
+		Ascending: true,
+		Limit:     statementBatchSize,
+	}
+	for {
+		postings, err := ws.store.ListPostings(query)
+		if err != nil {
+			return err
+		}
+
+		for _, posting := range postings {
+			balance = balance.Add(posting.SignedAmount())
+			line := &StatementLine{
+				Posting:           posting,
+				CounterpartyEmail: ws.counterpartyEmail(emails, posting.CounterpartyUserID),
+				Balance:           money.Money{Amount: balance, Currency: currency},
+			}
+			if err := w.WriteLine(line); err != nil {
+				return err
+			}
+		}
+
+		if len(postings) < statementBatchSize {
+			break
+		}
+		last := postings[len(postings)-1]
+		query.After = &repository.PostingCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+
+	statement.ClosingBalance = money.Money{Amount: balance, Currency: currency}
+	return w.WriteFooter(statement)
+}
+
+// counterpartyEmail looks up the email of a counterparty once per statement.
+// Postings without a counterparty, or whose counterparty no longer exists,
+// get an empty email.
+func (ws *WalletService) counterpartyEmail(emails map[int]string, userID int) string {
+	if userID == 0 {
+		return ""
+	}
+	if email, ok := emails[userID]; ok {
+		return email
+	}
+
+	var email string
+	if user, err := ws.store.GetUserByID(userID); err == nil {
+		email = user.EmailID
+	}
+	emails[userID] = email
+	return email
+}
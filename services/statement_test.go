@@ -0,0 +1,88 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingStatementWriter struct {
+	header, footer *Statement
+	lines          []*StatementLine
+}
+
+func (w *recordingStatementWriter) WriteHeader(statement *Statement) error {
+	copied := *statement
+	w.header = &copied
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteLine(line *StatementLine) error {
+	w.lines = append(w.lines, line)
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteFooter(statement *Statement) error {
+	w.footer = statement
+	return nil
+}
+
+func TestWriteStatement(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+
+	ownerID, _ := db.CreateUser(&models.User{EmailID: "statement_owner@example.com", Password: "password"})
+	friendID, _ := db.CreateUser(&models.User{EmailID: "statement_friend@example.com", Password: "password"})
+	wallet, err := walletService.CreateWallet(ownerID, money.INR)
+	assert.NoError(t, err)
+	_, err = walletService.CreateWallet(friendID, money.INR)
+	assert.NoError(t, err)
+
+	_, err = walletService.AddMoneyToWallet(ownerID, wallet.ID, inr(100))
+	assert.NoError(t, err)
+	from := time.Now()
+	_, err = walletService.AddMoneyToWallet(ownerID, wallet.ID, inr(40))
+	assert.NoError(t, err)
+	assert.NoError(t, walletService.TransferMoney(ownerID, wallet.ID, "statement_friend@example.com", 0, inr(15)))
+	to := time.Now()
+	_, err = walletService.WithdrawMoneyFromWallet(ownerID, wallet.ID, inr(5))
+	assert.NoError(t, err)
+
+	t.Run("WriteStatement method to write the balances and postings of the period in order", func(t *testing.T) {
+		w := &recordingStatementWriter{}
+		assert.NoError(t, walletService.WriteStatement(ownerID, 0, from, to, w))
+
+		assert.Equal(t, wallet.ID, w.header.Wallet.ID)
+		assert.True(t, w.header.OpeningBalance.Equals(inr(100)), "got %v", w.header.OpeningBalance)
+		assert.True(t, w.footer.ClosingBalance.Equals(inr(125)), "got %v", w.footer.ClosingBalance)
+
+		assert.Len(t, w.lines, 2)
+		assert.Equal(t, string(models.TransactionTypeAdd), w.lines[0].Posting.TransactionType)
+		assert.True(t, w.lines[0].Balance.Equals(inr(140)), "got %v", w.lines[0].Balance)
+		assert.Empty(t, w.lines[0].CounterpartyEmail)
+		assert.Equal(t, string(models.TransactionTypeTransfer), w.lines[1].Posting.TransactionType)
+		assert.Equal(t, "statement_friend@example.com", w.lines[1].CounterpartyEmail)
+		assert.True(t, w.lines[1].Balance.Equals(inr(125)), "got %v", w.lines[1].Balance)
+	})
+
+	t.Run("WriteStatement method to reject an empty period and another user's wallet", func(t *testing.T) {
+		err := walletService.WriteStatement(ownerID, 0, to, from, &recordingStatementWriter{})
+		assert.ErrorIs(t, err, ErrInvalidStatementPeriod)
+
+		friendWallet, _ := walletService.GetWalletByUserID(friendID)
+		w := &recordingStatementWriter{}
+		err = walletService.WriteStatement(ownerID, friendWallet.ID, from, to, w)
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+		assert.Nil(t, w.header)
+	})
+}
@@ -1,39 +1,287 @@
 package services
 
 import (
+	"errors"
 	"fmt"
+	"nikwallet/logger"
 	"nikwallet/repository"
 	"nikwallet/repository/models"
+	"nikwallet/services/password"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const passwordMigrationBatchSize = 100
+
+// DefaultUserSearchLimit caps how many users a single search returns.
+const DefaultUserSearchLimit = 50
+
+var (
+	ErrUserExists     = errors.New("user already exists")
+	ErrUserNotFound   = errors.New("user not found")
+	ErrAccountFrozen  = errors.New("account is frozen")
+	ErrInvalidRole    = errors.New("invalid role")
+	ErrReasonRequired = errors.New("a reason is required")
+	ErrSelfAdmin      = errors.New("staff cannot act on their own account")
+	ErrOutranked      = errors.New("staff cannot act on a user whose role has more access than theirs")
+	ErrAlreadyFrozen  = errors.New("account is already frozen")
+	ErrNotFrozen      = errors.New("account is not frozen")
+)
+
 type UserService struct {
-	db *gorm.DB
+	store     repository.Store
+	passwords *password.Manager
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{db: db}
+func NewUserService(store repository.Store, passwords *password.Manager) *UserService {
+	return &UserService{store: store, passwords: passwords}
 }
 
 func (us *UserService) CreateUser(newUser *models.User) (int, error) {
-	db := repository.PostgreSQL{DB: us.db}
-
-	existingUser, _ := db.GetUserByEmail(newUser.EmailID)
+	existingUser, _ := us.store.GetUserByEmail(newUser.EmailID)
 
 	if existingUser != nil {
-		return 0, fmt.Errorf("user already exists")
+		return 0, ErrUserExists
+	}
+
+	passwordHash, err := us.passwords.Hash(newUser.Password)
+	if err != nil {
+		return 0, fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	return db.CreateUser(newUser)
+	// Store a hashed copy so the caller's value is left untouched.
+	hashedUser := *newUser
+	hashedUser.Password = passwordHash
+
+	userID, err := us.store.CreateUser(&hashedUser)
+	if err != nil {
+		return 0, err
+	}
+	newUser.ID = hashedUser.ID
+	newUser.CreatedAt = hashedUser.CreatedAt
+	newUser.UpdatedAt = hashedUser.UpdatedAt
+
+	return userID, nil
 }
 
 func (us *UserService) GetUserByID(id int) (*models.User, error) {
-	db := repository.PostgreSQL{DB: us.db}
-	return db.GetUserByID(id)
+	return us.store.GetUserByID(id)
 }
 
 func (us *UserService) GetUserByEmail(email string) (*models.User, error) {
-	db := repository.PostgreSQL{DB: us.db}
-	return db.GetUserByEmail(email)
+	return us.store.GetUserByEmail(email)
+}
+
+// HashPlaintextPasswords replaces every password that is still stored in
+// plaintext with a hash and returns the number of users it converted.
+func (us *UserService) HashPlaintextPasswords() (int, error) {
+	converted := 0
+	afterID := 0
+
+	for {
+		users, err := us.store.ListUsers(afterID, passwordMigrationBatchSize)
+		if err != nil {
+			return converted, err
+		}
+		if len(users) == 0 {
+			return converted, nil
+		}
+
+		for _, user := range users {
+			afterID = int(user.ID)
+			if password.IsHashed(user.Password) {
+				continue
+			}
+
+			passwordHash, err := us.passwords.Hash(user.Password)
+			if err != nil {
+				return converted, fmt.Errorf("failed to hash password of user %d: %w", user.ID, err)
+			}
+			if err := us.store.UpdateUserPassword(int(user.ID), passwordHash); err != nil {
+				return converted, err
+			}
+			converted++
+		}
+	}
+}
+
+// SearchUsers returns up to limit users after afterID whose email contains
+// email, oldest first. An empty role matches every role.
+func (us *UserService) SearchUsers(email string, role models.Role, afterID int, limit int) ([]*models.User, error) {
+	if limit < 1 || limit > DefaultUserSearchLimit {
+		return nil, fmt.Errorf("%w: must be between 1 and %d, got %d", ErrInvalidLimit, DefaultUserSearchLimit, limit)
+	}
+	if role != "" && !role.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	return us.store.SearchUsers(repository.UserQuery{
+		Email:   strings.TrimSpace(email),
+		Role:    role,
+		AfterID: afterID,
+		Limit:   limit,
+	})
+}
+
+// SetRole gives the user role, records the change in the audit trail and
+// ends the sessions of the user, so that they sign in again with the scopes
+// of the new role. Staff cannot change their own role, so that an admin
+// cannot lock the last admin out by accident, and can only move users between
+// roles that grant no scope their own role lacks.
+func (us *UserService) SetRole(actorID int, userID int, role models.Role) (*models.User, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	if actorID == userID {
+		return nil, ErrSelfAdmin
+	}
+
+	var user *models.User
+	err := us.store.Transaction(func(tx repository.Store) error {
+		var err error
+		user, err = tx.GetUserByID(userID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+		}
+		actor, err := tx.GetUserByID(actorID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, actorID)
+		}
+		if !RoleOutranks(actor.Role, user.Role) {
+			return fmt.Errorf("%w: %s cannot change the role of %s %d", ErrOutranked, actor.Role, user.Role, userID)
+		}
+		if !RoleOutranks(actor.Role, role) {
+			return fmt.Errorf("%w: %s cannot grant the role %s", ErrOutranked, actor.Role, role)
+		}
+		return setRole(tx, user, actorID, role)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("user %d set the role of user %d to %s", actorID, userID, role)
+	return user, nil
+}
+
+// SetRoleByEmail gives the user with email role. It backs the set-role
+// command, which grants the first admin their role.
+func (us *UserService) SetRoleByEmail(email string, role models.Role) error {
+	if !role.IsValid() {
+		return fmt.Errorf("%w: %q", ErrInvalidRole, role)
+	}
+	return us.store.Transaction(func(tx repository.Store) error {
+		user, err := tx.GetUserByEmail(email)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrUserNotFound, email)
+		}
+		return setRole(tx, user, 0, role)
+	})
+}
+
+func setRole(tx repository.Store, user *models.User, actorID int, role models.Role) error {
+	from := user.Role
+	if from == "" {
+		from = models.RoleCustomer
+	}
+
+	err := tx.UpdateUserRole(int(user.ID), role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("%w: %d", ErrUserNotFound, user.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set the role of user %d: %w", user.ID, err)
+	}
+	user.Role = role
+
+	now := time.Now()
+	if err := tx.CreateRoleChange(&models.RoleChange{
+		UserID:    int(user.ID),
+		ActorID:   actorID,
+		FromRole:  from,
+		ToRole:    role,
+		CreatedAt: now,
+	}); err != nil {
+		return err
+	}
+	// Tokens carry the scopes of the role they were issued for.
+	return revokeUserSessions(tx, int(user.ID), now)
+}
+
+// FreezeUser stops the user from signing in, refreshing their tokens and
+// moving money until they are unfrozen, and ends their sessions. Staff can only freeze and unfreeze
+// users whose role has no scope that their own role lacks.
+func (us *UserService) FreezeUser(actorID int, userID int, reason string) (*models.User, error) {
+	reason, err := adminReason(reason)
+	if err != nil {
+		return nil, err
+	}
+	return us.setFrozen(actorID, userID, true, reason)
+}
+
+func (us *UserService) UnfreezeUser(actorID int, userID int) (*models.User, error) {
+	return us.setFrozen(actorID, userID, false, "")
+}
+
+func (us *UserService) setFrozen(actorID int, userID int, frozen bool, reason string) (*models.User, error) {
+	if actorID == userID {
+		return nil, ErrSelfAdmin
+	}
+
+	var user *models.User
+	err := us.store.Transaction(func(tx repository.Store) error {
+		var err error
+		user, err = tx.GetUserByID(userID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+		}
+		actor, err := tx.GetUserByID(actorID)
+		if err != nil {
+			return fmt.Errorf("%w: %d", ErrUserNotFound, actorID)
+		}
+		if !RoleOutranks(actor.Role, user.Role) {
+			return fmt.Errorf("%w: %s cannot change the account of %s %d", ErrOutranked, actor.Role, user.Role, userID)
+		}
+		if frozen && user.IsFrozen() {
+			return fmt.Errorf("%w: %d", ErrAlreadyFrozen, userID)
+		}
+		if !frozen && !user.IsFrozen() {
+			return fmt.Errorf("%w: %d", ErrNotFrozen, userID)
+		}
+
+		var frozenAt *time.Time
+		frozenBy := 0
+		if frozen {
+			now := time.Now()
+			frozenAt, frozenBy = &now, actorID
+		}
+		if err := tx.UpdateUserFrozen(userID, frozenAt, frozenBy, reason); err != nil {
+			return err
+		}
+		user.FrozenAt, user.FrozenBy, user.FrozenReason = frozenAt, frozenBy, reason
+		if frozen {
+			return revokeUserSessions(tx, userID, *frozenAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if frozen {
+		logger.Infof("user %d froze user %d: %s", actorID, userID, reason)
+	} else {
+		logger.Infof("user %d unfroze user %d", actorID, userID)
+	}
+	return user, nil
+}
+
+// adminReason trims the reason staff give for an action on an account and
+// checks that there is one.
+func adminReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", ErrReasonRequired
+	}
+	return reason, nil
 }
@@ -2,14 +2,18 @@ package services
 
 import (
 	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"nikwallet/services/password"
 	"testing"
 
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestUserService(t *testing.T) {
 	userService := &UserService{
-		db: db.DB,
+		store:     db,
+		passwords: passwords,
 	}
 	t.Run("UserService to return error for existing user trying to signup", func(t *testing.T) {
 		existingUser := &models.User{
@@ -27,7 +31,210 @@ func TestUserService(t *testing.T) {
 
 		noIdCreated, err := userService.CreateUser(duplicateUser)
 
-		assert.Error(t, err, "user already exists")
+		assert.ErrorIs(t, err, ErrUserExists)
 		assert.Equal(t, 0, noIdCreated)
 	})
+
+	t.Run("CreateUser method to store a hash instead of the password", func(t *testing.T) {
+		newUser := &models.User{
+			EmailID:  "hashed_signup@example.com",
+			Password: "password123",
+		}
+		userID, err := userService.CreateUser(newUser)
+		assert.NoError(t, err)
+		assert.Equal(t, "password123", newUser.Password)
+		assert.Equal(t, userID, int(newUser.ID))
+
+		stored, err := db.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.True(t, password.IsHashed(stored.Password))
+
+		ok, _, err := passwords.Verify("password123", stored.Password)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("HashPlaintextPasswords method to hash only plaintext passwords", func(t *testing.T) {
+		plaintextID, _ := db.CreateUser(&models.User{
+			EmailID:  "plaintext_migration@example.com",
+			Password: "password123",
+		})
+		hashedID, _ := userService.CreateUser(&models.User{
+			EmailID:  "hashed_migration@example.com",
+			Password: "password123",
+		})
+		hashedBefore, _ := db.GetUserByID(hashedID)
+
+		converted, err := userService.HashPlaintextPasswords()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, converted, 1)
+
+		plaintextAfter, _ := db.GetUserByID(plaintextID)
+		ok, _, err := passwords.Verify("password123", plaintextAfter.Password)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, password.IsHashed(plaintextAfter.Password))
+
+		hashedAfter, _ := db.GetUserByID(hashedID)
+		assert.Equal(t, hashedBefore.Password, hashedAfter.Password)
+
+		converted, err = userService.HashPlaintextPasswords()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, converted)
+	})
+
+	t.Run("SetRole method to change the role and the scopes of new tokens", func(t *testing.T) {
+		authService := NewAuthService(db, passwords, tokenOptions)
+		adminID, _ := userService.CreateUser(&models.User{EmailID: "role_admin@example.com", Password: "password123", Role: models.RoleAdmin})
+		userID, _ := userService.CreateUser(&models.User{EmailID: "role_auditor@example.com", Password: "password123"})
+
+		user, err := userService.GetUserByID(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleCustomer, user.Role)
+
+		user, err = userService.SetRole(adminID, userID, models.RoleAuditor)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAuditor, user.Role)
+		_, err = userService.SetRole(adminID, userID, "owner")
+		assert.ErrorIs(t, err, ErrInvalidRole)
+		_, err = userService.SetRole(adminID, adminID, models.RoleCustomer)
+		assert.ErrorIs(t, err, ErrSelfAdmin)
+
+		IDToken, err := authService.AuthenticateUser("role_auditor@example.com", "password123")
+		assert.NoError(t, err)
+		principal, err := authService.Authenticate(IDToken)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAuditor, principal.Role)
+		assert.True(t, principal.HasScope(ScopeLedgerRead))
+		assert.False(t, principal.HasScope(ScopeAdjustmentsWrite))
+
+		found, err := userService.SearchUsers("ROLE_", models.RoleAuditor, 0, DefaultUserSearchLimit)
+		assert.NoError(t, err)
+		assert.Len(t, found, 1)
+		assert.Equal(t, userID, int(found[0].ID))
+		_, err = userService.SearchUsers("", "", 0, DefaultUserSearchLimit+1)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	})
+
+	t.Run("SetRole method to record who changed the role and refuse roles above the actor", func(t *testing.T) {
+		adminID, _ := userService.CreateUser(&models.User{EmailID: "role_audit_admin@example.com", Password: "password123", Role: models.RoleAdmin})
+		supportID, _ := userService.CreateUser(&models.User{EmailID: "role_audit_support@example.com", Password: "password123", Role: models.RoleSupport})
+		userID, _ := userService.CreateUser(&models.User{EmailID: "role_audit_user@example.com", Password: "password123"})
+
+		_, err := userService.SetRole(supportID, userID, models.RoleAuditor)
+		assert.NoError(t, err)
+		_, err = userService.SetRole(supportID, userID, models.RoleAdmin)
+		assert.ErrorIs(t, err, ErrOutranked)
+		_, err = userService.SetRole(supportID, adminID, models.RoleCustomer)
+		assert.ErrorIs(t, err, ErrOutranked)
+		_, err = userService.SetRole(adminID, 1<<30, models.RoleSupport)
+		assert.ErrorIs(t, err, ErrUserNotFound)
+
+		changes, err := db.ListRoleChanges(userID)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 1)
+		assert.Equal(t, supportID, changes[0].ActorID)
+		assert.Equal(t, models.RoleCustomer, changes[0].FromRole)
+		assert.Equal(t, models.RoleAuditor, changes[0].ToRole)
+
+		assert.NoError(t, userService.SetRoleByEmail("role_audit_user@example.com", models.RoleCustomer))
+		changes, err = db.ListRoleChanges(userID)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+		assert.Zero(t, changes[1].ActorID)
+		assert.Equal(t, models.RoleAuditor, changes[1].FromRole)
+	})
+
+	t.Run("FreezeUser method to block sign-in and money movement until UnfreezeUser", func(t *testing.T) {
+		authService := NewAuthService(db, passwords, tokenOptions)
+		walletService := &WalletService{store: db, rates: DefaultStaticRateProvider(), historyMaxLimit: DefaultHistoryMaxLimit}
+		inr := money.Money{Amount: decimal.NewFromInt(10), Currency: money.INR}
+		supportID, _ := userService.CreateUser(&models.User{EmailID: "freeze_support@example.com", Password: "password123", Role: models.RoleSupport})
+		userID, _ := userService.CreateUser(&models.User{EmailID: "freeze_user@example.com", Password: "password123"})
+		friendID, _ := userService.CreateUser(&models.User{EmailID: "freeze_friend@example.com", Password: "password123"})
+		wallet, _ := walletService.CreateWallet(userID, money.INR)
+		_, err := walletService.AddMoneyToWallet(userID, wallet.ID, inr)
+		assert.NoError(t, err)
+		friendWallet, _ := walletService.CreateWallet(friendID, money.INR)
+		_, err = walletService.AddMoneyToWallet(friendID, friendWallet.ID, inr)
+		assert.NoError(t, err)
+		hold, err := walletService.AuthorizeHold(userID, wallet.ID, money.Money{Amount: decimal.NewFromInt(5), Currency: money.INR}, "", 0)
+		assert.NoError(t, err)
+		assert.NoError(t, walletService.TransferMoney(friendID, friendWallet.ID, "freeze_user@example.com", 0, money.Money{Amount: decimal.NewFromInt(5), Currency: money.INR}))
+		postings, err := db.GetLastNPostings(userID, 1)
+		assert.NoError(t, err)
+		transferID := postings[0].JournalEntryID
+
+		_, err = userService.FreezeUser(supportID, userID, "")
+		assert.ErrorIs(t, err, ErrReasonRequired)
+		user, err := userService.FreezeUser(supportID, userID, "suspected account takeover")
+		assert.NoError(t, err)
+		assert.True(t, user.IsFrozen())
+		assert.Equal(t, supportID, user.FrozenBy)
+		_, err = userService.FreezeUser(supportID, userID, "again")
+		assert.ErrorIs(t, err, ErrAlreadyFrozen)
+
+		_, err = authService.AuthenticateUser("freeze_user@example.com", "password123")
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, inr)
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		err = walletService.TransferMoney(friendID, friendWallet.ID, "freeze_user@example.com", 0, inr)
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		_, err = walletService.CaptureHold(userID, hold.ID, nil)
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		_, err = walletService.RefundTransfer(userID, transferID, money.Money{Amount: decimal.NewFromInt(1), Currency: money.INR})
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+		_, err = walletService.ReverseTransfer(userID, transferID)
+		assert.ErrorIs(t, err, ErrAccountFrozen)
+
+		user, err = userService.UnfreezeUser(supportID, userID)
+		assert.NoError(t, err)
+		assert.False(t, user.IsFrozen())
+		assert.Empty(t, user.FrozenReason)
+		_, err = userService.UnfreezeUser(supportID, userID)
+		assert.ErrorIs(t, err, ErrNotFrozen)
+		_, err = userService.FreezeUser(supportID, supportID, "testing")
+		assert.ErrorIs(t, err, ErrSelfAdmin)
+		adminID, _ := userService.CreateUser(&models.User{EmailID: "freeze_admin@example.com", Password: "password123", Role: models.RoleAdmin})
+		_, err = userService.FreezeUser(supportID, adminID, "testing")
+		assert.ErrorIs(t, err, ErrOutranked)
+		_, err = userService.FreezeUser(adminID, supportID, "testing")
+		assert.NoError(t, err)
+		_, err = userService.UnfreezeUser(adminID, supportID)
+		assert.NoError(t, err)
+
+		_, err = authService.AuthenticateUser("freeze_user@example.com", "password123")
+		assert.NoError(t, err)
+		_, err = walletService.WithdrawMoneyFromWallet(userID, wallet.ID, inr)
+		assert.NoError(t, err)
+		_, err = walletService.CaptureHold(userID, hold.ID, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("FreezeUser and SetRole methods to end every session of the user", func(t *testing.T) {
+		authService := NewAuthService(db, passwords, tokenOptions)
+		adminID, _ := userService.CreateUser(&models.User{EmailID: "revoke_admin@example.com", Password: "password123", Role: models.RoleAdmin})
+		userID, _ := userService.CreateUser(&models.User{EmailID: "revoke_user@example.com", Password: "password123", Role: models.RoleSupport})
+
+		tokens, err := authService.SignIn("revoke_user@example.com", "password123", ClientInfo{})
+		assert.NoError(t, err)
+		_, err = userService.SetRole(adminID, userID, models.RoleAuditor)
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(tokens.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+		_, err = authService.RefreshTokens(tokens.RefreshToken, ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+		tokens, err = authService.SignIn("revoke_user@example.com", "password123", ClientInfo{})
+		assert.NoError(t, err)
+		_, err = userService.FreezeUser(adminID, userID, "left the company")
+		assert.NoError(t, err)
+		_, err = authService.Authenticate(tokens.AccessToken)
+		assert.ErrorIs(t, err, ErrTokenRevoked)
+		_, err = authService.RefreshTokens(tokens.RefreshToken, ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+		sessions, err := authService.ListSessions(userID)
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
 }
@@ -1,157 +1,525 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"nikwallet/repository"
 	"nikwallet/repository/models"
 	"nikwallet/repository/money"
+	"strings"
 	"time"
 
-	"gorm.io/gorm"
+	"github.com/shopspring/decimal"
 )
 
+// DefaultHistoryMaxLimit caps how many postings a single history request can
+// ask for.
+const DefaultHistoryMaxLimit = 100
+
 type WalletService struct {
-	db *gorm.DB
+	store           repository.Store
+	rates           ExchangeRateProvider
+	historyMaxLimit int
+	limits          LimitPolicy
 }
 
-func NewWalletService(db *gorm.DB) *WalletService {
-	return &WalletService{db: db}
+func NewWalletService(store repository.Store, rates ExchangeRateProvider, historyMaxLimit int, limits LimitPolicy) *WalletService {
+	if historyMaxLimit <= 0 {
+		historyMaxLimit = DefaultHistoryMaxLimit
+	}
+	return &WalletService{store: store, rates: rates, historyMaxLimit: historyMaxLimit, limits: limits}
+}
+
+// In returns the service bound to the transaction IdempotencyService.Run
+// put in ctx, or the service itself when ctx carries none.
+func (ws *WalletService) In(ctx context.Context) *WalletService {
+	tx, ok := ctx.Value(transactionContextKey{}).(repository.Store)
+	if !ok {
+		return ws
+	}
+	scoped := *ws
+	scoped.store = tx
+	return &scoped
 }
 
+var (
+	ErrWalletNotFound    = errors.New("wallet not found")
+	ErrWalletExists      = errors.New("a wallet with this currency and name already exists")
+	ErrSameWallet        = errors.New("cannot transfer money to the same wallet")
+	ErrInvalidLimit      = errors.New("invalid limit")
+	ErrInvalidWalletName = errors.New("invalid wallet name")
+)
+
+const maxWalletNameLength = 64
+
+// CreateWallet opens the main wallet of the user for currency. The first
+// wallet a user opens becomes their default wallet.
 func (ws *WalletService) CreateWallet(userID int, currency money.Currency) (*models.Wallet, error) {
-	db := repository.PostgreSQL{DB: ws.db}
-	_, err := db.GetUserByID(userID)
+	return ws.createWallet(userID, currency, "")
+}
+
+// CreatePocket opens a named wallet next to the main wallet for currency.
+func (ws *WalletService) CreatePocket(userID int, currency money.Currency, name string) (*models.Wallet, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: pocket name cannot be empty", ErrInvalidWalletName)
+	}
+	if len(name) > maxWalletNameLength {
+		return nil, fmt.Errorf("%w: pocket name cannot be longer than %d characters", ErrInvalidWalletName, maxWalletNameLength)
+	}
+	return ws.createWallet(userID, currency, name)
+}
+
+func (ws *WalletService) createWallet(userID int, currency money.Currency, name string) (*models.Wallet, error) {
+	_, err := ws.store.GetUserByID(userID)
 	if err != nil {
 		return nil, err
 	}
-	initialZeroMoney, _ := money.NewMoney(money.ZeroAmountValue, currency)
-	newWallet := &models.Wallet{
-		UserID:    userID,
-		Money:     initialZeroMoney,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	initialZeroMoney, err := money.NewMoney(money.ZeroAmountValue, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var createdWallet *models.Wallet
+	err = ws.store.Transaction(func(tx repository.Store) error {
+		wallets, err := tx.ListWalletsByUserID(userID)
+		if err != nil {
+			return err
+		}
+
+		createdWallet, err = tx.CreateWallet(&models.Wallet{
+			UserID:    userID,
+			Name:      name,
+			IsDefault: len(wallets) == 0,
+			Money:     initialZeroMoney,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		return err
+	})
+	if errors.Is(err, repository.ErrWalletExists) {
+		return nil, ErrWalletExists
+	}
+	if err != nil {
+		return nil, err
 	}
-	return db.CreateWallet(newWallet)
+
+	return createdWallet, nil
 }
 
+// GetWalletByUserID returns the default wallet of the user.
 func (ws *WalletService) GetWalletByUserID(userID int) (*models.Wallet, error) {
-	db := repository.PostgreSQL{DB: ws.db}
-	return db.GetWalletByUserID(userID)
+	return ws.store.GetWalletByUserID(userID)
+}
+
+// GetWallet returns the wallet walletID of the user, or the default wallet
+// when walletID is 0.
+func (ws *WalletService) GetWallet(userID int, walletID int) (*models.Wallet, error) {
+	return resolveWallet(ws.store, userID, walletID)
+}
+
+func (ws *WalletService) ListWallets(userID int) ([]*models.Wallet, error) {
+	return ws.store.ListWalletsByUserID(userID)
 }
 
-func (ws *WalletService) AddMoneyToWallet(userID int, moneyToAdd money.Money) (*models.Wallet, error) {
-	db := repository.PostgreSQL{DB: ws.db}
+func (ws *WalletService) SetDefaultWallet(userID int, walletID int) error {
+	if _, err := resolveWallet(ws.store, userID, walletID); err != nil {
+		return err
+	}
+	return ws.store.SetDefaultWallet(userID, walletID)
+}
 
-	wallet, err := db.GetWalletByUserID(userID)
+func (ws *WalletService) AddMoneyToWallet(userID int, walletID int, moneyToAdd money.Money) (*models.Wallet, error) {
+	if err := moneyToAdd.Validate(); err != nil {
+		return nil, err
+	}
+
+	wallet, err := resolveWallet(ws.store, userID, walletID)
 	if err != nil {
 		return nil, err
 	}
 
-	newMoney, err := wallet.Money.Add(&moneyToAdd)
+	rate, err := ws.conversionRate(moneyToAdd.Currency, wallet.Money.Currency)
 	if err != nil {
 		return nil, err
 	}
 
-	wallet.Money = newMoney
+	var updatedWallet *models.Wallet
+	err = ws.store.Transaction(func(tx repository.Store) error {
+		lockedWallets, err := tx.LockWalletsForUpdate(wallet.ID)
+		if err != nil {
+			return err
+		}
+		lockedWallet := lockedWallets[wallet.ID]
+		if err := tx.LockUsersForUpdate(userID); err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(tx, userID); err != nil {
+			return err
+		}
+		if err := ws.checkBalanceCap(tx, userID, moneyToAdd); err != nil {
+			return err
+		}
 
-	updatedWallet, err := db.UpdateWallet(wallet)
+		postings, err := creditWallet(tx, lockedWallet, moneyToAdd, rate)
+		if err != nil {
+			return err
+		}
+
+		funding := models.NewSystemPosting(models.SystemAccountExternalFunding, models.PostingDirectionDebit, &moneyToAdd)
+		_, err = createJournalEntry(tx, models.TransactionTypeAdd, append(postings, funding))
+		if err != nil {
+			return err
+		}
+
+		updatedWallet = lockedWallet
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to add money")
+		return nil, err
 	}
 
-	ledgerEntry := &models.Ledger{
-		SenderUserID:    userID,
-		ReceiverUserID:  userID,
-		Amount:          &moneyToAdd,
-		TransactionType: string(models.TransactionTypeAdd),
-		CreatedAt:       time.Now(),
+	return updatedWallet, nil
+}
+
+func (ws *WalletService) WithdrawMoneyFromWallet(userID int, walletID int, moneyToWithdraw money.Money) (money.Money, error) {
+	if err := moneyToWithdraw.Validate(); err != nil {
+		return money.Money{}, err
 	}
 
-	err = db.CreateLedgerEntry(ledgerEntry)
+	err := ws.store.Transaction(func(tx repository.Store) error {
+		wallet, err := lockWallet(tx, userID, walletID)
+		if err != nil {
+			return err
+		}
+		if err := tx.LockUsersForUpdate(userID); err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(tx, userID); err != nil {
+			return err
+		}
+		if err := ws.checkLimits(tx, userID, models.TransactionTypeWithdraw, moneyToWithdraw); err != nil {
+			return err
+		}
+
+		postings, err := debitWallet(tx, wallet, moneyToWithdraw)
+		if err != nil {
+			return err
+		}
+
+		payout := models.NewSystemPosting(models.SystemAccountExternalPayout, models.PostingDirectionCredit, &moneyToWithdraw)
+		_, err = createJournalEntry(tx, models.TransactionTypeWithdraw, append(postings, payout))
+		return err
+	})
 	if err != nil {
-		return updatedWallet, fmt.Errorf("failed to create ledger entry")
+		return money.Money{}, err
 	}
-	return updatedWallet, nil
+
+	return moneyToWithdraw, nil
+}
+
+// TransferMoney moves money from a wallet of the sender to a wallet of the
+// recipient. A zero senderWalletID uses the sender's default wallet; a zero
+// recipientWalletID picks the recipient wallet by currency.
+func (ws *WalletService) TransferMoney(senderUserID int, senderWalletID int, recipientEmail string, recipientWalletID int, moneyToTransfer money.Money) error {
+	return ws.TransferMoneyWithQuote(senderUserID, senderWalletID, recipientEmail, recipientWalletID, "", moneyToTransfer)
 }
 
-func (ws *WalletService) WithdrawMoneyFromWallet(userID int, moneyToWithdraw money.Money) (money.Money, error) {
-	db := repository.PostgreSQL{DB: ws.db}
-	wallet, err := db.GetWalletByUserID(userID)
+// TransferMoneyWithQuote works like TransferMoney, but converts into the
+// recipient wallet currency at the rate locked by the sender's quote quoteID
+// and uses the quote up. An empty quoteID converts at the live rate.
+func (ws *WalletService) TransferMoneyWithQuote(senderUserID int, senderWalletID int, recipientEmail string, recipientWalletID int, quoteID string, moneyToTransfer money.Money) error {
+	if err := moneyToTransfer.Validate(); err != nil {
+		return err
+	}
+
+	recipient, err := ws.store.GetUserByEmail(recipientEmail)
 	if err != nil {
-		return money.Money{}, err
+		return fmt.Errorf("%w: %s", ErrUserNotFound, recipientEmail)
 	}
 
-	remainedMoney, err := wallet.Money.Subtract(&moneyToWithdraw)
+	senderWallet, err := resolveWallet(ws.store, senderUserID, senderWalletID)
 	if err != nil {
-		return money.Money{}, err
+		return err
 	}
 
-	wallet.Money = remainedMoney
-	_, err = db.UpdateWallet(wallet)
+	var recipientWallet *models.Wallet
+	if recipientWalletID != 0 {
+		recipientWallet, err = resolveWallet(ws.store, int(recipient.ID), recipientWalletID)
+	} else {
+		recipientWallet, err = routeWallet(ws.store, int(recipient.ID), moneyToTransfer.Currency)
+	}
 	if err != nil {
-		return money.Money{}, fmt.Errorf("failed to withdraw money")
+		return err
 	}
 
-	ledgerEntry := &models.Ledger{
-		SenderUserID:    userID,
-		ReceiverUserID:  userID,
-		Amount:          &moneyToWithdraw,
-		TransactionType: string(models.TransactionTypeWithdraw),
-		CreatedAt:       time.Now(),
+	if senderWallet.ID == recipientWallet.ID {
+		return ErrSameWallet
 	}
 
-	err = db.CreateLedgerEntry(ledgerEntry)
+	var rate *ExchangeRate
+	if quoteID != "" {
+		rate, err = quotedRate(ws.store, senderUserID, quoteID, moneyToTransfer.Currency, recipientWallet.Money.Currency)
+	} else {
+		rate, err = ws.conversionRate(moneyToTransfer.Currency, recipientWallet.Money.Currency)
+	}
 	if err != nil {
-		return moneyToWithdraw, fmt.Errorf("failed to create ledger entry")
+		return err
 	}
 
-	return moneyToWithdraw, nil
+	return ws.store.Transaction(func(tx repository.Store) error {
+		if quoteID != "" {
+			used, err := tx.MarkFXQuoteUsed(quoteID, time.Now())
+			if err != nil {
+				return err
+			}
+			if !used {
+				return ErrFXQuoteExpired
+			}
+		}
+
+		lockedWallets, err := tx.LockWalletsForUpdate(senderWallet.ID, recipientWallet.ID)
+		if err != nil {
+			return err
+		}
+		if err := tx.LockUsersForUpdate(senderUserID, recipientWallet.UserID); err != nil {
+			return err
+		}
+
+		if err := checkNotFrozen(tx, senderUserID); err != nil {
+			return err
+		}
+
+		// Moving money between the sender's own wallets is not limited.
+		if recipientWallet.UserID != senderUserID {
+			if err := checkNotFrozen(tx, recipientWallet.UserID); errors.Is(err, ErrAccountFrozen) {
+				return fmt.Errorf("%w: the recipient cannot receive money", ErrAccountFrozen)
+			} else if err != nil {
+				return err
+			}
+			if err := ws.checkLimits(tx, senderUserID, models.TransactionTypeTransfer, moneyToTransfer); err != nil {
+				return err
+			}
+			// The sender is not told the balance or tier of the recipient.
+			if err := ws.checkBalanceCap(tx, recipientWallet.UserID, moneyToTransfer); errors.Is(err, ErrBalanceCapExceeded) {
+				return fmt.Errorf("%w: the recipient cannot receive this amount", ErrBalanceCapExceeded)
+			} else if err != nil {
+				return err
+			}
+		}
+
+		debits, err := debitWallet(tx, lockedWallets[senderWallet.ID], moneyToTransfer)
+		if err != nil {
+			return err
+		}
+
+		credits, err := creditWallet(tx, lockedWallets[recipientWallet.ID], moneyToTransfer, rate)
+		if err != nil {
+			return err
+		}
+
+		for _, posting := range debits {
+			posting.CounterpartyUserID = recipientWallet.UserID
+		}
+		for _, posting := range credits {
+			if posting.AccountType == models.AccountTypeWallet {
+				posting.CounterpartyUserID = senderWallet.UserID
+			}
+		}
+
+		_, err = createJournalEntry(tx, models.TransactionTypeTransfer, append(debits, credits...))
+		return err
+	})
 }
 
-func (ws *WalletService) TransferMoney(senderUserID int, recipientEmail string, moneyToTransfer money.Money) error {
-	db := repository.PostgreSQL{DB: ws.db}
-	walletService := &WalletService{
-		db: db.DB,
+// GetLastNPostings returns the latest postings across all wallets of the
+// user, or of a single wallet when walletID is not 0.
+func (ws *WalletService) GetLastNPostings(userID, walletID, limit int) ([]*models.Posting, error) {
+	if limit < 1 || limit > ws.historyMaxLimit {
+		return nil, fmt.Errorf("%w: must be between 1 and %d, got %d", ErrInvalidLimit, ws.historyMaxLimit, limit)
 	}
-	recipient, err := db.GetUserByEmail(recipientEmail)
+
+	if walletID == 0 {
+		return ws.store.GetLastNPostings(userID, limit)
+	}
+
+	wallet, err := resolveWallet(ws.store, userID, walletID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return ws.store.GetLastNWalletPostings(wallet.ID, limit)
+}
 
-	recipientWallet, err := db.GetWalletByUserID(int(recipient.ID))
+// checkNotFrozen fails with ErrAccountFrozen when the user is frozen.
+func checkNotFrozen(tx repository.Store, userID int) error {
+	user, err := tx.GetUserByID(userID)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %d", ErrUserNotFound, userID)
+	}
+	if user.IsFrozen() {
+		return fmt.Errorf("%w: user %d", ErrAccountFrozen, userID)
+	}
+	return nil
+}
+
+// resolveWallet returns the wallet walletID if it belongs to userID, or the
+// default wallet of userID when walletID is 0.
+func resolveWallet(store repository.Store, userID int, walletID int) (*models.Wallet, error) {
+	if walletID == 0 {
+		return defaultWallet(store, userID)
+	}
+
+	wallet, err := store.GetWalletByID(walletID)
+	if err != nil || wallet.UserID != userID {
+		return nil, fmt.Errorf("%w: %d", ErrWalletNotFound, walletID)
 	}
+	return wallet, nil
+}
 
-	amountDeducted, err := walletService.WithdrawMoneyFromWallet(senderUserID, moneyToTransfer)
+func lockWallet(tx repository.Store, userID int, walletID int) (*models.Wallet, error) {
+	wallet, err := resolveWallet(tx, userID, walletID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = walletService.AddMoneyToWallet(recipientWallet.UserID, amountDeducted)
+	lockedWallets, err := tx.LockWalletsForUpdate(wallet.ID)
 	if err != nil {
-		_, _ = walletService.AddMoneyToWallet(senderUserID, amountDeducted)
-		return err
+		return nil, err
 	}
+	return lockedWallets[wallet.ID], nil
+}
 
-	ledgerEntry := &models.Ledger{
-		SenderUserID:    senderUserID,
-		ReceiverUserID:  int(recipient.ID),
-		Amount:          &moneyToTransfer,
-		TransactionType: string(models.TransactionTypeTransfer),
-		CreatedAt:       time.Now(),
+// routeWallet picks the wallet of userID that should receive money in
+// currency: the default wallet if it holds that currency, then the main
+// wallet for that currency, and otherwise the default wallet.
+func routeWallet(store repository.Store, userID int, currency money.Currency) (*models.Wallet, error) {
+	wallets, err := store.ListWalletsByUserID(userID)
+	if err != nil {
+		return nil, err
 	}
 
-	err = db.CreateLedgerEntry(ledgerEntry)
+	var mainWallet *models.Wallet
+	for _, wallet := range wallets {
+		if wallet.Money.Currency != currency {
+			continue
+		}
+		if wallet.IsDefault {
+			return wallet, nil
+		}
+		if wallet.Name == "" && mainWallet == nil {
+			mainWallet = wallet
+		}
+	}
+	if mainWallet != nil {
+		return mainWallet, nil
+	}
+
+	return defaultWallet(store, userID)
+}
+
+// defaultWallet returns the default wallet of userID, or ErrWalletNotFound
+// when the user has not opened a wallet yet.
+func defaultWallet(store repository.Store, userID int) (*models.Wallet, error) {
+	wallet, err := store.GetWalletByUserID(userID)
 	if err != nil {
-		return fmt.Errorf("failed to create ledger entry")
+		return nil, fmt.Errorf("%w: user %d has no wallet", ErrWalletNotFound, userID)
 	}
+	return wallet, nil
+}
 
-	return nil
+// conversionRate returns the live rate from one currency to another, or nil
+// when no conversion is needed.
+func (ws *WalletService) conversionRate(from, to money.Currency) (*ExchangeRate, error) {
+	if from == to {
+		return nil, nil
+	}
+	return ws.rates.Rate(from, to)
 }
 
+// creditWallet adds moneyToAdd to the wallet balance, converting it into the
+// wallet currency at rate if needed, and returns postings that balance
+// against a debit of moneyToAdd in its original currency.
+func creditWallet(tx repository.Store, wallet *models.Wallet, moneyToAdd money.Money, rate *ExchangeRate) ([]*models.Posting, error) {
+	credited := &moneyToAdd
+	if moneyToAdd.Currency != wallet.Money.Currency {
+		if rate == nil || rate.From != moneyToAdd.Currency || rate.To != wallet.Money.Currency {
+			return nil, fmt.Errorf("%w: %s to %s", ErrExchangeRateUnavailable, moneyToAdd.Currency, wallet.Money.Currency)
+		}
+		converted, err := moneyToAdd.Convert(rate.To, rate.Rate)
+		if err != nil {
+			return nil, err
+		}
+		credited = converted
+	}
+
+	newMoney, err := wallet.Money.Add(credited)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet.Money = newMoney
+
+	_, err = tx.UpdateWallet(wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add money: %w", err)
+	}
+
+	credit := models.NewWalletPosting(wallet, models.PostingDirectionCredit, credited)
+	credit.BalanceAfter = decimal.NewNullDecimal(newMoney.Amount)
+	postings := []*models.Posting{credit}
+	if credited.Currency != moneyToAdd.Currency {
+		postings = append(postings,
+			models.NewSystemPosting(models.SystemAccountFXClearing, models.PostingDirectionDebit, credited),
+			models.NewSystemPosting(models.SystemAccountFXClearing, models.PostingDirectionCredit, &moneyToAdd),
+		)
+		for _, posting := range postings {
+			posting.FXRate = decimal.NewNullDecimal(rate.Rate)
+			posting.FXRateSource = rate.Source
+		}
+	}
+
+	return postings, nil
+}
+
+// debitWallet subtracts moneyToWithdraw from the wallet balance and returns
+// the posting that records the debit. Money reserved by active holds cannot
+// be debited.
+func debitWallet(tx repository.Store, wallet *models.Wallet, moneyToWithdraw money.Money) ([]*models.Posting, error) {
+	if _, err := wallet.AvailableBalance().Subtract(&moneyToWithdraw); err != nil {
+		return nil, err
+	}
+
+	remainedMoney, err := wallet.Money.Subtract(&moneyToWithdraw)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet.Money = remainedMoney
+
+	_, err = tx.UpdateWallet(wallet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to withdraw money: %w", err)
+	}
+
+	debit := models.NewWalletPosting(wallet, models.PostingDirectionDebit, &moneyToWithdraw)
+	debit.BalanceAfter = decimal.NewNullDecimal(remainedMoney.Amount)
+	return []*models.Posting{debit}, nil
+}
+
+func createJournalEntry(tx repository.Store, transactionType models.TransactionType, postings []*models.Posting) (*models.JournalEntry, error) {
+	entry := &models.JournalEntry{
+		TransactionType: string(transactionType),
+		Postings:        postings,
+		CreatedAt:       time.Now(),
+	}
+
+	err := tx.CreateJournalEntry(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ledger entry: %w", err)
+	}
 
-func (ws *WalletService) GetLastNLedgerEntries(userID, limit int) ([]*models.Ledger, error) {
-	db := repository.PostgreSQL{DB: ws.db}
-	return db.GetLastNLedgerEntries(userID, limit)
+	return entry, nil
 }
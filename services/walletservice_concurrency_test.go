@@ -0,0 +1,195 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"nikwallet/repository"
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWalletServiceConcurrency proves the row locks of the Postgres store.
+// The memory store runs one transaction at a time, so it would pass there
+// without proving anything.
+func TestWalletServiceConcurrency(t *testing.T) {
+	if _, ok := db.(*repository.PostgreSQL); !ok {
+		t.Skip("set NIKWALLET_TEST_STORE=postgres to run against a real database")
+	}
+	walletService := &WalletService{
+		store: db,
+		rates: DefaultStaticRateProvider(),
+	}
+
+	createFundedUser := func(t *testing.T, email string, currency money.Currency, amount float64) int {
+		userID, err := db.CreateUser(&models.User{EmailID: email, Password: "test123"})
+		assert.NoError(t, err)
+
+		_, err = walletService.CreateWallet(userID, currency)
+		assert.NoError(t, err)
+
+		if amount > 0 {
+			initialMoney, _ := money.NewMoney(decimal.NewFromFloat(amount), currency)
+			_, err = walletService.AddMoneyToWallet(userID, 0, *initialMoney)
+			assert.NoError(t, err)
+		}
+
+		return userID
+	}
+
+	t.Run("Concurrent AddMoneyToWallet calls to not lose any update", func(t *testing.T) {
+		userID := createFundedUser(t, "concurrent_add@example.com", money.INR, 0)
+
+		workers := 50
+		deposit, _ := money.NewMoney(decimal.NewFromFloat(10.0), money.INR)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := walletService.AddMoneyToWallet(userID, 0, *deposit)
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		wallet, err := db.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, wallet.Money.Amount.Equal(decimal.NewFromFloat(500.0)), "got %s, want 500", wallet.Money.Amount)
+
+		postings, err := db.GetLastNPostings(userID, workers*2)
+		assert.NoError(t, err)
+		assert.Len(t, postings, workers)
+
+		derivedBalance, err := db.GetWalletBalanceFromPostings(wallet.ID)
+		assert.NoError(t, err)
+		assert.True(t, derivedBalance.Equal(wallet.Money.Amount))
+	})
+
+	t.Run("Concurrent WithdrawMoneyFromWallet calls to never overdraw the wallet", func(t *testing.T) {
+		userID := createFundedUser(t, "concurrent_withdraw@example.com", money.INR, 100.0)
+
+		workers := 30
+		withdrawal, _ := money.NewMoney(decimal.NewFromFloat(10.0), money.INR)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded := 0
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := walletService.WithdrawMoneyFromWallet(userID, 0, *withdrawal); err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, succeeded)
+
+		wallet, err := db.GetWalletByUserID(userID)
+		assert.NoError(t, err)
+		assert.True(t, wallet.Money.Amount.IsZero(), "got %s, want 0", wallet.Money.Amount)
+
+		postings, err := db.GetLastNPostings(userID, workers*2)
+		assert.NoError(t, err)
+		assert.Len(t, postings, 1+succeeded)
+
+		derivedBalance, err := db.GetWalletBalanceFromPostings(wallet.ID)
+		assert.NoError(t, err)
+		assert.True(t, derivedBalance.IsZero())
+	})
+
+	t.Run("Concurrent opposite TransferMoney calls to neither deadlock nor lose money", func(t *testing.T) {
+		aliceEmail := "concurrent_alice@example.com"
+		bobEmail := "concurrent_bob@example.com"
+		aliceID := createFundedUser(t, aliceEmail, money.EUR, 500.0)
+		bobID := createFundedUser(t, bobEmail, money.EUR, 500.0)
+
+		workers := 40
+		amount, _ := money.NewMoney(decimal.NewFromFloat(5.0), money.EUR)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				var err error
+				if i%2 == 0 {
+					err = walletService.TransferMoney(aliceID, 0, bobEmail, 0, *amount)
+				} else {
+					err = walletService.TransferMoney(bobID, 0, aliceEmail, 0, *amount)
+				}
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			assert.NoError(t, err)
+		}
+
+		aliceWallet, _ := db.GetWalletByUserID(aliceID)
+		bobWallet, _ := db.GetWalletByUserID(bobID)
+		total := aliceWallet.Money.Amount.Add(bobWallet.Money.Amount)
+		assert.True(t, total.Equal(decimal.NewFromFloat(1000.0)), "got %s, want 1000", total)
+		assert.True(t, aliceWallet.Money.Amount.Equal(decimal.NewFromFloat(500.0)), "got %s, want 500", aliceWallet.Money.Amount)
+	})
+
+	t.Run("Concurrent TransferMoney calls from one sender to never overdraw the wallet", func(t *testing.T) {
+		senderID := createFundedUser(t, "concurrent_sender@example.com", money.INR, 50.0)
+
+		recipients := 20
+		amount, _ := money.NewMoney(decimal.NewFromFloat(5.0), money.INR)
+		recipientEmails := make([]string, recipients)
+		for i := range recipientEmails {
+			recipientEmails[i] = fmt.Sprintf("concurrent_recipient%d@example.com", i)
+			createFundedUser(t, recipientEmails[i], money.INR, 0)
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded := 0
+		for _, email := range recipientEmails {
+			wg.Add(1)
+			go func(email string) {
+				defer wg.Done()
+				if err := walletService.TransferMoney(senderID, 0, email, 0, *amount); err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}(email)
+		}
+		wg.Wait()
+
+		assert.Equal(t, 10, succeeded)
+
+		senderWallet, _ := db.GetWalletByUserID(senderID)
+		assert.True(t, senderWallet.Money.Amount.IsZero(), "got %s, want 0", senderWallet.Money.Amount)
+
+		credited := decimal.Zero
+		for _, email := range recipientEmails {
+			recipient, _ := db.GetUserByEmail(email)
+			wallet, _ := db.GetWalletByUserID(int(recipient.ID))
+			credited = credited.Add(wallet.Money.Amount)
+		}
+		assert.True(t, credited.Equal(decimal.NewFromFloat(50.0)), "got %s, want 50", credited)
+	})
+}
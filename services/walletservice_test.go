@@ -12,7 +12,8 @@ import (
 
 func TestWalletService(t *testing.T) {
 	walletService := &WalletService{
-		db: db.DB,
+		store: db,
+		rates: DefaultStaticRateProvider(),
 	}
 	t.Run("CreateWallet method to create a valid wallet for successful user creation", func(t *testing.T) {
 		newUser := &models.User{
@@ -74,19 +75,26 @@ func TestWalletService(t *testing.T) {
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.USD)
 
-		_, err := walletService.AddMoneyToWallet(newUserID, *initialMoney)
+		_, err := walletService.AddMoneyToWallet(newUserID, 0, *initialMoney)
 		assert.NoError(t, err)
 
 		updatedWallet, _ := db.GetWalletByUserID(newUserID)
 		assert.True(t, updatedWallet.Money.Equals(*initialMoney))
 
-		ledgerEntry, err := db.GetLatestLedgerEntry(newUserID)
+		journalEntry, err := db.GetLatestJournalEntry(newUserID)
 		assert.NoError(t, err)
 
-		assert.Equal(t, newUserID, ledgerEntry.SenderUserID)
-		assert.Equal(t, newUserID, ledgerEntry.ReceiverUserID)
-		assert.True(t, ledgerEntry.Amount.Equals(*initialMoney))
-		assert.Equal(t, string(models.TransactionTypeAdd), ledgerEntry.TransactionType)
+		assert.Equal(t, string(models.TransactionTypeAdd), journalEntry.TransactionType)
+		assert.Len(t, journalEntry.Postings, 2)
+		walletPosting := journalEntry.PostingForUser(newUserID)
+		assert.Equal(t, models.PostingDirectionCredit, walletPosting.Direction)
+		assert.True(t, walletPosting.Amount.Equals(*initialMoney))
+		for _, posting := range journalEntry.Postings {
+			if posting.AccountType == models.AccountTypeSystem {
+				assert.Equal(t, models.SystemAccountExternalFunding, posting.SystemAccount)
+				assert.Equal(t, models.PostingDirectionDebit, posting.Direction)
+			}
+		}
 	})
 
 	t.Run("AddMoneyToWallet method to add money to non empty wallet", func(t *testing.T) {
@@ -99,11 +107,11 @@ func TestWalletService(t *testing.T) {
 		_, _ = walletService.CreateWallet(newUserID, money.EUR)
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.EUR)
-		_, err := walletService.AddMoneyToWallet(newUserID, *initialMoney)
+		_, err := walletService.AddMoneyToWallet(newUserID, 0, *initialMoney)
 		assert.Nil(t, err, "AddMoneyToWallet should not return an error")
 
 		additionalMoney, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.EUR)
-		_, err = walletService.AddMoneyToWallet(newUserID, *additionalMoney)
+		_, err = walletService.AddMoneyToWallet(newUserID, 0, *additionalMoney)
 		assert.Nil(t, err, "AddMoneyToWallet should not return an error")
 
 		updatedWallet, _ := db.GetWalletByUserID(newUserID)
@@ -111,11 +119,13 @@ func TestWalletService(t *testing.T) {
 		expectedMoney, _ := money.NewMoney(decimal.NewFromFloat(150.0), money.EUR)
 		assert.True(t, updatedWallet.Money.Equals(*expectedMoney), "Wallet money should be equal to expected amount")
 
-		latestEntry, _ := db.GetLatestLedgerEntry(newUserID)
-		assert.NotNil(t, latestEntry, "Latest ledger entry should not be nil")
-		assert.Equal(t, newUserID, latestEntry.SenderUserID, "SenderUserID should match the user ID")
-		assert.Equal(t, newUserID, latestEntry.ReceiverUserID, "ReceiverUserID should match the user ID")
+		latestEntry, _ := db.GetLatestJournalEntry(newUserID)
+		assert.NotNil(t, latestEntry, "Latest journal entry should not be nil")
 		assert.Equal(t, string(models.TransactionTypeAdd), latestEntry.TransactionType, "TransactionType should be 'add'")
+		assert.True(t, latestEntry.PostingForUser(newUserID).Amount.Equals(*additionalMoney), "Wallet posting should credit the added amount")
+
+		derivedBalance, _ := db.GetWalletBalanceFromPostings(updatedWallet.ID)
+		assert.True(t, derivedBalance.Equal(expectedMoney.Amount), "Balance derived from postings should match the wallet balance")
 	})
 
 	t.Run("WithdrawMoneyFromWallet method to successfully return withdrawn money for valid input", func(t *testing.T) {
@@ -129,12 +139,12 @@ func TestWalletService(t *testing.T) {
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.INR)
 
-		_, err := walletService.AddMoneyToWallet(newUserID, *initialMoney)
+		_, err := walletService.AddMoneyToWallet(newUserID, 0, *initialMoney)
 		assert.Nil(t, err, "AddMoneyToWallet should not return an error")
 
 		withdrawMoney, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.INR)
 
-		withdrawnMoney, err := walletService.WithdrawMoneyFromWallet(newUserID, *withdrawMoney)
+		withdrawnMoney, err := walletService.WithdrawMoneyFromWallet(newUserID, 0, *withdrawMoney)
 		assert.Nil(t, err, "WithdrawMoneyFromWallet should not return an error")
 		assert.True(t, reflect.DeepEqual(withdrawnMoney, *withdrawMoney), "Withdrawn money should be equal to the requested amount")
 
@@ -143,11 +153,13 @@ func TestWalletService(t *testing.T) {
 		expectedMoneyRemained, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.INR)
 		assert.True(t, updatedWallet.Money.Equals(*expectedMoneyRemained), "Wallet money should be equal to the expected amount")
 
-		latestEntry, _ := db.GetLatestLedgerEntry(newUserID)
-		assert.NotNil(t, latestEntry, "Latest ledger entry should not be nil")
-		assert.Equal(t, newUserID, latestEntry.SenderUserID, "SenderUserID should match the user ID")
-		assert.Equal(t, newUserID, latestEntry.ReceiverUserID, "ReceiverUserID should match the user ID")
+		latestEntry, _ := db.GetLatestJournalEntry(newUserID)
+		assert.NotNil(t, latestEntry, "Latest journal entry should not be nil")
 		assert.Equal(t, string(models.TransactionTypeWithdraw), latestEntry.TransactionType, "TransactionType should be 'withdraw'")
+		assert.Equal(t, models.PostingDirectionDebit, latestEntry.PostingForUser(newUserID).Direction, "Wallet posting should be a debit")
+
+		derivedBalance, _ := db.GetWalletBalanceFromPostings(updatedWallet.ID)
+		assert.True(t, derivedBalance.Equal(expectedMoneyRemained.Amount), "Balance derived from postings should match the wallet balance")
 	})
 
 	t.Run("WithdrawMoneyFromWallet to return error for not enough money in wallet", func(t *testing.T) {
@@ -161,13 +173,13 @@ func TestWalletService(t *testing.T) {
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.USD)
 
-		_, err := walletService.AddMoneyToWallet(newUserID, *initialMoney)
+		_, err := walletService.AddMoneyToWallet(newUserID, 0, *initialMoney)
 		assert.Nil(t, err, "AddMoneyToWallet should not return an error")
 
 		withdrawMoney, _ := money.NewMoney(decimal.NewFromFloat(150.0), money.USD)
 
-		_, err = walletService.WithdrawMoneyFromWallet(newUserID, *withdrawMoney)
-		assert.NotNil(t, err, "WithdrawMoneyFromWallet should return an error")
+		_, err = walletService.WithdrawMoneyFromWallet(newUserID, 0, *withdrawMoney)
+		assert.ErrorIs(t, err, money.ErrInsufficientFunds)
 
 		updatedWallet, _ := db.GetWalletByUserID(newUserID)
 		assert.True(t, updatedWallet.Money.Equals(*initialMoney), "Wallet money should remain unchanged")
@@ -189,10 +201,10 @@ func TestWalletService(t *testing.T) {
 		_, _ = walletService.CreateWallet(recipientID, money.EUR)
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.EUR)
-		walletService.AddMoneyToWallet(senderID, *initialMoney)
+		walletService.AddMoneyToWallet(senderID, 0, *initialMoney)
 
 		transferAmount, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.EUR)
-		err := walletService.TransferMoney(senderID, recipient.EmailID, *transferAmount)
+		err := walletService.TransferMoney(senderID, 0, recipient.EmailID, 0, *transferAmount)
 		assert.Nil(t, err, "TransferMoney should not return an error")
 
 		expectedSenderMoney, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.EUR)
@@ -203,19 +215,24 @@ func TestWalletService(t *testing.T) {
 		recipientWallet, _ := db.GetWalletByUserID(recipientID)
 		assert.True(t, recipientWallet.Money.Equals(*expectedRecipientMoney), "Recipient wallet money should be updated")
 
-		senderLedger, _ := db.GetLatestLedgerEntry(senderID)
-		assert.NotNil(t, senderLedger, "Sender ledger entry should exist")
-		assert.Equal(t, senderID, senderLedger.SenderUserID, "SenderUserID in ledger entry should match senderID")
-		assert.Equal(t, recipientID, senderLedger.ReceiverUserID, "ReceiverUserID in ledger entry should match recipientID")
-		assert.True(t, senderLedger.Amount.Equals(*transferAmount), "Amount in sender ledger entry should match transferAmount")
-		assert.Equal(t, string(models.TransactionTypeTransfer), senderLedger.TransactionType, "TransactionType in sender ledger entry should be 'transfer'")
-
-		recipientLedger, _ := db.GetLatestLedgerEntry(recipientID)
-		assert.NotNil(t, recipientLedger, "Recipient ledger entry should exist")
-		assert.Equal(t, senderID, recipientLedger.SenderUserID, "SenderUserID in recipient ledger entry should match senderID")
-		assert.Equal(t, recipientID, recipientLedger.ReceiverUserID, "ReceiverUserID in recipient ledger entry should match recipientID")
-		assert.True(t, recipientLedger.Amount.Equals(*transferAmount), "Amount in recipient ledger entry should match transferAmount")
-		assert.Equal(t, string(models.TransactionTypeTransfer), recipientLedger.TransactionType, "TransactionType in recipient ledger entry should be 'transfer'")
+		senderEntry, _ := db.GetLatestJournalEntry(senderID)
+		assert.NotNil(t, senderEntry, "Sender journal entry should exist")
+		assert.Equal(t, string(models.TransactionTypeTransfer), senderEntry.TransactionType, "TransactionType in sender journal entry should be 'transfer'")
+		assert.Len(t, senderEntry.Postings, 2, "Same currency transfer should post one debit and one credit")
+
+		senderPosting := senderEntry.PostingForUser(senderID)
+		assert.Equal(t, models.PostingDirectionDebit, senderPosting.Direction, "Sender posting should be a debit")
+		assert.Equal(t, recipientID, senderPosting.CounterpartyUserID, "Sender posting counterparty should be the recipient")
+		assert.True(t, senderPosting.Amount.Equals(*transferAmount), "Amount in sender posting should match transferAmount")
+
+		recipientEntry, _ := db.GetLatestJournalEntry(recipientID)
+		assert.NotNil(t, recipientEntry, "Recipient journal entry should exist")
+		assert.Equal(t, senderEntry.ID, recipientEntry.ID, "Both parties should share a single journal entry")
+
+		recipientPosting := recipientEntry.PostingForUser(recipientID)
+		assert.Equal(t, models.PostingDirectionCredit, recipientPosting.Direction, "Recipient posting should be a credit")
+		assert.Equal(t, senderID, recipientPosting.CounterpartyUserID, "Recipient posting counterparty should be the sender")
+		assert.True(t, recipientPosting.Amount.Equals(*transferAmount), "Amount in recipient posting should match transferAmount")
 	})
 
 	t.Run("TransferMoney method to successfully transfer money from sender to receiver having different currencies", func(t *testing.T) {
@@ -234,32 +251,33 @@ func TestWalletService(t *testing.T) {
 		_, _ = walletService.CreateWallet(recipientID, money.USD)
 
 		initialMoney, _ := money.NewMoney(decimal.NewFromFloat(100.0), money.EUR)
-		walletService.AddMoneyToWallet(senderID, *initialMoney)
+		walletService.AddMoneyToWallet(senderID, 0, *initialMoney)
 
 		transferAmount, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.EUR)
-		err := walletService.TransferMoney(senderID, recipient.EmailID, *transferAmount)
+		err := walletService.TransferMoney(senderID, 0, recipient.EmailID, 0, *transferAmount)
 		assert.Nil(t, err, "TransferMoney should not return an error")
 
 		expectedSenderMoney, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.EUR)
 		senderWallet, _ := db.GetWalletByUserID(senderID)
 		assert.True(t, senderWallet.Money.Equals(*expectedSenderMoney), "Sender wallet money should be updated")
 
-		expectedRecipientMoney, _ := money.NewMoney(decimal.NewFromFloat(45.83), money.USD)
+		expectedRecipientMoney, _ := money.NewMoney(decimal.NewFromFloat(54.55), money.USD)
 		recipientWallet, _ := db.GetWalletByUserID(recipientID)
 		assert.True(t, recipientWallet.Money.Equals(*expectedRecipientMoney), "Recipient wallet money should be updated")
 
-		senderLedger, _ := db.GetLatestLedgerEntry(senderID)
-		assert.NotNil(t, senderLedger, "Sender ledger entry should exist")
-		assert.Equal(t, senderID, senderLedger.SenderUserID, "SenderUserID in ledger entry should match senderID")
-		assert.Equal(t, recipientID, senderLedger.ReceiverUserID, "ReceiverUserID in ledger entry should match recipientID")
-		assert.True(t, senderLedger.Amount.Equals(*transferAmount), "Amount in sender ledger entry should match transferAmount")
-		assert.Equal(t, string(models.TransactionTypeTransfer), senderLedger.TransactionType, "TransactionType in sender ledger entry should be 'transfer'")
-
-		recipientLedger, _ := db.GetLatestLedgerEntry(recipientID)
-		assert.NotNil(t, recipientLedger, "Recipient ledger entry should exist")
-		assert.Equal(t, senderID, recipientLedger.SenderUserID, "SenderUserID in recipient ledger entry should match senderID")
-		assert.Equal(t, recipientID, recipientLedger.ReceiverUserID, "ReceiverUserID in recipient ledger entry should match recipientID")
-		assert.Equal(t, string(models.TransactionTypeTransfer), recipientLedger.TransactionType, "TransactionType in recipient ledger entry should be 'transfer'")
+		senderEntry, _ := db.GetLatestJournalEntry(senderID)
+		assert.NotNil(t, senderEntry, "Sender journal entry should exist")
+		assert.Equal(t, string(models.TransactionTypeTransfer), senderEntry.TransactionType, "TransactionType in sender journal entry should be 'transfer'")
+		assert.Len(t, senderEntry.Postings, 4, "Cross currency transfer should clear through the FX account")
+		assert.NoError(t, senderEntry.Validate(), "Journal entry should balance per currency")
+		assert.True(t, senderEntry.PostingForUser(senderID).Amount.Equals(*transferAmount), "Amount in sender posting should match transferAmount")
+
+		recipientEntry, _ := db.GetLatestJournalEntry(recipientID)
+		assert.NotNil(t, recipientEntry, "Recipient journal entry should exist")
+		assert.Equal(t, senderEntry.ID, recipientEntry.ID, "Both parties should share a single journal entry")
+		assert.True(t, recipientEntry.PostingForUser(recipientID).Amount.Equals(*expectedRecipientMoney), "Amount in recipient posting should be in the recipient currency")
+		assert.Equal(t, "1.09090909", recipientEntry.PostingForUser(recipientID).FXRate.Decimal.String(), "Recipient posting should record the rate used")
+		assert.Equal(t, "static:default", recipientEntry.PostingForUser(recipientID).FXRateSource, "Recipient posting should record the rate source")
 	})
 
 	t.Run("TransferMoney method should return error for invalid receiver ID", func(t *testing.T) {
@@ -272,7 +290,7 @@ func TestWalletService(t *testing.T) {
 
 		wrongRecipientEmail := "wrong_recipient@example.com"
 		wrongTransferAmount, _ := money.NewMoney(decimal.NewFromFloat(20.0), money.EUR)
-		err := walletService.TransferMoney(senderID, wrongRecipientEmail, *wrongTransferAmount)
+		err := walletService.TransferMoney(senderID, 0, wrongRecipientEmail, 0, *wrongTransferAmount)
 		assert.Error(t, err, "TransferMoney should return an error")
 	})
 
@@ -285,14 +303,14 @@ func TestWalletService(t *testing.T) {
 		_, _ = walletService.CreateWallet(recipientID, money.INR)
 
 		transferAmount, _ := money.NewMoney(decimal.NewFromFloat(50.0), money.INR)
-		err := walletService.TransferMoney(9999, recipient.EmailID, *transferAmount)
+		err := walletService.TransferMoney(9999, 0, recipient.EmailID, 0, *transferAmount)
 
 		assert.Error(t, err, "TransferMoney should return an error")
 
 		recipientWallet, _ := db.GetWalletByUserID(recipientID)
 		assert.Nil(t, recipientWallet, "Recipient wallet should not be affected")
 
-		ledgerEntries, _ := db.GetLatestLedgerEntry(recipientID)
-		assert.Empty(t, ledgerEntries, "No ledger entries should be created")
+		journalEntry, _ := db.GetLatestJournalEntry(recipientID)
+		assert.Empty(t, journalEntry, "No journal entries should be created")
 	})
 }
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"nikwallet/repository/models"
+	"nikwallet/repository/money"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultipleWallets(t *testing.T) {
+	walletService := &WalletService{
+		store:           db,
+		rates:           DefaultStaticRateProvider(),
+		historyMaxLimit: DefaultHistoryMaxLimit,
+	}
+	inr := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.INR}
+	}
+	usd := func(amount float64) money.Money {
+		return money.Money{Amount: decimal.NewFromFloat(amount), Currency: money.USD}
+	}
+
+	ownerID, _ := db.CreateUser(&models.User{EmailID: "wallets_owner@example.com", Password: "password"})
+	otherID, _ := db.CreateUser(&models.User{EmailID: "wallets_other@example.com", Password: "password"})
+
+	inrWallet, err := walletService.CreateWallet(ownerID, money.INR)
+	assert.NoError(t, err)
+	usdWallet, err := walletService.CreateWallet(ownerID, money.USD)
+	assert.NoError(t, err)
+	savings, err := walletService.CreatePocket(ownerID, money.INR, "Savings")
+	assert.NoError(t, err)
+	otherWallet, err := walletService.CreateWallet(otherID, money.INR)
+	assert.NoError(t, err)
+
+	t.Run("CreateWallet method to make only the first wallet the default", func(t *testing.T) {
+		assert.True(t, inrWallet.IsDefault)
+		assert.False(t, usdWallet.IsDefault)
+		assert.False(t, savings.IsDefault)
+		assert.Equal(t, "Savings", savings.Name)
+
+		wallets, err := walletService.ListWallets(ownerID)
+		assert.NoError(t, err)
+		assert.Len(t, wallets, 3)
+	})
+
+	t.Run("CreateWallet and CreatePocket methods to reject a second wallet with the same currency and name", func(t *testing.T) {
+		_, err := walletService.CreateWallet(ownerID, money.INR)
+		assert.ErrorIs(t, err, ErrWalletExists)
+
+		_, err = walletService.CreatePocket(ownerID, money.INR, "Savings")
+		assert.ErrorIs(t, err, ErrWalletExists)
+
+		_, err = walletService.CreatePocket(ownerID, money.INR, "  ")
+		assert.Error(t, err)
+	})
+
+	t.Run("AddMoneyToWallet method to credit the addressed wallet only", func(t *testing.T) {
+		_, err := walletService.AddMoneyToWallet(ownerID, savings.ID, inr(100))
+		assert.NoError(t, err)
+
+		stored, _ := walletService.GetWallet(ownerID, savings.ID)
+		assert.True(t, stored.Money.Equals(inr(100)))
+
+		defaultWallet, _ := walletService.GetWallet(ownerID, 0)
+		assert.Equal(t, inrWallet.ID, defaultWallet.ID)
+		assert.True(t, defaultWallet.Money.Amount.IsZero())
+	})
+
+	t.Run("AddMoneyToWallet method to return error for a wallet of another user", func(t *testing.T) {
+		_, err := walletService.AddMoneyToWallet(ownerID, otherWallet.ID, inr(100))
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+
+	t.Run("SetDefaultWallet method to switch the default wallet", func(t *testing.T) {
+		assert.NoError(t, walletService.SetDefaultWallet(ownerID, usdWallet.ID))
+
+		defaultWallet, err := walletService.GetWalletByUserID(ownerID)
+		assert.NoError(t, err)
+		assert.Equal(t, usdWallet.ID, defaultWallet.ID)
+
+		wallets, _ := walletService.ListWallets(ownerID)
+		defaults := 0
+		for _, wallet := range wallets {
+			if wallet.IsDefault {
+				defaults++
+			}
+		}
+		assert.Equal(t, 1, defaults)
+
+		assert.ErrorIs(t, walletService.SetDefaultWallet(ownerID, otherWallet.ID), ErrWalletNotFound)
+		assert.NoError(t, walletService.SetDefaultWallet(ownerID, inrWallet.ID))
+	})
+
+	t.Run("TransferMoney method to route to the recipient wallet holding the transfer currency", func(t *testing.T) {
+		_, err := walletService.AddMoneyToWallet(otherID, 0, inr(1000))
+		assert.NoError(t, err)
+		otherUSD, err := walletService.CreateWallet(otherID, money.USD)
+		assert.NoError(t, err)
+		_, err = walletService.AddMoneyToWallet(otherID, otherUSD.ID, usd(20))
+		assert.NoError(t, err)
+
+		err = walletService.TransferMoney(otherID, otherUSD.ID, "wallets_owner@example.com", 0, usd(5))
+		assert.NoError(t, err)
+
+		stored, _ := walletService.GetWallet(ownerID, usdWallet.ID)
+		assert.True(t, stored.Money.Equals(usd(5)), "got %v", stored.Money)
+
+		defaultWallet, _ := walletService.GetWallet(ownerID, 0)
+		assert.True(t, defaultWallet.Money.Amount.IsZero())
+	})
+
+	t.Run("TransferMoney method to credit an explicitly targeted recipient wallet", func(t *testing.T) {
+		err := walletService.TransferMoney(otherID, 0, "wallets_owner@example.com", savings.ID, inr(50))
+		assert.NoError(t, err)
+
+		stored, _ := walletService.GetWallet(ownerID, savings.ID)
+		assert.True(t, stored.Money.Equals(inr(150)), "got %v", stored.Money)
+
+		err = walletService.TransferMoney(otherID, 0, "wallets_owner@example.com", otherWallet.ID, inr(50))
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+	})
+
+	t.Run("TransferMoney method to move money between wallets of the same user", func(t *testing.T) {
+		err := walletService.TransferMoney(ownerID, savings.ID, "wallets_owner@example.com", inrWallet.ID, inr(30))
+		assert.NoError(t, err)
+
+		stored, _ := walletService.GetWallet(ownerID, inrWallet.ID)
+		assert.True(t, stored.Money.Equals(inr(30)), "got %v", stored.Money)
+
+		err = walletService.TransferMoney(ownerID, savings.ID, "wallets_owner@example.com", savings.ID, inr(1))
+		assert.ErrorIs(t, err, ErrSameWallet)
+	})
+
+	t.Run("GetLastNPostings method to filter by wallet when one is given", func(t *testing.T) {
+		postings, err := walletService.GetLastNPostings(ownerID, savings.ID, 10)
+		assert.NoError(t, err)
+		assert.Len(t, postings, 3)
+		for _, posting := range postings {
+			assert.Equal(t, savings.ID, posting.WalletID)
+		}
+
+		all, err := walletService.GetLastNPostings(ownerID, 0, 10)
+		assert.NoError(t, err)
+		assert.Greater(t, len(all), len(postings))
+
+		_, err = walletService.GetLastNPostings(ownerID, otherWallet.ID, 10)
+		assert.ErrorIs(t, err, ErrWalletNotFound)
+
+		_, err = walletService.GetLastNPostings(ownerID, 0, DefaultHistoryMaxLimit+1)
+		assert.ErrorIs(t, err, ErrInvalidLimit)
+	})
+}